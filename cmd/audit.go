@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/gosub/gitorum/internal/audit"
+	"github.com/gosub/gitorum/internal/repo"
+)
+
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Show the moderation log built from the forum history",
+	Long: `Show who approved which key, deleted which post, or changed the forum
+settings, newest first. The log is read from the git history: actions are
+recognised by the commit messages gitorum writes, and by the tombstones
+left by deleted posts.
+
+Every action is checked twice: the commit signature against the keys in
+keys/, and the signature of every admin-signed record it wrote (tombstones,
+thread state, bans.toml, roles.toml, category write policies) against the
+admin key. Actions where either check fails are marked with "!".
+
+Action types: ` + actionList() + `.
+
+--since and --until take a date (YYYY-MM-DD) or an RFC 3339 time; a date
+given to --until includes that day.`,
+	Args: cobra.NoArgs,
+	RunE: runAudit,
+}
+
+var (
+	auditRepoPath string
+	auditActor    string
+	auditAction   string
+	auditSince    string
+	auditUntil    string
+	auditLimit    int
+)
+
+func init() {
+	auditCmd.Flags().StringVar(&auditRepoPath, "repo", ".", "path to the forum git repository")
+	auditCmd.Flags().StringVar(&auditActor, "actor", "", "only list actions committed by this user")
+	auditCmd.Flags().StringVar(&auditAction, "action", "", "only list actions of this type")
+	auditCmd.Flags().StringVar(&auditSince, "since", "", "only list actions from this time on")
+	auditCmd.Flags().StringVar(&auditUntil, "until", "", "only list actions before this time")
+	auditCmd.Flags().IntVarP(&auditLimit, "limit", "n", 0, "list at most this many actions (0 for all)")
+
+	rootCmd.AddCommand(auditCmd)
+}
+
+func actionList() string {
+	var names []string
+	for _, a := range audit.Actions {
+		names = append(names, string(a))
+	}
+	return strings.Join(names, ", ")
+}
+
+func runAudit(cmd *cobra.Command, args []string) error {
+	f := audit.Filter{Actor: strings.TrimPrefix(auditActor, "@"), Action: audit.Action(auditAction)}
+	if f.Action != "" && !audit.ValidAction(f.Action) {
+		return fmt.Errorf("unknown action %q (use %s)", auditAction, actionList())
+	}
+	var err error
+	if auditSince != "" {
+		if f.Since, err = audit.ParseTime(auditSince, false); err != nil {
+			return fmt.Errorf("--since: %w", err)
+		}
+	}
+	if auditUntil != "" {
+		if f.Until, err = audit.ParseTime(auditUntil, true); err != nil {
+			return fmt.Errorf("--until: %w", err)
+		}
+	}
+
+	r, err := repo.Open(auditRepoPath)
+	if err != nil {
+		return fmt.Errorf("open repo: %w", err)
+	}
+	entries, err := audit.Log(r, f)
+	if err != nil {
+		return err
+	}
+	if auditLimit > 0 && len(entries) > auditLimit {
+		entries = entries[:auditLimit]
+	}
+	for _, e := range entries {
+		mark := " "
+		if !e.Verified {
+			mark = "!"
+		}
+		fmt.Printf("%s %s %s %-9s @%-16s %s\n", mark, e.Hash[:8], e.When.Local().Format("2006-01-02 15:04"), e.Action, e.Actor, e.Target)
+		if e.CommitSig != repo.CommitSigValid && !e.Verified {
+			fmt.Printf("      commit signature: %s\n", e.CommitSig)
+		}
+		for _, rec := range e.Records {
+			if rec.Err != "" {
+				fmt.Printf("      %s: %s\n", rec.Path, rec.Err)
+			}
+		}
+	}
+	if len(entries) == 0 {
+		fmt.Println("No matching actions.")
+	}
+	return nil
+}
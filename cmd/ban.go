@@ -0,0 +1,181 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/gosub/gitorum/internal/crypto"
+	"github.com/gosub/gitorum/internal/forum"
+	"github.com/gosub/gitorum/internal/repo"
+)
+
+var banCmd = &cobra.Command{
+	Use:   "ban",
+	Short: "Manage the admin-signed ban list",
+	Long: `Add, remove and list bans. The ban list lives in bans.toml and is signed
+with the admin key; only the forum admin can change it.
+
+Posts by a banned user written after the ban took effect are collapsed for
+everyone.`,
+}
+
+var banAddCmd = &cobra.Command{
+	Use:   "add <username>",
+	Short: "Ban a user",
+	Long: `Ban a user. All their posts are hidden, whatever time they claim to
+have been written at; --since only records when the ban was made (default:
+now). Banning a user again replaces the earlier ban.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runBanAdd,
+}
+
+var banRemoveCmd = &cobra.Command{
+	Use:   "remove <username>",
+	Short: "Lift the ban on a user",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runBanRemove,
+}
+
+var banListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Print the ban list",
+	Args:  cobra.NoArgs,
+	RunE:  runBanList,
+}
+
+var (
+	banRepoPath string
+	banIdentity string
+	banReason   string
+	banSince    string
+)
+
+func init() {
+	banCmd.PersistentFlags().StringVar(&banRepoPath, "repo", ".", "path to the forum git repository")
+	banCmd.PersistentFlags().StringVar(&banIdentity, "identity", "", "path to identity file (default: "+defaultIdentityHint()+")")
+	banAddCmd.Flags().StringVar(&banReason, "reason", "", "reason shown on the banned user's posts")
+	banAddCmd.Flags().StringVar(&banSince, "since", "", "time of the ban, RFC3339 (default: now)")
+
+	banCmd.AddCommand(banAddCmd, banRemoveCmd, banListCmd)
+	rootCmd.AddCommand(banCmd)
+}
+
+func runBanAdd(cmd *cobra.Command, args []string) error {
+	username := args[0]
+	since := time.Now().UTC()
+	if banSince != "" {
+		t, err := time.Parse(time.RFC3339, banSince)
+		if err != nil {
+			return fmt.Errorf("--since: %w", err)
+		}
+		since = t.UTC()
+	}
+	r, id, list, err := openBanList()
+	if err != nil {
+		return err
+	}
+	if username == id.Username {
+		return fmt.Errorf("the admin cannot be banned")
+	}
+
+	ban := forum.Ban{
+		Username: username,
+		Reason:   strings.TrimSpace(banReason),
+		Since:    since.Format(time.RFC3339),
+	}
+	if key, err := os.ReadFile(filepath.Join(r.Path, "keys", username+".pub")); err == nil {
+		ban.PubKey = strings.TrimSpace(string(key))
+	}
+	if err := commitBanList(r, id, list.With(ban), "config: ban @"+username); err != nil {
+		return err
+	}
+	fmt.Printf("@%s is banned from %s\n", username, ban.Since)
+	pushOrWarn(r)
+	return nil
+}
+
+func runBanRemove(cmd *cobra.Command, args []string) error {
+	username := args[0]
+	r, id, list, err := openBanList()
+	if err != nil {
+		return err
+	}
+	bans, found := list.Without(username)
+	if !found {
+		return fmt.Errorf("@%s is not banned", username)
+	}
+	if err := commitBanList(r, id, bans, "config: unban @"+username); err != nil {
+		return err
+	}
+	fmt.Printf("Lifted the ban on @%s\n", username)
+	pushOrWarn(r)
+	return nil
+}
+
+func runBanList(cmd *cobra.Command, args []string) error {
+	r, err := repo.Open(banRepoPath)
+	if err != nil {
+		return fmt.Errorf("open repo: %w", err)
+	}
+	meta, err := r.ReadMeta()
+	if err != nil {
+		return fmt.Errorf("read meta: %w", err)
+	}
+	list, err := forum.LoadBanList(filepath.Join(r.Path, forum.BansFilename), meta.AdminPubkey)
+	if err != nil {
+		return err
+	}
+	if len(list.Bans) == 0 {
+		fmt.Println("Nobody is banned.")
+		return nil
+	}
+	for _, b := range list.Bans {
+		line := fmt.Sprintf("@%-16s since %s", b.Username, b.Since)
+		if b.Reason != "" {
+			line += "  " + b.Reason
+		}
+		fmt.Println(line)
+	}
+	return nil
+}
+
+// openBanList opens the repository and the current ban list, checking that
+// the identity is the forum admin.
+func openBanList() (*repo.Repo, *crypto.Identity, *forum.BanList, error) {
+	id, err := loadIdentity(banIdentity, banRepoPath)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("load identity: %w", err)
+	}
+	r, err := repo.Open(banRepoPath)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("open repo: %w", err)
+	}
+	meta, err := r.ReadMeta()
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("read meta: %w", err)
+	}
+	if id.PublicKey != meta.AdminPubkey {
+		return nil, nil, nil, fmt.Errorf("only the forum admin can change the ban list")
+	}
+	list, err := forum.LoadBanList(filepath.Join(r.Path, forum.BansFilename), meta.AdminPubkey)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return r, id, list, nil
+}
+
+func commitBanList(r *repo.Repo, id *crypto.Identity, bans []forum.Ban, msg string) error {
+	list, err := forum.SignBanList(id, bans)
+	if err != nil {
+		return fmt.Errorf("sign bans: %w", err)
+	}
+	if err := r.CommitFile(id, forum.BansFilename, list.Format(), msg); err != nil {
+		return fmt.Errorf("commit bans: %w", err)
+	}
+	return nil
+}
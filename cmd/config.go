@@ -5,7 +5,7 @@ import (
 
 	"github.com/spf13/cobra"
 
-	"github.com/gosub/gitorum/internal/crypto"
+	"github.com/gosub/gitorum/internal/forum"
 	"github.com/gosub/gitorum/internal/repo"
 )
 
@@ -15,7 +15,16 @@ var configCmd = &cobra.Command{
 	Long: `View or update the forum's metadata and git remote configuration.
 
 Without flags, prints the current configuration.
-Use --name to rename the forum and --remote to set the origin remote URL.`,
+Use --name to rename the forum and --remote to set the origin remote URL.
+
+--proof-of-work N requires posts by authors whose key is not in keys/ to
+carry a proof of work of N bits, which costs about 2^N hashes to compute
+(20 takes a second or so). Posts without it are hidden. 0 turns the
+requirement off.
+
+--auto-approve-vouches N approves a join request on sync once N users with
+an approved key vouch for the requested key (see 'gitorum vouch'). 0 turns
+it off; --auto-approve approves every request regardless.`,
 	RunE: runConfig,
 }
 
@@ -25,6 +34,8 @@ var (
 	configForumName   string
 	configIdentity    string
 	configAutoApprove bool
+	configWork        int
+	configVouches     int
 )
 
 func init() {
@@ -33,6 +44,8 @@ func init() {
 	configCmd.Flags().StringVar(&configForumName, "name", "", "set the forum display name")
 	configCmd.Flags().StringVar(&configIdentity, "identity", "", "path to identity file (default: "+defaultIdentityHint()+")")
 	configCmd.Flags().BoolVar(&configAutoApprove, "auto-approve", false, "enable automatic approval of join requests on sync")
+	configCmd.Flags().IntVar(&configWork, "proof-of-work", 0, "bits of proof of work required of posts by authors without an approved key (0 to disable)")
+	configCmd.Flags().IntVar(&configVouches, "auto-approve-vouches", 0, "approve join requests on sync once this many approved users vouch for them (0 to disable)")
 	rootCmd.AddCommand(configCmd)
 }
 
@@ -49,20 +62,20 @@ func runConfig(cmd *cobra.Command, args []string) error {
 
 	// No flags → print current config.
 	autoApproveChanged := cmd.Flags().Changed("auto-approve")
-	if configRemoteURL == "" && configForumName == "" && !autoApproveChanged {
+	workChanged := cmd.Flags().Changed("proof-of-work")
+	vouchesChanged := cmd.Flags().Changed("auto-approve-vouches")
+	if configRemoteURL == "" && configForumName == "" && !autoApproveChanged && !workChanged && !vouchesChanged {
 		_, remoteURL := r.IsSynced()
 		fmt.Printf("Forum name   : %s\n", meta.Name)
 		fmt.Printf("Admin key    : %s\n", meta.AdminPubkey)
 		fmt.Printf("Remote URL   : %s\n", remoteURL)
 		fmt.Printf("Auto-approve : %v\n", meta.AutoApproveKeys)
+		fmt.Printf("Proof of work: %d bits\n", meta.ProofOfWork)
+		fmt.Printf("Vouch quorum : %d\n", meta.AutoApproveVouches)
 		return nil
 	}
 
-	identPath := configIdentity
-	if identPath == "" {
-		identPath = crypto.DefaultIdentityPath()
-	}
-	id, err := crypto.LoadIdentity(identPath)
+	id, err := loadIdentity(configIdentity, configRepoPath)
 	if err != nil {
 		return fmt.Errorf("load identity: %w", err)
 	}
@@ -76,6 +89,20 @@ func runConfig(cmd *cobra.Command, args []string) error {
 		meta.AutoApproveKeys = configAutoApprove
 		metaChanged = true
 	}
+	if workChanged {
+		if configWork < 0 || configWork > forum.MaxWork {
+			return fmt.Errorf("--proof-of-work must be between 0 and %d bits", forum.MaxWork)
+		}
+		meta.ProofOfWork = configWork
+		metaChanged = true
+	}
+	if vouchesChanged {
+		if configVouches < 0 {
+			return fmt.Errorf("--auto-approve-vouches must not be negative")
+		}
+		meta.AutoApproveVouches = configVouches
+		metaChanged = true
+	}
 	if metaChanged {
 		if err := r.UpdateMeta(id, *meta); err != nil {
 			return fmt.Errorf("update metadata: %w", err)
@@ -86,6 +113,12 @@ func runConfig(cmd *cobra.Command, args []string) error {
 		if autoApproveChanged {
 			fmt.Printf("Auto-approve set to %v\n", meta.AutoApproveKeys)
 		}
+		if workChanged {
+			fmt.Printf("Proof of work set to %d bits\n", meta.ProofOfWork)
+		}
+		if vouchesChanged {
+			fmt.Printf("Vouch quorum set to %d\n", meta.AutoApproveVouches)
+		}
 	}
 
 	if configRemoteURL != "" {
@@ -0,0 +1,165 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/gosub/gitorum/internal/local"
+	"github.com/gosub/gitorum/internal/repo"
+)
+
+var draftCmd = &cobra.Command{
+	Use:   "draft",
+	Short: "Manage your unfinished posts",
+	Long: `Manage drafts of replies and new threads. Drafts belong to your identity
+and are kept under .git/gitorum/drafts/, so they are never committed or
+pushed. The web UI saves drafts as you type and offers to resume them.
+
+Drafts are named reply:<category>/<thread> for a reply, and new:<category>
+for a new thread.`,
+}
+
+var draftListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List your drafts, most recently updated first",
+	Args:  cobra.NoArgs,
+	RunE:  runDraftList,
+}
+
+var draftShowCmd = &cobra.Command{
+	Use:   "show <draft>",
+	Short: "Print the body of a draft",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runDraftShow,
+}
+
+var draftSaveCmd = &cobra.Command{
+	Use:   "save <draft>",
+	Short: "Save a draft, reading the body from --body or standard input",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runDraftSave,
+}
+
+var draftDiscardCmd = &cobra.Command{
+	Use:   "discard <draft>",
+	Short: "Delete a draft",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runDraftDiscard,
+}
+
+var (
+	draftRepoPath string
+	draftIdentity string
+	draftBody     string
+	draftSlug     string
+	draftParent   string
+)
+
+func init() {
+	draftCmd.PersistentFlags().StringVar(&draftRepoPath, "repo", ".", "path to the forum git repository")
+	draftCmd.PersistentFlags().StringVar(&draftIdentity, "identity", "", "path to identity file (default: "+defaultIdentityHint()+")")
+
+	draftSaveCmd.Flags().StringVar(&draftBody, "body", "", "draft body in Markdown (default: read standard input)")
+	draftSaveCmd.Flags().StringVar(&draftSlug, "slug", "", "slug of the new thread")
+	draftSaveCmd.Flags().StringVar(&draftParent, "parent", "", "filename of the post replied to")
+
+	draftCmd.AddCommand(draftListCmd, draftShowCmd, draftSaveCmd, draftDiscardCmd)
+	rootCmd.AddCommand(draftCmd)
+}
+
+func runDraftList(cmd *cobra.Command, args []string) error {
+	d, err := openDrafts()
+	if err != nil {
+		return err
+	}
+	if len(d.Drafts) == 0 {
+		fmt.Println("You have no drafts.")
+		return nil
+	}
+	for _, dr := range d.Drafts {
+		line, _, _ := strings.Cut(strings.TrimSpace(dr.Body), "\n")
+		if len([]rune(line)) > 60 {
+			line = string([]rune(line)[:60]) + "…"
+		}
+		fmt.Printf("%s  %s  %s\n", dr.Updated.Local().Format("2006-01-02 15:04"), dr.ID(), line)
+	}
+	return nil
+}
+
+func runDraftShow(cmd *cobra.Command, args []string) error {
+	d, err := openDrafts()
+	if err != nil {
+		return err
+	}
+	dr, ok := d.Get(args[0])
+	if !ok {
+		return fmt.Errorf("no draft %s", args[0])
+	}
+	fmt.Print(dr.Body)
+	if !strings.HasSuffix(dr.Body, "\n") {
+		fmt.Println()
+	}
+	return nil
+}
+
+func runDraftSave(cmd *cobra.Command, args []string) error {
+	category, thread, err := local.ParseDraftID(args[0])
+	if err != nil {
+		return err
+	}
+	if thread == "" && draftParent != "" {
+		return fmt.Errorf("--parent only applies to replies")
+	}
+	if thread != "" && draftSlug != "" {
+		return fmt.Errorf("--slug only applies to new threads")
+	}
+	body := draftBody
+	if !cmd.Flags().Changed("body") {
+		b, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("read body: %w", err)
+		}
+		body = string(b)
+	}
+	d, err := openDrafts()
+	if err != nil {
+		return err
+	}
+	d.Put(local.Draft{Category: category, Thread: thread, Slug: draftSlug, Parent: draftParent, Body: body})
+	if err := d.Save(); err != nil {
+		return fmt.Errorf("save drafts: %w", err)
+	}
+	fmt.Printf("Saved %s\n", args[0])
+	return nil
+}
+
+func runDraftDiscard(cmd *cobra.Command, args []string) error {
+	d, err := openDrafts()
+	if err != nil {
+		return err
+	}
+	if !d.Discard(args[0]) {
+		return fmt.Errorf("no draft %s", args[0])
+	}
+	if err := d.Save(); err != nil {
+		return fmt.Errorf("save drafts: %w", err)
+	}
+	fmt.Printf("Discarded %s\n", args[0])
+	return nil
+}
+
+func openDrafts() (*local.Drafts, error) {
+	id, err := loadIdentity(draftIdentity, draftRepoPath)
+	if err != nil {
+		return nil, fmt.Errorf("load identity: %w", err)
+	}
+	r, err := repo.Open(draftRepoPath)
+	if err != nil {
+		return nil, fmt.Errorf("open repo: %w", err)
+	}
+	return local.LoadDrafts(r.Path, id.Username)
+}
@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/gosub/gitorum/internal/crypto"
+	"github.com/gosub/gitorum/internal/local"
+	"github.com/gosub/gitorum/internal/repo"
+)
+
+var idCmd = &cobra.Command{
+	Use:   "id",
+	Short: "Manage your identities",
+	Long: `Manage the identities you can post as. Identities are kept in
+~/.config/gitorum/identities/, one file per identity; the identity created by
+'gitorum keygen' is called "default".
+
+Each forum uses the default identity unless another one was chosen for it
+with 'gitorum id use'. The choice is stored in .git/gitorum/identity.toml and
+is never committed. A --identity flag always takes precedence.`,
+}
+
+var idListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List your identities, marking the one used in --repo",
+	Args:  cobra.NoArgs,
+	RunE:  runIDList,
+}
+
+var idAddCmd = &cobra.Command{
+	Use:   "add <name>",
+	Short: "Generate a new identity, or import one with --from",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runIDAdd,
+}
+
+var idUseCmd = &cobra.Command{
+	Use:   "use <name>",
+	Short: "Use an identity in the forum at --repo",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runIDUse,
+}
+
+var (
+	idRepoPath string
+	idUsername string
+	idFrom     string
+)
+
+func init() {
+	idCmd.PersistentFlags().StringVar(&idRepoPath, "repo", ".", "path to the forum git repository")
+	idAddCmd.Flags().StringVarP(&idUsername, "username", "u", "", "username of the new identity (default: the name)")
+	idAddCmd.Flags().StringVar(&idFrom, "from", "", "import the identity file at this path instead of generating one")
+
+	idCmd.AddCommand(idListCmd, idAddCmd, idUseCmd)
+	rootCmd.AddCommand(idCmd)
+}
+
+func runIDList(cmd *cobra.Command, args []string) error {
+	store := crypto.DefaultStore()
+	names, err := store.List()
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		fmt.Println("You have no identities; run 'gitorum keygen' or 'gitorum id add'.")
+		return nil
+	}
+	inUse := ""
+	if r, err := repo.Open(idRepoPath); err == nil {
+		inUse = chosenIdentity(r.Path)
+	}
+	for _, name := range names {
+		mark := " "
+		if name == inUse {
+			mark = "*"
+		}
+		id, err := store.Load(name)
+		if err != nil {
+			fmt.Printf("%s %-12s  (%v)\n", mark, name, err)
+			continue
+		}
+		fmt.Printf("%s %-12s  @%-16s %s\n", mark, name, id.Username, id.Fingerprint())
+	}
+	return nil
+}
+
+func runIDAdd(cmd *cobra.Command, args []string) error {
+	name := args[0]
+	if name == crypto.DefaultName {
+		return fmt.Errorf("the default identity is created with 'gitorum keygen'")
+	}
+	var (
+		id  *crypto.Identity
+		err error
+	)
+	if idFrom != "" {
+		if id, err = crypto.LoadIdentity(idFrom); err != nil {
+			return err
+		}
+		if idUsername != "" && idUsername != id.Username {
+			return fmt.Errorf("%s belongs to @%s, not @%s", idFrom, id.Username, idUsername)
+		}
+	} else {
+		username := idUsername
+		if username == "" {
+			username = name
+		}
+		if id, err = crypto.Generate(username); err != nil {
+			return fmt.Errorf("generate keypair: %w", err)
+		}
+	}
+	store := crypto.DefaultStore()
+	if err := store.Add(name, id); err != nil {
+		return err
+	}
+	path, _ := store.Path(name)
+	fmt.Printf("Identity %q added for @%s\n", name, id.Username)
+	fmt.Printf("Public key : %s\n", id.PublicKey)
+	fmt.Printf("Saved to   : %s\n", path)
+	return nil
+}
+
+func runIDUse(cmd *cobra.Command, args []string) error {
+	name := args[0]
+	id, err := crypto.DefaultStore().Load(name)
+	if err != nil {
+		return err
+	}
+	r, err := repo.Open(idRepoPath)
+	if err != nil {
+		return fmt.Errorf("open repo: %w", err)
+	}
+	if err := useIdentity(r.Path, name); err != nil {
+		return err
+	}
+	fmt.Printf("Using %q (@%s) in %s\n", name, id.Username, r.Path)
+	return nil
+}
+
+// loadIdentity loads the identity to act as in the repository at repoPath:
+// the file at path when given, else the identity chosen for the repository
+// with 'gitorum id use', else the default identity. A missing identity
+// file is reported as fs.ErrNotExist.
+func loadIdentity(path, repoPath string) (*crypto.Identity, error) {
+	if path != "" {
+		return crypto.LoadIdentity(path)
+	}
+	return crypto.DefaultStore().Load(chosenIdentity(repoPath))
+}
+
+// chosenIdentity returns the store name of the identity used in the
+// repository at repoPath.
+func chosenIdentity(repoPath string) string {
+	c, err := local.LoadIdentityChoice(repoPath)
+	if err != nil || c.Name == "" {
+		return crypto.DefaultName
+	}
+	return c.Name
+}
+
+// useIdentity records name as the identity of the repository at repoPath.
+func useIdentity(repoPath, name string) error {
+	c, err := local.LoadIdentityChoice(repoPath)
+	if err != nil {
+		return err
+	}
+	c.Name = name
+	return c.Save()
+}
@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/gosub/gitorum/internal/crypto"
+	"github.com/gosub/gitorum/internal/local"
+	"github.com/gosub/gitorum/internal/repo"
+)
+
+var muteCmd = &cobra.Command{
+	Use:   "mute",
+	Short: "Manage your personal mute list",
+	Long: `Mute users whose posts you do not want to see. The list belongs to your
+identity and is kept under .git/gitorum/, so it is never committed or pushed
+and affects nobody else.`,
+}
+
+var muteAddCmd = &cobra.Command{
+	Use:   "add <username>",
+	Short: "Mute a user",
+	Args:  cobra.ExactArgs(1),
+	RunE:  func(cmd *cobra.Command, args []string) error { return runMuteChange(args[0], true) },
+}
+
+var muteRemoveCmd = &cobra.Command{
+	Use:   "remove <username>",
+	Short: "Unmute a user",
+	Args:  cobra.ExactArgs(1),
+	RunE:  func(cmd *cobra.Command, args []string) error { return runMuteChange(args[0], false) },
+}
+
+var muteListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Print the users you muted",
+	Args:  cobra.NoArgs,
+	RunE:  runMuteList,
+}
+
+var (
+	muteRepoPath string
+	muteIdentity string
+)
+
+func init() {
+	muteCmd.PersistentFlags().StringVar(&muteRepoPath, "repo", ".", "path to the forum git repository")
+	muteCmd.PersistentFlags().StringVar(&muteIdentity, "identity", "", "path to identity file (default: "+defaultIdentityHint()+")")
+
+	muteCmd.AddCommand(muteAddCmd, muteRemoveCmd, muteListCmd)
+	rootCmd.AddCommand(muteCmd)
+}
+
+func runMuteChange(username string, mute bool) error {
+	m, id, err := openMuteList()
+	if err != nil {
+		return err
+	}
+	if mute && username == id.Username {
+		return fmt.Errorf("you cannot mute yourself")
+	}
+	changed := false
+	if mute {
+		changed = m.Mute(username)
+	} else {
+		changed = m.Unmute(username)
+	}
+	if !changed {
+		fmt.Printf("Nothing to do for @%s\n", username)
+		return nil
+	}
+	if err := m.Save(); err != nil {
+		return fmt.Errorf("save mutes: %w", err)
+	}
+	if mute {
+		fmt.Printf("Muted @%s\n", username)
+	} else {
+		fmt.Printf("Unmuted @%s\n", username)
+	}
+	return nil
+}
+
+func runMuteList(cmd *cobra.Command, args []string) error {
+	m, _, err := openMuteList()
+	if err != nil {
+		return err
+	}
+	if len(m.Users) == 0 {
+		fmt.Println("You have not muted anyone.")
+		return nil
+	}
+	for _, u := range m.Users {
+		fmt.Println("@" + u)
+	}
+	return nil
+}
+
+func openMuteList() (*local.MuteList, *crypto.Identity, error) {
+	id, err := loadIdentity(muteIdentity, muteRepoPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("load identity: %w", err)
+	}
+	r, err := repo.Open(muteRepoPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open repo: %w", err)
+	}
+	m, err := local.LoadMuteList(r.Path, id.Username)
+	if err != nil {
+		return nil, nil, err
+	}
+	return m, id, nil
+}
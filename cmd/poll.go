@@ -0,0 +1,241 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/gosub/gitorum/internal/crypto"
+	"github.com/gosub/gitorum/internal/forum"
+	"github.com/gosub/gitorum/internal/repo"
+)
+
+var pollCmd = &cobra.Command{
+	Use:   "poll",
+	Short: "Create, vote on, and tally polls",
+	Long: `Create polls, cast signed votes, and print verifiable poll results.
+
+Threads are addressed as <category>/<thread-slug>.`,
+}
+
+var pollCreateCmd = &cobra.Command{
+	Use:   "create <category>/<thread>",
+	Short: "Start a new poll thread",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runPollCreate,
+}
+
+var pollVoteCmd = &cobra.Command{
+	Use:   "vote <category>/<thread> <option>",
+	Short: "Cast a signed vote on a poll",
+	Long: `Cast a signed vote on a poll. Voting again replaces your previous vote:
+only the latest valid vote of each approved key is counted.`,
+	Args: cobra.ExactArgs(2),
+	RunE: runPollVote,
+}
+
+var pollResultsCmd = &cobra.Command{
+	Use:   "results <category>/<thread>",
+	Short: "Print poll results and the list of voters",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runPollResults,
+}
+
+var (
+	pollRepoPath string
+	pollIdentity string
+	pollOptions  []string
+	pollCloses   string
+	pollBody     string
+)
+
+func init() {
+	pollCmd.PersistentFlags().StringVar(&pollRepoPath, "repo", ".", "path to the forum git repository")
+	pollCmd.PersistentFlags().StringVar(&pollIdentity, "identity", "", "path to identity file (default: "+defaultIdentityHint()+")")
+	pollCreateCmd.Flags().StringArrayVar(&pollOptions, "option", nil, "poll option (repeat for each option)")
+	pollCreateCmd.Flags().StringVar(&pollCloses, "closes", "", "close time, RFC3339 (e.g. 2026-03-01T12:00:00Z)")
+	pollCreateCmd.Flags().StringVar(&pollBody, "body", "", "poll question in Markdown (required)")
+	_ = pollCreateCmd.MarkFlagRequired("body")
+
+	pollCmd.AddCommand(pollCreateCmd, pollVoteCmd, pollResultsCmd)
+	rootCmd.AddCommand(pollCmd)
+}
+
+func runPollCreate(cmd *cobra.Command, args []string) error {
+	cat, slug, err := splitThreadRef(args[0])
+	if err != nil {
+		return err
+	}
+	r, id, err := openPollRepo()
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(filepath.Join(r.Path, cat, "META.toml")); err != nil {
+		return fmt.Errorf("category not found: %s", cat)
+	}
+	if _, err := os.Stat(filepath.Join(r.Path, cat, slug)); err == nil {
+		return fmt.Errorf("thread %s/%s already exists", cat, slug)
+	}
+
+	meta, err := r.ReadMeta()
+	if err != nil {
+		return fmt.Errorf("read metadata: %w", err)
+	}
+	signOpts := forum.SignOptions{Work: meta.ProofOfWork, KeysDir: filepath.Join(r.Path, "keys")}
+	post, err := forum.SignPollWith(id, pollBody, pollOptions, pollCloses, signOpts)
+	if err != nil {
+		return fmt.Errorf("sign poll: %w", err)
+	}
+	post.Filename = forum.RootFilename
+	if err := r.CommitPost(id, filepath.Join(cat, slug, post.Filename), post.Format()); err != nil {
+		return fmt.Errorf("commit poll: %w", err)
+	}
+	fmt.Printf("Poll created at %s/%s\n", cat, slug)
+	pushOrWarn(r)
+	return nil
+}
+
+func runPollVote(cmd *cobra.Command, args []string) error {
+	cat, slug, err := splitThreadRef(args[0])
+	if err != nil {
+		return err
+	}
+	option := args[1]
+	r, id, err := openPollRepo()
+	if err != nil {
+		return err
+	}
+
+	threadDir := filepath.Join(r.Path, cat, slug)
+	rootContent, err := os.ReadFile(filepath.Join(threadDir, forum.RootFilename))
+	if err != nil {
+		return fmt.Errorf("thread not found: %s/%s", cat, slug)
+	}
+	root, err := forum.ParsePost(forum.RootFilename, rootContent)
+	if err != nil {
+		return err
+	}
+	if !root.IsPoll() {
+		return fmt.Errorf("%s/%s is not a poll", cat, slug)
+	}
+	if !slices.Contains(root.PollOptions, option) {
+		return fmt.Errorf("unknown option %q (choices: %s)", option, strings.Join(root.PollOptions, ", "))
+	}
+
+	vote, err := forum.SignVote(id, rootContent, option)
+	if err != nil {
+		return fmt.Errorf("sign vote: %w", err)
+	}
+	vote.Filename = forum.NewVoteFilename(id.Username, option)
+	if err := r.CommitPost(id, filepath.Join(cat, slug, vote.Filename), vote.Format()); err != nil {
+		return fmt.Errorf("commit vote: %w", err)
+	}
+	fmt.Printf("Voted %q as @%s\n", option, id.Username)
+	pushOrWarn(r)
+	return nil
+}
+
+func runPollResults(cmd *cobra.Command, args []string) error {
+	cat, slug, err := splitThreadRef(args[0])
+	if err != nil {
+		return err
+	}
+	r, err := repo.Open(pollRepoPath)
+	if err != nil {
+		return fmt.Errorf("open repo: %w", err)
+	}
+	res, err := forum.TallyPollWith(filepath.Join(r.Path, cat, slug), filepath.Join(r.Path, "keys"), pollLoadOptions(r, cat))
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case res.ClosesAt.IsZero():
+		fmt.Println("Status : open (no close time)")
+	case res.Closed:
+		fmt.Printf("Status : closed at %s\n", res.ClosesAt.UTC().Format(time.RFC3339))
+	default:
+		fmt.Printf("Status : open until %s\n", res.ClosesAt.UTC().Format(time.RFC3339))
+	}
+	fmt.Println()
+	for _, o := range res.Options {
+		fmt.Printf("%5d  %s\n", o.Votes, o.Text)
+	}
+	fmt.Printf("\nVoters (%d):\n", len(res.Voters))
+	for _, v := range res.Voters {
+		fmt.Printf("  @%-16s %-8s %s  %s\n", v.Voter, v.PubKey, v.Timestamp.UTC().Format(time.RFC3339), v.Option)
+		fmt.Printf("  %18s %s\n", "", v.Filename)
+	}
+	if len(res.Rejected) > 0 {
+		fmt.Printf("\nNot counted (%d):\n", len(res.Rejected))
+		for _, v := range res.Rejected {
+			fmt.Printf("  %s  @%s: %s\n", v.Filename, v.Voter, v.Reason)
+		}
+	}
+	return nil
+}
+
+// splitThreadRef splits "<category>/<thread>" into its two slugs.
+func splitThreadRef(ref string) (cat, slug string, err error) {
+	i := strings.LastIndex(ref, "/")
+	if i < 0 {
+		return "", "", fmt.Errorf("thread must be given as <category>/<thread>, got %q", ref)
+	}
+	cat, slug = ref[:i], ref[i+1:]
+	if cat == "" || slug == "" {
+		return "", "", fmt.Errorf("thread must be given as <category>/<thread>, got %q", ref)
+	}
+	return cat, slug, nil
+}
+
+func openPollRepo() (*repo.Repo, *crypto.Identity, error) {
+	id, err := loadIdentity(pollIdentity, pollRepoPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("load identity: %w", err)
+	}
+	r, err := repo.Open(pollRepoPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open repo: %w", err)
+	}
+	return r, id, nil
+}
+
+// pollLoadOptions returns the forum settings that decide which votes are
+// counted: the admin key, the ban list, when each vote arrived in the
+// history and the write policy of cat.
+// Settings that cannot be read are left out with a warning.
+func pollLoadOptions(r *repo.Repo, cat string) forum.LoadOptions {
+	meta, err := r.ReadMeta()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: read metadata: %v\n", err)
+		return forum.LoadOptions{}
+	}
+	opts := forum.LoadOptions{AdminPubkey: meta.AdminPubkey}
+	if opts.Bans, err = forum.LoadBanList(filepath.Join(r.Path, forum.BansFilename), meta.AdminPubkey); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+	}
+	if opts.Arrivals, err = r.Arrivals(); err != nil {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+	}
+	c, err := forum.LoadCategory(cat, filepath.Join(r.Path, filepath.FromSlash(cat)))
+	if err == nil {
+		opts, err = forum.ThreadOptions(opts, c, filepath.Join(r.Path, forum.RolesFilename))
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "warning: %v\n", err)
+	}
+	return opts
+}
+
+// pushOrWarn pushes r to its remote, printing a note instead of failing when
+// the push does not go through.
+func pushOrWarn(r *repo.Repo) {
+	if err := r.Push(); err != nil {
+		fmt.Fprintf(os.Stderr, "Note: push failed (%v); run 'gitorum serve' and sync, or 'git push' manually.\n", err)
+	}
+}
@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/gosub/gitorum/internal/repo"
+)
+
+var remoteCmd = &cobra.Command{
+	Use:   "remote",
+	Short: "Manage the remotes the forum syncs with",
+	Long: `Manage the git remotes a sync pulls from and pushes to. Each remote has
+a role:
+
+  pull    a peer: the posts it adds are merged, nothing is pushed to it
+  push    a backup: every local commit is pushed to it, nothing is pulled
+  mirror  both; a mirror is a full copy of the forum and is fast-forwarded to
+
+Only new files are merged from a remote that cannot be fast-forwarded to,
+and from a peer only new posts, votes and thread state records in existing
+categories; anything else it added, changed or removed is left alone and
+reported by 'gitorum remote list'. origin is a mirror unless given another role; other remotes default
+to pull. Roles are stored in .git/config.`,
+}
+
+var remoteListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the remotes and their roles",
+	Args:  cobra.NoArgs,
+	RunE:  runRemoteList,
+}
+
+var remoteAddCmd = &cobra.Command{
+	Use:   "add <name> <url>",
+	Short: "Add a remote, replacing any with the same name",
+	Args:  cobra.ExactArgs(2),
+	RunE:  runRemoteAdd,
+}
+
+var remoteRoleCmd = &cobra.Command{
+	Use:   "role <name> <pull|push|mirror>",
+	Short: "Change the role of a remote",
+	Args:  cobra.ExactArgs(2),
+	RunE:  runRemoteRole,
+}
+
+var remoteRemoveCmd = &cobra.Command{
+	Use:   "remove <name>",
+	Short: "Remove a remote",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runRemoteRemove,
+}
+
+var (
+	remoteRepoPath string
+	remoteRole     string
+)
+
+func init() {
+	remoteCmd.PersistentFlags().StringVar(&remoteRepoPath, "repo", ".", "path to the forum git repository")
+	remoteAddCmd.Flags().StringVar(&remoteRole, "role", "", "pull, push or mirror (default: mirror for origin, pull otherwise)")
+
+	remoteCmd.AddCommand(remoteListCmd, remoteAddCmd, remoteRoleCmd, remoteRemoveCmd)
+	rootCmd.AddCommand(remoteCmd)
+}
+
+func runRemoteList(cmd *cobra.Command, args []string) error {
+	r, err := repo.Open(remoteRepoPath)
+	if err != nil {
+		return fmt.Errorf("open repo: %w", err)
+	}
+	remotes, err := r.Remotes()
+	if err != nil {
+		return err
+	}
+	if len(remotes) == 0 {
+		fmt.Println("No remotes; add one with 'gitorum remote add'.")
+		return nil
+	}
+	for _, rm := range remotes {
+		fmt.Printf("%-12s %-7s %s\n", rm.Name, rm.Role, rm.URL)
+	}
+	return nil
+}
+
+func runRemoteAdd(cmd *cobra.Command, args []string) error {
+	name, url := args[0], args[1]
+	if remoteRole != "" && !repo.ValidRole(remoteRole) {
+		return fmt.Errorf("invalid role %q: use pull, push or mirror", remoteRole)
+	}
+	r, err := repo.Open(remoteRepoPath)
+	if err != nil {
+		return fmt.Errorf("open repo: %w", err)
+	}
+	if err := r.AddRemote(name, url); err != nil {
+		return err
+	}
+	if remoteRole != "" {
+		if err := r.SetRemoteRole(name, remoteRole); err != nil {
+			return err
+		}
+	}
+	return printRemote(r, name)
+}
+
+func runRemoteRole(cmd *cobra.Command, args []string) error {
+	r, err := repo.Open(remoteRepoPath)
+	if err != nil {
+		return fmt.Errorf("open repo: %w", err)
+	}
+	if err := r.SetRemoteRole(args[0], args[1]); err != nil {
+		return err
+	}
+	return printRemote(r, args[0])
+}
+
+func runRemoteRemove(cmd *cobra.Command, args []string) error {
+	r, err := repo.Open(remoteRepoPath)
+	if err != nil {
+		return fmt.Errorf("open repo: %w", err)
+	}
+	if err := r.RemoveRemote(args[0]); err != nil {
+		return err
+	}
+	fmt.Printf("Removed remote %s\n", args[0])
+	return nil
+}
+
+func printRemote(r *repo.Repo, name string) error {
+	remotes, err := r.Remotes()
+	if err != nil {
+		return err
+	}
+	for _, rm := range remotes {
+		if rm.Name == name {
+			fmt.Printf("Remote %s (%s): %s\n", rm.Name, rm.Role, rm.URL)
+		}
+	}
+	return nil
+}
@@ -7,7 +7,6 @@ import (
 
 	"github.com/spf13/cobra"
 
-	"github.com/gosub/gitorum/internal/crypto"
 	"github.com/gosub/gitorum/internal/repo"
 )
 
@@ -37,11 +36,7 @@ func init() {
 }
 
 func runRequest(cmd *cobra.Command, args []string) error {
-	identPath := requestIdentity
-	if identPath == "" {
-		identPath = crypto.DefaultIdentityPath()
-	}
-	id, err := crypto.LoadIdentity(identPath)
+	id, err := loadIdentity(requestIdentity, requestRepoPath)
 	if err != nil {
 		return fmt.Errorf("load identity: %w", err)
 	}
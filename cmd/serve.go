@@ -1,15 +1,22 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
 	"log"
+	"net"
 	"os"
 	"path/filepath"
+	"strings"
 
+	"github.com/BurntSushi/toml"
 	"github.com/spf13/cobra"
 
 	"github.com/gosub/gitorum/internal/api"
 	"github.com/gosub/gitorum/internal/crypto"
+	"github.com/gosub/gitorum/internal/forum"
+	"github.com/gosub/gitorum/internal/lan"
 	"github.com/gosub/gitorum/internal/repo"
 	"github.com/gosub/gitorum/internal/ui"
 )
@@ -20,20 +27,48 @@ var serveCmd = &cobra.Command{
 	Long: `Start the local HTTP server that serves the Gitorum web UI and JSON API.
 
 All forum data is read from and written to the local git repository at --repo.
-Open http://localhost:<port> in your browser to use the forum.`,
+Open http://localhost:<port> in your browser to use the forum.
+
+Several forums can be served at once by repeating --repo, optionally as
+name=path, or by listing them in the forums file (default:
+$XDG_CONFIG_HOME/gitorum/forums.toml), which is read when --repo is not given:
+
+  [[forum]]
+  name = "work"
+  repo = "/home/me/forums/work"
+
+Each forum's API is served under /f/<name>/api/; the first forum is also
+served under /api/. Without a name, a forum is named after its directory.
+
+With --lan the forums are advertised on the local network with mDNS, and
+other gitorum servers advertising the same forum show up in the web UI,
+ready to sync with directly. Peers fetch from a read-only git endpoint
+served on all interfaces at --lan-port; the web UI and API stay local.`,
 	RunE: runServe,
 }
 
 var (
-	servePort     int
-	serveRepoPath string
-	serveIdentity string
+	servePort        int
+	serveRepoPaths   []string
+	serveForumsFile  string
+	serveIdentity    string
+	serveHighlight   bool
+	serveLinkSchemes []string
+	serveLAN         bool
+	serveLANPort     int
+	serveTrustHops   int
 )
 
 func init() {
 	serveCmd.Flags().IntVarP(&servePort, "port", "p", 8080, "HTTP port to listen on")
-	serveCmd.Flags().StringVar(&serveRepoPath, "repo", ".", "path to the forum git repository")
+	serveCmd.Flags().StringArrayVar(&serveRepoPaths, "repo", []string{"."}, "path to a forum git repository, or name=path (repeatable)")
+	serveCmd.Flags().StringVar(&serveForumsFile, "forums", "", "forums file to read when --repo is not given (default: "+filepath.Join(filepath.Dir(defaultIdentityHint()), "forums.toml")+")")
 	serveCmd.Flags().StringVar(&serveIdentity, "identity", "", "path to identity file (default: "+defaultIdentityHint()+")")
+	serveCmd.Flags().BoolVar(&serveHighlight, "highlight", true, "syntax-highlight fenced code blocks")
+	serveCmd.Flags().BoolVar(&serveLAN, "lan", false, "advertise the forums on the local network and sync with peers found there")
+	serveCmd.Flags().IntVar(&serveLANPort, "lan-port", 0, "port of the read-only git endpoint for LAN peers (default: --port + 1)")
+	serveCmd.Flags().IntVar(&serveTrustHops, "trust-hops", forum.DefaultTrustHops, "how many vouches away from your identity keys are still trusted")
+	serveCmd.Flags().StringSliceVar(&serveLinkSchemes, "link-scheme", forum.DefaultRenderOptions().URLSchemes, "URL scheme allowed in post links and images (repeatable)")
 
 	rootCmd.AddCommand(serveCmd)
 }
@@ -47,36 +82,110 @@ func defaultIdentityHint() string {
 }
 
 func runServe(cmd *cobra.Command, args []string) error {
-	absRepo, err := filepath.Abs(serveRepoPath)
+	specs, err := serveForums(cmd)
 	if err != nil {
-		return fmt.Errorf("resolve repo path: %w", err)
+		return err
 	}
 
-	identPath := serveIdentity
-	if identPath == "" {
-		identPath = crypto.DefaultIdentityPath()
+	renderOpts := forum.DefaultRenderOptions()
+	renderOpts.Highlight = serveHighlight
+	renderOpts.URLSchemes = serveLinkSchemes
+	forum.SetRenderOptions(renderOpts)
+
+	// Open forum repos and load the identity of each — non-fatal if
+	// missing (setup wizard will handle it).
+	for i, spec := range specs {
+		if opened, err := repo.Open(spec.RepoPath); err == nil {
+			specs[i].Repo = opened
+			log.Printf("Forum repo: %s", spec.RepoPath)
+		} else {
+			log.Printf("No forum repo at %s; run 'gitorum init' first", spec.RepoPath)
+		}
+		id, err := loadIdentity(serveIdentity, spec.RepoPath)
+		if errors.Is(err, fs.ErrNotExist) {
+			log.Printf("No identity for %s; run 'gitorum keygen' or 'gitorum id use' first", spec.Name)
+			continue
+		} else if err != nil {
+			return fmt.Errorf("load identity for %s: %w", spec.Name, err)
+		}
+		specs[i].Identity = id
+		log.Printf("Identity in %s: @%s", spec.Name, id.Username)
 	}
 
-	// Load identity — non-fatal if missing (setup wizard will handle it).
-	var id *crypto.Identity
-	if loaded, err := crypto.LoadIdentity(identPath); err == nil {
-		id = loaded
-		log.Printf("Identity: @%s", id.Username)
-	} else if !os.IsNotExist(err) {
-		return fmt.Errorf("load identity: %w", err)
-	} else {
-		log.Printf("No identity file found at %s; run 'gitorum keygen' first", identPath)
+	srv, err := api.NewMulti(servePort, specs, nil)
+	if err != nil {
+		return err
+	}
+	srv.TrustHops = serveTrustHops
+	if serveLAN {
+		port := serveLANPort
+		if port == 0 {
+			port = servePort + 1
+		}
+		ln, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+		if err != nil {
+			return fmt.Errorf("LAN git endpoint: %w", err)
+		}
+		if err := srv.StartLAN(&lan.Discovery{}, ln); err != nil {
+			ln.Close()
+			return err
+		}
 	}
+	return srv.ListenAndServe(ui.StaticFS)
+}
+
+// forumsFile is the list of forums read by serve when --repo is not given.
+type forumsFile struct {
+	Forums []struct {
+		Name string `toml:"name"`
+		Repo string `toml:"repo"`
+	} `toml:"forum"`
+}
 
-	// Open forum repo — non-fatal if missing (setup wizard will handle it).
-	var r *repo.Repo
-	if opened, err := repo.Open(absRepo); err == nil {
-		r = opened
-		log.Printf("Forum repo: %s", absRepo)
-	} else {
-		log.Printf("No forum repo at %s; run 'gitorum init' first", absRepo)
+// serveForums returns the forums to serve: the --repo values when given,
+// else the forums file when it exists, else the current directory.
+func serveForums(cmd *cobra.Command) ([]api.ForumConfig, error) {
+	entries := serveRepoPaths
+	if !cmd.Flags().Changed("repo") {
+		path := serveForumsFile
+		if path == "" {
+			path = filepath.Join(filepath.Dir(crypto.DefaultIdentityPath()), "forums.toml")
+		}
+		var file forumsFile
+		if _, err := toml.DecodeFile(path, &file); err == nil {
+			entries = nil
+			for _, f := range file.Forums {
+				if f.Repo == "" {
+					return nil, fmt.Errorf("%s: forum %q has no repo", path, f.Name)
+				}
+				if f.Name != "" {
+					entries = append(entries, f.Name+"="+f.Repo)
+				} else {
+					entries = append(entries, f.Repo)
+				}
+			}
+		} else if !os.IsNotExist(err) || serveForumsFile != "" {
+			return nil, fmt.Errorf("read forums file: %w", err)
+		}
+		if len(entries) == 0 {
+			entries = []string{"."}
+		}
 	}
 
-	srv := api.New(servePort, absRepo, r, id)
-	return srv.ListenAndServe(ui.StaticFS)
+	var specs []api.ForumConfig
+	for _, entry := range entries {
+		name, path, named := strings.Cut(entry, "=")
+		if !named || strings.ContainsAny(name, `/\`) {
+			name, path, named = "", entry, false
+		}
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return nil, fmt.Errorf("resolve repo path: %w", err)
+		}
+		if !named {
+			name = api.ForumName(abs)
+		}
+		specs = append(specs, api.ForumConfig{Name: name, RepoPath: abs})
+	}
+	return specs, nil
 }
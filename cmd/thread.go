@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/gosub/gitorum/internal/crypto"
+	"github.com/gosub/gitorum/internal/forum"
+	"github.com/gosub/gitorum/internal/repo"
+)
+
+var threadCmd = &cobra.Command{
+	Use:   "thread",
+	Short: "Move, rename and merge threads",
+	Long: `Move, rename and merge threads. Only the forum admin can do this.
+
+Each operation leaves an admin-signed redirect record (REDIRECT.toml) in the
+old thread directory, so old URLs and cross-links keep working. Post files
+move unchanged, so their signatures and parent hashes stay valid.
+
+Threads are addressed as <category>/<thread-slug>.`,
+}
+
+var threadMoveCmd = &cobra.Command{
+	Use:   "move <category>/<thread> <category>/<thread>",
+	Short: "Move a thread to another category or slug",
+	Args:  cobra.ExactArgs(2),
+	RunE:  runThreadMove,
+}
+
+var threadRenameCmd = &cobra.Command{
+	Use:   "rename <category>/<thread> <new-slug>",
+	Short: "Give a thread a new slug in the same category",
+	Args:  cobra.ExactArgs(2),
+	RunE:  runThreadRename,
+}
+
+var threadMergeCmd = &cobra.Command{
+	Use:   "merge <category>/<thread> <category>/<thread>",
+	Short: "Merge the posts of the first thread into the second",
+	Long: `Merge the posts of the first thread into the second. The first thread's
+root post becomes an ordinary post of the second, placed by its timestamp;
+its thread state and poll records are dropped.`,
+	Args: cobra.ExactArgs(2),
+	RunE: runThreadMerge,
+}
+
+var (
+	threadRepoPath string
+	threadIdentity string
+)
+
+func init() {
+	threadCmd.PersistentFlags().StringVar(&threadRepoPath, "repo", ".", "path to the forum git repository")
+	threadCmd.PersistentFlags().StringVar(&threadIdentity, "identity", "", "path to identity file (default: "+defaultIdentityHint()+")")
+
+	threadCmd.AddCommand(threadMoveCmd, threadRenameCmd, threadMergeCmd)
+	rootCmd.AddCommand(threadCmd)
+}
+
+func runThreadMove(cmd *cobra.Command, args []string) error {
+	toCat, toSlug, err := splitThreadRef(args[1])
+	if err != nil {
+		return err
+	}
+	return relocateThread(args[0], toCat, toSlug, false)
+}
+
+func runThreadRename(cmd *cobra.Command, args []string) error {
+	cat, _, err := splitThreadRef(args[0])
+	if err != nil {
+		return err
+	}
+	return relocateThread(args[0], cat, args[1], false)
+}
+
+func runThreadMerge(cmd *cobra.Command, args []string) error {
+	toCat, toSlug, err := splitThreadRef(args[1])
+	if err != nil {
+		return err
+	}
+	return relocateThread(args[0], toCat, toSlug, true)
+}
+
+// relocateThread moves the thread ref to toCat/toSlug, or merges it into
+// that thread, and commits the result with a redirect record.
+func relocateThread(ref, toCat, toSlug string, merge bool) error {
+	cat, slug, err := splitThreadRef(ref)
+	if err != nil {
+		return err
+	}
+	r, id, err := openThreadRepo()
+	if err != nil {
+		return err
+	}
+
+	var plan *forum.ThreadRelocation
+	var msg string
+	if merge {
+		plan, err = forum.PlanMerge(id, r.Path, cat, slug, toCat, toSlug)
+		msg = fmt.Sprintf("thread: merge %s/%s into %s/%s", cat, slug, toCat, toSlug)
+	} else {
+		plan, err = forum.PlanMove(id, r.Path, cat, slug, toCat, toSlug)
+		msg = fmt.Sprintf("thread: move %s/%s to %s/%s", cat, slug, toCat, toSlug)
+	}
+	if err != nil {
+		return err
+	}
+	writes := map[string][]byte{plan.RedirectPath: plan.Redirect.Format()}
+	if err := r.CommitMoves(id, msg, plan.Moves, writes); err != nil {
+		return fmt.Errorf("commit: %w", err)
+	}
+	if merge {
+		fmt.Printf("Merged %s/%s into %s/%s\n", cat, slug, toCat, toSlug)
+	} else {
+		fmt.Printf("Moved %s/%s to %s/%s\n", cat, slug, toCat, toSlug)
+	}
+	pushOrWarn(r)
+	return nil
+}
+
+// openThreadRepo opens the repository and the identity, checking that the
+// identity is the forum admin.
+func openThreadRepo() (*repo.Repo, *crypto.Identity, error) {
+	id, err := loadIdentity(threadIdentity, threadRepoPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("load identity: %w", err)
+	}
+	r, err := repo.Open(threadRepoPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("open repo: %w", err)
+	}
+	meta, err := r.ReadMeta()
+	if err != nil {
+		return nil, nil, fmt.Errorf("read meta: %w", err)
+	}
+	if id.PublicKey != meta.AdminPubkey {
+		return nil, nil, fmt.Errorf("only the forum admin can move or merge threads")
+	}
+	return r, id, nil
+}
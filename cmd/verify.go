@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/gosub/gitorum/internal/repo"
+)
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Check the signature of every commit in the forum history",
+	Long: `Check the signature of every commit reachable from HEAD against the keys
+in keys/, newest first. gitorum signs the commits it makes with your
+identity's key in the SSH signature format git uses with gpg.format=ssh.
+Each commit is reported as:
+
+  valid     signed with the key keys/ has for its committer
+  unknown   a good signature, but not by the committer's key
+  invalid   a signature that does not verify
+  unsigned  no signature, e.g. a commit made with plain git
+
+With --allowed-signers, print the keys in keys/ as a git allowed signers
+file instead, so plain git can verify the same commits:
+
+  gitorum verify --allowed-signers > signers
+  git -c gpg.ssh.allowedSignersFile=signers log --show-signature`,
+	Args: cobra.NoArgs,
+	RunE: runVerify,
+}
+
+var (
+	verifyRepoPath       string
+	verifyAllowedSigners bool
+	verifyFailing        bool
+)
+
+func init() {
+	verifyCmd.Flags().StringVar(&verifyRepoPath, "repo", ".", "path to the forum git repository")
+	verifyCmd.Flags().BoolVar(&verifyAllowedSigners, "allowed-signers", false, "print an allowed signers file for git instead")
+	verifyCmd.Flags().BoolVar(&verifyFailing, "failing", false, "only list commits that are not validly signed")
+
+	rootCmd.AddCommand(verifyCmd)
+}
+
+func runVerify(cmd *cobra.Command, args []string) error {
+	r, err := repo.Open(verifyRepoPath)
+	if err != nil {
+		return fmt.Errorf("open repo: %w", err)
+	}
+	if verifyAllowedSigners {
+		signers, err := r.AllowedSigners()
+		if err != nil {
+			return err
+		}
+		fmt.Print(signers)
+		return nil
+	}
+
+	commits, err := r.VerifyCommits()
+	if err != nil {
+		return err
+	}
+	counts := map[repo.CommitSigStatus]int{}
+	for _, c := range commits {
+		counts[c.Status]++
+		if verifyFailing && c.Status == repo.CommitSigValid {
+			continue
+		}
+		note := ""
+		switch {
+		case c.Status == repo.CommitSigUnknown && c.Signer != "":
+			note = "  (signed by @" + c.Signer + ")"
+		case c.Status == repo.CommitSigInvalid:
+			note = "  (" + c.Err + ")"
+		}
+		fmt.Printf("%s %-8s @%-16s %s%s\n", c.Hash[:8], c.Status, c.Committer, c.Subject, note)
+	}
+	fmt.Printf("\n%d commits: %d valid, %d unknown, %d invalid, %d unsigned\n", len(commits),
+		counts[repo.CommitSigValid], counts[repo.CommitSigUnknown], counts[repo.CommitSigInvalid], counts[repo.CommitUnsigned])
+	return nil
+}
@@ -0,0 +1,196 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/gosub/gitorum/internal/crypto"
+	"github.com/gosub/gitorum/internal/forum"
+	"github.com/gosub/gitorum/internal/repo"
+)
+
+var vouchCmd = &cobra.Command{
+	Use:   "vouch",
+	Short: "Vouch for other users' keys and inspect your web of trust",
+	Long: `Add, withdraw and list your vouches. Your vouches live in
+vouches/<username>.toml and are signed with your key; each one states that a
+public key belongs to a user.
+
+Keys you vouch for, and keys vouched for by them up to --hops vouches away,
+form your web of trust: the web UI marks posts signed with them as vouched
+for even when the admin has not approved the key. The forum can also
+approve join requests automatically once enough approved users vouch for
+them (see 'gitorum config --auto-approve-vouches').`,
+}
+
+var vouchAddCmd = &cobra.Command{
+	Use:   "add <username>",
+	Short: "Vouch for a user's key",
+	Long: `Vouch for the key of a user. Without --pubkey, the key is taken from
+keys/ or, failing that, from the user's pending join request. Vouching for
+a user again replaces the earlier vouch.`,
+	Args: cobra.ExactArgs(1),
+	RunE: runVouchAdd,
+}
+
+var vouchRemoveCmd = &cobra.Command{
+	Use:   "remove <username>",
+	Short: "Withdraw your vouch for a user",
+	Args:  cobra.ExactArgs(1),
+	RunE:  runVouchRemove,
+}
+
+var vouchListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Print your vouches and the keys in your web of trust",
+	Args:  cobra.NoArgs,
+	RunE:  runVouchList,
+}
+
+var (
+	vouchRepoPath string
+	vouchIdentity string
+	vouchPubKey   string
+	vouchNote     string
+	vouchHops     int
+)
+
+func init() {
+	vouchCmd.PersistentFlags().StringVar(&vouchRepoPath, "repo", ".", "path to the forum git repository")
+	vouchCmd.PersistentFlags().StringVar(&vouchIdentity, "identity", "", "path to identity file (default: "+defaultIdentityHint()+")")
+	vouchAddCmd.Flags().StringVar(&vouchPubKey, "pubkey", "", "base64 public key to vouch for (default: the user's known key)")
+	vouchAddCmd.Flags().StringVar(&vouchNote, "note", "", "how you know the key is theirs")
+	vouchListCmd.Flags().IntVar(&vouchHops, "hops", forum.DefaultTrustHops, "how many vouches away keys are still trusted")
+
+	vouchCmd.AddCommand(vouchAddCmd, vouchRemoveCmd, vouchListCmd)
+	rootCmd.AddCommand(vouchCmd)
+}
+
+func runVouchAdd(cmd *cobra.Command, args []string) error {
+	username := args[0]
+	r, id, list, err := openVouchList()
+	if err != nil {
+		return err
+	}
+	pubkey := strings.TrimSpace(vouchPubKey)
+	if pubkey == "" {
+		for _, dir := range []string{"keys", "requests"} {
+			if data, err := os.ReadFile(filepath.Join(r.Path, dir, username+".pub")); err == nil {
+				pubkey = strings.TrimSpace(string(data))
+				break
+			}
+		}
+	}
+	if pubkey == "" {
+		return fmt.Errorf("no key known for @%s; give it with --pubkey", username)
+	}
+
+	v := forum.Vouch{
+		Username: username,
+		PubKey:   pubkey,
+		Note:     strings.TrimSpace(vouchNote),
+		Since:    time.Now().UTC().Format(time.RFC3339),
+	}
+	if err := commitVouchList(r, id, list.With(v), "vouch: @"+username); err != nil {
+		return err
+	}
+	fmt.Printf("You vouch for @%s (%s)\n", username, crypto.Fingerprint(pubkey))
+	pushOrWarn(r)
+	return nil
+}
+
+func runVouchRemove(cmd *cobra.Command, args []string) error {
+	username := args[0]
+	r, id, list, err := openVouchList()
+	if err != nil {
+		return err
+	}
+	vouches, found := list.Without(username)
+	if !found {
+		return fmt.Errorf("you do not vouch for @%s", username)
+	}
+	if err := commitVouchList(r, id, vouches, "vouch: withdraw @"+username); err != nil {
+		return err
+	}
+	fmt.Printf("Withdrew your vouch for @%s\n", username)
+	pushOrWarn(r)
+	return nil
+}
+
+func runVouchList(cmd *cobra.Command, args []string) error {
+	r, id, list, err := openVouchList()
+	if err != nil {
+		return err
+	}
+	if len(list.Vouches) == 0 {
+		fmt.Println("You vouch for nobody.")
+		return nil
+	}
+	fmt.Println("Your vouches:")
+	for _, v := range list.Vouches {
+		line := fmt.Sprintf("  @%-16s %s  since %s", v.Username, crypto.Fingerprint(v.PubKey), v.Since)
+		if v.Note != "" {
+			line += "  " + v.Note
+		}
+		fmt.Println(line)
+	}
+
+	w := forum.BuildWebOfTrust(filepath.Join(r.Path, forum.VouchesDir), id.Username, id.PublicKey, vouchHops)
+	var keys []*forum.TrustedKey
+	for _, k := range w.Keys {
+		if len(k.Path) > 1 {
+			keys = append(keys, k)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if len(keys[i].Path) != len(keys[j].Path) {
+			return len(keys[i].Path) < len(keys[j].Path)
+		}
+		return keys[i].Username < keys[j].Username
+	})
+	if len(keys) > 0 {
+		fmt.Printf("Trusted through others (up to %d hops):\n", vouchHops)
+		for _, k := range keys {
+			fmt.Printf("  @%-16s %s  via @%s\n", k.Username, crypto.Fingerprint(k.PubKey), strings.Join(k.Path[:len(k.Path)-1], " → @"))
+		}
+	}
+	for _, e := range w.Errors {
+		fmt.Fprintf(os.Stderr, "warning: %s\n", e)
+	}
+	return nil
+}
+
+// openVouchList opens the repository and the identity's current vouch list.
+func openVouchList() (*repo.Repo, *crypto.Identity, *forum.VouchList, error) {
+	id, err := loadIdentity(vouchIdentity, vouchRepoPath)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("load identity: %w", err)
+	}
+	r, err := repo.Open(vouchRepoPath)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("open repo: %w", err)
+	}
+	path := filepath.Join(r.Path, filepath.FromSlash(forum.VouchPath(id.Username)))
+	list, err := forum.LoadVouchList(path, id.Username, id.PublicKey)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return r, id, list, nil
+}
+
+func commitVouchList(r *repo.Repo, id *crypto.Identity, vouches []forum.Vouch, msg string) error {
+	list, err := forum.SignVouchList(id, vouches)
+	if err != nil {
+		return fmt.Errorf("sign vouches: %w", err)
+	}
+	if err := r.CommitFile(id, filepath.FromSlash(forum.VouchPath(id.Username)), list.Format(), msg); err != nil {
+		return fmt.Errorf("commit vouches: %w", err)
+	}
+	return nil
+}
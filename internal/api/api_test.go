@@ -3,18 +3,30 @@ package api_test
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
 	"testing"
+	"time"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
 
 	"github.com/gosub/gitorum/internal/api"
 	"github.com/gosub/gitorum/internal/crypto"
 	"github.com/gosub/gitorum/internal/forum"
+	"github.com/gosub/gitorum/internal/lan"
+	"github.com/gosub/gitorum/internal/local"
 	"github.com/gosub/gitorum/internal/repo"
 	"github.com/gosub/gitorum/internal/ui"
+	"github.com/gosub/gitorum/internal/webhook"
 )
 
 // setupForum creates a full forum fixture and returns a ready-to-use Server.
@@ -678,6 +690,79 @@ func TestHandleCreateCategory_DuplicateSlug(t *testing.T) {
 	}
 }
 
+func TestHandleCreateCategory_Subcategory(t *testing.T) {
+	srv := setupForum(t)
+	for _, req := range []api.CreateCategoryRequest{
+		{Slug: "projects", Name: "Projects"},
+		{Slug: "zeta", Name: "Zeta", Parent: "projects"},
+		{Slug: "alpha", Name: "Alpha", Parent: "projects", Order: 1},
+		{Slug: "first", Name: "First", Parent: "projects", Order: -1},
+	} {
+		if w := hitJSON(t, srv, "POST", "/api/categories", req); w.Code != http.StatusCreated {
+			t.Fatalf("create %s: status %d: %s", req.Slug, w.Code, w.Body)
+		}
+	}
+	w := hitJSON(t, srv, "POST", "/api/threads", api.NewThreadRequest{Category: "projects/zeta", Slug: "kickoff", Body: "# Kickoff"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("new thread in subcategory: status %d: %s", w.Code, w.Body)
+	}
+
+	var cats api.CategoriesResponse
+	decodeJSON(t, hit(t, srv, "GET", "/api/categories"), &cats)
+	var projects *api.CategorySummary
+	for i, c := range cats.Categories {
+		if strings.Contains(c.Slug, "/") {
+			t.Errorf("subcategory %q listed at the top level", c.Slug)
+		}
+		if c.Slug == "projects" {
+			projects = &cats.Categories[i]
+		}
+	}
+	if projects == nil {
+		t.Fatal("projects not listed")
+	}
+	var children []string
+	for _, c := range projects.Children {
+		children = append(children, c.Slug)
+	}
+	if got := strings.Join(children, ","); got != "projects/first,projects/zeta,projects/alpha" {
+		t.Errorf("children: got %s", got)
+	}
+	if projects.ThreadCount != 0 || projects.TotalThreads != 1 {
+		t.Errorf("counts: own %d, total %d", projects.ThreadCount, projects.TotalThreads)
+	}
+
+	// Subcategory slugs contain a slash, escaped as %2F in API paths.
+	var threads api.ThreadsResponse
+	decodeJSON(t, hit(t, srv, "GET", "/api/categories/projects%2Fzeta/threads"), &threads)
+	if threads.CategoryName != "Zeta" || len(threads.Threads) != 1 {
+		t.Errorf("subcategory threads: got %+v", threads)
+	}
+	if len(threads.Parents) != 1 || threads.Parents[0].Name != "Projects" {
+		t.Errorf("parents: got %+v", threads.Parents)
+	}
+	decodeJSON(t, hit(t, srv, "GET", "/api/categories/projects/threads"), &threads)
+	if len(threads.Subcategories) != 3 || threads.Subcategories[1].TotalThreads != 1 {
+		t.Errorf("subcategories: got %+v", threads.Subcategories)
+	}
+	if w := hit(t, srv, "GET", "/api/threads/projects%2Fzeta/kickoff"); w.Code != http.StatusOK {
+		t.Errorf("thread in subcategory: status %d", w.Code)
+	}
+
+	w = hitJSON(t, srv, "POST", "/api/categories", api.CreateCategoryRequest{Slug: "x", Name: "X", Parent: "missing"})
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("missing parent: status %d, want 400", w.Code)
+	}
+	w = hitJSON(t, srv, "POST", "/api/categories", api.CreateCategoryRequest{Slug: "x", Name: "X", Parent: "../etc"})
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("invalid parent: status %d, want 400", w.Code)
+	}
+	w = hitJSON(t, srv, "POST", "/api/categories", api.CreateCategoryRequest{Slug: "kickoff", Name: "K", Parent: "projects/zeta"})
+	if w.Code != http.StatusConflict {
+		t.Errorf("clash with thread: status %d, want 409", w.Code)
+	}
+}
+
 // ---- join requests ---------------------------------------------------------
 
 // setupForumWithRequest creates a forum with a pending join request from bob.
@@ -788,3 +873,2063 @@ func TestHandleCreateCategory_InvalidSlug(t *testing.T) {
 		}
 	}
 }
+
+// ---- polls -----------------------------------------------------------------
+
+func TestHandleNewThread_PollAndVote(t *testing.T) {
+	srv := setupForum(t)
+	body := map[string]any{
+		"category":     "general",
+		"slug":         "lunch",
+		"body":         "# Lunch?\n\nPick one.",
+		"poll_options": []string{"Pizza", "Sushi"},
+	}
+	w := hitJSON(t, srv, "POST", "/api/threads", body)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create poll: status %d\nbody: %s", w.Code, w.Body.String())
+	}
+
+	w = hitJSON(t, srv, "POST", "/api/threads/general/lunch/vote", map[string]string{"option": "Sushi"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("vote: status %d\nbody: %s", w.Code, w.Body.String())
+	}
+
+	w = hit(t, srv, "GET", "/api/threads/general/lunch")
+	var thread api.ThreadResponse
+	decodeJSON(t, w, &thread)
+	if thread.Poll == nil {
+		t.Fatal("poll missing from thread response")
+	}
+	if len(thread.Posts) != 1 {
+		t.Errorf("Posts: got %d, want 1 (votes are not posts)", len(thread.Posts))
+	}
+	if thread.Poll.Options[1].Text != "Sushi" || thread.Poll.Options[1].Votes != 1 {
+		t.Errorf("Options: got %+v", thread.Poll.Options)
+	}
+	if thread.Poll.MyVote != "Sushi" {
+		t.Errorf("MyVote: got %q", thread.Poll.MyVote)
+	}
+	if len(thread.Poll.Voters) != 1 || thread.Poll.Voters[0].Voter != "alice" || thread.Poll.Voters[0].Signature == "" {
+		t.Errorf("Voters: got %+v", thread.Poll.Voters)
+	}
+
+	// The thread list flags the poll.
+	w = hit(t, srv, "GET", "/api/categories/general/threads")
+	var list api.ThreadsResponse
+	decodeJSON(t, w, &list)
+	for _, th := range list.Threads {
+		if th.Slug == "lunch" && !th.IsPoll {
+			t.Error("IsPoll: expected true for the poll thread")
+		}
+	}
+}
+
+func TestHandleVote_Errors(t *testing.T) {
+	srv := setupForum(t)
+	closed := map[string]any{
+		"category":     "general",
+		"slug":         "closed",
+		"body":         "Closed poll",
+		"poll_options": []string{"A", "B"},
+		"poll_closes":  "2000-01-01T00:00:00Z",
+	}
+	if w := hitJSON(t, srv, "POST", "/api/threads", closed); w.Code != http.StatusCreated {
+		t.Fatalf("create poll: status %d\nbody: %s", w.Code, w.Body.String())
+	}
+
+	cases := []struct {
+		path, option string
+		want         int
+	}{
+		{"/api/threads/general/hello-world/vote", "A", http.StatusBadRequest}, // not a poll
+		{"/api/threads/general/closed/vote", "C", http.StatusBadRequest},      // unknown option
+		{"/api/threads/general/closed/vote", "A", http.StatusConflict},        // closed
+		{"/api/threads/general/nope/vote", "A", http.StatusNotFound},
+	}
+	for _, tc := range cases {
+		w := hitJSON(t, srv, "POST", tc.path, map[string]string{"option": tc.option})
+		if w.Code != tc.want {
+			t.Errorf("%s %q: expected %d, got %d", tc.path, tc.option, tc.want, w.Code)
+		}
+	}
+}
+
+func TestHandleVote_LockedAndBanned(t *testing.T) {
+	srv := setupForum(t)
+	poll := map[string]any{
+		"category":     "general",
+		"slug":         "lunch",
+		"body":         "Lunch?",
+		"poll_options": []string{"Pizza", "Sushi"},
+	}
+	if w := hitJSON(t, srv, "POST", "/api/threads", poll); w.Code != http.StatusCreated {
+		t.Fatalf("create poll: status %d\nbody: %s", w.Code, w.Body.String())
+	}
+	bob, err := crypto.Generate("bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(srv.RepoPath, "keys", "bob.pub"), []byte(bob.PublicKey+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r, err := repo.Open(srv.RepoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobSrv := api.New(8080, srv.RepoPath, r, bob)
+	vote := map[string]string{"option": "Pizza"}
+
+	on, off := true, false
+	setThreadState(t, srv, api.ThreadStateRequest{Category: "general", Thread: "lunch", Locked: &on})
+	if w := hitJSON(t, bobSrv, "POST", "/api/threads/general/lunch/vote", vote); w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "locked") {
+		t.Errorf("locked vote: status %d: %s", w.Code, w.Body)
+	}
+	setThreadState(t, srv, api.ThreadStateRequest{Category: "general", Thread: "lunch", Locked: &off})
+
+	if w := hitJSON(t, srv, "POST", "/api/admin/ban", api.BanRequest{Username: "bob"}); w.Code != http.StatusOK {
+		t.Fatalf("ban: status %d: %s", w.Code, w.Body)
+	}
+	if w := hitJSON(t, bobSrv, "POST", "/api/threads/general/lunch/vote", vote); w.Code != http.StatusForbidden {
+		t.Errorf("banned vote: status %d, want 403", w.Code)
+	}
+}
+
+func TestHandleNewThread_InvalidPoll(t *testing.T) {
+	srv := setupForum(t)
+	body := map[string]any{
+		"category":     "general",
+		"slug":         "bad-poll",
+		"body":         "q",
+		"poll_options": []string{"only"},
+	}
+	if w := hitJSON(t, srv, "POST", "/api/threads", body); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", w.Code)
+	}
+}
+
+// ---- read state ------------------------------------------------------------
+
+func TestUnreadCounts_MarkRead(t *testing.T) {
+	srv := setupForum(t)
+
+	var cats api.CategoriesResponse
+	decodeJSON(t, hit(t, srv, "GET", "/api/categories"), &cats)
+	if len(cats.Categories) != 1 || cats.Categories[0].UnreadCount != 2 {
+		t.Fatalf("categories before read: %+v", cats.Categories)
+	}
+
+	var thread api.ThreadResponse
+	decodeJSON(t, hit(t, srv, "GET", "/api/threads/general/hello-world"), &thread)
+	for _, p := range thread.Posts {
+		if !p.Unread {
+			t.Errorf("post %s read before reading", p.Filename)
+		}
+	}
+
+	// Mark only the root post read.
+	w := hitJSON(t, srv, "POST", "/api/threads/general/hello-world/read", api.MarkReadRequest{Filenames: []string{forum.RootFilename}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("mark read: status %d: %s", w.Code, w.Body)
+	}
+	var threads api.ThreadsResponse
+	decodeJSON(t, hit(t, srv, "GET", "/api/categories/general/threads"), &threads)
+	if threads.Threads[0].UnreadCount != 1 {
+		t.Errorf("UnreadCount after reading root: got %d, want 1", threads.Threads[0].UnreadCount)
+	}
+
+	// An empty body marks the whole thread read.
+	if w := hit(t, srv, "POST", "/api/threads/general/hello-world/read"); w.Code != http.StatusOK {
+		t.Fatalf("mark read: status %d: %s", w.Code, w.Body)
+	}
+	decodeJSON(t, hit(t, srv, "GET", "/api/categories"), &cats)
+	if cats.Categories[0].UnreadCount != 0 {
+		t.Errorf("category UnreadCount after reading: got %d", cats.Categories[0].UnreadCount)
+	}
+	var reread api.ThreadResponse
+	decodeJSON(t, hit(t, srv, "GET", "/api/threads/general/hello-world"), &reread)
+	for _, p := range reread.Posts {
+		if p.Unread {
+			t.Errorf("post %s unread after reading", p.Filename)
+		}
+	}
+
+	// Marking a post read again changes nothing.
+	hitJSON(t, srv, "POST", "/api/threads/general/hello-world/read", api.MarkReadRequest{Filenames: []string{forum.RootFilename}})
+	decodeJSON(t, hit(t, srv, "GET", "/api/categories"), &cats)
+	if cats.Categories[0].UnreadCount != 0 {
+		t.Errorf("UnreadCount after marking a read post: got %d", cats.Categories[0].UnreadCount)
+	}
+}
+
+func TestUnread_SyncedOlderReply(t *testing.T) {
+	srv := setupForum(t)
+	hit(t, srv, "POST", "/api/threads/general/hello-world/read")
+
+	// A reply pulled from a peer can be named before posts already read.
+	bob, err := crypto.Generate("bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	threadDir := filepath.Join(srv.RepoPath, "general", "hello-world")
+	name := writeReply(t, srv, bob, forum.RootFilename, "written offline")
+	if err := os.Rename(filepath.Join(threadDir, name), filepath.Join(threadDir, "1000000000000_offline.md")); err != nil {
+		t.Fatal(err)
+	}
+	var threads api.ThreadsResponse
+	decodeJSON(t, hit(t, srv, "GET", "/api/categories/general/threads"), &threads)
+	if threads.Threads[0].UnreadCount != 1 {
+		t.Errorf("UnreadCount after sync: got %d, want 1", threads.Threads[0].UnreadCount)
+	}
+	var thread api.ThreadResponse
+	decodeJSON(t, hit(t, srv, "GET", "/api/threads/general/hello-world"), &thread)
+	for _, p := range thread.Posts {
+		if p.Unread != (p.Filename == "1000000000000_offline.md") {
+			t.Errorf("post %s: unread %v", p.Filename, p.Unread)
+		}
+	}
+}
+
+func TestMarkRead_UnknownFilename(t *testing.T) {
+	srv := setupForum(t)
+	for _, f := range []string{"zzz", "../../other.md", "0000_root.md.tomb"} {
+		w := hitJSON(t, srv, "POST", "/api/threads/general/hello-world/read", api.MarkReadRequest{Filenames: []string{forum.RootFilename, f}})
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%q: status %d, want 400", f, w.Code)
+		}
+	}
+	var threads api.ThreadsResponse
+	decodeJSON(t, hit(t, srv, "GET", "/api/categories/general/threads"), &threads)
+	if threads.Threads[0].UnreadCount != 2 {
+		t.Errorf("UnreadCount after rejected requests: got %d, want 2", threads.Threads[0].UnreadCount)
+	}
+}
+
+func TestMarkRead_ThreadNotFound(t *testing.T) {
+	srv := setupForum(t)
+	w := hit(t, srv, "POST", "/api/threads/general/no-such-thread/read")
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status: got %d, want 404", w.Code)
+	}
+}
+
+func TestMarkRead_InvalidPath(t *testing.T) {
+	srv := setupForum(t)
+	for _, p := range []string{
+		"/api/threads/general%2F..%2Fgeneral/hello-world/read",
+		"/api/threads/..%2F..%2Foutside/x/read",
+		"/api/threads/general/..%2Fhello-world/read",
+		"/api/threads/General/hello-world/read",
+		"/api/threads/general/hello.world/read",
+	} {
+		if w := hit(t, srv, "POST", p); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", p, w.Code)
+		}
+	}
+}
+
+func TestOwnReply_IsRead(t *testing.T) {
+	srv := setupForum(t)
+	hit(t, srv, "POST", "/api/threads/general/hello-world/read")
+
+	w := hitJSON(t, srv, "POST", "/api/threads/general/hello-world/reply", api.ReplyRequest{Body: "my own reply"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("reply: status %d: %s", w.Code, w.Body)
+	}
+	var threads api.ThreadsResponse
+	decodeJSON(t, hit(t, srv, "GET", "/api/categories/general/threads"), &threads)
+	if threads.Threads[0].UnreadCount != 0 {
+		t.Errorf("own reply counted as unread: %d", threads.Threads[0].UnreadCount)
+	}
+}
+
+func TestWhatsNew(t *testing.T) {
+	srv := setupForum(t)
+
+	var resp api.WhatsNewResponse
+	decodeJSON(t, hit(t, srv, "GET", "/api/new"), &resp)
+	if len(resp.Threads) != 1 {
+		t.Fatalf("threads: got %d, want 1", len(resp.Threads))
+	}
+	got := resp.Threads[0]
+	if got.Category != "general" || got.CategoryName != "General" || got.Slug != "hello-world" || got.UnreadCount != 2 {
+		t.Errorf("unexpected entry: %+v", got)
+	}
+
+	if w := hit(t, srv, "POST", "/api/new/read"); w.Code != http.StatusOK {
+		t.Fatalf("mark all read: status %d: %s", w.Code, w.Body)
+	}
+	decodeJSON(t, hit(t, srv, "GET", "/api/new"), &resp)
+	if len(resp.Threads) != 0 {
+		t.Errorf("threads after mark all read: %+v", resp.Threads)
+	}
+}
+
+// ---- notifications ---------------------------------------------------------
+
+// writeReply signs body as id, with parent set to the hash of the given file
+// in the thread, and writes it to the hello-world thread without committing.
+func writeReply(t *testing.T, srv *api.Server, id *crypto.Identity, parentFile, body string) string {
+	t.Helper()
+	threadDir := filepath.Join(srv.RepoPath, "general", "hello-world")
+	parent, err := os.ReadFile(filepath.Join(threadDir, parentFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	post, err := forum.SignPost(id, forum.PostHash(parent), body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	post.Filename = forum.NewPostFilename(body)
+	if err := os.WriteFile(filepath.Join(threadDir, post.Filename), post.Format(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return post.Filename
+}
+
+func TestNotifications(t *testing.T) {
+	srv := setupForum(t)
+	bob, err := crypto.Generate("bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(srv.RepoPath, "keys", "bob.pub"), []byte(bob.PublicKey+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	mallory, err := crypto.Generate("mallory") // no key in keys/
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var thread api.ThreadResponse
+	decodeJSON(t, hit(t, srv, "GET", "/api/threads/general/hello-world"), &thread)
+	aliceReply := thread.Posts[1].Filename
+
+	mention := writeReply(t, srv, bob, forum.RootFilename, "what do you think, @alice?")
+	reply := writeReply(t, srv, bob, aliceReply, "I disagree with this reply")
+	threadReply := writeReply(t, srv, bob, forum.RootFilename, "just chiming in")
+	writeReply(t, srv, mallory, forum.RootFilename, "@alice unsigned spam")
+
+	var resp api.NotificationsResponse
+	decodeJSON(t, hit(t, srv, "GET", "/api/notifications"), &resp)
+	if resp.Unseen != 3 || len(resp.Notifications) != 3 {
+		t.Fatalf("notifications: unseen %d, got %+v", resp.Unseen, resp.Notifications)
+	}
+	kinds := make(map[string]string)
+	for _, n := range resp.Notifications {
+		kinds[n.Filename] = n.Kind
+		if n.Author != "bob" || n.Category != "general" || n.Thread != "hello-world" || n.Title != "Hello" {
+			t.Errorf("unexpected notification: %+v", n)
+		}
+	}
+	want := map[string]string{mention: "mention", reply: "reply", threadReply: "thread_reply"}
+	for file, kind := range want {
+		if kinds[file] != kind {
+			t.Errorf("%s: kind %q, want %q", file, kinds[file], kind)
+		}
+	}
+
+	// Seen state: mark one, then all.
+	w := hitJSON(t, srv, "POST", "/api/notifications/seen", api.NotificationsSeenRequest{IDs: []string{"general/hello-world/" + mention}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("seen: status %d: %s", w.Code, w.Body)
+	}
+	decodeJSON(t, hit(t, srv, "GET", "/api/notifications"), &resp)
+	if resp.Unseen != 2 || len(resp.Notifications) != 3 {
+		t.Errorf("after marking one seen: unseen %d, total %d", resp.Unseen, len(resp.Notifications))
+	}
+	hit(t, srv, "POST", "/api/notifications/seen")
+	decodeJSON(t, hit(t, srv, "GET", "/api/notifications"), &resp)
+	if resp.Unseen != 0 {
+		t.Errorf("after marking all seen: unseen %d", resp.Unseen)
+	}
+
+	// My own posts never notify me.
+	hitJSON(t, srv, "POST", "/api/threads/general/hello-world/reply", api.ReplyRequest{Body: "@alice note to self", Parent: mention})
+	decodeJSON(t, hit(t, srv, "GET", "/api/notifications"), &resp)
+	if resp.Unseen != 0 || len(resp.Notifications) != 3 {
+		t.Errorf("own post notified: unseen %d, total %d", resp.Unseen, len(resp.Notifications))
+	}
+}
+
+func TestHandleReply_Parent(t *testing.T) {
+	srv := setupForum(t)
+	var thread api.ThreadResponse
+	decodeJSON(t, hit(t, srv, "GET", "/api/threads/general/hello-world"), &thread)
+
+	w := hitJSON(t, srv, "POST", "/api/threads/general/hello-world/reply", api.ReplyRequest{Body: "nested", Parent: thread.Posts[1].Filename})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	w = hitJSON(t, srv, "POST", "/api/threads/general/hello-world/reply", api.ReplyRequest{Body: "x", Parent: "../../GITORUM.toml"})
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("traversal parent: status %d, want 400", w.Code)
+	}
+	w = hitJSON(t, srv, "POST", "/api/threads/general/hello-world/reply", api.ReplyRequest{Body: "x", Parent: "123_missing.md"})
+	if w.Code != http.StatusNotFound {
+		t.Errorf("missing parent: status %d, want 404", w.Code)
+	}
+}
+
+// ---- webhooks --------------------------------------------------------------
+
+// hookReceiver is an httptest server that collects webhook payloads.
+type hookReceiver struct {
+	*httptest.Server
+	events chan webhook.Event
+}
+
+func newHookReceiver(t *testing.T, secret string) *hookReceiver {
+	t.Helper()
+	rc := &hookReceiver{events: make(chan webhook.Event, 10)}
+	rc.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if !webhook.Verify(secret, body, r.Header.Get("X-Gitorum-Signature")) {
+			t.Errorf("bad webhook signature")
+		}
+		var ev webhook.Event
+		if err := json.Unmarshal(body, &ev); err != nil {
+			t.Errorf("webhook payload: %v", err)
+		}
+		rc.events <- ev
+	}))
+	t.Cleanup(rc.Close)
+	return rc
+}
+
+func (rc *hookReceiver) next(t *testing.T) webhook.Event {
+	t.Helper()
+	select {
+	case ev := <-rc.events:
+		return ev
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for webhook")
+		return webhook.Event{}
+	}
+}
+
+func writeWebhookConfig(t *testing.T, repoPath, url, secret string) {
+	t.Helper()
+	dir := filepath.Join(repoPath, ".git", "gitorum")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	cfg := fmt.Sprintf("[[webhook]]\nurl = %q\nsecret = %q\n", url, secret)
+	if err := os.WriteFile(filepath.Join(dir, "webhooks.toml"), []byte(cfg), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWebhooks_LocalReply(t *testing.T) {
+	srv := setupForum(t)
+	rc := newHookReceiver(t, "s3cret")
+	writeWebhookConfig(t, srv.RepoPath, rc.URL, "s3cret")
+
+	w := hitJSON(t, srv, "POST", "/api/threads/general/hello-world/reply", api.ReplyRequest{Body: "Webhook   me\nplease"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("reply: status %d: %s", w.Code, w.Body)
+	}
+	ev := rc.next(t)
+	if ev.Source != webhook.SourceLocal || ev.Category != "general" || ev.Thread != "hello-world" ||
+		ev.Author != "alice" || ev.SigStatus != "valid" || ev.Excerpt != "Webhook me please" || ev.Forum != "Test Forum" {
+		t.Errorf("event: %+v", ev)
+	}
+
+	// The delivery shows up in the log; the secret does not.
+	deadline := time.Now().Add(5 * time.Second)
+	var resp api.WebhooksResponse
+	for {
+		decodeJSON(t, hit(t, srv, "GET", "/api/webhooks"), &resp)
+		if len(resp.Deliveries) == 1 && resp.Deliveries[0].Delivered || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(resp.Webhooks) != 1 || resp.Webhooks[0].URL != rc.URL || !resp.Webhooks[0].Signed {
+		t.Errorf("webhooks: %+v", resp.Webhooks)
+	}
+	if len(resp.Deliveries) != 1 || !resp.Deliveries[0].Delivered || resp.Deliveries[0].Event.Filename != ev.Filename {
+		t.Errorf("deliveries: %+v", resp.Deliveries)
+	}
+}
+
+func TestWebhooks_PulledPosts(t *testing.T) {
+	srv := setupForum(t)
+	r, err := repo.Open(srv.RepoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Commit the fixture thread and publish it to a bare remote.
+	wt, err := r.Git().Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wt.AddGlob("general"); err != nil {
+		t.Fatal(err)
+	}
+	sig := &object.Signature{Name: "alice", Email: "alice@gitorum.local", When: time.Now()}
+	if _, err := wt.Commit("post: fixture", &gogit.CommitOptions{Author: sig, Committer: sig}); err != nil {
+		t.Fatal(err)
+	}
+	bare := t.TempDir()
+	if _, err := gogit.PlainInit(bare, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.AddRemote("origin", bare); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Push(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Bob clones, replies, and pushes.
+	bob, err := crypto.Generate("bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobDir := t.TempDir()
+	if _, err := gogit.PlainClone(bobDir, false, &gogit.CloneOptions{URL: bare}); err != nil {
+		t.Fatal(err)
+	}
+	bobRepo, err := repo.Open(bobDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := os.ReadFile(filepath.Join(bobDir, "general", "hello-world", forum.RootFilename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply, err := forum.SignPost(bob, forum.PostHash(root), "hello from bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply.Filename = forum.NewPostFilename(reply.Body)
+	if err := bobRepo.CommitPost(bob, filepath.Join("general", "hello-world", reply.Filename), reply.Format()); err != nil {
+		t.Fatal(err)
+	}
+	if err := bobRepo.Push(); err != nil {
+		t.Fatal(err)
+	}
+
+	rc := newHookReceiver(t, "k")
+	writeWebhookConfig(t, srv.RepoPath, rc.URL, "k")
+	if w := hit(t, srv, "GET", "/api/sync"); w.Code != http.StatusOK {
+		t.Fatalf("sync: status %d: %s", w.Code, w.Body)
+	}
+	ev := rc.next(t)
+	if ev.Source != webhook.SourcePull || ev.Filename != reply.Filename || ev.Author != "bob" || ev.SigStatus != "missing" {
+		t.Errorf("event: %+v", ev)
+	}
+	select {
+	case extra := <-rc.events:
+		t.Errorf("unexpected extra event: %+v", extra)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// ---- pagination ------------------------------------------------------------
+
+// setupSortFixture creates category "sorting" with threads a ("Zulu", no
+// replies), b ("Mike", two late replies) and c ("Alpha", one reply).
+func setupSortFixture(t *testing.T) *api.Server {
+	t.Helper()
+	srv := setupForum(t)
+	if w := hitJSON(t, srv, "POST", "/api/categories", api.CreateCategoryRequest{Slug: "sorting", Name: "Sorting"}); w.Code != http.StatusCreated {
+		t.Fatalf("create category: %d %s", w.Code, w.Body)
+	}
+	// Created c, b, a: whether or not a second boundary falls in between,
+	// newest first with ties broken by slug is a,b,c.
+	for _, th := range []struct{ slug, title string }{{"c", "Alpha"}, {"b", "Mike"}, {"a", "Zulu"}} {
+		w := hitJSON(t, srv, "POST", "/api/threads", api.NewThreadRequest{Category: "sorting", Slug: th.slug, Body: "# " + th.title})
+		if w.Code != http.StatusCreated {
+			t.Fatalf("new thread %s: %d %s", th.slug, w.Code, w.Body)
+		}
+	}
+	// Reply filenames carry their time, so these replies sort as recent activity.
+	for _, f := range []string{"b/4102444800001_bbbbbbb1.md", "b/4102444800002_bbbbbbb2.md", "c/4102444800000_cccccccc.md"} {
+		if err := os.WriteFile(filepath.Join(srv.RepoPath, "sorting", f), []byte("+++\n+++\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return srv
+}
+
+func threadSlugs(resp api.ThreadsResponse) string {
+	var slugs []string
+	for _, t := range resp.Threads {
+		slugs = append(slugs, t.Slug)
+	}
+	return strings.Join(slugs, ",")
+}
+
+func TestHandleThreads_Sort(t *testing.T) {
+	srv := setupSortFixture(t)
+	tests := []struct{ sort, want string }{
+		{"", "b,c,a"},
+		{"activity", "b,c,a"},
+		{"replies", "b,c,a"},
+		{"alpha", "c,b,a"},
+		{"newest", "a,b,c"}, // created in the same second: ties broken by slug
+	}
+	for _, tc := range tests {
+		var resp api.ThreadsResponse
+		decodeJSON(t, hit(t, srv, "GET", "/api/categories/sorting/threads?sort="+tc.sort), &resp)
+		if got := threadSlugs(resp); got != tc.want {
+			t.Errorf("sort=%q: got %s, want %s", tc.sort, got, tc.want)
+		}
+		if resp.Total != 3 || resp.NextCursor != "" {
+			t.Errorf("sort=%q: total %d, next %q", tc.sort, resp.Total, resp.NextCursor)
+		}
+	}
+}
+
+func TestHandleThreads_Cursor(t *testing.T) {
+	srv := setupSortFixture(t)
+
+	var first api.ThreadsResponse
+	decodeJSON(t, hit(t, srv, "GET", "/api/categories/sorting/threads?sort=alpha&limit=2"), &first)
+	if got := threadSlugs(first); got != "c,b" || first.NextCursor == "" || first.Total != 3 {
+		t.Fatalf("first page: %s, next %q, total %d", got, first.NextCursor, first.Total)
+	}
+	var second api.ThreadsResponse
+	decodeJSON(t, hit(t, srv, "GET", "/api/categories/sorting/threads?sort=alpha&limit=2&cursor="+first.NextCursor), &second)
+	if got := threadSlugs(second); got != "a" || second.NextCursor != "" {
+		t.Errorf("second page: %s, next %q", got, second.NextCursor)
+	}
+
+	// A cursor only makes sense for the sort mode that produced it.
+	if w := hit(t, srv, "GET", "/api/categories/sorting/threads?sort=replies&cursor="+first.NextCursor); w.Code != http.StatusBadRequest {
+		t.Errorf("cursor for another sort: status %d, want 400", w.Code)
+	}
+	for _, q := range []string{"limit=0", "limit=abc", "limit=100000", "sort=random", "cursor=!!!"} {
+		if w := hit(t, srv, "GET", "/api/categories/sorting/threads?"+q); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", q, w.Code)
+		}
+	}
+}
+
+func TestHandleThread_Cursor(t *testing.T) {
+	srv := setupForum(t)
+	for i := range 3 {
+		w := hitJSON(t, srv, "POST", "/api/threads/general/hello-world/reply", api.ReplyRequest{Body: fmt.Sprintf("reply %d", i)})
+		if w.Code != http.StatusCreated {
+			t.Fatalf("reply: %d %s", w.Code, w.Body)
+		}
+		time.Sleep(2 * time.Millisecond) // distinct filenames
+	}
+
+	var all []api.PostResponse
+	cursor := ""
+	for pages := 0; ; pages++ {
+		if pages > 5 {
+			t.Fatal("too many pages")
+		}
+		var resp api.ThreadResponse
+		decodeJSON(t, hit(t, srv, "GET", "/api/threads/general/hello-world?limit=2&cursor="+cursor), &resp)
+		if resp.TotalPosts != 5 {
+			t.Errorf("TotalPosts: got %d, want 5", resp.TotalPosts)
+		}
+		all = append(all, resp.Posts...)
+		if resp.NextCursor == "" {
+			break
+		}
+		cursor = resp.NextCursor
+	}
+	if len(all) != 5 || all[0].Filename != forum.RootFilename || all[4].Body != "reply 2" {
+		t.Errorf("paged posts: got %d, first %q, last %q", len(all), all[0].Filename, all[len(all)-1].Body)
+	}
+
+	if w := hit(t, srv, "GET", "/api/threads/general/hello-world?cursor=bm9wZQ"); w.Code != http.StatusBadRequest {
+		t.Errorf("unknown cursor: status %d, want 400", w.Code)
+	}
+}
+
+// ---- thread state ----------------------------------------------------------
+
+func setThreadState(t *testing.T, srv *api.Server, req api.ThreadStateRequest) api.ThreadState {
+	t.Helper()
+	w := hitJSON(t, srv, "POST", "/api/admin/thread-state", req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("thread-state: status %d: %s", w.Code, w.Body)
+	}
+	var st api.ThreadState
+	decodeJSON(t, w, &st)
+	return st
+}
+
+func TestHandleThreadState_Lock(t *testing.T) {
+	srv := setupForum(t)
+	on := true
+	st := setThreadState(t, srv, api.ThreadStateRequest{Category: "general", Thread: "hello-world", Locked: &on})
+	if !st.Locked || st.Pinned || st.ChangedBy != "alice" {
+		t.Errorf("state: got %+v", st)
+	}
+
+	// The admin may still reply; anyone else is refused.
+	if w := hitJSON(t, srv, "POST", "/api/threads/general/hello-world/reply", api.ReplyRequest{Body: "admin note"}); w.Code != http.StatusCreated {
+		t.Errorf("admin reply: status %d: %s", w.Code, w.Body)
+	}
+	bob, err := crypto.Generate("bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(srv.RepoPath, "keys", "bob.pub"), []byte(bob.PublicKey+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r, err := repo.Open(srv.RepoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobSrv := api.New(8080, srv.RepoPath, r, bob)
+	w := hitJSON(t, bobSrv, "POST", "/api/threads/general/hello-world/reply", api.ReplyRequest{Body: "too late"})
+	if w.Code != http.StatusForbidden || !strings.Contains(w.Body.String(), "locked") {
+		t.Errorf("locked reply: status %d: %s", w.Code, w.Body)
+	}
+
+	// A reply that bypasses the server, as a pulled one would, is flagged.
+	late := writeReply(t, srv, bob, forum.RootFilename, "sneaked in")
+	var thread api.ThreadResponse
+	decodeJSON(t, hit(t, srv, "GET", "/api/threads/general/hello-world"), &thread)
+	if !thread.State.Locked {
+		t.Error("thread state not locked")
+	}
+	for _, p := range thread.Posts {
+		want := ""
+		if p.Filename == late {
+			want = forum.FlagLocked
+		}
+		if p.Flag != want {
+			t.Errorf("post %s: flag %q, want %q", p.Filename, p.Flag, want)
+		}
+	}
+
+	off := false
+	st = setThreadState(t, srv, api.ThreadStateRequest{Category: "general", Thread: "hello-world", Locked: &off})
+	if st.Locked {
+		t.Error("still locked after unlock")
+	}
+	if w := hitJSON(t, bobSrv, "POST", "/api/threads/general/hello-world/reply", api.ReplyRequest{Body: "back again"}); w.Code != http.StatusCreated {
+		t.Errorf("reply after unlock: status %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestHandleThreadState_BackdatedReplyFlagged(t *testing.T) {
+	srv := setupForum(t)
+	bob, err := crypto.Generate("bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := repo.Open(srv.RepoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.WritePublicKey(bob, "bob", bob.PublicKey); err != nil {
+		t.Fatal(err)
+	}
+	on := true
+	setThreadState(t, srv, api.ThreadStateRequest{Category: "general", Thread: "hello-world", Locked: &on})
+
+	// bob's reply claims to predate the lock, but is committed after it.
+	root, err := os.ReadFile(filepath.Join(srv.RepoPath, "general", "hello-world", forum.RootFilename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	priv, err := bob.PrivKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &forum.Post{
+		Author:       "bob",
+		PubKey:       bob.Fingerprint(),
+		TimestampRaw: time.Now().Add(-time.Hour).UTC().Format(time.RFC3339),
+		Parent:       forum.PostHash(root),
+		Body:         "backdated",
+	}
+	p.Signature = crypto.Sign(priv, crypto.CanonicalForm(map[string]string{
+		"author":    p.Author,
+		"pubkey":    p.PubKey,
+		"timestamp": p.TimestampRaw,
+		"parent":    p.Parent,
+	}, p.Body))
+	name := "1000000000000_backdate.md"
+	if err := r.CommitPost(bob, filepath.Join("general", "hello-world", name), p.Format()); err != nil {
+		t.Fatal(err)
+	}
+
+	var thread api.ThreadResponse
+	decodeJSON(t, hit(t, srv, "GET", "/api/threads/general/hello-world"), &thread)
+	found := false
+	for _, post := range thread.Posts {
+		if post.Filename == name {
+			found = true
+			if post.Flag != forum.FlagLocked {
+				t.Errorf("backdated reply: flag %q, want %q", post.Flag, forum.FlagLocked)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("backdated reply missing from %+v", thread.Posts)
+	}
+}
+
+func TestHandleThreadState_PinnedFirstArchivedLast(t *testing.T) {
+	srv := setupSortFixture(t)
+	on := true
+	setThreadState(t, srv, api.ThreadStateRequest{Category: "sorting", Thread: "a", Pinned: &on})
+	setThreadState(t, srv, api.ThreadStateRequest{Category: "sorting", Thread: "b", Archived: &on})
+
+	var resp api.ThreadsResponse
+	decodeJSON(t, hit(t, srv, "GET", "/api/categories/sorting/threads?sort=activity"), &resp)
+	if got := threadSlugs(resp); got != "a,c,b" {
+		t.Errorf("order: got %s, want a,c,b", got)
+	}
+	if !resp.Threads[0].Pinned || !resp.Threads[2].Archived {
+		t.Errorf("flags: got %+v", resp.Threads)
+	}
+
+	// Pagination keeps the same order across pages.
+	decodeJSON(t, hit(t, srv, "GET", "/api/categories/sorting/threads?limit=2"), &resp)
+	var next api.ThreadsResponse
+	decodeJSON(t, hit(t, srv, "GET", "/api/categories/sorting/threads?limit=2&cursor="+resp.NextCursor), &next)
+	if got := threadSlugs(resp) + "|" + threadSlugs(next); got != "a,c|b" {
+		t.Errorf("pages: got %s, want a,c|b", got)
+	}
+}
+
+func TestHandleThreadState_Errors(t *testing.T) {
+	on := true
+	srv := setupForumAsNonAdmin(t)
+	w := hitJSON(t, srv, "POST", "/api/admin/thread-state", api.ThreadStateRequest{Category: "general", Thread: "x", Pinned: &on})
+	if w.Code != http.StatusForbidden {
+		t.Errorf("non-admin: status %d, want 403", w.Code)
+	}
+
+	srv = setupForum(t)
+	w = hitJSON(t, srv, "POST", "/api/admin/thread-state", api.ThreadStateRequest{Category: "general", Thread: "hello-world"})
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("no flags: status %d, want 400", w.Code)
+	}
+	w = hitJSON(t, srv, "POST", "/api/admin/thread-state", api.ThreadStateRequest{Category: "general", Thread: "missing", Pinned: &on})
+	if w.Code != http.StatusNotFound {
+		t.Errorf("missing thread: status %d, want 404", w.Code)
+	}
+	for _, req := range []api.ThreadStateRequest{
+		{Category: "..", Thread: "general", Pinned: &on},
+		{Category: "general", Thread: "../../outside", Pinned: &on},
+		{Category: "general/../..", Thread: "x", Pinned: &on},
+	} {
+		w = hitJSON(t, srv, "POST", "/api/admin/thread-state", req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s/%s: status %d, want 400", req.Category, req.Thread, w.Code)
+		}
+	}
+}
+
+// ---- write policies --------------------------------------------------------
+
+func TestCategoryPolicy(t *testing.T) {
+	srv := setupForum(t)
+	w := hitJSON(t, srv, "POST", "/api/admin/roles", api.RolesResponse{Roles: map[string][]string{"staff": {"alice", " carol "}}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("set roles: status %d: %s", w.Code, w.Body)
+	}
+	var roles api.RolesResponse
+	decodeJSON(t, hit(t, srv, "GET", "/api/admin/roles"), &roles)
+	if got := strings.Join(roles.Roles["staff"], ","); got != "alice,carol" {
+		t.Errorf("roles: got %s", got)
+	}
+
+	w = hitJSON(t, srv, "POST", "/api/admin/category-policy", api.CategoryPolicyRequest{Category: "general", Threads: []string{"@staff"}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("set policy: status %d: %s", w.Code, w.Body)
+	}
+
+	bob, err := crypto.Generate("bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(srv.RepoPath, "keys", "bob.pub"), []byte(bob.PublicKey+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r, err := repo.Open(srv.RepoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobSrv := api.New(8080, srv.RepoPath, r, bob)
+
+	var threads api.ThreadsResponse
+	decodeJSON(t, hit(t, bobSrv, "GET", "/api/categories/general/threads"), &threads)
+	if threads.CanPost || threads.Policy == nil || strings.Join(threads.Policy.Threads, ",") != "@staff" {
+		t.Errorf("bob's view: can_post %v, policy %+v", threads.CanPost, threads.Policy)
+	}
+	decodeJSON(t, hit(t, srv, "GET", "/api/categories/general/threads"), &threads)
+	if !threads.CanPost {
+		t.Error("admin cannot post")
+	}
+
+	w = hitJSON(t, bobSrv, "POST", "/api/threads", api.NewThreadRequest{Category: "general", Slug: "mine", Body: "# Mine"})
+	if w.Code != http.StatusForbidden {
+		t.Errorf("bob new thread: status %d, want 403", w.Code)
+	}
+	if w := hitJSON(t, bobSrv, "POST", "/api/threads/general/hello-world/reply", api.ReplyRequest{Body: "replies are open"}); w.Code != http.StatusCreated {
+		t.Errorf("bob reply: status %d: %s", w.Code, w.Body)
+	}
+
+	// A thread that bypasses the server, as a pulled one would, is flagged.
+	threadDir := filepath.Join(srv.RepoPath, "general", "sneaky")
+	if err := os.MkdirAll(threadDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	root, err := forum.SignPost(bob, "", "# Sneaky")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(threadDir, forum.RootFilename), root.Format(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	decodeJSON(t, hit(t, srv, "GET", "/api/categories/general/threads"), &threads)
+	for _, th := range threads.Threads {
+		want := ""
+		if th.Slug == "sneaky" {
+			want = forum.FlagPolicy
+		}
+		if th.Flag != want {
+			t.Errorf("thread %s: flag %q, want %q", th.Slug, th.Flag, want)
+		}
+	}
+	var thread api.ThreadResponse
+	decodeJSON(t, hit(t, srv, "GET", "/api/threads/general/sneaky"), &thread)
+	if thread.Posts[0].Flag != forum.FlagPolicy {
+		t.Errorf("root flag: got %q", thread.Posts[0].Flag)
+	}
+
+	// Clearing the policy opens the category again.
+	w = hitJSON(t, srv, "POST", "/api/admin/category-policy", api.CategoryPolicyRequest{Category: "general"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("clear policy: status %d: %s", w.Code, w.Body)
+	}
+	if w := hitJSON(t, bobSrv, "POST", "/api/threads", api.NewThreadRequest{Category: "general", Slug: "mine", Body: "# Mine"}); w.Code != http.StatusCreated {
+		t.Errorf("bob new thread after clear: status %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestCategoryPolicy_Errors(t *testing.T) {
+	srv := setupForumAsNonAdmin(t)
+	w := hitJSON(t, srv, "POST", "/api/admin/category-policy", api.CategoryPolicyRequest{Category: "general", Threads: []string{"bob"}})
+	if w.Code != http.StatusForbidden {
+		t.Errorf("non-admin: status %d, want 403", w.Code)
+	}
+
+	srv = setupForum(t)
+	w = hitJSON(t, srv, "POST", "/api/admin/category-policy", api.CategoryPolicyRequest{Category: "general", Threads: []string{"bad name"}})
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("invalid entry: status %d, want 400", w.Code)
+	}
+	w = hitJSON(t, srv, "POST", "/api/admin/category-policy", api.CategoryPolicyRequest{Category: "missing", Threads: []string{"bob"}})
+	if w.Code != http.StatusNotFound {
+		t.Errorf("missing category: status %d, want 404", w.Code)
+	}
+	w = hitJSON(t, srv, "POST", "/api/admin/roles", api.RolesResponse{Roles: map[string][]string{"staff": {"@admins"}}})
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("nested role: status %d, want 400", w.Code)
+	}
+}
+
+func TestBansAndMutes(t *testing.T) {
+	srv := setupForum(t)
+	bob, err := crypto.Generate("bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(srv.RepoPath, "keys", "bob.pub"), []byte(bob.PublicKey+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r, err := repo.Open(srv.RepoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobSrv := api.New(8080, srv.RepoPath, r, bob)
+
+	if w := hitJSON(t, bobSrv, "POST", "/api/admin/ban", api.BanRequest{Username: "alice"}); w.Code != http.StatusForbidden {
+		t.Errorf("non-admin ban: status %d, want 403", w.Code)
+	}
+	if w := hitJSON(t, srv, "POST", "/api/admin/ban", api.BanRequest{Username: "alice"}); w.Code != http.StatusBadRequest {
+		t.Errorf("ban the admin: status %d, want 400", w.Code)
+	}
+	if w := hitJSON(t, srv, "POST", "/api/admin/ban", api.BanRequest{Username: "bob", Reason: "spam"}); w.Code != http.StatusOK {
+		t.Fatalf("ban: status %d: %s", w.Code, w.Body)
+	}
+
+	var bans api.BansResponse
+	decodeJSON(t, hit(t, bobSrv, "GET", "/api/bans"), &bans)
+	if len(bans.Bans) != 1 || bans.Bans[0].Username != "bob" || bans.Bans[0].PubKey != bob.PublicKey {
+		t.Fatalf("bans: %+v", bans.Bans)
+	}
+	if w := hitJSON(t, bobSrv, "POST", "/api/threads/general/hello-world/reply", api.ReplyRequest{Body: "still here"}); w.Code != http.StatusForbidden {
+		t.Errorf("banned reply: status %d, want 403", w.Code)
+	}
+
+	late := writeReply(t, srv, bob, forum.RootFilename, "after the ban")
+	flags := func(s *api.Server) map[string]string {
+		t.Helper()
+		var thread api.ThreadResponse
+		decodeJSON(t, hit(t, s, "GET", "/api/threads/general/hello-world"), &thread)
+		m := map[string]string{}
+		for _, p := range thread.Posts {
+			m[p.Filename] = p.Flag
+		}
+		return m
+	}
+	if got := flags(srv); got[late] != forum.FlagBanned {
+		t.Errorf("flag while banned: %q", got[late])
+	}
+
+	if w := hitJSON(t, srv, "POST", "/api/admin/unban", api.UsernameRequest{Username: "bob"}); w.Code != http.StatusOK {
+		t.Fatalf("unban: status %d: %s", w.Code, w.Body)
+	}
+	if w := hitJSON(t, srv, "POST", "/api/admin/unban", api.UsernameRequest{Username: "bob"}); w.Code != http.StatusNotFound {
+		t.Errorf("unban again: status %d, want 404", w.Code)
+	}
+	if got := flags(srv); got[late] != "" {
+		t.Errorf("flag after unban: %q", got[late])
+	}
+
+	// Muting only changes the muting identity's view.
+	var mutes api.MutesResponse
+	decodeJSON(t, hitJSON(t, srv, "POST", "/api/mute", api.UsernameRequest{Username: "bob"}), &mutes)
+	if strings.Join(mutes.Users, ",") != "bob" {
+		t.Errorf("mutes: %v", mutes.Users)
+	}
+	if got := flags(srv); got[late] != forum.FlagMuted {
+		t.Errorf("alice's view after mute: %v", got)
+	}
+	if got := flags(bobSrv); got[late] != "" {
+		t.Errorf("bob's view after alice muted him: %v", got)
+	}
+	if w := hitJSON(t, srv, "POST", "/api/mute", api.UsernameRequest{Username: "alice"}); w.Code != http.StatusBadRequest {
+		t.Errorf("mute self: status %d, want 400", w.Code)
+	}
+	decodeJSON(t, hitJSON(t, srv, "POST", "/api/unmute", api.UsernameRequest{Username: "bob"}), &mutes)
+	if len(mutes.Users) != 0 {
+		t.Errorf("mutes after unmute: %v", mutes.Users)
+	}
+}
+
+func TestUserProfile(t *testing.T) {
+	srv := setupForum(t)
+
+	if w := hit(t, srv, "GET", "/api/users/nobody"); w.Code != http.StatusNotFound {
+		t.Errorf("unknown user: status %d, want 404", w.Code)
+	}
+	if w := hitJSON(t, srv, "POST", "/api/threads/general/hello-world/reply", api.ReplyRequest{Body: "committed reply"}); w.Code != http.StatusCreated {
+		t.Fatalf("reply: status %d: %s", w.Code, w.Body)
+	}
+
+	var user api.UserResponse
+	decodeJSON(t, hit(t, srv, "GET", "/api/users/alice"), &user)
+	if user.JoinedAt == "" || user.PostCount != 1 || user.Fingerprint != user.PubKey[:8] {
+		t.Errorf("user before profile: %+v", user)
+	}
+	if user.Profile.UpdatedAt != "" {
+		t.Errorf("profile before publishing: %+v", user.Profile)
+	}
+
+	if w := hitJSON(t, srv, "POST", "/api/profile", forum.ProfileFields{Timezone: "Nowhere/Special"}); w.Code != http.StatusBadRequest {
+		t.Errorf("bad timezone: status %d, want 400", w.Code)
+	}
+	req := forum.ProfileFields{DisplayName: " Alice ", Bio: "Runs **this** forum.", Timezone: "UTC"}
+	if w := hitJSON(t, srv, "POST", "/api/profile", req); w.Code != http.StatusOK {
+		t.Fatalf("set profile: status %d: %s", w.Code, w.Body)
+	}
+	decodeJSON(t, hit(t, srv, "GET", "/api/users/alice"), &user)
+	if user.Profile.DisplayName != "Alice" || !strings.Contains(user.Profile.BioHTML, "<strong>this</strong>") || user.ProfileError != "" {
+		t.Errorf("profile: %+v (%s)", user.Profile, user.ProfileError)
+	}
+	if user.PostCount != 1 {
+		t.Errorf("the profile counted as a post: %d", user.PostCount)
+	}
+
+	var thread api.ThreadResponse
+	decodeJSON(t, hit(t, srv, "GET", "/api/threads/general/hello-world"), &thread)
+	for _, p := range thread.Posts {
+		if p.AuthorName != "Alice" || p.AuthorURL != "/api/users/alice" {
+			t.Errorf("post %s: author_name %q, author_url %q", p.Filename, p.AuthorName, p.AuthorURL)
+		}
+	}
+}
+
+func TestHandleThread_Quotes(t *testing.T) {
+	srv := setupForum(t)
+	body := "> [!quote general/hello-world/0000_root.md]\n> This is the root post.\n\n> [!quote general/hello-world/0000_root.md]\n> This is my post."
+	if w := hitJSON(t, srv, "POST", "/api/threads/general/hello-world/reply", map[string]string{"body": body}); w.Code != http.StatusCreated {
+		t.Fatalf("reply: status %d: %s", w.Code, w.Body.String())
+	}
+
+	var thread api.ThreadResponse
+	decodeJSON(t, hit(t, srv, "GET", "/api/threads/general/hello-world"), &thread)
+	var quotes []api.QuoteResponse
+	for _, p := range thread.Posts {
+		if p.Body == body {
+			quotes = p.Quotes
+		}
+	}
+	if len(quotes) != 2 {
+		t.Fatalf("quotes: got %+v", quotes)
+	}
+	want := api.QuoteResponse{
+		Ref:       "general/hello-world/0000_root.md",
+		Category:  "general",
+		Thread:    "hello-world",
+		Filename:  forum.RootFilename,
+		Author:    "alice",
+		SigStatus: "valid",
+		Found:     true,
+	}
+	if quotes[0] != want {
+		t.Errorf("quote: got %+v, want %+v", quotes[0], want)
+	}
+	if want.Altered = true; quotes[1] != want {
+		t.Errorf("altered quote: got %+v, want %+v", quotes[1], want)
+	}
+}
+
+func TestHandleThread_QuoteIndexFollowsHead(t *testing.T) {
+	srv := setupForum(t)
+	root, err := os.ReadFile(filepath.Join(srv.RepoPath, "general", "hello-world", forum.RootFilename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	quote := func(content []byte) string {
+		return "> [!quote " + forum.PostHash(content) + "]\n> quoted"
+	}
+	if w := hitJSON(t, srv, "POST", "/api/threads/general/hello-world/reply", map[string]string{"body": quote(root)}); w.Code != http.StatusCreated {
+		t.Fatalf("reply: status %d: %s", w.Code, w.Body.String())
+	}
+	found := func() map[string]bool {
+		t.Helper()
+		var thread api.ThreadResponse
+		decodeJSON(t, hit(t, srv, "GET", "/api/threads/general/hello-world"), &thread)
+		m := map[string]bool{}
+		for _, p := range thread.Posts {
+			for _, q := range p.Quotes {
+				m[q.Ref] = q.Found
+			}
+		}
+		return m
+	}
+	if !found()[forum.PostHash(root)] {
+		t.Fatal("quote by hash not resolved")
+	}
+
+	// A post committed after the index was built is found by hash.
+	w := hitJSON(t, srv, "POST", "/api/threads", api.NewThreadRequest{Category: "general", Slug: "later", Body: "# Later"})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("new thread: %d %s", w.Code, w.Body)
+	}
+	later, err := os.ReadFile(filepath.Join(srv.RepoPath, "general", "later", forum.RootFilename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := hitJSON(t, srv, "POST", "/api/threads/general/hello-world/reply", map[string]string{"body": quote(later)}); w.Code != http.StatusCreated {
+		t.Fatalf("reply: status %d: %s", w.Code, w.Body.String())
+	}
+	if !found()[forum.PostHash(later)] {
+		t.Error("index not rebuilt after a commit")
+	}
+}
+
+func TestHandleThread_Backlinks(t *testing.T) {
+	srv := setupForum(t)
+	var thread api.ThreadResponse
+	decodeJSON(t, hit(t, srv, "GET", "/api/threads/general/hello-world"), &thread)
+	if thread.Backlinks == nil || len(thread.Backlinks) != 0 {
+		t.Fatalf("backlinks before: %+v", thread.Backlinks)
+	}
+
+	w := hitJSON(t, srv, "POST", "/api/threads", api.NewThreadRequest{
+		Category: "general", Slug: "follow-up", Body: "# Follow-up\n\nContinuing [[general/hello-world]].",
+	})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("new thread: %d %s", w.Code, w.Body)
+	}
+	var followUp api.ThreadResponse
+	decodeJSON(t, hit(t, srv, "GET", "/api/threads/general/follow-up"), &followUp)
+	if html := followUp.Posts[0].BodyHTML; !strings.Contains(html, `<a class="xref xref-thread" href="#/cat/general/thread/hello-world" title="Hello">Hello</a>`) {
+		t.Errorf("cross-link not resolved: %s", html)
+	}
+
+	decodeJSON(t, hit(t, srv, "GET", "/api/threads/general/hello-world"), &thread)
+	if len(thread.Backlinks) != 1 {
+		t.Fatalf("backlinks after: %+v", thread.Backlinks)
+	}
+	if b := thread.Backlinks[0]; b.Category != "general" || b.Thread != "follow-up" || b.Filename != forum.RootFilename ||
+		b.Title != "Follow-up" || b.Author != "alice" || b.Target != "" {
+		t.Errorf("backlink: %+v", b)
+	}
+}
+
+func TestHandleMoveAndMergeThread(t *testing.T) {
+	srv := setupForum(t)
+	if w := hitJSON(t, srv, "POST", "/api/categories", api.CreateCategoryRequest{Slug: "archive", Name: "Archive"}); w.Code != http.StatusCreated {
+		t.Fatalf("create category: %d %s", w.Code, w.Body)
+	}
+	if w := hitJSON(t, srv, "POST", "/api/threads/general/hello-world/reply", api.ReplyRequest{Body: "a reply"}); w.Code != http.StatusCreated {
+		t.Fatalf("reply: %d %s", w.Code, w.Body)
+	}
+
+	move := api.ThreadMoveRequest{Category: "general", Thread: "hello-world", ToCategory: "archive", ToThread: "hello"}
+	if w := hitJSON(t, srv, "POST", "/api/admin/move", move); w.Code != http.StatusOK {
+		t.Fatalf("move: %d %s", w.Code, w.Body)
+	}
+	// The old address redirects to the moved thread, which keeps its replies.
+	var thread api.ThreadResponse
+	decodeJSON(t, hit(t, srv, "GET", "/api/threads/general/hello-world"), &thread)
+	if thread.Category != "archive" || thread.Slug != "hello" || len(thread.Posts) != 3 {
+		t.Errorf("moved thread: %s/%s with %d posts", thread.Category, thread.Slug, len(thread.Posts))
+	}
+	if r := thread.Redirect; r == nil || r.FromCategory != "general" || r.FromThread != "hello-world" || r.Root != forum.RootFilename {
+		t.Errorf("redirect: %+v", thread.Redirect)
+	}
+	var threads api.ThreadsResponse
+	decodeJSON(t, hit(t, srv, "GET", "/api/categories/general/threads"), &threads)
+	if len(threads.Threads) != 0 {
+		t.Errorf("old category still lists %s", threadSlugs(threads))
+	}
+	if w := hitJSON(t, srv, "POST", "/api/admin/move", move); w.Code != http.StatusBadRequest {
+		t.Errorf("move again: %d %s", w.Code, w.Body)
+	}
+
+	w := hitJSON(t, srv, "POST", "/api/threads", api.NewThreadRequest{Category: "archive", Slug: "later", Body: "# Later\n\nSee [[general/hello-world/0000_root.md]]."})
+	if w.Code != http.StatusCreated {
+		t.Fatalf("new thread: %d %s", w.Code, w.Body)
+	}
+	merge := api.ThreadMoveRequest{Category: "archive", Thread: "hello", ToCategory: "archive", ToThread: "later"}
+	if w := hitJSON(t, srv, "POST", "/api/admin/merge", merge); w.Code != http.StatusOK {
+		t.Fatalf("merge: %d %s", w.Code, w.Body)
+	}
+	// Both redirects are followed, and the old root post keeps its address.
+	decodeJSON(t, hit(t, srv, "GET", "/api/threads/general/hello-world"), &thread)
+	if thread.Category != "archive" || thread.Slug != "later" || len(thread.Posts) != 4 {
+		t.Fatalf("merged thread: %s/%s with %d posts", thread.Category, thread.Slug, len(thread.Posts))
+	}
+	root := thread.Redirect.Root
+	if root == forum.RootFilename || thread.Posts[0].Filename != forum.RootFilename {
+		t.Errorf("old root renamed to %q; first post %s", root, thread.Posts[0].Filename)
+	}
+	var link string
+	for _, p := range thread.Posts {
+		if p.Filename == forum.RootFilename {
+			link = p.BodyHTML
+		}
+		if p.Filename == root && p.SigStatus != "valid" {
+			t.Errorf("merged root post: signature %s", p.SigStatus)
+		}
+	}
+	if !strings.Contains(link, `href="#/cat/archive/thread/later/`+root+`"`) {
+		t.Errorf("cross-link to the old root not followed: %s", link)
+	}
+}
+
+func TestHandleMoveThread_Errors(t *testing.T) {
+	srv := setupForum(t)
+	cases := []struct {
+		name string
+		path string
+		req  api.ThreadMoveRequest
+		code int
+	}{
+		{"missing source", "/api/admin/move", api.ThreadMoveRequest{Category: "general", Thread: "nope", ToCategory: "general", ToThread: "x"}, http.StatusBadRequest},
+		{"same thread", "/api/admin/move", api.ThreadMoveRequest{Category: "general", Thread: "hello-world", ToCategory: "general", ToThread: "hello-world"}, http.StatusBadRequest},
+		{"bad slug", "/api/admin/move", api.ThreadMoveRequest{Category: "general", Thread: "hello-world", ToCategory: "general", ToThread: "Bad Slug"}, http.StatusBadRequest},
+		{"no category", "/api/admin/move", api.ThreadMoveRequest{Category: "general", Thread: "hello-world", ToCategory: "nope", ToThread: "x"}, http.StatusBadRequest},
+		{"merge into nothing", "/api/admin/merge", api.ThreadMoveRequest{Category: "general", Thread: "hello-world", ToCategory: "general", ToThread: "x"}, http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		if w := hitJSON(t, srv, "POST", c.path, c.req); w.Code != c.code {
+			t.Errorf("%s: status %d, want %d: %s", c.name, w.Code, c.code, w.Body)
+		}
+	}
+
+	bob, err := crypto.Generate("bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := repo.Open(srv.RepoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobSrv := api.New(8080, srv.RepoPath, r, bob)
+	req := api.ThreadMoveRequest{Category: "general", Thread: "hello-world", ToCategory: "general", ToThread: "x"}
+	if w := hitJSON(t, bobSrv, "POST", "/api/admin/move", req); w.Code != http.StatusForbidden {
+		t.Errorf("non-admin move: status %d", w.Code)
+	}
+}
+
+func TestDrafts(t *testing.T) {
+	srv := setupForum(t)
+	for _, req := range []api.DraftRequest{
+		{Category: "general", Slug: "later", Body: "# Later\n\nNot done yet."},
+		{Category: "general", Thread: "hello-world", Parent: forum.RootFilename, Body: "Half a reply"},
+	} {
+		if w := hitJSON(t, srv, "POST", "/api/draft", req); w.Code != http.StatusOK {
+			t.Fatalf("save draft: status %d: %s", w.Code, w.Body)
+		}
+	}
+
+	var list api.DraftsResponse
+	decodeJSON(t, hit(t, srv, "GET", "/api/drafts"), &list)
+	if len(list.Drafts) != 2 || list.Drafts[0].ID != "reply:general/hello-world" {
+		t.Fatalf("drafts: got %+v", list.Drafts)
+	}
+
+	var dr api.DraftResponse
+	decodeJSON(t, hit(t, srv, "GET", "/api/draft?category=general&thread=hello-world"), &dr)
+	if dr.Body != "Half a reply" || dr.Parent != forum.RootFilename {
+		t.Errorf("draft reply: got %+v", dr)
+	}
+
+	// Posting the reply discards its draft.
+	if w := hitJSON(t, srv, "POST", "/api/threads/general/hello-world/reply", map[string]string{"body": "A whole reply"}); w.Code != http.StatusCreated {
+		t.Fatalf("reply: status %d: %s", w.Code, w.Body)
+	}
+	if w := hit(t, srv, "GET", "/api/draft?category=general&thread=hello-world"); w.Code != http.StatusNotFound {
+		t.Errorf("draft after reply: status %d", w.Code)
+	}
+
+	if w := hitJSON(t, srv, "POST", "/api/draft/discard", api.DraftRequest{Category: "general"}); w.Code != http.StatusOK {
+		t.Fatalf("discard: status %d: %s", w.Code, w.Body)
+	}
+	decodeJSON(t, hit(t, srv, "GET", "/api/drafts"), &list)
+	if len(list.Drafts) != 0 {
+		t.Errorf("drafts after discard: got %+v", list.Drafts)
+	}
+
+	for _, req := range []api.DraftRequest{
+		{Body: "no category"},
+		{Category: "../x", Body: "bad category"},
+		{Category: "general", Thread: "Bad Slug", Body: "bad thread"},
+		{Category: "general", Thread: "hello-world", Parent: "../x.md", Body: "bad parent"},
+	} {
+		if w := hitJSON(t, srv, "POST", "/api/draft", req); w.Code != http.StatusBadRequest {
+			t.Errorf("save %+v: status %d", req, w.Code)
+		}
+	}
+}
+
+func TestOutbox(t *testing.T) {
+	srv := setupForum(t)
+	r, err := repo.Open(srv.RepoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// An origin that cannot be reached, so nothing is ever pushed.
+	if err := r.AddRemote("origin", filepath.Join(t.TempDir(), "missing")); err != nil {
+		t.Fatal(err)
+	}
+
+	var status api.StatusResponse
+	decodeJSON(t, hit(t, srv, "GET", "/api/status"), &status)
+	if status.Synced || status.Pending == 0 {
+		t.Errorf("status with fixture files uncommitted: %+v", status)
+	}
+	var out api.OutboxResponse
+	decodeJSON(t, hit(t, srv, "GET", "/api/outbox"), &out)
+	if len(out.Uncommitted) == 0 || !slices.Contains(out.Uncommitted, "general/META.toml") {
+		t.Fatalf("uncommitted: %v", out.Uncommitted)
+	}
+
+	wt, err := r.Git().Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wt.AddGlob("."); err != nil {
+		t.Fatal(err)
+	}
+	sig := &object.Signature{Name: "alice", Email: "alice@gitorum.local", When: time.Now()}
+	if _, err := wt.Commit("fixture", &gogit.CommitOptions{Author: sig, Committer: sig}); err != nil {
+		t.Fatal(err)
+	}
+
+	if w := hitJSON(t, srv, "POST", "/api/threads/general/hello-world/reply", map[string]string{"body": "Stuck in the outbox."}); w.Code != http.StatusCreated {
+		t.Fatalf("reply: status %d: %s", w.Code, w.Body)
+	}
+	decodeJSON(t, hit(t, srv, "GET", "/api/outbox"), &out)
+	if out.LastPushError == "" || len(out.Uncommitted) != 0 {
+		t.Fatalf("outbox after failed push: %+v", out)
+	}
+	last := out.Commits[len(out.Commits)-1]
+	if last.PushError == "" || len(last.Posts) != 1 || last.Posts[0].Category != "general" || last.Posts[0].Thread != "hello-world" {
+		t.Fatalf("pending reply: %+v", last)
+	}
+
+	if w := hitJSON(t, srv, "POST", "/api/outbox/retry", nil); w.Code != http.StatusBadGateway {
+		t.Errorf("retry: status %d", w.Code)
+	}
+	if w := hitJSON(t, srv, "POST", "/api/outbox/discard", api.OutboxDiscardRequest{Commit: last.Hash}); w.Code != http.StatusOK {
+		t.Fatalf("discard: status %d: %s", w.Code, w.Body)
+	}
+	var thread api.ThreadResponse
+	decodeJSON(t, hit(t, srv, "GET", "/api/threads/general/hello-world"), &thread)
+	if len(thread.Posts) != 2 {
+		t.Errorf("posts after discarding the reply: got %d, want 2", len(thread.Posts))
+	}
+
+	for _, req := range []api.OutboxDiscardRequest{{}, {Commit: last.Hash, Path: "x"}, {Commit: last.Hash}, {Path: "general/META.toml"}} {
+		if w := hitJSON(t, srv, "POST", "/api/outbox/discard", req); w.Code != http.StatusBadRequest {
+			t.Errorf("discard %+v: status %d", req, w.Code)
+		}
+	}
+}
+
+func TestMultipleForums(t *testing.T) {
+	one, two := setupForum(t), setupForum(t)
+	r1, err := repo.Open(one.RepoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r2, err := repo.Open(two.RepoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := crypto.Generate("carol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv, err := api.NewMulti(8080, []api.ForumConfig{
+		{Name: "one", RepoPath: one.RepoPath, Repo: r1},
+		{Name: "two", RepoPath: two.RepoPath, Repo: r2},
+	}, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var forums api.ForumsResponse
+	decodeJSON(t, hit(t, srv, "GET", "/api/forums"), &forums)
+	if len(forums.Forums) != 2 || !forums.Forums[0].Default || forums.Forums[1].Name != "two" || forums.Forums[1].Title != "Test Forum" {
+		t.Fatalf("forums: %+v", forums.Forums)
+	}
+	if forums.Forums[0].Unread == 0 || forums.TotalUnread != forums.Forums[0].Unread+forums.Forums[1].Unread {
+		t.Errorf("unread counts: %+v", forums)
+	}
+
+	if w := hitJSON(t, srv, "POST", "/f/two/api/threads/general/hello-world/reply", map[string]string{"body": "Only in two."}); w.Code != http.StatusCreated {
+		t.Fatalf("reply in two: status %d: %s", w.Code, w.Body)
+	}
+	for path, want := range map[string]int{
+		"/f/two/api/threads/general/hello-world": 3,
+		"/f/one/api/threads/general/hello-world": 2,
+		"/api/threads/general/hello-world":       2, // the default forum
+	} {
+		var thread api.ThreadResponse
+		decodeJSON(t, hit(t, srv, "GET", path), &thread)
+		if len(thread.Posts) != want {
+			t.Errorf("%s: got %d posts, want %d", path, len(thread.Posts), want)
+		}
+	}
+	if w := hit(t, srv, "GET", "/f/three/api/status"); w.Code != http.StatusNotFound {
+		t.Errorf("unknown forum: status %d", w.Code)
+	}
+
+	for _, forums := range [][]api.ForumConfig{
+		nil,
+		{{Name: "one", RepoPath: one.RepoPath}, {Name: "one", RepoPath: two.RepoPath}},
+		{{Name: "Not A Slug", RepoPath: one.RepoPath}},
+	} {
+		if _, err := api.NewMulti(8080, forums, id); err == nil {
+			t.Errorf("NewMulti(%+v): want error", forums)
+		}
+	}
+	if got := api.ForumName("/srv/forums/Home Stuff/"); got != "home-stuff" {
+		t.Errorf("ForumName: got %q", got)
+	}
+}
+
+func TestSwitchIdentity(t *testing.T) {
+	srv := setupForum(t)
+	dir := t.TempDir()
+	srv.Identities = &crypto.Store{Dir: filepath.Join(dir, "identities"), Default: filepath.Join(dir, "identity.toml")}
+	bob, err := crypto.Generate("bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := srv.Identities.Add("test-bob", bob); err != nil {
+		t.Fatal(err)
+	}
+
+	var ids api.IdentitiesResponse
+	decodeJSON(t, hit(t, srv, "GET", "/api/identities"), &ids)
+	if len(ids.Identities) != 1 || ids.Identities[0].Username != "bob" || ids.Identities[0].Active {
+		t.Fatalf("identities: %+v", ids.Identities)
+	}
+
+	if w := hitJSON(t, srv, "POST", "/api/identity", api.UseIdentityRequest{Name: "test-bob"}); w.Code != http.StatusOK {
+		t.Fatalf("use: status %d: %s", w.Code, w.Body)
+	}
+	var status api.StatusResponse
+	decodeJSON(t, hit(t, srv, "GET", "/api/status"), &status)
+	if status.Username != "bob" || status.IsAdmin {
+		t.Errorf("status after switching: %+v", status)
+	}
+	decodeJSON(t, hit(t, srv, "GET", "/api/identities"), &ids)
+	if !ids.Identities[0].Active {
+		t.Errorf("switched identity not active: %+v", ids.Identities)
+	}
+	if choice, err := local.LoadIdentityChoice(srv.RepoPath); err != nil || choice.Name != "test-bob" {
+		t.Errorf("remembered choice: %+v, %v", choice, err)
+	}
+
+	if w := hitJSON(t, srv, "POST", "/api/identity", api.UseIdentityRequest{Name: "nobody"}); w.Code != http.StatusNotFound {
+		t.Errorf("unknown identity: status %d", w.Code)
+	}
+	if w := hitJSON(t, srv, "POST", "/api/identity", api.UseIdentityRequest{Name: "../x"}); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid name: status %d", w.Code)
+	}
+}
+
+// TestSwitchIdentity_Concurrent verifies that a request uses one identity
+// throughout, even when the identity is switched while it runs: every reply
+// is committed by the user who signed it.
+func TestSwitchIdentity_Concurrent(t *testing.T) {
+	srv := setupForum(t)
+	dir := t.TempDir()
+	srv.Identities = &crypto.Store{Dir: filepath.Join(dir, "identities"), Default: filepath.Join(dir, "identity.toml")}
+	for _, name := range []string{"bob", "carol"} {
+		id, err := crypto.Generate(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := srv.Identities.Add("test-"+name, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; ; i++ {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			hitJSON(t, srv, "POST", "/api/identity", api.UseIdentityRequest{Name: []string{"test-bob", "test-carol"}[i%2]})
+		}
+	}()
+	for i := 0; i < 10; i++ {
+		body := api.ReplyRequest{Body: fmt.Sprintf("reply %d", i)}
+		if w := hitJSON(t, srv, "POST", "/api/threads/general/hello-world/reply", body); w.Code != http.StatusCreated {
+			t.Errorf("reply %d: status %d: %s", i, w.Code, w.Body)
+		}
+	}
+	close(done)
+	wg.Wait()
+
+	r, err := repo.Open(srv.RepoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var thread api.ThreadResponse
+	decodeJSON(t, hit(t, srv, "GET", "/api/threads/general/hello-world"), &thread)
+	for _, p := range thread.Posts[2:] {
+		prov, err := r.Provenance("general/hello-world/" + p.Filename)
+		if err != nil {
+			t.Fatalf("Provenance %s: %v", p.Filename, err)
+		}
+		if prov.Introduced.Committer != p.Author {
+			t.Errorf("%s: signed by %s, committed by %s", p.Filename, p.Author, prov.Introduced.Committer)
+		}
+	}
+}
+
+// ---- LAN -------------------------------------------------------------------
+
+func TestLANSync(t *testing.T) {
+	a := setupForum(t)
+
+	// Peers only post to categories the forum has committed.
+	aGit, err := gogit.PlainOpen(a.RepoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aWt, err := aGit.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := aWt.Add("general/META.toml"); err != nil {
+		t.Fatal(err)
+	}
+	aSig := &object.Signature{Name: "alice", Email: "alice@gitorum.local", When: time.Now()}
+	if _, err := aWt.Commit("category: add general", &gogit.CommitOptions{Author: aSig, Committer: aSig}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The git endpoint serves clones on its own.
+	ts := httptest.NewServer(a.GitHandler())
+	defer ts.Close()
+	bDir := t.TempDir()
+	bGit, err := gogit.PlainClone(bDir, false, &gogit.CloneOptions{URL: ts.URL + "/git/"})
+	if err != nil {
+		t.Fatalf("clone from git endpoint: %v", err)
+	}
+	if resp, err := http.Post(ts.URL+"/git/git-receive-pack", "application/x-git-receive-pack-request", nil); err != nil || resp.StatusCode == http.StatusOK {
+		t.Errorf("git endpoint accepts pushes: %v, %v", resp, err)
+	}
+
+	// Bob starts a thread in his copy.
+	threadDir := filepath.Join(bDir, "general", "from-bob")
+	if err := os.MkdirAll(threadDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(threadDir, forum.RootFilename), []byte("hello over the LAN"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	wt, err := bGit.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wt.AddGlob("."); err != nil {
+		t.Fatal(err)
+	}
+	sig := &object.Signature{Name: "bob", Email: "bob@gitorum.local", When: time.Now()}
+	if _, err := wt.Commit("post: from-bob", &gogit.CommitOptions{Author: sig, Committer: sig}); err != nil {
+		t.Fatal(err)
+	}
+	rb, err := repo.Open(bDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := api.New(0, bDir, rb, nil)
+
+	var peers api.LANPeersResponse
+	decodeJSON(t, hit(t, a, "GET", "/api/lan/peers"), &peers)
+	if peers.Enabled {
+		t.Errorf("LAN enabled before StartLAN")
+	}
+
+	// Both servers advertise on a group port of their own.
+	probe, err := net.ListenUDP("udp4", &net.UDPAddr{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	group := &net.UDPAddr{IP: lan.DefaultGroup.IP, Port: probe.LocalAddr().(*net.UDPAddr).Port}
+	probe.Close()
+	for _, srv := range []*api.Server{a, b} {
+		ln, err := net.Listen("tcp", ":0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer ln.Close()
+		d := &lan.Discovery{Group: group, Interval: 100 * time.Millisecond}
+		if err := srv.StartLAN(d, ln); err != nil {
+			t.Skipf("no multicast here: %v", err)
+		}
+		defer d.Close()
+	}
+	deadline := time.Now().Add(3 * time.Second)
+	for len(peers.Peers) == 0 {
+		if time.Now().After(deadline) {
+			t.Skip("no multicast here: peers never showed up")
+		}
+		time.Sleep(50 * time.Millisecond)
+		decodeJSON(t, hit(t, a, "GET", "/api/lan/peers"), &peers)
+	}
+	if !peers.Enabled || len(peers.Peers) != 1 || peers.Peers[0].Name != "Test Forum" {
+		t.Fatalf("peers: %+v", peers)
+	}
+
+	w := hitJSON(t, a, "POST", "/api/lan/sync", api.LANSyncRequest{Instance: "nobody"})
+	if w.Code != http.StatusNotFound {
+		t.Errorf("sync with an unknown peer: got %d", w.Code)
+	}
+	w = hitJSON(t, a, "POST", "/api/lan/sync", api.LANSyncRequest{Instance: peers.Peers[0].Instance})
+	var res api.LANSyncResponse
+	decodeJSON(t, w, &res)
+	if w.Code != http.StatusOK || res.Added != 1 {
+		t.Fatalf("lan sync: %d %+v", w.Code, res)
+	}
+	if _, err := os.Stat(filepath.Join(a.RepoPath, "general", "from-bob", forum.RootFilename)); err != nil {
+		t.Errorf("peer's thread not pulled: %v", err)
+	}
+}
+
+func TestAudit(t *testing.T) {
+	srv := setupForum(t)
+	bob, err := crypto.Generate("bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := hitJSON(t, srv, "POST", "/api/admin/addkey", api.AdminAddKeyRequest{Username: "bob", PubKey: bob.PublicKey}); w.Code != http.StatusOK {
+		t.Fatalf("addkey: status %d: %s", w.Code, w.Body)
+	}
+	on := true
+	setThreadState(t, srv, api.ThreadStateRequest{Category: "general", Thread: "hello-world", Locked: &on})
+
+	var page api.AuditResponse
+	decodeJSON(t, hit(t, srv, "GET", "/api/admin/audit?limit=2"), &page)
+	if page.Total != 3 || len(page.Entries) != 2 || page.NextCursor == "" {
+		t.Fatalf("first page: %+v", page)
+	}
+	if e := page.Entries[0]; e.Action != "thread" || e.Actor != "alice" || !e.Verified || !e.ByAdmin || len(e.Records) != 1 {
+		t.Errorf("thread entry: %+v", e)
+	}
+	if e := page.Entries[1]; e.Action != "keys" || e.Target != "add public key for bob" {
+		t.Errorf("keys entry: %+v", e)
+	}
+	next := page.NextCursor
+	page = api.AuditResponse{}
+	decodeJSON(t, hit(t, srv, "GET", "/api/admin/audit?limit=2&cursor="+next), &page)
+	if len(page.Entries) != 1 || page.Entries[0].Action != "init" || page.NextCursor != "" {
+		t.Errorf("second page: %+v", page)
+	}
+
+	page = api.AuditResponse{}
+	decodeJSON(t, hit(t, srv, "GET", "/api/admin/audit?action=keys&since=2000-01-01"), &page)
+	if page.Total != 1 {
+		t.Errorf("action=keys: %+v", page)
+	}
+	page = api.AuditResponse{}
+	decodeJSON(t, hit(t, srv, "GET", "/api/admin/audit?actor=bob"), &page)
+	if page.Total != 0 || page.Entries == nil {
+		t.Errorf("actor=bob: %+v", page)
+	}
+	for _, q := range []string{"action=nope", "since=yesterday", "cursor=bogus"} {
+		if w := hit(t, srv, "GET", "/api/admin/audit?"+q); w.Code != http.StatusBadRequest {
+			t.Errorf("%s: status %d, want 400", q, w.Code)
+		}
+	}
+
+	r, err := repo.Open(srv.RepoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := hit(t, api.New(8080, srv.RepoPath, r, bob), "GET", "/api/admin/audit"); w.Code != http.StatusForbidden {
+		t.Errorf("non-admin: status %d, want 403", w.Code)
+	}
+}
+
+func TestProvenance(t *testing.T) {
+	srv := setupForum(t)
+	r, err := repo.Open(srv.RepoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice, err := crypto.Generate("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := crypto.Generate("bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := writeReply(t, srv, bob, forum.RootFilename, "committed by bob")
+	content, err := os.ReadFile(filepath.Join(srv.RepoPath, "general", "hello-world", file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.CommitPost(bob, "general/hello-world/"+file, content); err != nil {
+		t.Fatal(err)
+	}
+
+	url := "/api/threads/general/hello-world/posts/" + file + "/provenance"
+	var prov api.ProvenanceResponse
+	decodeJSON(t, hit(t, srv, "GET", url), &prov)
+	if prov.Introduced.Committer != "bob" || prov.Introduced.Change != "add" || prov.Introduced.Signature != "unknown" ||
+		prov.ClaimedAt == "" || len(prov.Later) != 0 || prov.Modified {
+		t.Errorf("provenance: %+v", prov)
+	}
+
+	// Someone rewrites the post after the fact.
+	if err := r.CommitFile(alice, "general/hello-world/"+file, append(content, " (edited)"...), "post: fix typo"); err != nil {
+		t.Fatal(err)
+	}
+	prov = api.ProvenanceResponse{}
+	decodeJSON(t, hit(t, srv, "GET", url), &prov)
+	if !prov.Modified || len(prov.Later) != 1 || prov.Later[0].Change != "modify" || prov.Later[0].Subject != "post: fix typo" {
+		t.Errorf("tampered provenance: %+v", prov)
+	}
+
+	if w := hit(t, srv, "GET", "/api/threads/general/hello-world/posts/"+forum.RootFilename+"/provenance"); w.Code != http.StatusNotFound {
+		t.Errorf("uncommitted post: status %d, want 404", w.Code)
+	}
+	if w := hit(t, srv, "GET", "/api/threads/general/hello-world/posts/META.toml/provenance"); w.Code != http.StatusBadRequest {
+		t.Errorf("not a post: status %d, want 400", w.Code)
+	}
+}
+
+func TestProofOfWork(t *testing.T) {
+	srv := setupForum(t)
+	r, err := repo.Open(srv.RepoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice, err := crypto.Generate("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta, err := r.ReadMeta()
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta.ProofOfWork = 10
+	if err := r.UpdateMeta(alice, *meta); err != nil {
+		t.Fatal(err)
+	}
+
+	// bob has no key in keys/: his reply through the API carries the work,
+	// one written without it is hidden.
+	bob, err := crypto.Generate("bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := hitJSON(t, api.New(8080, srv.RepoPath, r, bob), "POST", "/api/threads/general/hello-world/reply", api.ReplyRequest{Body: "worked for it"}); w.Code != http.StatusCreated {
+		t.Fatalf("reply: status %d: %s", w.Code, w.Body)
+	}
+	writeReply(t, srv, bob, forum.RootFilename, "free ride")
+
+	var thread api.ThreadResponse
+	decodeJSON(t, hit(t, srv, "GET", "/api/threads/general/hello-world"), &thread)
+	flags := map[string]string{}
+	for _, p := range thread.Posts {
+		flags[p.Body] = p.Flag
+	}
+	if flags["worked for it"] != "" || flags["free ride"] != forum.FlagNoWork {
+		t.Errorf("flags: %v", flags)
+	}
+
+	// Once alice vouches for bob's key, she sees his post without the work.
+	if w := hitJSON(t, srv, "POST", "/api/vouch", api.VouchRequest{Username: "bob", PubKey: bob.PublicKey}); w.Code != http.StatusOK {
+		t.Fatalf("vouch: status %d: %s", w.Code, w.Body)
+	}
+	thread = api.ThreadResponse{}
+	decodeJSON(t, hit(t, srv, "GET", "/api/threads/general/hello-world"), &thread)
+	for _, p := range thread.Posts {
+		if p.Body == "free ride" && (p.Flag != "" || p.Trust != "vouched") {
+			t.Errorf("vouched post: flag %q, trust %q", p.Flag, p.Trust)
+		}
+	}
+}
+
+func TestProofOfWork_OutOfRange(t *testing.T) {
+	srv := setupForum(t)
+	r, err := repo.Open(srv.RepoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	alice, err := crypto.Generate("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta, err := r.ReadMeta()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// A hand-edited GITORUM.toml can ask for more work than exists.
+	meta.ProofOfWork = 1 << 20
+	if err := r.UpdateMeta(alice, *meta); err != nil {
+		t.Fatal(err)
+	}
+
+	bob, err := crypto.Generate("bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobSrv := api.New(8080, srv.RepoPath, r, bob)
+	w := hitJSON(t, bobSrv, "POST", "/api/threads/general/hello-world/reply", api.ReplyRequest{Body: "never signed"})
+	if w.Code != http.StatusInternalServerError || !strings.Contains(w.Body.String(), "proof of work") {
+		t.Errorf("reply: status %d: %s", w.Code, w.Body)
+	}
+	w = hitJSON(t, bobSrv, "POST", "/api/threads", api.NewThreadRequest{Category: "general", Slug: "no-work", Body: "never signed"})
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("new thread: status %d: %s", w.Code, w.Body)
+	}
+
+	// Posts by approved keys need no work and still go through.
+	if w := hitJSON(t, srv, "POST", "/api/threads/general/hello-world/reply", api.ReplyRequest{Body: "from the admin"}); w.Code != http.StatusCreated {
+		t.Errorf("admin reply: status %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestVouch(t *testing.T) {
+	srv := setupForum(t)
+	r, err := repo.Open(srv.RepoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := crypto.Generate("bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	carol, err := crypto.Generate("carol")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.SubmitJoinRequest(bob); err != nil {
+		t.Fatal(err)
+	}
+
+	// carol has neither a key nor a join request, so her key must be given.
+	if w := hitJSON(t, srv, "POST", "/api/vouch", api.VouchRequest{Username: "carol"}); w.Code != http.StatusBadRequest {
+		t.Errorf("vouch without a known key: status %d", w.Code)
+	}
+	w := hitJSON(t, srv, "POST", "/api/vouch", api.VouchRequest{Username: "bob", Note: "met in person"})
+	if w.Code != http.StatusOK {
+		t.Fatalf("vouch: status %d: %s", w.Code, w.Body)
+	}
+	var trust api.TrustResponse
+	decodeJSON(t, w, &trust)
+	if len(trust.Vouches) != 1 || trust.Vouches[0].PubKey != bob.PublicKey ||
+		len(trust.Trusted) != 1 || trust.Trusted[0].Approved || len(trust.Trusted[0].Path) != 1 {
+		t.Fatalf("trust: %+v", trust)
+	}
+
+	// bob is still unapproved, but alice vouches for his key; nobody vouches
+	// for carol.
+	writeReply(t, srv, bob, forum.RootFilename, "from bob")
+	writeReply(t, srv, carol, forum.RootFilename, "from carol")
+	var thread api.ThreadResponse
+	decodeJSON(t, hit(t, srv, "GET", "/api/threads/general/hello-world"), &thread)
+	got := map[string]string{}
+	for _, p := range thread.Posts {
+		got[p.Author+":"+p.Body] = p.Trust
+	}
+	if got["bob:from bob"] != "vouched" || got["carol:from carol"] != "unknown" || got["alice:A reply to the root post."] != "approved" {
+		t.Errorf("trust: %v", got)
+	}
+
+	// With a quorum of one vouch, sync approves bob but leaves carol alone.
+	if err := r.SubmitJoinRequest(carol); err != nil {
+		t.Fatal(err)
+	}
+	var reqs api.JoinRequestsResponse
+	decodeJSON(t, hit(t, srv, "GET", "/api/admin/requests"), &reqs)
+	for _, req := range reqs.Requests {
+		if want := map[string]int{"bob": 1, "carol": 0}[req.Username]; len(req.VouchedBy) != want {
+			t.Errorf("request from %s vouched by %v", req.Username, req.VouchedBy)
+		}
+	}
+	meta, err := r.ReadMeta()
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta.AutoApproveVouches = 1
+	alice, err := crypto.Generate("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.UpdateMeta(alice, *meta); err != nil {
+		t.Fatal(err)
+	}
+	if w := hit(t, srv, "GET", "/api/sync"); w.Code != http.StatusOK {
+		t.Fatalf("sync: status %d: %s", w.Code, w.Body)
+	}
+	if _, err := os.Stat(filepath.Join(srv.RepoPath, "keys", "bob.pub")); err != nil {
+		t.Errorf("bob not approved: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(srv.RepoPath, "keys", "carol.pub")); err == nil {
+		t.Error("carol approved without vouches")
+	}
+
+	if w := hitJSON(t, srv, "POST", "/api/unvouch", api.UsernameRequest{Username: "bob"}); w.Code != http.StatusOK {
+		t.Fatalf("unvouch: status %d: %s", w.Code, w.Body)
+	}
+	if w := hitJSON(t, srv, "POST", "/api/unvouch", api.UsernameRequest{Username: "bob"}); w.Code != http.StatusNotFound {
+		t.Errorf("unvouch twice: status %d", w.Code)
+	}
+}
+
+func TestAutoApprove_VouchQuorum(t *testing.T) {
+	srv := setupForum(t)
+	r, err := repo.Open(srv.RepoPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := map[string]*crypto.Identity{}
+	for _, name := range []string{"bob", "carol", "dave", "erin"} {
+		if ids[name], err = crypto.Generate(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"bob", "carol"} {
+		if err := r.WritePublicKey(ids[name], name, ids[name].PublicKey); err != nil {
+			t.Fatal(err)
+		}
+	}
+	bobSrv := api.New(8080, srv.RepoPath, r, ids["bob"])
+	carolSrv := api.New(8080, srv.RepoPath, r, ids["carol"])
+	meta, err := r.ReadMeta()
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta.AutoApproveVouches = 2
+	if err := r.UpdateMeta(ids["bob"], *meta); err != nil {
+		t.Fatal(err)
+	}
+
+	// bob vouched for erin's key an hour before her request was made.
+	early, err := forum.SignVouchList(ids["bob"], []forum.Vouch{{
+		Username: "erin",
+		PubKey:   ids["erin"].PublicKey,
+		Since:    time.Now().Add(-time.Hour).UTC().Format(time.RFC3339),
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.CommitFile(ids["bob"], filepath.FromSlash(forum.VouchPath("bob")), early.Format(), "vouch: @erin"); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"dave", "erin"} {
+		if err := r.SubmitJoinRequest(ids[name]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, s := range []*api.Server{bobSrv, carolSrv} {
+		if w := hitJSON(t, s, "POST", "/api/vouch", api.VouchRequest{Username: "dave"}); w.Code != http.StatusOK {
+			t.Fatalf("vouch: status %d: %s", w.Code, w.Body)
+		}
+	}
+	if w := hitJSON(t, carolSrv, "POST", "/api/vouch", api.VouchRequest{Username: "erin"}); w.Code != http.StatusOK {
+		t.Fatalf("vouch: status %d: %s", w.Code, w.Body)
+	}
+
+	vouchedBy := func() map[string]string {
+		t.Helper()
+		var reqs api.JoinRequestsResponse
+		decodeJSON(t, hit(t, srv, "GET", "/api/admin/requests"), &reqs)
+		m := map[string]string{}
+		for _, req := range reqs.Requests {
+			m[req.Username] = strings.Join(req.VouchedBy, ",")
+		}
+		return m
+	}
+	if got := vouchedBy(); got["dave"] != "bob,carol" || got["erin"] != "carol" {
+		t.Errorf("vouched by: %v", got)
+	}
+
+	// bob withdraws his vouch, leaving dave one short of the quorum.
+	if w := hitJSON(t, bobSrv, "POST", "/api/unvouch", api.UsernameRequest{Username: "dave"}); w.Code != http.StatusOK {
+		t.Fatalf("unvouch: status %d: %s", w.Code, w.Body)
+	}
+	if got := vouchedBy(); got["dave"] != "carol" {
+		t.Errorf("vouched by after unvouch: %v", got)
+	}
+	if w := hit(t, srv, "GET", "/api/sync"); w.Code != http.StatusOK {
+		t.Fatalf("sync: status %d: %s", w.Code, w.Body)
+	}
+	for _, name := range []string{"dave", "erin"} {
+		if _, err := os.Stat(filepath.Join(srv.RepoPath, "keys", name+".pub")); err == nil {
+			t.Errorf("%s approved below the quorum", name)
+		}
+	}
+
+	// Renewing the early vouch makes it count.
+	if w := hitJSON(t, bobSrv, "POST", "/api/vouch", api.VouchRequest{Username: "erin"}); w.Code != http.StatusOK {
+		t.Fatalf("vouch: status %d: %s", w.Code, w.Body)
+	}
+	if w := hit(t, srv, "GET", "/api/sync"); w.Code != http.StatusOK {
+		t.Fatalf("sync: status %d: %s", w.Code, w.Body)
+	}
+	if _, err := os.Stat(filepath.Join(srv.RepoPath, "keys", "erin.pub")); err != nil {
+		t.Errorf("erin not approved: %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gosub/gitorum/internal/audit"
+)
+
+// GET /api/admin/audit?actor=&action=&since=&until=&limit=&cursor=
+//
+// Returns the moderation log built from the git history, newest first.
+// since and until are dates (YYYY-MM-DD) or RFC 3339 times; a date given as
+// until includes that day.
+func (s *forumView) handleAudit(w http.ResponseWriter, r *http.Request) {
+	if !s.requireAdmin(w) {
+		return
+	}
+	limit, err := pageLimit(r, defaultAuditPage)
+	if err != nil {
+		apiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	q := r.URL.Query()
+	f := audit.Filter{Actor: q.Get("actor"), Action: audit.Action(q.Get("action"))}
+	if f.Action != "" && !audit.ValidAction(f.Action) {
+		apiError(w, http.StatusBadRequest, "unknown action "+q.Get("action"))
+		return
+	}
+	if v := q.Get("since"); v != "" {
+		if f.Since, err = audit.ParseTime(v, false); err != nil {
+			apiError(w, http.StatusBadRequest, "since: "+err.Error())
+			return
+		}
+	}
+	if v := q.Get("until"); v != "" {
+		if f.Until, err = audit.ParseTime(v, true); err != nil {
+			apiError(w, http.StatusBadRequest, "until: "+err.Error())
+			return
+		}
+	}
+
+	entries, err := audit.Log(s.repo, f)
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, "audit: "+err.Error())
+		return
+	}
+	start := 0
+	if cursor := q.Get("cursor"); cursor != "" {
+		var after string
+		if err := decodeCursor(cursor, &after); err != nil {
+			apiError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		start = -1
+		for i, e := range entries {
+			if e.Hash == after {
+				start = i + 1
+				break
+			}
+		}
+		if start < 0 {
+			apiError(w, http.StatusBadRequest, "invalid cursor")
+			return
+		}
+	}
+	end := min(start+limit, len(entries))
+	resp := AuditResponse{Entries: []AuditEntry{}, Total: len(entries)}
+	for _, e := range entries[start:end] {
+		resp.Entries = append(resp.Entries, auditToResponse(e))
+	}
+	if end < len(entries) && end > start {
+		resp.NextCursor = encodeCursor(entries[end-1].Hash)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func auditToResponse(e audit.Entry) AuditEntry {
+	ae := AuditEntry{
+		Hash:      e.Hash,
+		When:      e.When.UTC().Format(time.RFC3339),
+		Actor:     e.Actor,
+		Action:    string(e.Action),
+		Subject:   e.Subject,
+		Target:    e.Target,
+		CommitSig: string(e.CommitSig),
+		Signer:    e.Signer,
+		ByAdmin:   e.ByAdmin,
+		Verified:  e.Verified,
+	}
+	for _, rec := range e.Records {
+		ae.Records = append(ae.Records, AuditRecord{Path: rec.Path, Error: rec.Err})
+	}
+	return ae
+}
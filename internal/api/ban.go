@@ -0,0 +1,204 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gosub/gitorum/internal/forum"
+	"github.com/gosub/gitorum/internal/local"
+)
+
+// banned returns the ban in opts that covers the local identity, or nil.
+func (s *forumView) banned(opts forum.LoadOptions) *forum.Ban {
+	if s.identity == nil {
+		return nil
+	}
+	return opts.Bans.Find(s.identity.Username, s.identity.Fingerprint())
+}
+
+// validUsername reports whether u can name a user; role references are not
+// accepted.
+func validUsername(u string) bool {
+	return policyEntryRe.MatchString(u) && !strings.HasPrefix(u, "@")
+}
+
+// GET /api/bans
+func (s *forumView) handleBans(w http.ResponseWriter, r *http.Request) {
+	if s.repo == nil {
+		apiError(w, http.StatusServiceUnavailable, "forum not initialized")
+		return
+	}
+	bans := s.loadOptions().Bans
+	resp := BansResponse{Bans: []forum.Ban{}}
+	if bans != nil && bans.Bans != nil {
+		resp.Bans = bans.Bans
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// POST /api/admin/ban
+func (s *forumView) handleBan(w http.ResponseWriter, r *http.Request) {
+	var req BanRequest
+	if err := readJSON(r, &req); err != nil {
+		apiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !validUsername(req.Username) {
+		apiError(w, http.StatusBadRequest, "invalid username")
+		return
+	}
+	since := time.Now().UTC()
+	if req.Since != "" {
+		t, err := time.Parse(time.RFC3339, req.Since)
+		if err != nil {
+			apiError(w, http.StatusBadRequest, "since must be RFC3339")
+			return
+		}
+		since = t.UTC()
+	}
+	if !s.requireAdmin(w) {
+		return
+	}
+	if req.Username == s.identity.Username {
+		apiError(w, http.StatusBadRequest, "the admin cannot be banned")
+		return
+	}
+
+	ban := forum.Ban{
+		Username: req.Username,
+		Reason:   strings.TrimSpace(req.Reason),
+		Since:    since.Format(time.RFC3339),
+	}
+	if key, err := os.ReadFile(filepath.Join(s.repo.Path, "keys", req.Username+".pub")); err == nil {
+		ban.PubKey = strings.TrimSpace(string(key))
+	}
+	list, err := forum.LoadBanList(filepath.Join(s.repo.Path, forum.BansFilename), s.identity.PublicKey)
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	s.commitBans(w, list.With(ban), "config: ban @"+req.Username)
+}
+
+// POST /api/admin/unban
+func (s *forumView) handleUnban(w http.ResponseWriter, r *http.Request) {
+	var req UsernameRequest
+	if err := readJSON(r, &req); err != nil {
+		apiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !s.requireAdmin(w) {
+		return
+	}
+	list, err := forum.LoadBanList(filepath.Join(s.repo.Path, forum.BansFilename), s.identity.PublicKey)
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	bans, found := list.Without(req.Username)
+	if !found {
+		apiError(w, http.StatusNotFound, "user is not banned")
+		return
+	}
+	s.commitBans(w, bans, "config: unban @"+req.Username)
+}
+
+// commitBans signs bans as the new ban list, commits it and pushes.
+func (s *forumView) commitBans(w http.ResponseWriter, bans []forum.Ban, msg string) {
+	list, err := forum.SignBanList(s.identity, bans)
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, "sign bans: "+err.Error())
+		return
+	}
+	if err := s.repo.CommitFile(s.identity, forum.BansFilename, list.Format(), msg); err != nil {
+		apiError(w, http.StatusInternalServerError, "commit bans: "+err.Error())
+		return
+	}
+	if err := s.repo.Push(); err != nil {
+		log.Printf("commitBans: push: %v", err)
+	}
+	writeJSON(w, http.StatusOK, OKResponse{OK: true})
+}
+
+// GET /api/mutes
+func (s *forumView) handleMutes(w http.ResponseWriter, r *http.Request) {
+	m, ok := s.muteList(w)
+	if !ok {
+		return
+	}
+	writeMutes(w, m)
+}
+
+func writeMutes(w http.ResponseWriter, m *local.MuteList) {
+	users := m.Users
+	if users == nil {
+		users = []string{}
+	}
+	writeJSON(w, http.StatusOK, MutesResponse{Users: users})
+}
+
+// POST /api/mute
+func (s *forumView) handleMute(w http.ResponseWriter, r *http.Request) {
+	s.changeMute(w, r, true)
+}
+
+// POST /api/unmute
+func (s *forumView) handleUnmute(w http.ResponseWriter, r *http.Request) {
+	s.changeMute(w, r, false)
+}
+
+func (s *forumView) changeMute(w http.ResponseWriter, r *http.Request, mute bool) {
+	var req UsernameRequest
+	if err := readJSON(r, &req); err != nil {
+		apiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !validUsername(req.Username) {
+		apiError(w, http.StatusBadRequest, "invalid username")
+		return
+	}
+	m, ok := s.muteList(w)
+	if !ok {
+		return
+	}
+	if mute && req.Username == s.identity.Username {
+		apiError(w, http.StatusBadRequest, "you cannot mute yourself")
+		return
+	}
+	var changed bool
+	if mute {
+		changed = m.Mute(req.Username)
+	} else {
+		changed = m.Unmute(req.Username)
+	}
+	if changed {
+		if err := m.Save(); err != nil {
+			apiError(w, http.StatusInternalServerError, "save mutes: "+err.Error())
+			return
+		}
+	}
+	writeMutes(w, m)
+}
+
+// muteList loads the local identity's mute list, writing an error response
+// and returning false when there is none.
+func (s *forumView) muteList(w http.ResponseWriter) (*local.MuteList, bool) {
+	if s.identity == nil {
+		apiError(w, http.StatusServiceUnavailable, "no identity configured")
+		return nil, false
+	}
+	if s.repo == nil {
+		apiError(w, http.StatusServiceUnavailable, "forum not initialized")
+		return nil, false
+	}
+	m, err := local.LoadMuteList(s.repo.Path, s.identity.Username)
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, "load mutes: "+err.Error())
+		return nil, false
+	}
+	return m, true
+}
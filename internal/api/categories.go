@@ -0,0 +1,91 @@
+package api
+
+import (
+	"log"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/gosub/gitorum/internal/forum"
+	"github.com/gosub/gitorum/internal/local"
+)
+
+// validCategory reports whether slug is a category path: one or more
+// slash-separated slugs, e.g. "general" or "projects/gitorum".
+func validCategory(slug string) bool {
+	for _, part := range strings.Split(slug, "/") {
+		if !slugRe.MatchString(part) {
+			return false
+		}
+	}
+	return true
+}
+
+// summarizeCategory loads the category at slug and, recursively, its
+// subcategories. The summary's own counts cover the category's threads;
+// the totals add up the whole subtree. rs may be nil.
+func (s *forumView) summarizeCategory(slug string, rs *local.ReadState) (CategorySummary, error) {
+	catDir := filepath.Join(s.repo.Path, filepath.FromSlash(slug))
+	cat, err := forum.LoadCategory(slug, catDir)
+	if err != nil {
+		return CategorySummary{}, err
+	}
+	unread := 0
+	if rs != nil {
+		keysDir := filepath.Join(s.repo.Path, "keys")
+		for _, threadSlug := range cat.ThreadSlugs {
+			if scan, err := forum.ScanThread(threadSlug, filepath.Join(catDir, threadSlug), keysDir); err == nil {
+				unread += unreadIn(rs, slug, scan)
+			}
+		}
+	}
+	sum := CategorySummary{
+		Slug:         cat.Slug,
+		Name:         cat.Name,
+		Description:  cat.Description,
+		Parent:       cat.Parent,
+		Order:        cat.Order,
+		ThreadCount:  len(cat.ThreadSlugs),
+		UnreadCount:  unread,
+		TotalThreads: len(cat.ThreadSlugs),
+		TotalUnread:  unread,
+	}
+	for _, child := range cat.Children {
+		cs, err := s.summarizeCategory(child, rs)
+		if err != nil {
+			log.Printf("summarizeCategory: skip %q: %v", child, err)
+			continue
+		}
+		sum.Children = append(sum.Children, cs)
+		sum.TotalThreads += cs.TotalThreads
+		sum.TotalUnread += cs.TotalUnread
+	}
+	return sum, nil
+}
+
+// categoryParents returns the ancestors of cat, outermost first.
+func (s *forumView) categoryParents(cat *forum.Category) []CategoryRef {
+	var refs []CategoryRef
+	for slug := cat.Parent; slug != ""; {
+		parent, err := forum.LoadCategory(slug, filepath.Join(s.repo.Path, filepath.FromSlash(slug)))
+		if err != nil {
+			break
+		}
+		refs = append(refs, CategoryRef{Slug: parent.Slug, Name: parent.Name})
+		slug = parent.Parent
+	}
+	for i, j := 0, len(refs)-1; i < j; i, j = i+1, j-1 {
+		refs[i], refs[j] = refs[j], refs[i]
+	}
+	return refs
+}
+
+// sortCategories orders sibling summaries by their order field, then slug.
+func sortCategories(cats []CategorySummary) {
+	sort.SliceStable(cats, func(i, j int) bool {
+		if cats[i].Order != cats[j].Order {
+			return cats[i].Order < cats[j].Order
+		}
+		return cats[i].Slug < cats[j].Slug
+	})
+}
@@ -1,7 +1,7 @@
 package api
 
 import (
-	"strings"
+	"time"
 
 	"github.com/gosub/gitorum/internal/forum"
 )
@@ -17,17 +17,42 @@ func postToResponse(p *forum.Post) PostResponse {
 			Tombstoned: true,
 		}
 	}
-	return PostResponse{
-		Author:    p.Author,
-		PubKey:    p.PubKey,
-		Timestamp: p.TimestampRaw,
-		Parent:    p.Parent,
-		Body:      p.Body,
-		BodyHTML:  p.BodyHTML,
-		Filename:  p.Filename,
-		SigStatus: sigStatusStr(p.SigStatus),
-		SigError:  p.SigError,
+	resp := PostResponse{
+		Author:     p.Author,
+		AuthorURL:  userURL(p.Author),
+		PubKey:     p.PubKey,
+		Timestamp:  p.TimestampRaw,
+		Parent:     p.Parent,
+		Body:       p.Body,
+		BodyHTML:   p.BodyHTML,
+		Filename:   p.Filename,
+		SigStatus:  sigStatusStr(p.SigStatus),
+		SigError:   p.SigError,
+		Flag:       p.Flag,
+		FlagReason: p.FlagReason,
 	}
+	for _, q := range p.Quotes {
+		qr := QuoteResponse{Ref: q.Ref, Found: q.Found, Altered: q.Altered}
+		if q.Found {
+			qr.Category, qr.Thread, qr.Filename = q.Category, q.Thread, q.Filename
+			qr.Author, qr.SigStatus = q.Author, sigStatusStr(q.SigStatus)
+		}
+		resp.Quotes = append(resp.Quotes, qr)
+	}
+	return resp
+}
+
+func threadStateToResponse(st *forum.ThreadState) ThreadState {
+	resp := ThreadState{
+		Pinned:    st.Pinned,
+		Locked:    st.Locked,
+		Archived:  st.Archived,
+		ChangedBy: st.ChangedBy,
+	}
+	if !st.ChangedAt.IsZero() {
+		resp.ChangedAt = st.ChangedAt.UTC().Format(time.RFC3339)
+	}
+	return resp
 }
 
 func sigStatusStr(s forum.SigStatus) string {
@@ -52,25 +77,70 @@ func threadSummaryFrom(scan *forum.ThreadScan) ThreadSummary {
 	if scan.Root != nil {
 		author = scan.Root.Author
 		createdAt = scan.Root.TimestampRaw
-		// Title: first non-empty, non-heading-marker line of the body.
-		for _, line := range strings.Split(scan.Root.Body, "\n") {
-			line = strings.TrimSpace(strings.TrimLeft(line, "#"))
-			if line != "" {
-				title = line
-				break
-			}
-		}
-		if len([]rune(title)) > 100 {
-			title = string([]rune(title)[:100]) + "…"
+		if t := forum.ThreadTitle(scan.Root.Body); t != "" {
+			title = t
 		}
 	}
 
-	return ThreadSummary{
+	summary := ThreadSummary{
 		Slug:        scan.Slug,
 		Title:       title,
 		Author:      author,
 		ReplyCount:  scan.ReplyCount,
 		CreatedAt:   createdAt,
 		LastReplyAt: scan.LastReplyAt,
+		IsPoll:      scan.Root != nil && scan.Root.IsPoll(),
+	}
+	if scan.Root != nil {
+		summary.Flag = scan.Root.Flag
+	}
+	if scan.State != nil {
+		summary.Pinned = scan.State.Pinned
+		summary.Locked = scan.State.Locked
+		summary.Archived = scan.State.Archived
+	}
+	return summary
+}
+
+// pollToResponse converts a poll tally to its wire type. username is the
+// local identity, used to report which option it currently has counted.
+func pollToResponse(p *forum.PollResult, username string) *PollResponse {
+	resp := &PollResponse{
+		Options:  make([]PollOptionResponse, 0, len(p.Options)),
+		Voters:   make([]VoteResponse, 0, len(p.Voters)),
+		Rejected: make([]VoteResponse, 0, len(p.Rejected)),
+		Closed:   p.Closed,
+	}
+	if !p.ClosesAt.IsZero() {
+		resp.ClosesAt = p.ClosesAt.UTC().Format(time.RFC3339)
+	}
+	for _, o := range p.Options {
+		resp.Options = append(resp.Options, PollOptionResponse{Text: o.Text, Votes: o.Votes})
+	}
+	for _, v := range p.Voters {
+		resp.Voters = append(resp.Voters, voteToResponse(v))
+		if v.Voter == username {
+			resp.MyVote = v.Option
+		}
+	}
+	for _, v := range p.Rejected {
+		resp.Rejected = append(resp.Rejected, voteToResponse(v))
+	}
+	return resp
+}
+
+func voteToResponse(v *forum.Vote) VoteResponse {
+	ts := ""
+	if !v.Timestamp.IsZero() {
+		ts = v.Timestamp.UTC().Format(time.RFC3339)
+	}
+	return VoteResponse{
+		Voter:     v.Voter,
+		PubKey:    v.PubKey,
+		Option:    v.Option,
+		Timestamp: ts,
+		Filename:  v.Filename,
+		Signature: v.Signature,
+		Reason:    v.Reason,
 	}
 }
@@ -0,0 +1,171 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gosub/gitorum/internal/local"
+)
+
+// GET /api/drafts
+func (s *forumView) handleDrafts(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := s.drafts(w)
+	if !ok {
+		return
+	}
+	resp := DraftsResponse{Drafts: []DraftResponse{}}
+	for _, dr := range d.Drafts {
+		resp.Drafts = append(resp.Drafts, draftToResponse(dr))
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// GET /api/draft?category=...&thread=...
+//
+// Returns the draft reply to a thread, or the draft new thread in the
+// category when thread is omitted.
+func (s *forumView) handleDraft(w http.ResponseWriter, r *http.Request) {
+	category, thread := r.URL.Query().Get("category"), r.URL.Query().Get("thread")
+	if !validDraftRef(category, thread) {
+		apiError(w, http.StatusBadRequest, "invalid category or thread")
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := s.drafts(w)
+	if !ok {
+		return
+	}
+	dr, found := d.Get(local.DraftID(category, thread))
+	if !found {
+		apiError(w, http.StatusNotFound, "no draft")
+		return
+	}
+	writeJSON(w, http.StatusOK, draftToResponse(dr))
+}
+
+// POST /api/draft
+func (s *forumView) handleSaveDraft(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+	var req DraftRequest
+	if err := readJSON(r, &req); err != nil {
+		apiError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+	if !validDraftRef(req.Category, req.Thread) {
+		apiError(w, http.StatusBadRequest, "invalid category or thread")
+		return
+	}
+	if req.Parent != "" && (filepath.Base(req.Parent) != req.Parent || !strings.HasSuffix(req.Parent, ".md")) {
+		apiError(w, http.StatusBadRequest, "invalid parent filename")
+		return
+	}
+	dr := local.Draft{Category: req.Category, Thread: req.Thread, Body: req.Body}
+	if req.Thread == "" {
+		dr.Slug = req.Slug
+	} else {
+		dr.Parent = req.Parent
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := s.drafts(w)
+	if !ok {
+		return
+	}
+	d.Put(dr)
+	if err := d.Save(); err != nil {
+		apiError(w, http.StatusInternalServerError, "save drafts: "+err.Error())
+		return
+	}
+	saved, _ := d.Get(dr.ID())
+	writeJSON(w, http.StatusOK, draftToResponse(saved))
+}
+
+// POST /api/draft/discard
+func (s *forumView) handleDiscardDraft(w http.ResponseWriter, r *http.Request) {
+	var req DraftRequest
+	if err := readJSON(r, &req); err != nil {
+		apiError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+	if !validDraftRef(req.Category, req.Thread) {
+		apiError(w, http.StatusBadRequest, "invalid category or thread")
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, ok := s.drafts(w)
+	if !ok {
+		return
+	}
+	if d.Discard(local.DraftID(req.Category, req.Thread)) {
+		if err := d.Save(); err != nil {
+			apiError(w, http.StatusInternalServerError, "save drafts: "+err.Error())
+			return
+		}
+	}
+	writeJSON(w, http.StatusOK, OKResponse{OK: true})
+}
+
+// discardDraft drops the draft a post was written from once it has been
+// committed. Errors are logged: the post itself has already been made.
+func (s *forumView) discardDraft(category, thread string) {
+	if s.identity == nil || s.repo == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	d, err := local.LoadDrafts(s.repo.Path, s.identity.Username)
+	if err != nil {
+		log.Printf("discardDraft: %v", err)
+		return
+	}
+	if d.Discard(local.DraftID(category, thread)) {
+		if err := d.Save(); err != nil {
+			log.Printf("discardDraft: %v", err)
+		}
+	}
+}
+
+// drafts loads the local identity's drafts, writing an error response and
+// returning false when there is no identity or repo. The caller holds s.mu.
+func (s *forumView) drafts(w http.ResponseWriter) (*local.Drafts, bool) {
+	if s.identity == nil {
+		apiError(w, http.StatusServiceUnavailable, "no identity configured")
+		return nil, false
+	}
+	if s.repo == nil {
+		apiError(w, http.StatusServiceUnavailable, "forum not initialized")
+		return nil, false
+	}
+	d, err := local.LoadDrafts(s.repo.Path, s.identity.Username)
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, "load drafts: "+err.Error())
+		return nil, false
+	}
+	return d, true
+}
+
+// validDraftRef reports whether category and thread name a thread, or a
+// category when thread is empty.
+func validDraftRef(category, thread string) bool {
+	return category != "" && validCategory(category) && (thread == "" || slugRe.MatchString(thread))
+}
+
+func draftToResponse(dr local.Draft) DraftResponse {
+	return DraftResponse{
+		ID:       dr.ID(),
+		Category: dr.Category,
+		Thread:   dr.Thread,
+		Slug:     dr.Slug,
+		Parent:   dr.Parent,
+		Body:     dr.Body,
+		Updated:  dr.Updated.UTC().Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,62 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gosub/gitorum/internal/crypto"
+)
+
+// GET /api/forums
+func (s *Server) handleForums(w http.ResponseWriter, r *http.Request) {
+	resp := ForumsResponse{Forums: []ForumSummary{}}
+	for i, f := range s.forums {
+		sum := ForumSummary{Name: f.Name, Title: f.Name, Default: i == 0}
+		if v := f.view(); v.repo != nil {
+			sum.Initialized = true
+			if meta, err := v.repo.ReadMeta(); err == nil {
+				sum.Title = meta.Name
+			}
+			sum.Unread = v.unreadTotal()
+		}
+		resp.TotalUnread += sum.Unread
+		resp.Forums = append(resp.Forums, sum)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// unreadTotal counts the unread posts of the whole forum.
+func (s *forumView) unreadTotal() int {
+	rs := s.readState()
+	if rs == nil {
+		return 0
+	}
+	slugs, err := s.repo.Categories()
+	if err != nil {
+		log.Printf("unreadTotal: %v", err)
+		return 0
+	}
+	total := 0
+	for _, slug := range slugs {
+		if strings.Contains(slug, "/") {
+			continue // counted in its parent's total
+		}
+		if sum, err := s.summarizeCategory(slug, rs); err == nil {
+			total += sum.TotalUnread
+		}
+	}
+	return total
+}
+
+// shareIdentity hands an identity created by the setup of one forum to the
+// other forums that have none yet.
+func (s *Server) shareIdentity(id *crypto.Identity) {
+	for _, f := range s.forums {
+		f.mu.Lock()
+		if f.identity == nil {
+			f.identity = id
+		}
+		f.mu.Unlock()
+	}
+}
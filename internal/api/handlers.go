@@ -6,11 +6,14 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/gosub/gitorum/internal/crypto"
 	"github.com/gosub/gitorum/internal/forum"
 	"github.com/gosub/gitorum/internal/repo"
+	"github.com/gosub/gitorum/internal/webhook"
 )
 
 const maxBodyBytes = 64 << 10 // 64 KB
@@ -18,7 +21,7 @@ const maxBodyBytes = 64 << 10 // 64 KB
 var slugRe = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
 
 // GET /api/status
-func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+func (s *forumServer) handleStatus(w http.ResponseWriter, r *http.Request) {
 	s.mu.Lock()
 	lastSyncAt := s.lastSyncAt
 	repo := s.repo
@@ -41,6 +44,14 @@ func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 		resp.Synced, resp.RemoteURL = repo.IsSynced()
+		if !resp.Synced {
+			if out, err := repo.Outbox(); err == nil {
+				resp.Pending = len(out.Commits) + len(out.Uncommitted)
+			}
+		}
+		if remotes, err := repo.RemoteStatus(); err == nil {
+			resp.Remotes = remotesToInfo(remotes)
+		}
 	} else {
 		resp.ForumName = "Gitorum"
 		resp.Synced = true
@@ -54,7 +65,19 @@ func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
 }
 
 // POST /api/setup
-func (s *Server) handleSetup(w http.ResponseWriter, r *http.Request) {
+func (s *forumServer) handleSetup(w http.ResponseWriter, r *http.Request) {
+	// A new identity is shared with the other forums, and the new forum
+	// advertised on the local network, once s.mu is released.
+	var generated *crypto.Identity
+	created := false
+	defer func() {
+		if generated != nil {
+			s.server.shareIdentity(generated)
+		}
+		if created {
+			s.server.advertise(s)
+		}
+	}()
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -80,17 +103,16 @@ func (s *Server) handleSetup(w http.ResponseWriter, r *http.Request) {
 	// Reuse existing identity or generate a new one.
 	id := s.identity
 	if id == nil {
-		generated, err := crypto.Generate(req.Username)
+		newID, err := crypto.Generate(req.Username)
 		if err != nil {
 			apiError(w, http.StatusInternalServerError, "generate identity: "+err.Error())
 			return
 		}
-		identPath := crypto.DefaultIdentityPath()
-		if err := generated.Save(identPath); err != nil {
+		if err := newID.Save(s.server.Identities.Default); err != nil {
 			apiError(w, http.StatusInternalServerError, "save identity: "+err.Error())
 			return
 		}
-		id = generated
+		id, generated = newID, newID
 	}
 
 	meta := repo.ForumMeta{
@@ -113,20 +135,43 @@ func (s *Server) handleSetup(w http.ResponseWriter, r *http.Request) {
 
 	s.identity = id
 	s.repo = newRepo
+	created = true
 	writeJSON(w, http.StatusOK, OKResponse{OK: true})
 }
 
 // GET /api/sync
-func (s *Server) handleSync(w http.ResponseWriter, r *http.Request) {
+func (s *forumView) handleSync(w http.ResponseWriter, r *http.Request) {
 	if s.repo == nil {
 		apiError(w, http.StatusServiceUnavailable, "forum not initialized")
 		return
 	}
+	if s.identity == nil {
+		apiError(w, http.StatusServiceUnavailable, "no identity configured")
+		return
+	}
 
-	if err := s.repo.Pull(); err != nil {
-		apiError(w, http.StatusInternalServerError, "pull: "+err.Error())
+	// A remote that cannot be reached must not hold back what the others
+	// brought in, nor the push; its error is reported once the sync is done.
+	before := s.repo.HeadHash()
+	pullErr := s.repo.Pull(s.identity)
+	s.afterPull(before)
+
+	if pullErr != nil {
+		apiError(w, http.StatusBadGateway, pullErr.Error())
 		return
 	}
+	writeJSON(w, http.StatusOK, OKResponse{OK: true})
+}
+
+// afterPull runs what a sync does with the posts a pull brought in since
+// HEAD was at before: webhooks, auto-approval of join requests,
+// notifications, and the push of anything made locally.
+func (s *forumView) afterPull(before string) {
+	if added, err := s.repo.AddedFiles(before); err != nil {
+		log.Printf("sync: list pulled files: %v", err)
+	} else {
+		s.notifyPosts(webhook.SourcePull, added)
+	}
 
 	s.mu.Lock()
 	s.lastSyncAt = time.Now()
@@ -135,30 +180,24 @@ func (s *Server) handleSync(w http.ResponseWriter, r *http.Request) {
 	// Auto-approve join requests if the forum is configured to do so and the
 	// running identity is the admin.
 	if s.identity != nil {
-		if meta, err := s.repo.ReadMeta(); err == nil &&
-			meta.AutoApproveKeys &&
-			s.identity.PublicKey == meta.AdminPubkey {
-			if requests, err := s.repo.JoinRequests(); err == nil {
-				for _, req := range requests {
-					if err := s.repo.ApproveJoinRequest(s.identity, req.Username); err != nil {
-						log.Printf("handleSync: auto-approve %s: %v", req.Username, err)
-					} else {
-						log.Printf("handleSync: auto-approved join request from @%s", req.Username)
-					}
-				}
-			}
+		if meta, err := s.repo.ReadMeta(); err == nil && s.identity.PublicKey == meta.AdminPubkey {
+			s.autoApprove(meta)
 		}
 	}
 
-	if err := s.repo.Push(); err != nil {
-		log.Printf("handleSync: push: %v", err)
+	if s.identity != nil {
+		if _, err := s.updateNotifications(); err != nil {
+			log.Printf("sync: notifications: %v", err)
+		}
 	}
 
-	writeJSON(w, http.StatusOK, OKResponse{OK: true})
+	if err := s.repo.Push(); err != nil {
+		log.Printf("sync: push: %v", err)
+	}
 }
 
 // GET /api/categories
-func (s *Server) handleCategories(w http.ResponseWriter, r *http.Request) {
+func (s *forumView) handleCategories(w http.ResponseWriter, r *http.Request) {
 	if s.repo == nil {
 		writeJSON(w, http.StatusOK, CategoriesResponse{Categories: []CategorySummary{}})
 		return
@@ -170,36 +209,46 @@ func (s *Server) handleCategories(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	rs := s.readState()
 	cats := make([]CategorySummary, 0, len(slugs))
 	for _, slug := range slugs {
-		catDir := filepath.Join(s.repo.Path, slug)
-		cat, err := forum.LoadCategory(slug, catDir)
+		if strings.Contains(slug, "/") {
+			continue // listed under its parent
+		}
+		sum, err := s.summarizeCategory(slug, rs)
 		if err != nil {
 			log.Printf("handleCategories: skip %q: %v", slug, err)
 			continue
 		}
-		cats = append(cats, CategorySummary{
-			Slug:        cat.Slug,
-			Name:        cat.Name,
-			Description: cat.Description,
-			ThreadCount: len(cat.ThreadSlugs),
-		})
+		cats = append(cats, sum)
 	}
+	sortCategories(cats)
 	writeJSON(w, http.StatusOK, CategoriesResponse{Categories: cats})
 }
 
 // GET /api/categories/{cat}/threads
-func (s *Server) handleThreads(w http.ResponseWriter, r *http.Request) {
+func (s *forumView) handleThreads(w http.ResponseWriter, r *http.Request) {
 	catSlug := r.PathValue("cat")
 
 	if s.repo == nil {
 		writeJSON(w, http.StatusOK, ThreadsResponse{
-			Category: catSlug, CategoryName: catSlug, Threads: []ThreadSummary{},
+			Category: catSlug, CategoryName: catSlug,
+			Parents: []CategoryRef{}, Subcategories: []CategorySummary{}, Threads: []ThreadSummary{},
 		})
 		return
 	}
 
-	catDir := filepath.Join(s.repo.Path, catSlug)
+	limit, err := pageLimit(r, defaultThreadPage)
+	if err != nil {
+		apiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if !validCategory(catSlug) {
+		apiError(w, http.StatusNotFound, "category not found")
+		return
+	}
+	catDir := filepath.Join(s.repo.Path, filepath.FromSlash(catSlug))
 	cat, err := forum.LoadCategory(catSlug, catDir)
 	if err != nil {
 		apiError(w, http.StatusNotFound, "category not found")
@@ -207,26 +256,56 @@ func (s *Server) handleThreads(w http.ResponseWriter, r *http.Request) {
 	}
 
 	keysDir := filepath.Join(s.repo.Path, "keys")
+	opts := s.optionsFor(cat)
+	rs := s.readState()
+	subs := make([]CategorySummary, 0, len(cat.Children))
+	for _, child := range cat.Children {
+		sum, err := s.summarizeCategory(child, rs)
+		if err != nil {
+			log.Printf("handleThreads: skip subcategory %q: %v", child, err)
+			continue
+		}
+		subs = append(subs, sum)
+	}
 	summaries := make([]ThreadSummary, 0, len(cat.ThreadSlugs))
 	for _, slug := range cat.ThreadSlugs {
 		threadDir := filepath.Join(catDir, slug)
-		scan, err := forum.ScanThread(slug, threadDir, keysDir)
+		scan, err := forum.ScanThreadWith(slug, threadDir, keysDir, opts)
 		if err != nil {
 			log.Printf("handleThreads: skip thread %q: %v", slug, err)
 			continue
 		}
-		summaries = append(summaries, threadSummaryFrom(scan))
+		summary := threadSummaryFrom(scan)
+		summary.UnreadCount = unreadIn(rs, catSlug, scan)
+		summaries = append(summaries, summary)
+	}
+
+	mode := r.URL.Query().Get("sort")
+	page, next, err := pageThreads(summaries, mode, r.URL.Query().Get("cursor"), limit)
+	if err != nil {
+		apiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if mode == "" {
+		mode = sortActivity
 	}
 
 	writeJSON(w, http.StatusOK, ThreadsResponse{
-		Category:     catSlug,
-		CategoryName: cat.Name,
-		Threads:      summaries,
+		Category:      catSlug,
+		CategoryName:  cat.Name,
+		Parents:       s.categoryParents(cat),
+		Subcategories: subs,
+		Policy:        policyToResponse(cat, opts),
+		CanPost:       s.mayPost(opts, true),
+		Threads:       page,
+		Sort:          mode,
+		Total:         len(summaries),
+		NextCursor:    next,
 	})
 }
 
 // GET /api/threads/{cat}/{thread}
-func (s *Server) handleThread(w http.ResponseWriter, r *http.Request) {
+func (s *forumView) handleThread(w http.ResponseWriter, r *http.Request) {
 	catSlug := r.PathValue("cat")
 	threadSlug := r.PathValue("thread")
 
@@ -234,30 +313,69 @@ func (s *Server) handleThread(w http.ResponseWriter, r *http.Request) {
 		apiError(w, http.StatusServiceUnavailable, "forum not initialized")
 		return
 	}
+	limit, err := pageLimit(r, defaultPostPage)
+	if err != nil {
+		apiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
 
+	var redirect *RedirectResponse
+	if to, ok := s.followRedirects(catSlug, threadSlug); ok {
+		redirect = &RedirectResponse{FromCategory: catSlug, FromThread: threadSlug, Root: to.Root}
+		catSlug, threadSlug = to.Category, to.Thread
+	}
 	threadDir := filepath.Join(s.repo.Path, catSlug, threadSlug)
 	keysDir := filepath.Join(s.repo.Path, "keys")
 
-	thread, err := forum.LoadThread(catSlug, threadSlug, threadDir, keysDir)
+	opts := s.categoryOptions(catSlug)
+	thread, err := forum.LoadThreadWith(catSlug, threadSlug, threadDir, keysDir, opts)
 	if err != nil {
 		apiError(w, http.StatusNotFound, "thread not found")
 		return
 	}
 
+	names := s.displayNames()
+	wot := s.webOfTrust()
+	rs := s.readState()
 	posts := make([]PostResponse, 0, len(thread.Posts))
 	for _, p := range thread.Posts {
-		posts = append(posts, postToResponse(p))
+		resp := postToResponse(p)
+		if resp.Author != "" {
+			resp.AuthorName = names(resp.Author)
+		}
+		resp.Trust, resp.TrustPath = postTrust(p, wot)
+		resp.Unread = rs != nil && !p.Tombstoned && !rs.IsSeen(catSlug, threadSlug, p.Filename)
+		posts = append(posts, resp)
+	}
+	page, next, err := pagePosts(posts, r.URL.Query().Get("cursor"), limit)
+	if err != nil {
+		apiError(w, http.StatusBadRequest, err.Error())
+		return
 	}
 
-	writeJSON(w, http.StatusOK, ThreadResponse{
-		Category: catSlug,
-		Slug:     threadSlug,
-		Posts:    posts,
-	})
+	resp := ThreadResponse{
+		Category:   catSlug,
+		Slug:       threadSlug,
+		Posts:      page,
+		State:      threadStateToResponse(thread.State),
+		CanReply:   s.mayPost(opts, false),
+		Backlinks:  s.backlinksTo(catSlug, threadSlug),
+		TotalPosts: len(posts),
+		NextCursor: next,
+		Redirect:   redirect,
+	}
+	if thread.Poll != nil {
+		username := ""
+		if s.identity != nil {
+			username = s.identity.Username
+		}
+		resp.Poll = pollToResponse(thread.Poll, username)
+	}
+	writeJSON(w, http.StatusOK, resp)
 }
 
 // POST /api/threads/{cat}/{thread}/reply
-func (s *Server) handleReply(w http.ResponseWriter, r *http.Request) {
+func (s *forumView) handleReply(w http.ResponseWriter, r *http.Request) {
 	catSlug := r.PathValue("cat")
 	threadSlug := r.PathValue("thread")
 
@@ -280,14 +398,38 @@ func (s *Server) handleReply(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	rootPath := filepath.Join(s.repo.Path, catSlug, threadSlug, forum.RootFilename)
-	rootContent, err := os.ReadFile(rootPath)
+	threadDir := filepath.Join(s.repo.Path, catSlug, threadSlug)
+	rootContent, err := os.ReadFile(filepath.Join(threadDir, forum.RootFilename))
 	if err != nil {
 		apiError(w, http.StatusNotFound, "thread not found")
 		return
 	}
+	if !s.acceptsReplies(w, threadDir) {
+		return
+	}
+	opts := s.categoryOptions(catSlug)
+	if s.banned(opts) != nil {
+		apiError(w, http.StatusForbidden, "you are banned from this forum")
+		return
+	}
+	if !s.mayPost(opts, false) {
+		apiError(w, http.StatusForbidden, "you may not reply in this category")
+		return
+	}
+	parentContent := rootContent
+	if req.Parent != "" {
+		if filepath.Base(req.Parent) != req.Parent || !strings.HasSuffix(req.Parent, ".md") {
+			apiError(w, http.StatusBadRequest, "invalid parent filename")
+			return
+		}
+		parentContent, err = os.ReadFile(filepath.Join(threadDir, req.Parent))
+		if err != nil {
+			apiError(w, http.StatusNotFound, "parent post not found")
+			return
+		}
+	}
 
-	post, err := forum.SignPost(s.identity, forum.PostHash(rootContent), req.Body)
+	post, err := forum.SignPostWith(s.identity, forum.PostHash(parentContent), req.Body, s.signOptions())
 	if err != nil {
 		apiError(w, http.StatusInternalServerError, "sign post: "+err.Error())
 		return
@@ -299,14 +441,93 @@ func (s *Server) handleReply(w http.ResponseWriter, r *http.Request) {
 		apiError(w, http.StatusInternalServerError, "commit post: "+err.Error())
 		return
 	}
+	s.markRead(catSlug, threadSlug, post.Filename)
+	s.discardDraft(catSlug, threadSlug)
+	s.notifyPosts(webhook.SourceLocal, []string{relPath})
 	if err := s.repo.Push(); err != nil {
 		log.Printf("handleReply: push: %v", err)
 	}
 	writeJSON(w, http.StatusCreated, OKResponse{OK: true})
 }
 
+// POST /api/threads/{cat}/{thread}/vote
+func (s *forumView) handleVote(w http.ResponseWriter, r *http.Request) {
+	catSlug := r.PathValue("cat")
+	threadSlug := r.PathValue("thread")
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
+	var req VoteRequest
+	if err := readJSON(r, &req); err != nil {
+		apiError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+	if req.Option == "" {
+		apiError(w, http.StatusBadRequest, "option is required")
+		return
+	}
+	if s.identity == nil {
+		apiError(w, http.StatusServiceUnavailable, "no identity configured")
+		return
+	}
+	if s.repo == nil {
+		apiError(w, http.StatusServiceUnavailable, "forum not initialized")
+		return
+	}
+
+	threadDir := filepath.Join(s.repo.Path, catSlug, threadSlug)
+	rootContent, err := os.ReadFile(filepath.Join(threadDir, forum.RootFilename))
+	if err != nil {
+		apiError(w, http.StatusNotFound, "thread not found")
+		return
+	}
+	root, err := forum.ParsePost(forum.RootFilename, rootContent)
+	if err != nil || !root.IsPoll() {
+		apiError(w, http.StatusBadRequest, "thread is not a poll")
+		return
+	}
+	if !slices.Contains(root.PollOptions, req.Option) {
+		apiError(w, http.StatusBadRequest, "unknown option: "+req.Option)
+		return
+	}
+	if root.PollCloses != "" {
+		if closes, err := time.Parse(time.RFC3339, root.PollCloses); err == nil && time.Now().After(closes) {
+			apiError(w, http.StatusConflict, "poll is closed")
+			return
+		}
+	}
+	if !s.acceptsReplies(w, threadDir) {
+		return
+	}
+	opts := s.categoryOptions(catSlug)
+	if s.banned(opts) != nil {
+		apiError(w, http.StatusForbidden, "you are banned from this forum")
+		return
+	}
+	if !s.mayPost(opts, false) {
+		apiError(w, http.StatusForbidden, "you may not vote in this category")
+		return
+	}
+
+	vote, err := forum.SignVote(s.identity, rootContent, req.Option)
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, "sign vote: "+err.Error())
+		return
+	}
+	vote.Filename = forum.NewVoteFilename(s.identity.Username, req.Option)
+
+	relPath := filepath.Join(catSlug, threadSlug, vote.Filename)
+	if err := s.repo.CommitPost(s.identity, relPath, vote.Format()); err != nil {
+		apiError(w, http.StatusInternalServerError, "commit vote: "+err.Error())
+		return
+	}
+	if err := s.repo.Push(); err != nil {
+		log.Printf("handleVote: push: %v", err)
+	}
+	writeJSON(w, http.StatusCreated, OKResponse{OK: true})
+}
+
 // POST /api/categories
-func (s *Server) handleCreateCategory(w http.ResponseWriter, r *http.Request) {
+func (s *forumView) handleCreateCategory(w http.ResponseWriter, r *http.Request) {
 	var req CreateCategoryRequest
 	if err := readJSON(r, &req); err != nil {
 		apiError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
@@ -320,17 +541,34 @@ func (s *Server) handleCreateCategory(w http.ResponseWriter, r *http.Request) {
 		apiError(w, http.StatusBadRequest, "slug must be lowercase letters, digits, and hyphens")
 		return
 	}
+	if req.Parent != "" && !validCategory(req.Parent) {
+		apiError(w, http.StatusBadRequest, "invalid parent category")
+		return
+	}
 	if !s.requireAdmin(w) {
 		return
 	}
 
-	catMetaPath := filepath.Join(s.repo.Path, req.Slug, "META.toml")
-	if _, err := os.Stat(catMetaPath); err == nil {
+	slug := req.Slug
+	if req.Parent != "" {
+		parentMeta := filepath.Join(s.repo.Path, filepath.FromSlash(req.Parent), forum.MetaFilename)
+		if _, err := os.Stat(parentMeta); err != nil {
+			apiError(w, http.StatusBadRequest, "parent category not found: "+req.Parent)
+			return
+		}
+		slug = req.Parent + "/" + req.Slug
+	}
+	catDir := filepath.Join(s.repo.Path, filepath.FromSlash(slug))
+	if _, err := os.Stat(filepath.Join(catDir, forum.MetaFilename)); err == nil {
 		apiError(w, http.StatusConflict, "category slug already exists")
 		return
 	}
+	if _, err := os.Stat(filepath.Join(catDir, forum.RootFilename)); err == nil {
+		apiError(w, http.StatusConflict, "a thread with this slug already exists")
+		return
+	}
 
-	if err := s.repo.CreateCategory(s.identity, req.Slug, req.Name, req.Description); err != nil {
+	if err := s.repo.CreateCategory(s.identity, slug, req.Name, req.Description, req.Order); err != nil {
 		apiError(w, http.StatusInternalServerError, "create category: "+err.Error())
 		return
 	}
@@ -341,7 +579,7 @@ func (s *Server) handleCreateCategory(w http.ResponseWriter, r *http.Request) {
 }
 
 // POST /api/threads
-func (s *Server) handleNewThread(w http.ResponseWriter, r *http.Request) {
+func (s *forumView) handleNewThread(w http.ResponseWriter, r *http.Request) {
 	r.Body = http.MaxBytesReader(w, r.Body, maxBodyBytes)
 	var req NewThreadRequest
 	if err := readJSON(r, &req); err != nil {
@@ -365,19 +603,49 @@ func (s *Server) handleNewThread(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	catMetaPath := filepath.Join(s.repo.Path, req.Category, "META.toml")
+	if !validCategory(req.Category) {
+		apiError(w, http.StatusBadRequest, "category not found: "+req.Category)
+		return
+	}
+	catMetaPath := filepath.Join(s.repo.Path, filepath.FromSlash(req.Category), forum.MetaFilename)
 	if _, err := os.Stat(catMetaPath); err != nil {
 		apiError(w, http.StatusBadRequest, "category not found: "+req.Category)
 		return
 	}
 
+	opts := s.categoryOptions(req.Category)
+	if s.banned(opts) != nil {
+		apiError(w, http.StatusForbidden, "you are banned from this forum")
+		return
+	}
+	if !s.mayPost(opts, true) {
+		apiError(w, http.StatusForbidden, "you may not start threads in this category")
+		return
+	}
+
 	threadDir := filepath.Join(s.repo.Path, req.Category, req.Slug)
 	if _, err := os.Stat(threadDir); err == nil {
 		apiError(w, http.StatusConflict, "thread slug already exists")
 		return
 	}
 
-	post, err := forum.SignPost(s.identity, "", req.Body)
+	var post *forum.Post
+	var err error
+	if len(req.PollOptions) > 0 {
+		if err := forum.ValidatePollOptions(req.PollOptions); err != nil {
+			apiError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if req.PollCloses != "" {
+			if _, err := time.Parse(time.RFC3339, req.PollCloses); err != nil {
+				apiError(w, http.StatusBadRequest, "poll_closes must be an RFC3339 time")
+				return
+			}
+		}
+		post, err = forum.SignPollWith(s.identity, req.Body, req.PollOptions, req.PollCloses, s.signOptions())
+	} else {
+		post, err = forum.SignPostWith(s.identity, "", req.Body, s.signOptions())
+	}
 	if err != nil {
 		apiError(w, http.StatusInternalServerError, "sign post: "+err.Error())
 		return
@@ -389,6 +657,9 @@ func (s *Server) handleNewThread(w http.ResponseWriter, r *http.Request) {
 		apiError(w, http.StatusInternalServerError, "commit post: "+err.Error())
 		return
 	}
+	s.markRead(req.Category, req.Slug, post.Filename)
+	s.discardDraft(req.Category, "")
+	s.notifyPosts(webhook.SourceLocal, []string{relPath})
 	if err := s.repo.Push(); err != nil {
 		log.Printf("handleNewThread: push: %v", err)
 	}
@@ -396,7 +667,7 @@ func (s *Server) handleNewThread(w http.ResponseWriter, r *http.Request) {
 }
 
 // GET /api/admin/requests
-func (s *Server) handleJoinRequests(w http.ResponseWriter, r *http.Request) {
+func (s *forumView) handleJoinRequests(w http.ResponseWriter, r *http.Request) {
 	if !s.requireAdmin(w) {
 		return
 	}
@@ -408,15 +679,16 @@ func (s *Server) handleJoinRequests(w http.ResponseWriter, r *http.Request) {
 	summaries := make([]JoinRequestSummary, 0, len(requests))
 	for _, req := range requests {
 		summaries = append(summaries, JoinRequestSummary{
-			Username: req.Username,
-			PubKey:   req.PubKey,
+			Username:  req.Username,
+			PubKey:    req.PubKey,
+			VouchedBy: s.requestVouchedBy(req),
 		})
 	}
 	writeJSON(w, http.StatusOK, JoinRequestsResponse{Requests: summaries})
 }
 
 // POST /api/admin/approve
-func (s *Server) handleApproveRequest(w http.ResponseWriter, r *http.Request) {
+func (s *forumView) handleApproveRequest(w http.ResponseWriter, r *http.Request) {
 	var req ApproveRejectRequest
 	if err := readJSON(r, &req); err != nil {
 		apiError(w, http.StatusBadRequest, err.Error())
@@ -440,7 +712,7 @@ func (s *Server) handleApproveRequest(w http.ResponseWriter, r *http.Request) {
 }
 
 // POST /api/admin/reject
-func (s *Server) handleRejectRequest(w http.ResponseWriter, r *http.Request) {
+func (s *forumView) handleRejectRequest(w http.ResponseWriter, r *http.Request) {
 	var req ApproveRejectRequest
 	if err := readJSON(r, &req); err != nil {
 		apiError(w, http.StatusBadRequest, err.Error())
@@ -464,7 +736,7 @@ func (s *Server) handleRejectRequest(w http.ResponseWriter, r *http.Request) {
 }
 
 // POST /api/admin/delete
-func (s *Server) handleAdminDelete(w http.ResponseWriter, r *http.Request) {
+func (s *forumView) handleAdminDelete(w http.ResponseWriter, r *http.Request) {
 	var req AdminDeleteRequest
 	if err := readJSON(r, &req); err != nil {
 		apiError(w, http.StatusBadRequest, err.Error())
@@ -504,7 +776,7 @@ func (s *Server) handleAdminDelete(w http.ResponseWriter, r *http.Request) {
 }
 
 // POST /api/admin/addkey
-func (s *Server) handleAdminAddKey(w http.ResponseWriter, r *http.Request) {
+func (s *forumView) handleAdminAddKey(w http.ResponseWriter, r *http.Request) {
 	var req AdminAddKeyRequest
 	if err := readJSON(r, &req); err != nil {
 		apiError(w, http.StatusBadRequest, err.Error())
@@ -530,7 +802,7 @@ func (s *Server) handleAdminAddKey(w http.ResponseWriter, r *http.Request) {
 
 // requireAdmin checks that s.identity is the forum admin. It writes the
 // appropriate error response and returns false when the check fails.
-func (s *Server) requireAdmin(w http.ResponseWriter) bool {
+func (s *forumView) requireAdmin(w http.ResponseWriter) bool {
 	if s.identity == nil {
 		apiError(w, http.StatusServiceUnavailable, "no identity configured")
 		return false
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"errors"
+	"io/fs"
+	"log"
+	"net/http"
+
+	"github.com/gosub/gitorum/internal/local"
+)
+
+// GET /api/identities
+func (s *forumServer) handleIdentities(w http.ResponseWriter, r *http.Request) {
+	store := s.server.Identities
+	names, err := store.List()
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	s.mu.Lock()
+	active := s.identity
+	s.mu.Unlock()
+
+	resp := IdentitiesResponse{Identities: []IdentitySummary{}}
+	for _, name := range names {
+		id, err := store.Load(name)
+		if err != nil {
+			log.Printf("handleIdentities: %v", err)
+			continue
+		}
+		resp.Identities = append(resp.Identities, IdentitySummary{
+			Name:        name,
+			Username:    id.Username,
+			Fingerprint: id.Fingerprint(),
+			Active:      active != nil && active.PublicKey == id.PublicKey,
+		})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// POST /api/identity
+//
+// Switches the identity this forum is used as, and remembers the choice for
+// the next start.
+func (s *forumServer) handleUseIdentity(w http.ResponseWriter, r *http.Request) {
+	var req UseIdentityRequest
+	if err := readJSON(r, &req); err != nil {
+		apiError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+	id, err := s.server.Identities.Load(req.Name)
+	if errors.Is(err, fs.ErrNotExist) {
+		apiError(w, http.StatusNotFound, err.Error())
+		return
+	} else if err != nil {
+		apiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.identity = id
+	if s.repo != nil {
+		choice, err := local.LoadIdentityChoice(s.repo.Path)
+		if err == nil {
+			choice.Name = req.Name
+			err = choice.Save()
+		}
+		if err != nil {
+			log.Printf("handleUseIdentity: remember choice: %v", err)
+		}
+	}
+	writeJSON(w, http.StatusOK, OKResponse{OK: true})
+}
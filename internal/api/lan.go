@@ -0,0 +1,160 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/gosub/gitorum/internal/lan"
+)
+
+// GitHandler serves the forums read-only over git's smart HTTP protocol:
+// each forum at /f/{forum}/git/ and the default forum also at /git/. It
+// offers nothing else, so it is safe to expose to the local network.
+func (s *Server) GitHandler() http.Handler {
+	mux := http.NewServeMux()
+	for _, f := range s.forums {
+		mux.Handle("/f/"+f.Name+"/git/", http.StripPrefix("/f/"+f.Name+"/git", f.gitHandler()))
+	}
+	mux.Handle("/git/", http.StripPrefix("/git", s.forums[0].gitHandler()))
+	return mux
+}
+
+func (s *forumServer) gitHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		rp := s.repo
+		s.mu.Unlock()
+		if rp == nil {
+			http.NotFound(w, r)
+			return
+		}
+		rp.GitHandler().ServeHTTP(w, r)
+	})
+}
+
+// StartLAN advertises the forums on the local network with d and serves
+// their git endpoints to peers on ln, until ln is closed. Only the git
+// endpoints are served there: the API stays on the main listener. Forums
+// initialized later by the setup wizard are advertised then.
+func (s *Server) StartLAN(d *lan.Discovery, ln net.Listener) error {
+	addr, ok := ln.Addr().(*net.TCPAddr)
+	if !ok {
+		return fmt.Errorf("LAN listener %s is not TCP", ln.Addr())
+	}
+	s.lan, s.lanPort = d, addr.Port
+	for _, f := range s.forums {
+		s.advertise(f)
+	}
+	if err := d.Start(); err != nil {
+		return fmt.Errorf("start LAN discovery: %w", err)
+	}
+	go func() {
+		if err := http.Serve(ln, s.GitHandler()); err != nil {
+			log.Printf("LAN git endpoint: %v", err)
+		}
+	}()
+	log.Printf("Advertising on the local network; git endpoints on port %d", addr.Port)
+	return nil
+}
+
+// advertise announces f on the local network, once it is initialized.
+func (s *Server) advertise(f *forumServer) {
+	if s.lan == nil {
+		return
+	}
+	f.mu.Lock()
+	rp, id := f.repo, f.identity
+	f.mu.Unlock()
+	if rp == nil {
+		return
+	}
+	meta, err := rp.ReadMeta()
+	if err != nil {
+		log.Printf("advertise %s: %v", f.Name, err)
+		return
+	}
+	user := ""
+	if id != nil {
+		user = id.Username
+	}
+	s.lan.Advertise(lan.Service{
+		Instance: lan.InstanceName(user, f.Name),
+		ForumID:  lan.ForumID(meta.AdminPubkey),
+		Name:     meta.Name,
+		User:     user,
+		Port:     s.lanPort,
+		Path:     "/f/" + f.Name + "/git/",
+	})
+}
+
+// forumID returns the ID the forum is advertised under, or "" when it is
+// not initialized.
+func (s *forumView) forumID() string {
+	if s.repo == nil {
+		return ""
+	}
+	meta, err := s.repo.ReadMeta()
+	if err != nil {
+		return ""
+	}
+	return lan.ForumID(meta.AdminPubkey)
+}
+
+// GET /api/lan/peers
+func (s *forumView) handleLANPeers(w http.ResponseWriter, r *http.Request) {
+	resp := LANPeersResponse{Enabled: s.server.lan != nil, Peers: []LANPeer{}}
+	if id := s.forumID(); resp.Enabled && id != "" {
+		for _, p := range s.server.lan.Peers(id) {
+			resp.Peers = append(resp.Peers, LANPeer{
+				Instance: p.Instance,
+				Name:     p.Name,
+				User:     p.User,
+				URL:      p.URL(),
+				LastSeen: p.LastSeen.UTC().Format(time.RFC3339),
+			})
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// POST /api/lan/sync
+//
+// Fetches from a peer found on the local network and merges the posts it
+// added, as a sync does for a peer remote, then runs the rest of a sync.
+func (s *forumView) handleLANSync(w http.ResponseWriter, r *http.Request) {
+	var req LANSyncRequest
+	if err := readJSON(r, &req); err != nil {
+		apiError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+	if s.repo == nil {
+		apiError(w, http.StatusServiceUnavailable, "forum not initialized")
+		return
+	}
+	if s.identity == nil {
+		apiError(w, http.StatusServiceUnavailable, "no identity configured")
+		return
+	}
+	if s.server.lan == nil {
+		apiError(w, http.StatusConflict, "LAN discovery is off; start the server with --lan")
+		return
+	}
+	peer, ok := s.server.lan.Peer(req.Instance)
+	if !ok || peer.ForumID != s.forumID() {
+		apiError(w, http.StatusNotFound, "no peer "+req.Instance+" for this forum")
+		return
+	}
+
+	before := s.repo.HeadHash()
+	rejected, err := s.repo.PullPeer(s.identity, "lan-"+peer.Instance, peer.URL())
+	if err != nil {
+		apiError(w, http.StatusBadGateway, "pull from "+peer.Instance+": "+err.Error())
+		return
+	}
+	added, _ := s.repo.AddedFiles(before)
+	s.afterPull(before)
+	writeJSON(w, http.StatusOK, LANSyncResponse{OK: true, Added: len(added), Rejected: rejected})
+}
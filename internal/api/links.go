@@ -0,0 +1,54 @@
+package api
+
+import (
+	"path/filepath"
+	"time"
+
+	"github.com/gosub/gitorum/internal/forum"
+)
+
+// backlinksTo returns the posts in other threads that link to or quote the
+// thread. The forum is scanned once per HEAD; every change to the forum is a
+// commit, so the index is rebuilt whenever it may be stale.
+func (s *forumView) backlinksTo(category, thread string) []BacklinkResponse {
+	head := s.repo.HeadHash()
+	s.linksMu.Lock()
+	if s.backlinks == nil || s.backlinksHead != head {
+		adminPubkey := ""
+		if meta, err := s.repo.ReadMeta(); err == nil {
+			adminPubkey = meta.AdminPubkey
+		}
+		s.backlinks = forum.BuildBacklinks(s.repo.Path, filepath.Join(s.repo.Path, "keys"), adminPubkey)
+		s.backlinksHead = head
+	}
+	links := s.backlinks.To(category, thread)
+	s.linksMu.Unlock()
+
+	resp := make([]BacklinkResponse, 0, len(links))
+	for _, l := range links {
+		resp = append(resp, BacklinkResponse{
+			Category:  l.Category,
+			Thread:    l.Thread,
+			Filename:  l.Filename,
+			Title:     l.Title,
+			Author:    l.Author,
+			Timestamp: l.Timestamp.UTC().Format(time.RFC3339),
+			Target:    l.Target,
+		})
+	}
+	return resp
+}
+
+// postIndex returns the index that quotes are resolved against. Like the
+// backlinks, it is kept until HEAD moves, so that the tree is hashed once
+// per change rather than on every thread load.
+func (s *forumView) postIndex(adminPubkey string) *forum.PostIndex {
+	head := s.repo.HeadHash()
+	s.linksMu.Lock()
+	defer s.linksMu.Unlock()
+	if s.index == nil || s.indexHead != head {
+		s.index = forum.NewPostIndex(s.repo.Path, filepath.Join(s.repo.Path, "keys"), adminPubkey)
+		s.indexHead = head
+	}
+	return s.index
+}
@@ -0,0 +1,159 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"github.com/gosub/gitorum/internal/forum"
+	"github.com/gosub/gitorum/internal/local"
+)
+
+// updateNotifications examines the posts that arrived since the previous
+// pass and records those that concern the local identity: mentions, replies
+// to its posts, and replies in threads it started. Only validly signed posts
+// by other users count.
+func (s *forumView) updateNotifications() (*local.Inbox, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	me := s.identity.Username
+	inbox, err := local.LoadInbox(s.repo.Path, me)
+	if err != nil {
+		return nil, err
+	}
+	keysDir := filepath.Join(s.repo.Path, "keys")
+	opts := map[string]forum.LoadOptions{} // per category
+	err = s.eachThread(func(cat *forum.Category, scan *forum.ThreadScan) {
+		key := cat.Slug + "/" + scan.Slug
+		checked := inbox.Checked[key]
+		newest := scan.Filenames[len(scan.Filenames)-1]
+		if newest <= checked {
+			return
+		}
+		if _, ok := opts[cat.Slug]; !ok {
+			opts[cat.Slug] = s.optionsFor(cat)
+		}
+		thread, err := forum.LoadThreadWith(cat.Slug, scan.Slug, filepath.Join(s.repo.Path, cat.Slug, scan.Slug), keysDir, opts[cat.Slug])
+		if err != nil {
+			log.Printf("updateNotifications: %s: %v", key, err)
+			return
+		}
+		title := threadSummaryFrom(scan).Title
+		for _, p := range thread.Posts {
+			if p.Filename <= checked {
+				continue
+			}
+			if kind := notificationKind(thread, p, me); kind != "" {
+				inbox.Add(local.Notification{
+					Category:  cat.Slug,
+					Thread:    scan.Slug,
+					Title:     title,
+					Filename:  p.Filename,
+					Kind:      kind,
+					Author:    p.Author,
+					Timestamp: p.Timestamp,
+				})
+			}
+		}
+		inbox.Checked[key] = newest
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := inbox.Save(); err != nil {
+		return nil, err
+	}
+	return inbox, nil
+}
+
+// notificationKind classifies p for user me, returning "" when p is of no
+// concern to them.
+func notificationKind(t *forum.Thread, p *forum.Post, me string) string {
+	if p.Tombstoned || p.Flag != "" || p.SigStatus != forum.SigValid || p.Author == me {
+		return ""
+	}
+	for _, m := range p.Mentions {
+		if m == me {
+			return local.KindMention
+		}
+	}
+	if p.Parent == "" {
+		return ""
+	}
+	for _, q := range t.Posts {
+		if q.Hash == p.Parent && q.Author == me && q.SigStatus == forum.SigValid {
+			if q == t.Root {
+				return local.KindThreadReply
+			}
+			return local.KindReply
+		}
+	}
+	if t.Root != nil && t.Root.Author == me && t.Root.SigStatus == forum.SigValid {
+		return local.KindThreadReply
+	}
+	return ""
+}
+
+// GET /api/notifications
+func (s *forumView) handleNotifications(w http.ResponseWriter, r *http.Request) {
+	resp := NotificationsResponse{Notifications: []NotificationResponse{}}
+	if s.identity == nil || s.repo == nil {
+		writeJSON(w, http.StatusOK, resp)
+		return
+	}
+	inbox, err := s.updateNotifications()
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, "notifications: "+err.Error())
+		return
+	}
+	resp.Unseen = inbox.Unseen()
+	for _, n := range inbox.Sorted() {
+		resp.Notifications = append(resp.Notifications, NotificationResponse{
+			ID:        n.ID(),
+			Kind:      n.Kind,
+			Category:  n.Category,
+			Thread:    n.Thread,
+			Title:     n.Title,
+			Filename:  n.Filename,
+			Author:    n.Author,
+			Timestamp: n.Timestamp.UTC().Format(time.RFC3339),
+			Seen:      n.Seen,
+		})
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// POST /api/notifications/seen
+func (s *forumView) handleNotificationsSeen(w http.ResponseWriter, r *http.Request) {
+	var req NotificationsSeenRequest
+	if r.ContentLength != 0 {
+		if err := readJSON(r, &req); err != nil {
+			apiError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+			return
+		}
+	}
+	if s.identity == nil {
+		apiError(w, http.StatusServiceUnavailable, "no identity configured")
+		return
+	}
+	if s.repo == nil {
+		apiError(w, http.StatusServiceUnavailable, "forum not initialized")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	inbox, err := local.LoadInbox(s.repo.Path, s.identity.Username)
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, "load notifications: "+err.Error())
+		return
+	}
+	inbox.MarkSeen(req.IDs...)
+	if err := inbox.Save(); err != nil {
+		apiError(w, http.StatusInternalServerError, "save notifications: "+err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, OKResponse{OK: true})
+}
@@ -0,0 +1,147 @@
+package api
+
+import (
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/gosub/gitorum/internal/repo"
+)
+
+// GET /api/outbox
+func (s *forumView) handleOutbox(w http.ResponseWriter, r *http.Request) {
+	if s.repo == nil {
+		apiError(w, http.StatusServiceUnavailable, "forum not initialized")
+		return
+	}
+	s.writeOutbox(w)
+}
+
+// POST /api/outbox/retry
+//
+// Pushes again. A failed push is reported as 502 with the error, which the
+// outbox also records.
+func (s *forumView) handleOutboxRetry(w http.ResponseWriter, r *http.Request) {
+	if s.repo == nil {
+		apiError(w, http.StatusServiceUnavailable, "forum not initialized")
+		return
+	}
+	if err := s.repo.Push(); err != nil {
+		apiError(w, http.StatusBadGateway, "push: "+err.Error())
+		return
+	}
+	s.writeOutbox(w)
+}
+
+// POST /api/outbox/discard
+//
+// Drops a commit no push remote has from the local history, or reverts an
+// uncommitted file.
+func (s *forumView) handleOutboxDiscard(w http.ResponseWriter, r *http.Request) {
+	var req OutboxDiscardRequest
+	if err := readJSON(r, &req); err != nil {
+		apiError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return
+	}
+	if (req.Commit == "") == (req.Path == "") {
+		apiError(w, http.StatusBadRequest, "exactly one of commit and path is required")
+		return
+	}
+	if s.repo == nil {
+		apiError(w, http.StatusServiceUnavailable, "forum not initialized")
+		return
+	}
+	var err error
+	if req.Commit != "" {
+		if s.identity == nil {
+			apiError(w, http.StatusServiceUnavailable, "no identity configured")
+			return
+		}
+		err = s.repo.DiscardCommit(s.identity, req.Commit)
+	} else {
+		err = s.repo.DiscardUncommitted(req.Path)
+	}
+	if err != nil {
+		apiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	s.writeOutbox(w)
+}
+
+func (s *forumView) writeOutbox(w http.ResponseWriter) {
+	out, err := s.repo.Outbox()
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, "outbox: "+err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, outboxToResponse(out))
+}
+
+func outboxToResponse(out repo.Outbox) OutboxResponse {
+	resp := OutboxResponse{
+		Commits:       []PendingCommit{},
+		Uncommitted:   out.Uncommitted,
+		LastPushError: out.LastPushErr,
+	}
+	if resp.Uncommitted == nil {
+		resp.Uncommitted = []string{}
+	}
+	if !out.LastPushAt.IsZero() {
+		resp.LastPushAt = out.LastPushAt.UTC().Format(time.RFC3339)
+	}
+	for _, c := range out.Commits {
+		pc := PendingCommit{
+			Hash:      c.Hash,
+			Message:   c.Message,
+			Author:    c.Author,
+			When:      c.When.UTC().Format(time.RFC3339),
+			Files:     c.Files,
+			PushError: c.PushError,
+			PushedTo:  c.PushedTo,
+		}
+		for _, f := range c.Files {
+			if p, ok := pendingPost(f); ok {
+				pc.Posts = append(pc.Posts, p)
+			}
+		}
+		resp.Commits = append(resp.Commits, pc)
+	}
+	return resp
+}
+
+// pendingPost reports whether the repository path file is a post, a .md
+// file in a thread directory ({category}/.../{thread}/{file}.md).
+func pendingPost(file string) (PendingPost, bool) {
+	if path.Ext(file) != ".md" {
+		return PendingPost{}, false
+	}
+	threadDir := path.Dir(file)
+	category := path.Dir(threadDir)
+	if category == "." || !validCategory(category) {
+		return PendingPost{}, false
+	}
+	return PendingPost{Category: category, Thread: path.Base(threadDir), Filename: path.Base(file)}, true
+}
+
+func remotesToInfo(remotes []repo.RemoteStatus) []RemoteInfo {
+	info := make([]RemoteInfo, 0, len(remotes))
+	for _, rm := range remotes {
+		ri := RemoteInfo{
+			Name:          rm.Name,
+			URL:           rm.URL,
+			Role:          rm.Role,
+			Synced:        rm.Synced,
+			LastPullError: rm.LastPullErr,
+			Rejected:      rm.Rejected,
+			LastPushError: rm.LastPushErr,
+		}
+		if !rm.LastPullAt.IsZero() {
+			ri.LastPullAt = rm.LastPullAt.UTC().Format(time.RFC3339)
+		}
+		if !rm.LastPushAt.IsZero() {
+			ri.LastPushAt = rm.LastPushAt.UTC().Format(time.RFC3339)
+		}
+		info = append(info, ri)
+	}
+	return info
+}
@@ -0,0 +1,198 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Page sizes for list endpoints. Clients ask for more with ?limit=N (up to
+// maxPageSize) and continue from the next_cursor of the previous page.
+const (
+	defaultThreadPage = 50
+	defaultPostPage   = 100
+	defaultAuditPage  = 100
+	maxPageSize       = 500
+)
+
+// Thread sort modes accepted by ?sort= on the thread list.
+const (
+	sortActivity = "activity" // most recent reply first (default)
+	sortNewest   = "newest"   // most recently created first
+	sortReplies  = "replies"  // most replies first
+	sortAlpha    = "alpha"    // by title, A to Z
+)
+
+// pageLimit parses ?limit=, falling back to def when absent.
+func pageLimit(r *http.Request, def int) (int, error) {
+	raw := r.URL.Query().Get("limit")
+	if raw == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 1 || n > maxPageSize {
+		return 0, fmt.Errorf("limit must be between 1 and %d", maxPageSize)
+	}
+	return n, nil
+}
+
+// threadCursor marks the last thread of a page. Cursors are opaque to
+// clients: base64url-encoded JSON, bound to the sort mode they came from.
+type threadCursor struct {
+	Sort string `json:"s"`
+	Rank int    `json:"r"`
+	Key  string `json:"k"`
+	Slug string `json:"t"`
+}
+
+func encodeCursor(v any) string {
+	data, _ := json.Marshal(v)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(raw string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(raw)
+	if err != nil || json.Unmarshal(data, v) != nil {
+		return fmt.Errorf("invalid cursor")
+	}
+	return nil
+}
+
+// threadSortKey returns the value threads are ordered by in mode, as a
+// string that compares correctly.
+func threadSortKey(t ThreadSummary, mode string) string {
+	switch mode {
+	case sortNewest:
+		return utcKey(t.CreatedAt)
+	case sortReplies:
+		return fmt.Sprintf("%010d", t.ReplyCount)
+	case sortAlpha:
+		return strings.ToLower(t.Title)
+	default:
+		return utcKey(t.LastReplyAt)
+	}
+}
+
+// utcKey normalises an RFC3339 timestamp to UTC so that timestamps written
+// with different offsets compare correctly as strings.
+func utcKey(ts string) string {
+	t, err := time.Parse(time.RFC3339, ts)
+	if err != nil {
+		return ts
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// threadRank groups threads before the sort mode applies: pinned threads
+// come first and archived ones last.
+func threadRank(t ThreadSummary) int {
+	switch {
+	case t.Archived:
+		return 2
+	case t.Pinned:
+		return 0
+	default:
+		return 1
+	}
+}
+
+// threadPos is the position of a thread in a sorted listing.
+type threadPos struct {
+	rank int
+	key  string
+	slug string
+}
+
+// threadBefore reports whether thread position a comes before b. Lower ranks
+// come first; within a rank every mode but alpha puts the largest key first,
+// and ties are broken by slug so the order is total.
+func threadBefore(mode string, a, b threadPos) bool {
+	if a.rank != b.rank {
+		return a.rank < b.rank
+	}
+	ka, kb := a.key, b.key
+	if ka != kb {
+		if mode == sortAlpha {
+			return ka < kb
+		}
+		return ka > kb
+	}
+	return a.slug < b.slug
+}
+
+// pageThreads sorts threads (pinned first, archived last, then by mode) and
+// returns up to limit of them that come after the cursor, plus the cursor
+// for the following page ("" when this is the last page).
+func pageThreads(threads []ThreadSummary, mode, cursor string, limit int) ([]ThreadSummary, string, error) {
+	switch mode {
+	case "":
+		mode = sortActivity
+	case sortActivity, sortNewest, sortReplies, sortAlpha:
+	default:
+		return nil, "", fmt.Errorf("unknown sort %q (use %s, %s, %s or %s)", mode, sortActivity, sortNewest, sortReplies, sortAlpha)
+	}
+	pos := make(map[string]threadPos, len(threads))
+	for _, t := range threads {
+		pos[t.Slug] = threadPos{rank: threadRank(t), key: threadSortKey(t, mode), slug: t.Slug}
+	}
+	sort.SliceStable(threads, func(i, j int) bool {
+		return threadBefore(mode, pos[threads[i].Slug], pos[threads[j].Slug])
+	})
+
+	start := 0
+	if cursor != "" {
+		var c threadCursor
+		if err := decodeCursor(cursor, &c); err != nil {
+			return nil, "", err
+		}
+		if c.Sort != mode {
+			return nil, "", fmt.Errorf("cursor was issued for sort %q", c.Sort)
+		}
+		after := threadPos{rank: c.Rank, key: c.Key, slug: c.Slug}
+		start = sort.Search(len(threads), func(i int) bool {
+			return threadBefore(mode, after, pos[threads[i].Slug])
+		})
+	}
+	end := min(start+limit, len(threads))
+	page := threads[start:end]
+	next := ""
+	if end < len(threads) && len(page) > 0 {
+		last := pos[page[len(page)-1].Slug]
+		next = encodeCursor(threadCursor{Sort: mode, Rank: last.rank, Key: last.key, Slug: last.slug})
+	}
+	return page, next, nil
+}
+
+// pagePosts returns up to limit posts following the post whose filename is
+// encoded in cursor, plus the cursor for the following page.
+func pagePosts(posts []PostResponse, cursor string, limit int) ([]PostResponse, string, error) {
+	start := 0
+	if cursor != "" {
+		var after string
+		if err := decodeCursor(cursor, &after); err != nil {
+			return nil, "", err
+		}
+		start = -1
+		for i, p := range posts {
+			if p.Filename == after {
+				start = i + 1
+				break
+			}
+		}
+		if start < 0 {
+			return nil, "", fmt.Errorf("invalid cursor")
+		}
+	}
+	end := min(start+limit, len(posts))
+	page := posts[start:end]
+	next := ""
+	if end < len(posts) && len(page) > 0 {
+		next = encodeCursor(page[len(page)-1].Filename)
+	}
+	return page, next, nil
+}
@@ -0,0 +1,206 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gosub/gitorum/internal/forum"
+)
+
+// policyEntryRe matches a username or an "@role" reference.
+var policyEntryRe = regexp.MustCompile(`^@?[\pL\pN_][\pL\pN_.-]*$`)
+
+// optionsFor returns the load options for threads in cat: the forum-wide
+// settings plus the category's write policy, when it is admin-signed.
+func (s *forumView) optionsFor(cat *forum.Category) forum.LoadOptions {
+	opts, err := forum.ThreadOptions(s.loadOptions(), cat, filepath.Join(s.repo.Path, forum.RolesFilename))
+	if err != nil {
+		log.Printf("optionsFor: %v", err)
+	}
+	return opts
+}
+
+// categoryOptions is optionsFor for a category slug. A category that cannot
+// be loaded gets the forum-wide settings only.
+func (s *forumView) categoryOptions(catSlug string) forum.LoadOptions {
+	cat, err := forum.LoadCategory(catSlug, filepath.Join(s.repo.Path, filepath.FromSlash(catSlug)))
+	if err != nil {
+		return s.loadOptions()
+	}
+	return s.optionsFor(cat)
+}
+
+// mayPost reports whether the local identity may start a thread (root) or
+// reply under opts. The admin may always post; banned users never may.
+func (s *forumView) mayPost(opts forum.LoadOptions, root bool) bool {
+	pm := opts.Permissions
+	switch {
+	case s.identity == nil || s.banned(opts) != nil:
+		return false
+	case pm == nil || s.identity.PublicKey == opts.AdminPubkey:
+		return true
+	case root:
+		return pm.CanStartThread(s.identity.Username)
+	default:
+		return pm.CanReply(s.identity.Username)
+	}
+}
+
+// POST /api/admin/category-policy
+func (s *forumView) handleCategoryPolicy(w http.ResponseWriter, r *http.Request) {
+	var req CategoryPolicyRequest
+	if err := readJSON(r, &req); err != nil {
+		apiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !validCategory(req.Category) {
+		apiError(w, http.StatusBadRequest, "invalid category")
+		return
+	}
+	threads, err := policyEntries(req.Threads)
+	if err != nil {
+		apiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	replies, err := policyEntries(req.Replies)
+	if err != nil {
+		apiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !s.requireAdmin(w) {
+		return
+	}
+
+	catDir := filepath.Join(s.repo.Path, filepath.FromSlash(req.Category))
+	var policy *forum.WritePolicy
+	if len(threads) > 0 || len(replies) > 0 {
+		if policy, err = forum.SignWritePolicy(s.identity, req.Category, threads, replies); err != nil {
+			apiError(w, http.StatusInternalServerError, "sign policy: "+err.Error())
+			return
+		}
+	}
+	content, err := forum.CategoryMetaWithPolicy(catDir, policy)
+	if err != nil {
+		apiError(w, http.StatusNotFound, "category not found")
+		return
+	}
+
+	relPath := filepath.Join(filepath.FromSlash(req.Category), forum.MetaFilename)
+	msg := "category: set write policy of " + req.Category
+	if policy == nil {
+		msg = "category: clear write policy of " + req.Category
+	}
+	if err := s.repo.CommitFile(s.identity, relPath, content, msg); err != nil {
+		apiError(w, http.StatusInternalServerError, "commit policy: "+err.Error())
+		return
+	}
+	if err := s.repo.Push(); err != nil {
+		log.Printf("handleCategoryPolicy: push: %v", err)
+	}
+	writeJSON(w, http.StatusOK, OKResponse{OK: true})
+}
+
+// GET /api/admin/roles
+func (s *forumView) handleRoles(w http.ResponseWriter, r *http.Request) {
+	if !s.requireAdmin(w) {
+		return
+	}
+	roles, err := forum.LoadRoles(filepath.Join(s.repo.Path, forum.RolesFilename), s.identity.PublicKey)
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	members := roles.Members
+	if members == nil {
+		members = map[string][]string{}
+	}
+	writeJSON(w, http.StatusOK, RolesResponse{Roles: members})
+}
+
+// POST /api/admin/roles
+func (s *forumView) handleSetRoles(w http.ResponseWriter, r *http.Request) {
+	var req RolesResponse
+	if err := readJSON(r, &req); err != nil {
+		apiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	members := make(map[string][]string, len(req.Roles))
+	for role, users := range req.Roles {
+		if !slugRe.MatchString(role) {
+			apiError(w, http.StatusBadRequest, fmt.Sprintf("invalid role name %q", role))
+			return
+		}
+		list, err := policyEntries(users)
+		if err != nil {
+			apiError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		for _, u := range list {
+			if strings.HasPrefix(u, "@") {
+				apiError(w, http.StatusBadRequest, "roles cannot contain other roles")
+				return
+			}
+		}
+		if len(list) > 0 {
+			members[role] = list
+		}
+	}
+	if !s.requireAdmin(w) {
+		return
+	}
+
+	roles, err := forum.SignRoles(s.identity, members)
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, "sign roles: "+err.Error())
+		return
+	}
+	if err := s.repo.CommitFile(s.identity, forum.RolesFilename, roles.Format(), "config: update roles"); err != nil {
+		apiError(w, http.StatusInternalServerError, "commit roles: "+err.Error())
+		return
+	}
+	if err := s.repo.Push(); err != nil {
+		log.Printf("handleSetRoles: push: %v", err)
+	}
+	writeJSON(w, http.StatusOK, OKResponse{OK: true})
+}
+
+// policyEntries trims, validates, deduplicates and sorts usernames and
+// "@role" references.
+func policyEntries(in []string) ([]string, error) {
+	seen := map[string]bool{}
+	var out []string
+	for _, e := range in {
+		e = strings.TrimSpace(e)
+		if e == "" || seen[e] {
+			continue
+		}
+		if !policyEntryRe.MatchString(e) {
+			return nil, fmt.Errorf("invalid user or role %q", e)
+		}
+		seen[e] = true
+		out = append(out, e)
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+// policyToResponse converts the write policy of cat for the wire; it is nil
+// unless opts carries the policy, i.e. it is admin-signed.
+func policyToResponse(cat *forum.Category, opts forum.LoadOptions) *CategoryPolicy {
+	if opts.Permissions == nil {
+		return nil
+	}
+	resp := &CategoryPolicy{Threads: cat.Policy.Threads, Replies: cat.Policy.Replies}
+	if resp.Threads == nil {
+		resp.Threads = []string{}
+	}
+	if resp.Replies == nil {
+		resp.Replies = []string{}
+	}
+	return resp
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"github.com/gosub/gitorum/internal/forum"
+	"github.com/gosub/gitorum/internal/repo"
+)
+
+// GET /api/threads/{cat}/{thread}/posts/{file}/provenance
+//
+// Reports the commit that added the post to the repository and every later
+// commit that touched it. A post whose content was changed after it was
+// committed has been tampered with, whatever its signature says.
+func (s *forumView) handleProvenance(w http.ResponseWriter, r *http.Request) {
+	catSlug, threadSlug, file := r.PathValue("cat"), r.PathValue("thread"), r.PathValue("file")
+	if !validCategory(catSlug) || !slugRe.MatchString(threadSlug) || file != path.Base(file) || path.Ext(file) != ".md" {
+		apiError(w, http.StatusBadRequest, "invalid post path")
+		return
+	}
+	if s.repo == nil {
+		apiError(w, http.StatusServiceUnavailable, "forum not initialized")
+		return
+	}
+	if to, ok := s.followRedirects(catSlug, threadSlug); ok {
+		catSlug, threadSlug = to.Category, to.Thread
+	}
+	relPath := path.Join(catSlug, threadSlug, file)
+	content, err := os.ReadFile(filepath.Join(s.repo.Path, filepath.FromSlash(relPath)))
+	if err != nil {
+		apiError(w, http.StatusNotFound, "post not found")
+		return
+	}
+	post, err := forum.ParsePost(file, content)
+	if err != nil {
+		apiError(w, http.StatusUnprocessableEntity, "parse post: "+err.Error())
+		return
+	}
+
+	prov, err := s.repo.Provenance(relPath)
+	if errors.Is(err, repo.ErrNotInHistory) {
+		apiError(w, http.StatusNotFound, "post is not committed yet")
+		return
+	} else if err != nil {
+		apiError(w, http.StatusInternalServerError, "provenance: "+err.Error())
+		return
+	}
+	resp := ProvenanceResponse{
+		Path:       prov.Path,
+		ClaimedAt:  post.Timestamp.UTC().Format(time.RFC3339),
+		Introduced: provenanceCommit(prov.Introduced, repo.ChangeAdd, ""),
+		Later:      []ProvenanceCommit{},
+	}
+	for _, t := range prov.Later {
+		resp.Later = append(resp.Later, provenanceCommit(t.CommitVerification, t.Kind, t.From))
+		if t.Kind == repo.TouchModify {
+			resp.Modified = true
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func provenanceCommit(c repo.CommitVerification, change, from string) ProvenanceCommit {
+	return ProvenanceCommit{
+		Hash:      c.Hash,
+		Subject:   c.Subject,
+		Committer: c.Committer,
+		When:      c.When.UTC().Format(time.RFC3339),
+		Signature: string(c.Status),
+		Signer:    c.Signer,
+		Change:    change,
+		From:      from,
+	}
+}
@@ -0,0 +1,192 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/gosub/gitorum/internal/forum"
+	"github.com/gosub/gitorum/internal/local"
+)
+
+// readState loads the read state of the local identity. It returns nil
+// when there is no identity or repo, in which case nothing counts as unread.
+func (s *forumView) readState() *local.ReadState {
+	if s.identity == nil || s.repo == nil {
+		return nil
+	}
+	rs, err := local.LoadReadState(s.repo.Path, s.identity.Username)
+	if err != nil {
+		log.Printf("readState: %v", err)
+		return nil
+	}
+	return rs
+}
+
+// markRead records the posts filenames of a thread as seen. Errors are
+// logged: failing to persist the read state must not fail the request.
+func (s *forumView) markRead(catSlug, threadSlug string, filenames ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rs := s.readState()
+	if rs == nil {
+		return
+	}
+	rs.MarkRead(catSlug, threadSlug, filenames...)
+	if err := rs.Save(); err != nil {
+		log.Printf("markRead: %v", err)
+	}
+}
+
+// unreadIn counts the unread posts of a thread scan; rs may be nil.
+func unreadIn(rs *local.ReadState, catSlug string, scan *forum.ThreadScan) int {
+	if rs == nil {
+		return 0
+	}
+	return rs.Unread(catSlug, scan.Slug, scan.Filenames)
+}
+
+// POST /api/threads/{cat}/{thread}/read
+func (s *forumView) handleMarkRead(w http.ResponseWriter, r *http.Request) {
+	catSlug := r.PathValue("cat")
+	threadSlug := r.PathValue("thread")
+	if !validCategory(catSlug) || !slugRe.MatchString(threadSlug) {
+		apiError(w, http.StatusBadRequest, "invalid thread path")
+		return
+	}
+
+	var req MarkReadRequest
+	if r.ContentLength != 0 {
+		if err := readJSON(r, &req); err != nil {
+			apiError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+			return
+		}
+	}
+	if s.identity == nil {
+		apiError(w, http.StatusServiceUnavailable, "no identity configured")
+		return
+	}
+	if s.repo == nil {
+		apiError(w, http.StatusServiceUnavailable, "forum not initialized")
+		return
+	}
+
+	threadDir := filepath.Join(s.repo.Path, filepath.FromSlash(catSlug), threadSlug)
+	scan, err := forum.ScanThread(threadSlug, threadDir, filepath.Join(s.repo.Path, "keys"))
+	if err != nil {
+		apiError(w, http.StatusNotFound, "thread not found")
+		return
+	}
+	filenames := req.Filenames
+	if len(filenames) == 0 {
+		filenames = scan.Filenames
+	}
+	for _, f := range filenames {
+		if !slices.Contains(scan.Filenames, f) {
+			apiError(w, http.StatusBadRequest, "no post "+f+" in this thread")
+			return
+		}
+	}
+	s.markRead(catSlug, threadSlug, filenames...)
+	writeJSON(w, http.StatusOK, OKResponse{OK: true})
+}
+
+// GET /api/new
+func (s *forumView) handleWhatsNew(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	lastSyncAt := s.lastSyncAt
+	s.mu.Unlock()
+
+	resp := WhatsNewResponse{Threads: []UnreadThread{}}
+	if !lastSyncAt.IsZero() {
+		resp.LastSyncAt = lastSyncAt.UTC().Format(time.RFC3339)
+	}
+	rs := s.readState()
+	if rs == nil {
+		writeJSON(w, http.StatusOK, resp)
+		return
+	}
+
+	err := s.eachThread(func(cat *forum.Category, scan *forum.ThreadScan) {
+		if n := unreadIn(rs, cat.Slug, scan); n > 0 {
+			summary := threadSummaryFrom(scan)
+			summary.UnreadCount = n
+			resp.Threads = append(resp.Threads, UnreadThread{
+				Category:      cat.Slug,
+				CategoryName:  cat.Name,
+				ThreadSummary: summary,
+			})
+		}
+	})
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, "list threads: "+err.Error())
+		return
+	}
+	sort.SliceStable(resp.Threads, func(i, j int) bool {
+		return resp.Threads[i].LastReplyAt > resp.Threads[j].LastReplyAt
+	})
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// POST /api/new/read
+func (s *forumView) handleMarkAllRead(w http.ResponseWriter, r *http.Request) {
+	if s.identity == nil {
+		apiError(w, http.StatusServiceUnavailable, "no identity configured")
+		return
+	}
+	if s.repo == nil {
+		apiError(w, http.StatusServiceUnavailable, "forum not initialized")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rs := s.readState()
+	if rs == nil {
+		apiError(w, http.StatusInternalServerError, "could not load read state")
+		return
+	}
+	err := s.eachThread(func(cat *forum.Category, scan *forum.ThreadScan) {
+		rs.MarkRead(cat.Slug, scan.Slug, scan.Filenames...)
+	})
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, "list threads: "+err.Error())
+		return
+	}
+	if err := rs.Save(); err != nil {
+		apiError(w, http.StatusInternalServerError, "save read state: "+err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, OKResponse{OK: true})
+}
+
+// eachThread scans every thread of every category and calls fn for each.
+// Categories or threads that cannot be read are logged and skipped.
+func (s *forumView) eachThread(fn func(cat *forum.Category, scan *forum.ThreadScan)) error {
+	slugs, err := s.repo.Categories()
+	if err != nil {
+		return err
+	}
+	keysDir := filepath.Join(s.repo.Path, "keys")
+	for _, slug := range slugs {
+		catDir := filepath.Join(s.repo.Path, slug)
+		cat, err := forum.LoadCategory(slug, catDir)
+		if err != nil {
+			log.Printf("eachThread: skip category %q: %v", slug, err)
+			continue
+		}
+		opts := s.optionsFor(cat)
+		for _, threadSlug := range cat.ThreadSlugs {
+			scan, err := forum.ScanThreadWith(threadSlug, filepath.Join(catDir, threadSlug), keysDir, opts)
+			if err != nil {
+				log.Printf("eachThread: skip thread %q: %v", threadSlug, err)
+				continue
+			}
+			fn(cat, scan)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"path/filepath"
+
+	"github.com/gosub/gitorum/internal/forum"
+)
+
+// followRedirects returns where the thread at category/thread lives now when
+// it was moved or merged, and false when it is not a redirect.
+func (s *forumView) followRedirects(category, thread string) (forum.Redirected, bool) {
+	meta, err := s.repo.ReadMeta()
+	if err != nil {
+		return forum.Redirected{}, false
+	}
+	return forum.FollowRedirects(s.repo.Path, filepath.Join(s.repo.Path, "keys"), meta.AdminPubkey, category, thread)
+}
+
+// POST /api/admin/move
+func (s *forumView) handleMoveThread(w http.ResponseWriter, r *http.Request) {
+	s.relocateThread(w, r, false)
+}
+
+// POST /api/admin/merge
+func (s *forumView) handleMergeThread(w http.ResponseWriter, r *http.Request) {
+	s.relocateThread(w, r, true)
+}
+
+// relocateThread moves the thread in the request, or merges it into the
+// destination thread, leaving a redirect record in its old directory.
+func (s *forumView) relocateThread(w http.ResponseWriter, r *http.Request, merge bool) {
+	var req ThreadMoveRequest
+	if err := readJSON(r, &req); err != nil {
+		apiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.Category == "" || req.Thread == "" || req.ToCategory == "" || req.ToThread == "" {
+		apiError(w, http.StatusBadRequest, "category, thread, to_category and to_thread are required")
+		return
+	}
+	if !validCategory(req.ToCategory) || !slugRe.MatchString(req.ToThread) {
+		apiError(w, http.StatusBadRequest, "invalid destination")
+		return
+	}
+	if !s.requireAdmin(w) {
+		return
+	}
+
+	var (
+		plan *forum.ThreadRelocation
+		msg  string
+		err  error
+	)
+	if merge {
+		plan, err = forum.PlanMerge(s.identity, s.repo.Path, req.Category, req.Thread, req.ToCategory, req.ToThread)
+		msg = fmt.Sprintf("thread: merge %s/%s into %s/%s", req.Category, req.Thread, req.ToCategory, req.ToThread)
+	} else {
+		plan, err = forum.PlanMove(s.identity, s.repo.Path, req.Category, req.Thread, req.ToCategory, req.ToThread)
+		msg = fmt.Sprintf("thread: move %s/%s to %s/%s", req.Category, req.Thread, req.ToCategory, req.ToThread)
+	}
+	if err != nil {
+		apiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	writes := map[string][]byte{plan.RedirectPath: plan.Redirect.Format()}
+	if err := s.repo.CommitMoves(s.identity, msg, plan.Moves, writes); err != nil {
+		apiError(w, http.StatusInternalServerError, "commit: "+err.Error())
+		return
+	}
+	if err := s.repo.Push(); err != nil {
+		log.Printf("relocateThread: push: %v", err)
+	}
+	writeJSON(w, http.StatusOK, OKResponse{OK: true})
+}
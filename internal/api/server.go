@@ -7,55 +7,230 @@ import (
 	"io/fs"
 	"log"
 	"net/http"
+	"path/filepath"
+	"regexp"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/gosub/gitorum/internal/crypto"
+	"github.com/gosub/gitorum/internal/forum"
+	"github.com/gosub/gitorum/internal/lan"
 	"github.com/gosub/gitorum/internal/repo"
+	"github.com/gosub/gitorum/internal/webhook"
 )
 
-// Server holds runtime state for the HTTP server.
+// Server serves one or more forums. Each forum's API lives under
+// /f/{forum}/api/; the first forum is the default and is also served under
+// /api/, so single-forum setups keep their URLs.
 type Server struct {
-	Port     int
+	Port int
+	// RepoPath is the repository of the default forum.
 	RepoPath string
-	repo     *repo.Repo
-	identity *crypto.Identity
+	// Identities is where identities are loaded from when switching, and
+	// where the setup wizard saves a new one.
+	Identities *crypto.Store
+	// TrustHops is how many vouches away from the local identity keys are
+	// still trusted.
+	TrustHops int
+
+	forums  []*forumServer // in the order given; the first is the default
+	lan     *lan.Discovery // nil unless StartLAN was called
+	lanPort int            // port of the LAN git endpoints
+}
+
+// ForumConfig names a forum repository to serve. Repo may be nil when the
+// forum has not been initialized yet.
+type ForumConfig struct {
+	Name     string // URL segment in /f/{name}/api/
+	RepoPath string
+	Repo     *repo.Repo
+	Identity *crypto.Identity // identity used in this forum; nil for the server's
+}
+
+// forumServer holds the runtime state of one forum.
+type forumServer struct {
+	Name       string
+	RepoPath   string
+	server     *Server
+	repo       *repo.Repo
+	identity   *crypto.Identity
 	mu         sync.Mutex // guards repo, identity, and lastSyncAt
 	lastSyncAt time.Time
+	hooks      *webhook.Dispatcher
+
+	linksMu       sync.Mutex // guards backlinks, index and their HEADs
+	backlinks     *forum.Backlinks
+	backlinksHead string // HEAD the backlinks were built at
+	index         *forum.PostIndex
+	indexHead     string // HEAD the index was built at
 }
 
-// New creates a Server. repo and identity may be nil when the forum has not
-// been initialized yet; handlers degrade gracefully in that case.
+// forumView is a forum as one request sees it. The repository and identity
+// are read once, under the lock, when the request starts and shadow the
+// forumServer's: the setup wizard and an identity switch replace them, and a
+// request must not sign with one identity and commit as another.
+type forumView struct {
+	*forumServer
+	repo     *repo.Repo
+	identity *crypto.Identity
+}
+
+// view returns a snapshot of the forum's repository and identity.
+func (s *forumServer) view() *forumView {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return &forumView{forumServer: s, repo: s.repo, identity: s.identity}
+}
+
+// New creates a Server for a single forum. repo and identity may be nil
+// when the forum has not been initialized yet; handlers degrade gracefully
+// in that case.
 func New(port int, repoPath string, r *repo.Repo, id *crypto.Identity) *Server {
-	return &Server{Port: port, RepoPath: repoPath, repo: r, identity: id}
+	s, err := NewMulti(port, []ForumConfig{{Name: ForumName(repoPath), RepoPath: repoPath, Repo: r}}, id)
+	if err != nil {
+		panic(err) // a single forum always has a valid, unique name
+	}
+	return s
 }
 
+// NewMulti creates a Server for several forums. Each forum uses its own
+// identity when the config has one, and id otherwise. Forum names must be
+// unique slugs.
+func NewMulti(port int, forums []ForumConfig, id *crypto.Identity) (*Server, error) {
+	if len(forums) == 0 {
+		return nil, fmt.Errorf("no forums to serve")
+	}
+	s := &Server{Port: port, RepoPath: forums[0].RepoPath, Identities: crypto.DefaultStore(), TrustHops: forum.DefaultTrustHops}
+	seen := map[string]bool{}
+	for _, fc := range forums {
+		if !slugRe.MatchString(fc.Name) {
+			return nil, fmt.Errorf("invalid forum name %q: use lowercase letters, digits and dashes", fc.Name)
+		}
+		if seen[fc.Name] {
+			return nil, fmt.Errorf("forum %q is listed twice", fc.Name)
+		}
+		seen[fc.Name] = true
+		fid := fc.Identity
+		if fid == nil {
+			fid = id
+		}
+		s.forums = append(s.forums, &forumServer{
+			Name:     fc.Name,
+			RepoPath: fc.RepoPath,
+			server:   s,
+			repo:     fc.Repo,
+			identity: fid,
+			hooks:    webhook.NewDispatcher(),
+		})
+	}
+	return s, nil
+}
+
+// ForumName derives a forum name from a repository path: its directory
+// name, lowercased, with anything but letters and digits turned into
+// dashes.
+func ForumName(repoPath string) string {
+	if abs, err := filepath.Abs(repoPath); err == nil {
+		repoPath = abs
+	}
+	name := strings.Trim(nonSlugRe.ReplaceAllString(strings.ToLower(filepath.Base(repoPath)), "-"), "-")
+	if name == "" {
+		return "forum"
+	}
+	return name
+}
+
+var nonSlugRe = regexp.MustCompile(`[^a-z0-9]+`)
+
 // Handler returns an http.Handler with all routes registered.
 // staticFS is typically ui.StaticFS.
 func (s *Server) Handler(staticFS fs.FS) http.Handler {
 	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/forums", s.handleForums)
+	for _, f := range s.forums {
+		mux.Handle("/f/"+f.Name+"/api/", http.StripPrefix("/f/"+f.Name, f.routes()))
+	}
+	mux.HandleFunc("/f/{forum}/api/", func(w http.ResponseWriter, r *http.Request) {
+		apiError(w, http.StatusNotFound, "unknown forum "+r.PathValue("forum"))
+	})
+	mux.Handle("/api/", s.forums[0].routes())
+	git := s.GitHandler()
+	mux.Handle("/git/", git)
+	for _, f := range s.forums {
+		mux.Handle("/f/"+f.Name+"/git/", git)
+	}
+	mux.Handle("/", http.FileServer(http.FS(staticFS)))
+	return mux
+}
+
+// routes returns the API of one forum, rooted at /api/.
+func (s *forumServer) routes() http.Handler {
+	mux := http.NewServeMux()
+	handle := func(pattern string, h func(*forumView, http.ResponseWriter, *http.Request)) {
+		mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) { h(s.view(), w, r) })
+	}
 	mux.HandleFunc("GET /api/status", s.handleStatus)
+	mux.HandleFunc("GET /api/identities", s.handleIdentities)
+	mux.HandleFunc("POST /api/identity", s.handleUseIdentity)
 	mux.HandleFunc("POST /api/setup", s.handleSetup)
-	mux.HandleFunc("GET /api/sync", s.handleSync)
-	mux.HandleFunc("GET /api/categories", s.handleCategories)
-	mux.HandleFunc("GET /api/categories/{cat}/threads", s.handleThreads)
-	mux.HandleFunc("GET /api/threads/{cat}/{thread}", s.handleThread)
-	mux.HandleFunc("POST /api/threads/{cat}/{thread}/reply", s.handleReply)
-	mux.HandleFunc("POST /api/threads", s.handleNewThread)
-	mux.HandleFunc("POST /api/categories", s.handleCreateCategory)
-	mux.HandleFunc("GET /api/admin/requests", s.handleJoinRequests)
-	mux.HandleFunc("POST /api/admin/approve", s.handleApproveRequest)
-	mux.HandleFunc("POST /api/admin/reject", s.handleRejectRequest)
-	mux.HandleFunc("POST /api/admin/delete", s.handleAdminDelete)
-	mux.HandleFunc("POST /api/admin/addkey", s.handleAdminAddKey)
-	mux.Handle("/", http.FileServer(http.FS(staticFS)))
+	handle("GET /api/sync", (*forumView).handleSync)
+	handle("GET /api/categories", (*forumView).handleCategories)
+	handle("GET /api/categories/{cat}/threads", (*forumView).handleThreads)
+	handle("GET /api/threads/{cat}/{thread}", (*forumView).handleThread)
+	handle("POST /api/threads/{cat}/{thread}/reply", (*forumView).handleReply)
+	handle("POST /api/threads/{cat}/{thread}/vote", (*forumView).handleVote)
+	handle("POST /api/threads/{cat}/{thread}/read", (*forumView).handleMarkRead)
+	handle("GET /api/threads/{cat}/{thread}/posts/{file}/provenance", (*forumView).handleProvenance)
+	handle("GET /api/new", (*forumView).handleWhatsNew)
+	handle("POST /api/new/read", (*forumView).handleMarkAllRead)
+	handle("GET /api/notifications", (*forumView).handleNotifications)
+	handle("POST /api/notifications/seen", (*forumView).handleNotificationsSeen)
+	handle("GET /api/webhooks", (*forumView).handleWebhooks)
+	handle("GET /api/users/{username}", (*forumView).handleUser)
+	handle("POST /api/profile", (*forumView).handleSetProfile)
+	handle("GET /api/bans", (*forumView).handleBans)
+	handle("GET /api/mutes", (*forumView).handleMutes)
+	handle("POST /api/mute", (*forumView).handleMute)
+	handle("POST /api/unmute", (*forumView).handleUnmute)
+	handle("GET /api/trust", (*forumView).handleTrust)
+	handle("POST /api/vouch", (*forumView).handleVouch)
+	handle("POST /api/unvouch", (*forumView).handleUnvouch)
+	handle("GET /api/drafts", (*forumView).handleDrafts)
+	handle("GET /api/draft", (*forumView).handleDraft)
+	handle("POST /api/draft", (*forumView).handleSaveDraft)
+	handle("POST /api/draft/discard", (*forumView).handleDiscardDraft)
+	handle("GET /api/lan/peers", (*forumView).handleLANPeers)
+	handle("POST /api/lan/sync", (*forumView).handleLANSync)
+	handle("GET /api/outbox", (*forumView).handleOutbox)
+	handle("POST /api/outbox/retry", (*forumView).handleOutboxRetry)
+	handle("POST /api/outbox/discard", (*forumView).handleOutboxDiscard)
+	handle("POST /api/threads", (*forumView).handleNewThread)
+	handle("POST /api/categories", (*forumView).handleCreateCategory)
+	handle("GET /api/admin/requests", (*forumView).handleJoinRequests)
+	handle("POST /api/admin/approve", (*forumView).handleApproveRequest)
+	handle("POST /api/admin/reject", (*forumView).handleRejectRequest)
+	handle("POST /api/admin/delete", (*forumView).handleAdminDelete)
+	handle("POST /api/admin/addkey", (*forumView).handleAdminAddKey)
+	handle("POST /api/admin/thread-state", (*forumView).handleThreadState)
+	handle("POST /api/admin/move", (*forumView).handleMoveThread)
+	handle("POST /api/admin/merge", (*forumView).handleMergeThread)
+	handle("POST /api/admin/category-policy", (*forumView).handleCategoryPolicy)
+	handle("GET /api/admin/roles", (*forumView).handleRoles)
+	handle("POST /api/admin/roles", (*forumView).handleSetRoles)
+	handle("POST /api/admin/ban", (*forumView).handleBan)
+	handle("POST /api/admin/unban", (*forumView).handleUnban)
+	handle("GET /api/admin/audit", (*forumView).handleAudit)
 	return mux
 }
 
 // ListenAndServe starts the HTTP server.
 func (s *Server) ListenAndServe(staticFS fs.FS) error {
 	addr := fmt.Sprintf(":%d", s.Port)
-	log.Printf("Gitorum listening on http://localhost%s  (repo: %s)", addr, s.RepoPath)
+	for _, f := range s.forums {
+		log.Printf("Forum %s: http://localhost%s/f/%s/api/  (repo: %s)", f.Name, addr, f.Name, f.RepoPath)
+	}
+	log.Printf("Gitorum listening on http://localhost%s", addr)
 	return http.ListenAndServe(addr, s.Handler(staticFS))
 }
 
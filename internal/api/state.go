@@ -0,0 +1,163 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gosub/gitorum/internal/forum"
+	"github.com/gosub/gitorum/internal/local"
+)
+
+// loadOptions returns the forum-wide settings used when reading threads:
+// the admin key, the ban list, the local identity's mute list, the post
+// index, when each file arrived in the history and, when the forum requires proof of work, its web of trust. An
+// unreadable GITORUM.toml yields the zero value, which trusts no record as
+// admin-signed. A proof of work above forum.MaxWork, which no signer will
+// produce, is read as forum.MaxWork.
+func (s *forumView) loadOptions() forum.LoadOptions {
+	meta, err := s.repo.ReadMeta()
+	if err != nil {
+		log.Printf("loadOptions: read meta: %v", err)
+		return forum.LoadOptions{}
+	}
+	opts := forum.LoadOptions{AdminPubkey: meta.AdminPubkey, Work: min(meta.ProofOfWork, forum.MaxWork), Index: s.postIndex(meta.AdminPubkey)}
+	if opts.Bans, err = forum.LoadBanList(filepath.Join(s.repo.Path, forum.BansFilename), meta.AdminPubkey); err != nil {
+		log.Printf("loadOptions: %v", err)
+	}
+	if opts.Arrivals, err = s.repo.Arrivals(); err != nil {
+		log.Printf("loadOptions: %v", err)
+	}
+	if s.identity != nil {
+		mutes, err := local.LoadMuteList(s.repo.Path, s.identity.Username)
+		if err != nil {
+			log.Printf("loadOptions: %v", err)
+		} else {
+			opts.Muted = mutes.Set()
+		}
+	}
+	if opts.Work > 0 {
+		opts.Trust = s.webOfTrust()
+	}
+	return opts
+}
+
+// signOptions returns the forum-wide settings used when signing posts: the
+// proof of work required of authors without a key in keys/. A requirement
+// above forum.MaxWork is passed through so that signing fails instead of
+// hanging while the server lock is held.
+func (s *forumView) signOptions() forum.SignOptions {
+	opts := forum.SignOptions{KeysDir: filepath.Join(s.repo.Path, "keys")}
+	if meta, err := s.repo.ReadMeta(); err == nil {
+		opts.Work = meta.ProofOfWork
+	} else {
+		log.Printf("signOptions: read meta: %v", err)
+	}
+	return opts
+}
+
+// acceptsReplies writes a 403 and returns false when the thread in dir is
+// locked or archived. The admin may still post in closed threads.
+func (s *forumView) acceptsReplies(w http.ResponseWriter, dir string) bool {
+	opts := s.loadOptions()
+	st, err := forum.LoadThreadState(dir, filepath.Join(s.repo.Path, "keys"), opts.AdminPubkey)
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, "load thread state: "+err.Error())
+		return false
+	}
+	if !st.Closed() || s.identity.PublicKey == opts.AdminPubkey {
+		return true
+	}
+	if st.Archived {
+		apiError(w, http.StatusForbidden, "thread is archived")
+	} else {
+		apiError(w, http.StatusForbidden, "thread is locked")
+	}
+	return false
+}
+
+// POST /api/admin/thread-state
+func (s *forumView) handleThreadState(w http.ResponseWriter, r *http.Request) {
+	var req ThreadStateRequest
+	if err := readJSON(r, &req); err != nil {
+		apiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.Category == "" || req.Thread == "" {
+		apiError(w, http.StatusBadRequest, "category and thread are required")
+		return
+	}
+	if !validCategory(req.Category) || !slugRe.MatchString(req.Thread) {
+		apiError(w, http.StatusBadRequest, "invalid thread path")
+		return
+	}
+	if req.Pinned == nil && req.Locked == nil && req.Archived == nil {
+		apiError(w, http.StatusBadRequest, "one of pinned, locked, or archived is required")
+		return
+	}
+	if !s.requireAdmin(w) {
+		return
+	}
+
+	// The root post is what makes a directory a thread rather than a
+	// category, so reading it also checks that the thread exists.
+	threadDir := filepath.Join(s.repo.Path, filepath.FromSlash(req.Category), req.Thread)
+	rootContent, err := os.ReadFile(filepath.Join(threadDir, forum.RootFilename))
+	if err != nil {
+		apiError(w, http.StatusNotFound, "thread not found")
+		return
+	}
+	st, err := forum.LoadThreadState(threadDir, filepath.Join(s.repo.Path, "keys"), s.identity.PublicKey)
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, "load thread state: "+err.Error())
+		return
+	}
+
+	rec := st.StateRecord
+	var changed []string
+	if req.Pinned != nil {
+		rec.Pinned = *req.Pinned
+		changed = append(changed, flagChange("pinned", rec.Pinned))
+	}
+	if req.Locked != nil {
+		rec.Locked = *req.Locked
+		changed = append(changed, flagChange("locked", rec.Locked))
+	}
+	if req.Archived != nil {
+		rec.Archived = *req.Archived
+		changed = append(changed, flagChange("archived", rec.Archived))
+	}
+
+	post, err := forum.SignThreadState(s.identity, rootContent, rec)
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, "sign state: "+err.Error())
+		return
+	}
+	post.Filename = forum.NewStateFilename(post.Body)
+
+	relPath := filepath.Join(filepath.FromSlash(req.Category), req.Thread, post.Filename)
+	msg := fmt.Sprintf("thread: %s %s/%s", strings.Join(changed, ", "), req.Category, req.Thread)
+	if err := s.repo.CommitFile(s.identity, relPath, post.Format(), msg); err != nil {
+		apiError(w, http.StatusInternalServerError, "commit state: "+err.Error())
+		return
+	}
+	if err := s.repo.Push(); err != nil {
+		log.Printf("handleThreadState: push: %v", err)
+	}
+
+	st.StateRecord = rec
+	st.ChangedAt = post.Timestamp
+	st.ChangedBy = post.Author
+	writeJSON(w, http.StatusOK, threadStateToResponse(st))
+}
+
+// flagChange describes setting or clearing flag for a commit message.
+func flagChange(flag string, on bool) string {
+	if on {
+		return flag
+	}
+	return "un" + flag
+}
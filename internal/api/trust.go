@@ -0,0 +1,275 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gosub/gitorum/internal/crypto"
+	"github.com/gosub/gitorum/internal/forum"
+	"github.com/gosub/gitorum/internal/repo"
+)
+
+// webOfTrust returns the keys the local identity trusts through vouches, or
+// nil when there is no identity.
+func (s *forumView) webOfTrust() *forum.WebOfTrust {
+	if s.identity == nil || s.repo == nil {
+		return nil
+	}
+	w := forum.BuildWebOfTrust(filepath.Join(s.repo.Path, forum.VouchesDir), s.identity.Username, s.identity.PublicKey, s.server.TrustHops)
+	for _, e := range w.Errors {
+		log.Printf("webOfTrust: %v", e)
+	}
+	return w
+}
+
+// postTrust classifies the key that signed p: "approved" when it is in
+// keys/, "vouched" when it is in the web of trust w, and "unknown"
+// otherwise. For vouched keys it also returns the chain of vouches.
+func postTrust(p *forum.Post, w *forum.WebOfTrust) (string, []string) {
+	switch {
+	case p.Tombstoned:
+		return "", nil
+	case p.SigStatus == forum.SigValid:
+		return "approved", nil
+	case p.SigStatus != forum.SigMissing:
+		return "", nil
+	}
+	if k := w.Vouched(p); k != nil {
+		return "vouched", k.Path
+	}
+	return "unknown", nil
+}
+
+// vouchedBy returns the approved users who vouch for pubkey as username's
+// key, logging and returning none on error.
+func (s *forumView) vouchedBy(username, pubkey string) []string {
+	users := []string{}
+	for _, v := range s.vouchers(username, pubkey) {
+		users = append(users, v.Username)
+	}
+	return users
+}
+
+// vouchers is vouchedBy with the time of each vouch.
+func (s *forumView) vouchers(username, pubkey string) []forum.Voucher {
+	vouchers, err := forum.VouchesFor(filepath.Join(s.repo.Path, forum.VouchesDir), filepath.Join(s.repo.Path, "keys"), username, pubkey)
+	if err != nil {
+		log.Printf("vouchers: %v", err)
+	}
+	return vouchers
+}
+
+// requestVouchedBy returns the approved users whose vouches count towards
+// approving req: those made after the request was committed, by users whose
+// key in keys/, which their vouch list verifies against, was already there
+// when they vouched. Vouches made earlier have to be renewed.
+func (s *forumView) requestVouchedBy(req repo.JoinRequest) []string {
+	users := []string{}
+	requested, err := s.repo.LastChanged(path.Join("requests", req.Username+".pub"))
+	if err != nil {
+		log.Printf("join request of %s: %v", req.Username, err)
+		return users
+	}
+	for _, v := range s.vouchers(req.Username, req.PubKey) {
+		approved, err := s.repo.LastChanged(path.Join("keys", v.Username+".pub"))
+		if err != nil || v.Since.Before(requested) || v.Since.Before(approved) {
+			continue
+		}
+		users = append(users, v.Username)
+	}
+	return users
+}
+
+// autoApprove approves the pending join requests that meta allows to be
+// approved without the admin: all of them with auto_approve_keys, else
+// those with at least auto_approve_vouches vouches that count (see
+// requestVouchedBy).
+func (s *forumView) autoApprove(meta *repo.ForumMeta) {
+	if !meta.AutoApproveKeys && meta.AutoApproveVouches <= 0 {
+		return
+	}
+	requests, err := s.repo.JoinRequests()
+	if err != nil {
+		log.Printf("sync: list join requests: %v", err)
+		return
+	}
+	for _, req := range requests {
+		how := ""
+		if !meta.AutoApproveKeys {
+			vouchers := s.requestVouchedBy(req)
+			if len(vouchers) < meta.AutoApproveVouches {
+				continue
+			}
+			how = " (vouched for by @" + strings.Join(vouchers, ", @") + ")"
+		}
+		if err := s.repo.ApproveJoinRequest(s.identity, req.Username); err != nil {
+			log.Printf("sync: auto-approve %s: %v", req.Username, err)
+		} else {
+			log.Printf("sync: auto-approved join request from @%s%s", req.Username, how)
+		}
+	}
+}
+
+// GET /api/trust
+func (s *forumView) handleTrust(w http.ResponseWriter, r *http.Request) {
+	l, ok := s.vouchList(w)
+	if !ok {
+		return
+	}
+	s.writeTrust(w, l)
+}
+
+// POST /api/vouch
+func (s *forumView) handleVouch(w http.ResponseWriter, r *http.Request) {
+	var req VouchRequest
+	if err := readJSON(r, &req); err != nil {
+		apiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !validUsername(req.Username) {
+		apiError(w, http.StatusBadRequest, "invalid username")
+		return
+	}
+	l, ok := s.vouchList(w)
+	if !ok {
+		return
+	}
+	if req.Username == s.identity.Username {
+		apiError(w, http.StatusBadRequest, "you cannot vouch for yourself")
+		return
+	}
+	pubkey := strings.TrimSpace(req.PubKey)
+	if pubkey == "" {
+		pubkey = s.knownKey(req.Username)
+	}
+	if pubkey == "" {
+		apiError(w, http.StatusBadRequest, "no key known for @"+req.Username+"; give their public key")
+		return
+	}
+	v := forum.Vouch{
+		Username: req.Username,
+		PubKey:   pubkey,
+		Note:     strings.TrimSpace(req.Note),
+		Since:    time.Now().UTC().Format(time.RFC3339),
+	}
+	s.commitVouches(w, l.With(v), "vouch: @"+req.Username)
+}
+
+// POST /api/unvouch
+func (s *forumView) handleUnvouch(w http.ResponseWriter, r *http.Request) {
+	var req UsernameRequest
+	if err := readJSON(r, &req); err != nil {
+		apiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	l, ok := s.vouchList(w)
+	if !ok {
+		return
+	}
+	vouches, found := l.Without(req.Username)
+	if !found {
+		apiError(w, http.StatusNotFound, "you do not vouch for this user")
+		return
+	}
+	s.commitVouches(w, vouches, "vouch: withdraw @"+req.Username)
+}
+
+// knownKey returns username's key from keys/ or, failing that, from their
+// pending join request; empty when there is neither.
+func (s *forumView) knownKey(username string) string {
+	if key := s.approvedKey(username); key != "" {
+		return key
+	}
+	data, err := os.ReadFile(filepath.Join(s.repo.Path, "requests", username+".pub"))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// approvedKey returns username's key from keys/, or "" when they have none.
+func (s *forumView) approvedKey(username string) string {
+	data, err := os.ReadFile(filepath.Join(s.repo.Path, "keys", username+".pub"))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// commitVouches signs vouches as the local identity's new vouch list,
+// commits it, pushes, and responds with the updated web of trust.
+func (s *forumView) commitVouches(w http.ResponseWriter, vouches []forum.Vouch, msg string) {
+	l, err := forum.SignVouchList(s.identity, vouches)
+	if err != nil {
+		apiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	relPath := filepath.FromSlash(forum.VouchPath(s.identity.Username))
+	if err := s.repo.CommitFile(s.identity, relPath, l.Format(), msg); err != nil {
+		apiError(w, http.StatusInternalServerError, "commit vouches: "+err.Error())
+		return
+	}
+	if err := s.repo.Push(); err != nil {
+		log.Printf("commitVouches: push: %v", err)
+	}
+	s.writeTrust(w, l)
+}
+
+// vouchList loads the local identity's vouch list, writing an error
+// response and returning false when there is none.
+func (s *forumView) vouchList(w http.ResponseWriter) (*forum.VouchList, bool) {
+	if s.identity == nil {
+		apiError(w, http.StatusServiceUnavailable, "no identity configured")
+		return nil, false
+	}
+	if s.repo == nil {
+		apiError(w, http.StatusServiceUnavailable, "forum not initialized")
+		return nil, false
+	}
+	path := filepath.Join(s.repo.Path, filepath.FromSlash(forum.VouchPath(s.identity.Username)))
+	l, err := forum.LoadVouchList(path, s.identity.Username, s.identity.PublicKey)
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, "load vouches: "+err.Error())
+		return nil, false
+	}
+	return l, true
+}
+
+func (s *forumView) writeTrust(w http.ResponseWriter, own *forum.VouchList) {
+	wot := s.webOfTrust()
+	resp := TrustResponse{
+		Hops:    s.server.TrustHops,
+		Vouches: own.Vouches,
+		Trusted: []TrustedKey{},
+		Errors:  wot.Errors,
+	}
+	if resp.Vouches == nil {
+		resp.Vouches = []forum.Vouch{}
+	}
+	for _, k := range wot.Keys {
+		if k.Username == wot.Root {
+			continue
+		}
+		resp.Trusted = append(resp.Trusted, TrustedKey{
+			Username:    k.Username,
+			PubKey:      k.PubKey,
+			Fingerprint: crypto.Fingerprint(k.PubKey),
+			Path:        k.Path,
+			Approved:    s.approvedKey(k.Username) == k.PubKey,
+		})
+	}
+	sort.Slice(resp.Trusted, func(i, j int) bool {
+		a, b := resp.Trusted[i], resp.Trusted[j]
+		if len(a.Path) != len(b.Path) {
+			return len(a.Path) < len(b.Path)
+		}
+		return a.Username < b.Username
+	})
+	writeJSON(w, http.StatusOK, resp)
+}
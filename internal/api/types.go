@@ -1,22 +1,52 @@
 package api
 
+import (
+	"github.com/gosub/gitorum/internal/forum"
+	"github.com/gosub/gitorum/internal/webhook"
+)
+
 // ---- response types --------------------------------------------------------
 
+// CategoriesResponse is the category tree: the top-level categories, each
+// with its subcategories nested in Children.
 type CategoriesResponse struct {
 	Categories []CategorySummary `json:"categories"`
 }
 
+// CategorySummary describes a category. Slug is the slash-separated path of
+// the category directory, e.g. "projects/gitorum" for a subcategory.
+// ThreadCount and UnreadCount cover the category's own threads; the totals
+// include every subcategory below it.
 type CategorySummary struct {
-	Slug        string `json:"slug"`
-	Name        string `json:"name"`
-	Description string `json:"description"`
-	ThreadCount int    `json:"thread_count"`
+	Slug         string            `json:"slug"`
+	Name         string            `json:"name"`
+	Description  string            `json:"description"`
+	Parent       string            `json:"parent,omitempty"`
+	Order        int               `json:"order"`
+	ThreadCount  int               `json:"thread_count"`
+	UnreadCount  int               `json:"unread_count"` // unread posts across all threads
+	TotalThreads int               `json:"total_threads"`
+	TotalUnread  int               `json:"total_unread"`
+	Children     []CategorySummary `json:"children,omitempty"`
+}
+
+// CategoryRef names a category, as used in breadcrumbs.
+type CategoryRef struct {
+	Slug string `json:"slug"`
+	Name string `json:"name"`
 }
 
 type ThreadsResponse struct {
-	Category     string          `json:"category"`      // slug
-	CategoryName string          `json:"category_name"` // display name
-	Threads      []ThreadSummary `json:"threads"`
+	Category      string            `json:"category"`      // slug
+	CategoryName  string            `json:"category_name"` // display name
+	Parents       []CategoryRef     `json:"parents"`       // ancestors, outermost first
+	Subcategories []CategorySummary `json:"subcategories"`
+	Policy        *CategoryPolicy   `json:"policy,omitempty"` // nil when anyone may post
+	CanPost       bool              `json:"can_post"`         // the local identity may start threads
+	Threads       []ThreadSummary   `json:"threads"`
+	Sort          string            `json:"sort"`
+	Total         int               `json:"total"`                 // threads in the category
+	NextCursor    string            `json:"next_cursor,omitempty"` // empty on the last page
 }
 
 type ThreadSummary struct {
@@ -26,38 +56,415 @@ type ThreadSummary struct {
 	ReplyCount  int    `json:"reply_count"`
 	CreatedAt   string `json:"created_at"`
 	LastReplyAt string `json:"last_reply_at"`
+	IsPoll      bool   `json:"is_poll,omitempty"`
+	UnreadCount int    `json:"unread_count"`
+	Pinned      bool   `json:"pinned,omitempty"`
+	Locked      bool   `json:"locked,omitempty"`
+	Archived    bool   `json:"archived,omitempty"`
+	Flag        string `json:"flag,omitempty"` // set when the root breaks the category's write policy
+}
+
+// CategoryPolicy lists who may start threads and who may reply in a
+// category: usernames and "@role" references. An empty list means anyone.
+type CategoryPolicy struct {
+	Threads []string `json:"threads"`
+	Replies []string `json:"replies"`
 }
 
 type ThreadResponse struct {
 	Category string         `json:"category"`
 	Slug     string         `json:"slug"`
 	Posts    []PostResponse `json:"posts"`
+	Poll     *PollResponse  `json:"poll,omitempty"`
+	State    ThreadState    `json:"state"`
+	CanReply bool           `json:"can_reply"` // the local identity may reply under the category's write policy
+
+	TotalPosts int    `json:"total_posts"`
+	NextCursor string `json:"next_cursor,omitempty"` // empty on the last page
+
+	Backlinks []BacklinkResponse `json:"backlinks"`          // posts in other threads that link here or quote from here
+	Redirect  *RedirectResponse  `json:"redirect,omitempty"` // set when the requested thread was moved or merged here
+}
+
+// RedirectResponse tells the client that the thread it asked for now lives
+// elsewhere. Root is the filename the requested thread's root post has in
+// this thread; it differs from "0000_root.md" after a merge.
+type RedirectResponse struct {
+	FromCategory string `json:"from_category"`
+	FromThread   string `json:"from_thread"`
+	Root         string `json:"root"`
+}
+
+// ThreadMoveRequest moves the thread Category/Thread to
+// ToCategory/ToThread, or merges it into that thread.
+type ThreadMoveRequest struct {
+	Category   string `json:"category"`
+	Thread     string `json:"thread"`
+	ToCategory string `json:"to_category"`
+	ToThread   string `json:"to_thread"`
+}
+
+// BacklinkResponse is a post in another thread that links to, or quotes
+// from, the thread. Target is the filename of the referenced post; it is
+// empty for links to the whole thread.
+type BacklinkResponse struct {
+	Category  string `json:"category"`
+	Thread    string `json:"thread"`
+	Filename  string `json:"filename"`
+	Title     string `json:"title"`
+	Author    string `json:"author"`
+	Timestamp string `json:"timestamp"`
+	Target    string `json:"target,omitempty"`
+}
+
+// WhatsNewResponse lists the threads that have posts the local identity has
+// not read yet, most recently active first.
+type WhatsNewResponse struct {
+	LastSyncAt string         `json:"last_sync_at,omitempty"`
+	Threads    []UnreadThread `json:"threads"`
+}
+
+// NotificationsResponse lists the local identity's notifications, newest
+// first.
+type NotificationsResponse struct {
+	Unseen        int                    `json:"unseen"`
+	Notifications []NotificationResponse `json:"notifications"`
+}
+
+type NotificationResponse struct {
+	ID        string `json:"id"`
+	Kind      string `json:"kind"` // "mention", "reply" or "thread_reply"
+	Category  string `json:"category"`
+	Thread    string `json:"thread"`
+	Title     string `json:"title"`
+	Filename  string `json:"filename"`
+	Author    string `json:"author"`
+	Timestamp string `json:"timestamp"`
+	Seen      bool   `json:"seen"`
+}
+
+// WebhooksResponse lists the configured webhooks (secrets are never sent)
+// and the most recent deliveries, newest first.
+type WebhooksResponse struct {
+	Webhooks   []WebhookResponse  `json:"webhooks"`
+	Deliveries []webhook.Delivery `json:"deliveries"`
+}
+
+type WebhookResponse struct {
+	URL    string `json:"url"`
+	Signed bool   `json:"signed"` // a secret is configured
+}
+
+// UserResponse describes a user: their key, what the git history says about
+// them and their self-signed profile. ProfileError is set, and Profile left
+// empty, when the profile file does not verify.
+type UserResponse struct {
+	Username     string          `json:"username"`
+	PubKey       string          `json:"pubkey"`
+	Fingerprint  string          `json:"fingerprint"`
+	JoinedAt     string          `json:"joined_at,omitempty"` // RFC3339 time the key was added
+	PostCount    int             `json:"post_count"`
+	Profile      ProfileResponse `json:"profile"`
+	ProfileError string          `json:"profile_error,omitempty"`
+	VouchedBy    []string        `json:"vouched_by"` // approved users who vouch for this key
+}
+
+type ProfileResponse struct {
+	forum.ProfileFields
+	BioHTML   string `json:"bio_html"`
+	UpdatedAt string `json:"updated_at,omitempty"` // empty when the user has no profile
+}
+
+// BansResponse is the admin-signed ban list.
+type BansResponse struct {
+	Bans []forum.Ban `json:"bans"`
+}
+
+// TrustResponse is the local identity's web of trust: its own vouches and
+// every key reached through vouches within Hops.
+type TrustResponse struct {
+	Hops    int           `json:"hops"`
+	Vouches []forum.Vouch `json:"vouches"`
+	Trusted []TrustedKey  `json:"trusted"` // closest first
+	Errors  []string      `json:"errors,omitempty"`
+}
+
+// TrustedKey is a key in the local identity's web of trust.
+type TrustedKey struct {
+	Username    string   `json:"username"`
+	PubKey      string   `json:"pubkey"`
+	Fingerprint string   `json:"fingerprint"`
+	Path        []string `json:"path"`     // chain of vouches from the local identity
+	Approved    bool     `json:"approved"` // the key is also in keys/
+}
+
+// VouchRequest vouches for Username's key. PubKey defaults to the key in
+// keys/ or, failing that, the user's pending join request.
+type VouchRequest struct {
+	Username string `json:"username"`
+	PubKey   string `json:"pubkey"`
+	Note     string `json:"note"`
+}
+
+// MutesResponse lists the users the local identity has muted.
+type MutesResponse struct {
+	Users []string `json:"users"`
+}
+
+// DraftsResponse lists the local identity's drafts, most recently updated
+// first.
+type DraftsResponse struct {
+	Drafts []DraftResponse `json:"drafts"`
+}
+
+// DraftResponse is an unfinished post. Thread is empty for a new thread.
+type DraftResponse struct {
+	ID       string `json:"id"`
+	Category string `json:"category"`
+	Thread   string `json:"thread,omitempty"`
+	Slug     string `json:"slug,omitempty"`
+	Parent   string `json:"parent,omitempty"`
+	Body     string `json:"body"`
+	Updated  string `json:"updated"`
+}
+
+// OutboxResponse lists what has not reached the remote yet.
+type OutboxResponse struct {
+	Commits       []PendingCommit `json:"commits"`     // oldest first
+	Uncommitted   []string        `json:"uncommitted"` // files changed in the working tree
+	LastPushAt    string          `json:"last_push_at,omitempty"`
+	LastPushError string          `json:"last_push_error,omitempty"`
+}
+
+// PendingCommit is a local commit that has not been pushed everywhere.
+// PushError is the error of the last push attempted since it was made;
+// PushedTo names the push remotes that already have it, in which case it
+// cannot be discarded.
+type PendingCommit struct {
+	Hash      string        `json:"hash"`
+	Message   string        `json:"message"`
+	Author    string        `json:"author"`
+	When      string        `json:"when"`
+	Files     []string      `json:"files"`
+	Posts     []PendingPost `json:"posts,omitempty"` // the files that are posts
+	PushError string        `json:"push_error,omitempty"`
+	PushedTo  []string      `json:"pushed_to,omitempty"`
+}
+
+// ProvenanceResponse is what the git history says about a post: when it
+// entered the repository, as opposed to the timestamp it claims, and every
+// commit that touched it since.
+type ProvenanceResponse struct {
+	Path       string             `json:"path"`       // where the post was added, before any moves
+	ClaimedAt  string             `json:"claimed_at"` // timestamp in the post
+	Introduced ProvenanceCommit   `json:"introduced"`
+	Later      []ProvenanceCommit `json:"later"`    // oldest first
+	Modified   bool               `json:"modified"` // a later commit changed the content
+}
+
+// ProvenanceCommit is a commit that touched a post. Change is "add",
+// "modify", or "move" (renamed from From with its content unchanged).
+type ProvenanceCommit struct {
+	Hash      string `json:"hash"`
+	Subject   string `json:"subject"`
+	Committer string `json:"committer"`
+	When      string `json:"when"`
+	Signature string `json:"signature"` // valid, unknown, invalid or unsigned
+	Signer    string `json:"signer,omitempty"`
+	Change    string `json:"change"`
+	From      string `json:"from,omitempty"`
+}
+
+// AuditResponse is a page of the moderation log, newest first.
+type AuditResponse struct {
+	Entries    []AuditEntry `json:"entries"`
+	Total      int          `json:"total"`                 // entries matching the filters
+	NextCursor string       `json:"next_cursor,omitempty"` // empty on the last page
+}
+
+// AuditEntry is one moderation action and how its signatures check out.
+type AuditEntry struct {
+	Hash      string        `json:"hash"`
+	When      string        `json:"when"`
+	Actor     string        `json:"actor"`
+	Action    string        `json:"action"`
+	Subject   string        `json:"subject"`
+	Target    string        `json:"target"`
+	CommitSig string        `json:"commit_sig"` // valid, unknown, invalid or unsigned
+	Signer    string        `json:"signer,omitempty"`
+	ByAdmin   bool          `json:"by_admin"`
+	Records   []AuditRecord `json:"records,omitempty"`
+	Verified  bool          `json:"verified"`
+}
+
+// AuditRecord is a signed file written by an action. Error is empty when
+// its signature verifies.
+type AuditRecord struct {
+	Path  string `json:"path"`
+	Error string `json:"error,omitempty"`
+}
+
+type PendingPost struct {
+	Category string `json:"category"`
+	Thread   string `json:"thread"`
+	Filename string `json:"filename"`
+}
+
+type UnreadThread struct {
+	Category     string `json:"category"`
+	CategoryName string `json:"category_name"`
+	ThreadSummary
+}
+
+// ThreadState is the admin-set moderation state of a thread.
+type ThreadState struct {
+	Pinned    bool   `json:"pinned"`
+	Locked    bool   `json:"locked"`
+	Archived  bool   `json:"archived"`
+	ChangedAt string `json:"changed_at,omitempty"`
+	ChangedBy string `json:"changed_by,omitempty"`
+}
+
+// PollResponse is the tally of a poll thread. Voters lists every counted
+// vote with its signature so clients can verify the result independently.
+type PollResponse struct {
+	Options  []PollOptionResponse `json:"options"`
+	Voters   []VoteResponse       `json:"voters"`
+	Rejected []VoteResponse       `json:"rejected"`
+	ClosesAt string               `json:"closes_at,omitempty"` // RFC3339
+	Closed   bool                 `json:"closed"`
+	MyVote   string               `json:"my_vote,omitempty"` // option counted for the local identity
+}
+
+type PollOptionResponse struct {
+	Text  string `json:"text"`
+	Votes int    `json:"votes"`
+}
+
+type VoteResponse struct {
+	Voter     string `json:"voter"`
+	PubKey    string `json:"pubkey"`
+	Option    string `json:"option"`
+	Timestamp string `json:"timestamp"`
+	Filename  string `json:"filename"`
+	Signature string `json:"signature"`
+	Reason    string `json:"reason,omitempty"`
 }
 
 // PostResponse is the wire representation of a post sent to the browser.
 // SigStatus is "valid", "invalid", "missing", or "deleted" (tombstoned).
 type PostResponse struct {
-	Author     string `json:"author"`
-	PubKey     string `json:"pubkey"`
-	Timestamp  string `json:"timestamp"`
-	Parent     string `json:"parent"`
-	Body       string `json:"body"`
-	BodyHTML   string `json:"body_html"`
-	Filename   string `json:"filename"`
-	SigStatus  string `json:"sig_status"`
-	SigError   string `json:"sig_error,omitempty"`
-	Tombstoned bool   `json:"tombstoned,omitempty"`
+	Author     string          `json:"author"`
+	AuthorName string          `json:"author_name,omitempty"` // display name from the author's profile
+	AuthorURL  string          `json:"author_url,omitempty"`  // API path of the author's profile
+	PubKey     string          `json:"pubkey"`
+	Timestamp  string          `json:"timestamp"`
+	Parent     string          `json:"parent"`
+	Body       string          `json:"body"`
+	BodyHTML   string          `json:"body_html"`
+	Filename   string          `json:"filename"`
+	SigStatus  string          `json:"sig_status"`
+	SigError   string          `json:"sig_error,omitempty"`
+	Trust      string          `json:"trust,omitempty"`      // "approved", "vouched" or "unknown"; empty for invalid signatures
+	TrustPath  []string        `json:"trust_path,omitempty"` // chain of vouches from the local identity to a vouched author
+	Tombstoned bool            `json:"tombstoned,omitempty"`
+	Flag       string          `json:"flag,omitempty"` // moderation flag, e.g. "locked"; empty for normal posts
+	FlagReason string          `json:"flag_reason,omitempty"`
+	Quotes     []QuoteResponse `json:"quotes,omitempty"`
+	Unread     bool            `json:"unread,omitempty"` // not seen by the local identity before this view
+}
+
+// QuoteResponse is a quote of another post. Location, author and signature
+// are set only when Found.
+type QuoteResponse struct {
+	Ref       string `json:"ref"`
+	Category  string `json:"category,omitempty"`
+	Thread    string `json:"thread,omitempty"`
+	Filename  string `json:"filename,omitempty"`
+	Author    string `json:"author,omitempty"`
+	SigStatus string `json:"sig_status,omitempty"`
+	Found     bool   `json:"found"`
+	Altered   bool   `json:"altered"` // the quoted text is not in the quoted post
 }
 
 type StatusResponse struct {
+	Username    string       `json:"username"`
+	PubKey      string       `json:"pubkey"`
+	IsAdmin     bool         `json:"is_admin"`
+	ForumName   string       `json:"forum_name"`
+	RemoteURL   string       `json:"remote_url,omitempty"`
+	Synced      bool         `json:"synced"`
+	Pending     int          `json:"pending,omitempty"`      // unpushed commits and uncommitted files
+	Initialized bool         `json:"initialized"`            // false until forum repo exists
+	LastSyncAt  string       `json:"last_sync_at,omitempty"` // RFC3339, set after first sync
+	Remotes     []RemoteInfo `json:"remotes,omitempty"`
+}
+
+// RemoteInfo is a configured remote and how the last sync with it went.
+type RemoteInfo struct {
+	Name          string   `json:"name"`
+	URL           string   `json:"url"`
+	Role          string   `json:"role"` // pull, push or mirror
+	Synced        bool     `json:"synced"`
+	LastPullAt    string   `json:"last_pull_at,omitempty"`
+	LastPullError string   `json:"last_pull_error,omitempty"`
+	Rejected      []string `json:"rejected,omitempty"` // files the last pull did not merge
+	LastPushAt    string   `json:"last_push_at,omitempty"`
+	LastPushError string   `json:"last_push_error,omitempty"`
+}
+
+// LANPeersResponse lists the peers on the local network that serve the
+// same forum.
+type LANPeersResponse struct {
+	Enabled bool      `json:"enabled"` // false unless the server runs with --lan
+	Peers   []LANPeer `json:"peers"`   // most recently seen first
+}
+
+type LANPeer struct {
+	Instance string `json:"instance"` // pass to POST /api/lan/sync
+	Name     string `json:"name"`     // forum name it advertises
+	User     string `json:"user,omitempty"`
+	URL      string `json:"url"` // its read-only git endpoint
+	LastSeen string `json:"last_seen"`
+}
+
+// LANSyncRequest names the peer to sync with.
+type LANSyncRequest struct {
+	Instance string `json:"instance"`
+}
+
+// LANSyncResponse reports what a sync with a peer merged.
+type LANSyncResponse struct {
+	OK       bool     `json:"ok"`
+	Added    int      `json:"added"`              // files the pull brought in
+	Rejected []string `json:"rejected,omitempty"` // files the peer changed or removed, not merged
+}
+
+// ForumsResponse lists the forums this server serves, the default first.
+type ForumsResponse struct {
+	Forums      []ForumSummary `json:"forums"`
+	TotalUnread int            `json:"total_unread"` // across all forums
+}
+
+type ForumSummary struct {
+	Name        string `json:"name"`  // URL segment: /f/{name}/api/
+	Title       string `json:"title"` // forum name from GITORUM.toml
+	Default     bool   `json:"default"`
+	Initialized bool   `json:"initialized"`
+	Unread      int    `json:"unread"`
+}
+
+// IdentitiesResponse lists the identities in the identity store. Active
+// marks the one the forum is used as.
+type IdentitiesResponse struct {
+	Identities []IdentitySummary `json:"identities"`
+}
+
+type IdentitySummary struct {
+	Name        string `json:"name"`
 	Username    string `json:"username"`
-	PubKey      string `json:"pubkey"`
-	IsAdmin     bool   `json:"is_admin"`
-	ForumName   string `json:"forum_name"`
-	RemoteURL   string `json:"remote_url,omitempty"`
-	Synced      bool   `json:"synced"`
-	Initialized bool   `json:"initialized"`              // false until forum repo exists
-	LastSyncAt  string `json:"last_sync_at,omitempty"` // RFC3339, set after first sync
+	Fingerprint string `json:"fingerprint"`
+	Active      bool   `json:"active"`
 }
 
 type SetupRequest struct {
@@ -69,15 +476,87 @@ type SetupRequest struct {
 // ---- request types ---------------------------------------------------------
 
 type ReplyRequest struct {
-	Body string `json:"body"`
+	Body   string `json:"body"`
+	Parent string `json:"parent,omitempty"` // filename of the post replied to; defaults to the root
 }
 
 type NewThreadRequest struct {
+	Category    string   `json:"category"`
+	Slug        string   `json:"slug"`
+	Body        string   `json:"body"`
+	PollOptions []string `json:"poll_options,omitempty"` // non-empty to start a poll
+	PollCloses  string   `json:"poll_closes,omitempty"`  // RFC3339; empty = never closes
+}
+
+type MarkReadRequest struct {
+	Filenames []string `json:"filenames"` // posts seen; defaults to every post of the thread
+}
+
+type NotificationsSeenRequest struct {
+	IDs []string `json:"ids"` // empty marks every notification seen
+}
+
+// ThreadStateRequest changes the flags that are set; nil fields keep their
+// current value.
+type ThreadStateRequest struct {
 	Category string `json:"category"`
-	Slug     string `json:"slug"`
+	Thread   string `json:"thread"`
+	Pinned   *bool  `json:"pinned,omitempty"`
+	Locked   *bool  `json:"locked,omitempty"`
+	Archived *bool  `json:"archived,omitempty"`
+}
+
+// CategoryPolicyRequest sets the write policy of a category; with both
+// lists empty it removes the policy.
+type CategoryPolicyRequest struct {
+	Category string   `json:"category"`
+	Threads  []string `json:"threads"`
+	Replies  []string `json:"replies"`
+}
+
+// RolesResponse maps each role to its members. It is also the body of
+// POST /api/admin/roles, which replaces every role.
+type RolesResponse struct {
+	Roles map[string][]string `json:"roles"`
+}
+
+// BanRequest bans a user from Since (RFC3339; defaults to now) onwards.
+// Banning a user again replaces the earlier ban.
+type BanRequest struct {
+	Username string `json:"username"`
+	Reason   string `json:"reason"`
+	Since    string `json:"since,omitempty"`
+}
+
+// UsernameRequest names the user an action applies to.
+type UsernameRequest struct {
+	Username string `json:"username"`
+}
+
+// DraftRequest saves a draft reply to Thread, or a draft new thread in
+// Category when Thread is empty.
+type DraftRequest struct {
+	Category string `json:"category"`
+	Thread   string `json:"thread,omitempty"`
+	Slug     string `json:"slug,omitempty"`   // new threads only
+	Parent   string `json:"parent,omitempty"` // replies only
 	Body     string `json:"body"`
 }
 
+// OutboxDiscardRequest names an unpushed commit or an uncommitted file.
+type OutboxDiscardRequest struct {
+	Commit string `json:"commit,omitempty"`
+	Path   string `json:"path,omitempty"`
+}
+
+type UseIdentityRequest struct {
+	Name string `json:"name"` // identity store name; "default" for identity.toml
+}
+
+type VoteRequest struct {
+	Option string `json:"option"`
+}
+
 type AdminDeleteRequest struct {
 	Category string `json:"category"`
 	Thread   string `json:"thread"`
@@ -93,6 +572,8 @@ type CreateCategoryRequest struct {
 	Slug        string `json:"slug"`
 	Name        string `json:"name"`
 	Description string `json:"description"`
+	Parent      string `json:"parent,omitempty"` // slug of the parent category; empty for a top-level one
+	Order       int    `json:"order,omitempty"`
 }
 
 type JoinRequestsResponse struct {
@@ -100,8 +581,9 @@ type JoinRequestsResponse struct {
 }
 
 type JoinRequestSummary struct {
-	Username string `json:"username"`
-	PubKey   string `json:"pubkey"`
+	Username  string   `json:"username"`
+	PubKey    string   `json:"pubkey"`
+	VouchedBy []string `json:"vouched_by"` // approved users whose vouch for the requested key counts towards approval
 }
 
 type ApproveRejectRequest struct {
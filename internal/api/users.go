@@ -0,0 +1,146 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gosub/gitorum/internal/crypto"
+	"github.com/gosub/gitorum/internal/forum"
+)
+
+// userURL is the API path of username's profile.
+func userURL(username string) string {
+	return "/api/users/" + url.PathEscape(username)
+}
+
+// loadProfile returns the verified profile of username, or an empty one when
+// the user has not published a profile.
+func (s *forumView) loadProfile(username string) (*forum.Profile, error) {
+	return forum.LoadProfile(
+		filepath.Join(s.repo.Path, filepath.FromSlash(forum.ProfilePath(username))),
+		filepath.Join(s.repo.Path, "keys"),
+		username,
+	)
+}
+
+// displayNames returns a lookup of display names for post authors, loading
+// each profile at most once.
+func (s *forumView) displayNames() func(username string) string {
+	names := map[string]string{}
+	return func(username string) string {
+		if name, ok := names[username]; ok {
+			return name
+		}
+		name := ""
+		if p, err := s.loadProfile(username); err != nil {
+			log.Printf("displayNames: %v", err)
+		} else {
+			name = p.DisplayName
+		}
+		names[username] = name
+		return name
+	}
+}
+
+// GET /api/users/{username}
+func (s *forumView) handleUser(w http.ResponseWriter, r *http.Request) {
+	username := r.PathValue("username")
+	if !validUsername(username) {
+		apiError(w, http.StatusBadRequest, "invalid username")
+		return
+	}
+	if s.repo == nil {
+		apiError(w, http.StatusServiceUnavailable, "forum not initialized")
+		return
+	}
+	resp, err := s.userResponse(username)
+	if errors.Is(err, os.ErrNotExist) {
+		apiError(w, http.StatusNotFound, "user not found")
+		return
+	}
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// POST /api/profile
+func (s *forumView) handleSetProfile(w http.ResponseWriter, r *http.Request) {
+	var req forum.ProfileFields
+	if err := readJSON(r, &req); err != nil {
+		apiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	req.DisplayName = strings.TrimSpace(req.DisplayName)
+	req.Contact = strings.TrimSpace(req.Contact)
+	req.Timezone = strings.TrimSpace(req.Timezone)
+	req.Avatar = strings.TrimSpace(req.Avatar)
+	if err := req.Validate(); err != nil {
+		apiError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if s.identity == nil {
+		apiError(w, http.StatusServiceUnavailable, "no identity configured")
+		return
+	}
+	if s.repo == nil {
+		apiError(w, http.StatusServiceUnavailable, "forum not initialized")
+		return
+	}
+
+	post, err := forum.SignProfile(s.identity, req)
+	if err != nil {
+		apiError(w, http.StatusInternalServerError, "sign profile: "+err.Error())
+		return
+	}
+	relPath := filepath.FromSlash(forum.ProfilePath(s.identity.Username))
+	if err := s.repo.CommitFile(s.identity, relPath, post.Format(), "profile: update @"+s.identity.Username); err != nil {
+		apiError(w, http.StatusInternalServerError, "commit profile: "+err.Error())
+		return
+	}
+	if err := s.repo.Push(); err != nil {
+		log.Printf("handleSetProfile: push: %v", err)
+	}
+	writeJSON(w, http.StatusOK, OKResponse{OK: true})
+}
+
+// userResponse gathers the key, history and profile of username. It returns
+// an error wrapping os.ErrNotExist when the user has no key in keys/.
+func (s *forumView) userResponse(username string) (UserResponse, error) {
+	key, err := os.ReadFile(filepath.Join(s.repo.Path, "keys", username+".pub"))
+	if err != nil {
+		return UserResponse{}, err
+	}
+	pubkey := strings.TrimSpace(string(key))
+	resp := UserResponse{
+		Username:    username,
+		PubKey:      pubkey,
+		Fingerprint: crypto.Fingerprint(pubkey),
+		VouchedBy:   s.vouchedBy(username, pubkey),
+	}
+	activity, err := s.repo.UserActivity(username)
+	if err != nil {
+		return UserResponse{}, err
+	}
+	resp.PostCount = activity.Posts
+	if !activity.JoinedAt.IsZero() {
+		resp.JoinedAt = activity.JoinedAt.UTC().Format(time.RFC3339)
+	}
+	profile, err := s.loadProfile(username)
+	if err != nil {
+		resp.ProfileError = err.Error()
+		return resp, nil
+	}
+	resp.Profile = ProfileResponse{ProfileFields: profile.ProfileFields, BioHTML: profile.BioHTML}
+	if !profile.UpdatedAt.IsZero() {
+		resp.Profile.UpdatedAt = profile.UpdatedAt.UTC().Format(time.RFC3339)
+	}
+	return resp, nil
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gosub/gitorum/internal/forum"
+	"github.com/gosub/gitorum/internal/local"
+	"github.com/gosub/gitorum/internal/webhook"
+)
+
+// webhooksPath is the per-instance webhook configuration. Like the rest of
+// the local state it lives under .git/ and is never committed.
+func (s *forumView) webhooksPath() string {
+	return filepath.Join(local.Dir(s.repo.Path), "webhooks.toml")
+}
+
+// notifyPosts sends a webhook event for every post among relPaths (paths
+// relative to the repository root). Other files are ignored.
+func (s *forumView) notifyPosts(source string, relPaths []string) {
+	if s.repo == nil || len(relPaths) == 0 {
+		return
+	}
+	hooks, err := webhook.LoadHooks(s.webhooksPath())
+	if err != nil {
+		log.Printf("webhooks: %v", err)
+		return
+	}
+	if len(hooks) == 0 {
+		return
+	}
+	forumName := ""
+	if meta, err := s.repo.ReadMeta(); err == nil {
+		forumName = meta.Name
+	}
+	keysDir := filepath.Join(s.repo.Path, "keys")
+
+	var events []webhook.Event
+	for _, rel := range relPaths {
+		rel = filepath.ToSlash(rel)
+		threadRel, name := filepath.Split(rel)
+		threadRel = strings.TrimSuffix(threadRel, "/")
+		catRel, threadSlug := filepath.Split(threadRel)
+		catRel = strings.TrimSuffix(catRel, "/")
+		if !strings.HasSuffix(name, ".md") || catRel == "" {
+			continue
+		}
+		threadDir := filepath.Join(s.repo.Path, filepath.FromSlash(threadRel))
+		if _, err := os.Stat(filepath.Join(threadDir, forum.RootFilename)); err != nil {
+			continue // not inside a thread
+		}
+		content, err := os.ReadFile(filepath.Join(threadDir, name))
+		if err != nil {
+			continue
+		}
+		post, err := forum.ParsePost(name, content)
+		if err != nil {
+			log.Printf("webhooks: skip %s: %v", rel, err)
+			continue
+		}
+		post.VerifySignature(keysDir)
+		events = append(events, webhook.Event{
+			Event:     webhook.EventNewPost,
+			Source:    source,
+			Forum:     forumName,
+			Category:  catRel,
+			Thread:    threadSlug,
+			Filename:  name,
+			Author:    post.Author,
+			SigStatus: sigStatusStr(post.SigStatus),
+			Timestamp: post.TimestampRaw,
+			Excerpt:   webhook.Excerpt(post.Body, 280),
+		})
+	}
+	s.hooks.Send(hooks, events)
+}
+
+// GET /api/webhooks
+func (s *forumView) handleWebhooks(w http.ResponseWriter, r *http.Request) {
+	resp := WebhooksResponse{Webhooks: []WebhookResponse{}, Deliveries: s.hooks.Log()}
+	if s.repo != nil {
+		hooks, err := webhook.LoadHooks(s.webhooksPath())
+		if err != nil {
+			apiError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		for _, h := range hooks {
+			resp.Webhooks = append(resp.Webhooks, WebhookResponse{URL: h.URL, Signed: h.Secret != ""})
+		}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
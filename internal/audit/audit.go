@@ -0,0 +1,192 @@
+// Package audit reconstructs the moderation log of a forum from its git
+// history: who approved which key, deleted which post, or changed the forum
+// settings, and whether the signatures on each action check out.
+//
+// Actions are recognised by the commit message prefixes gitorum writes
+// ("keys:", "request:", "category:", "config:", "thread:", "init:") and by
+// the tombstone files added with "post:" commits. Each action is checked
+// twice: the commit signature against the keys in keys/, and the signature
+// of every admin-signed record it wrote (tombstones, thread state and
+// redirect records, bans.toml, roles.toml, category write policies)
+// against the admin key in GITORUM.toml.
+package audit
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/gosub/gitorum/internal/forum"
+	"github.com/gosub/gitorum/internal/repo"
+)
+
+// Action is the kind of a moderation action.
+type Action string
+
+const (
+	ActionInit      Action = "init"      // forum created
+	ActionKeys      Action = "keys"      // public key added or join request approved
+	ActionRequest   Action = "request"   // join request submitted or rejected
+	ActionCategory  Action = "category"  // category created or write policy changed
+	ActionConfig    Action = "config"    // forum metadata, roles or bans changed
+	ActionThread    Action = "thread"    // thread pinned, locked, archived, moved or merged
+	ActionTombstone Action = "tombstone" // post deleted by the admin
+)
+
+// Actions lists every action type, in the order they are documented.
+var Actions = []Action{ActionInit, ActionKeys, ActionRequest, ActionCategory, ActionConfig, ActionThread, ActionTombstone}
+
+// ValidAction reports whether a is a known action type.
+func ValidAction(a Action) bool {
+	for _, known := range Actions {
+		if a == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Record is a signed file written by an action.
+type Record struct {
+	Path string
+	Err  string // why its signature does not verify; empty when it does
+}
+
+// Entry is one moderation action: a commit of the forum history.
+type Entry struct {
+	Hash      string
+	When      time.Time
+	Actor     string // committer
+	Action    Action
+	Subject   string // first line of the commit message
+	Target    string // what was acted on: the deleted post for tombstones, else the rest of the subject
+	CommitSig repo.CommitSigStatus
+	Signer    string // user whose key made the commit signature, if known
+	ByAdmin   bool   // the commit is signed with the admin key
+	Records   []Record
+	Verified  bool // the commit is signed by its committer and every record verifies
+}
+
+// Filter selects entries. Zero fields match everything.
+type Filter struct {
+	Actor  string
+	Action Action
+	Since  time.Time // inclusive
+	Until  time.Time // exclusive
+}
+
+func (f Filter) match(e Entry) bool {
+	return (f.Actor == "" || e.Actor == f.Actor) &&
+		(f.Action == "" || e.Action == f.Action) &&
+		(f.Since.IsZero() || !e.When.Before(f.Since)) &&
+		(f.Until.IsZero() || e.When.Before(f.Until))
+}
+
+// Log returns the moderation actions in the history of r that match f,
+// newest first.
+func Log(r *repo.Repo, f Filter) ([]Entry, error) {
+	meta, err := r.ReadMeta()
+	if err != nil {
+		return nil, fmt.Errorf("read meta: %w", err)
+	}
+	commits, err := r.Log()
+	if err != nil {
+		return nil, err
+	}
+	var out []Entry
+	for _, c := range commits {
+		e, ok, err := entry(r, c, meta.AdminPubkey)
+		if err != nil {
+			return nil, err
+		}
+		if ok && f.match(e) {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+// entry turns commit c into an action, reporting false when c is not one.
+func entry(r *repo.Repo, c repo.LogEntry, adminPubkey string) (Entry, bool, error) {
+	prefix, rest, _ := strings.Cut(c.Subject, ": ")
+	e := Entry{
+		Hash:      c.Hash,
+		When:      c.When,
+		Actor:     c.Committer,
+		Action:    Action(prefix),
+		Subject:   c.Subject,
+		Target:    rest,
+		CommitSig: c.Status,
+		Signer:    c.Signer,
+		ByAdmin:   adminPubkey != "" && c.SignerKey == adminPubkey,
+	}
+	if prefix == "post" {
+		e.Action = ""
+		for _, ch := range c.Changes {
+			if ch.Action == repo.ChangeAdd && path.Ext(ch.Path) == ".tomb" {
+				e.Action, e.Target = ActionTombstone, strings.TrimSuffix(ch.Path, ".tomb")
+				break
+			}
+		}
+	}
+	if !ValidAction(e.Action) || c.Merge {
+		return Entry{}, false, nil
+	}
+
+	signed := c.Status == repo.CommitSigValid
+	for _, ch := range c.Changes {
+		if ch.Action == repo.ChangeDelete {
+			continue
+		}
+		content, err := r.ReadFileAt(c.Hash, ch.Path)
+		if err != nil {
+			return Entry{}, false, err
+		}
+		if e.Action == ActionRequest && strings.HasPrefix(ch.Path, "requests/") {
+			// A join request comes from a key that is not in keys/ yet, so
+			// the commit must be signed with the requested key instead.
+			rec := Record{Path: ch.Path}
+			if c.SignerKey != "" && c.SignerKey == strings.TrimSpace(string(content)) {
+				signed = true
+			} else {
+				rec.Err = "not signed with the requested key"
+			}
+			e.Records = append(e.Records, rec)
+			continue
+		}
+		isRecord, err := forum.VerifyAdminRecord(ch.Path, content, adminPubkey)
+		if !isRecord {
+			continue
+		}
+		rec := Record{Path: ch.Path}
+		if err != nil {
+			rec.Err = err.Error()
+		}
+		e.Records = append(e.Records, rec)
+	}
+	e.Verified = signed
+	for _, rec := range e.Records {
+		if rec.Err != "" {
+			e.Verified = false
+		}
+	}
+	return e, true, nil
+}
+
+// ParseTime parses a filter bound given as a date (2006-01-02, UTC) or an
+// RFC 3339 time. A date given as the upper bound (upper) covers that whole
+// day.
+func ParseTime(s string, upper bool) (time.Time, error) {
+	if t, err := time.Parse(time.DateOnly, s); err == nil {
+		if upper {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q: use YYYY-MM-DD or RFC 3339", s)
+	}
+	return t, nil
+}
@@ -0,0 +1,106 @@
+package audit_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gosub/gitorum/internal/audit"
+	"github.com/gosub/gitorum/internal/crypto"
+	"github.com/gosub/gitorum/internal/forum"
+	"github.com/gosub/gitorum/internal/repo"
+)
+
+func newIdentity(t *testing.T, username string) *crypto.Identity {
+	t.Helper()
+	id, err := crypto.Generate(username)
+	if err != nil {
+		t.Fatalf("crypto.Generate(%q): %v", username, err)
+	}
+	return id
+}
+
+func TestLog(t *testing.T) {
+	alice, bob, mallory := newIdentity(t, "alice"), newIdentity(t, "bob"), newIdentity(t, "mallory")
+	r, err := repo.Init(t.TempDir(), repo.ForumMeta{Name: "Forum", AdminPubkey: alice.PublicKey}, alice)
+	if err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	must := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	must(r.SubmitJoinRequest(bob))
+	must(r.ApproveJoinRequest(alice, "bob"))
+	must(r.CreateCategory(alice, "general", "General", "", 0))
+
+	root, err := forum.SignPost(bob, "", "hello")
+	must(err)
+	must(r.CommitPost(bob, "general/hello/0000_root.md", root.Format()))
+	tomb, err := forum.SignTombstone(alice, root.Format())
+	must(err)
+	must(r.CommitPost(alice, forum.TombstoneFilename("general/hello/0000_root.md"), tomb.Format()))
+
+	bans, err := forum.SignBanList(alice, []forum.Ban{{Username: "bob", Since: "2026-01-01T00:00:00Z"}})
+	must(err)
+	must(r.CommitFile(alice, forum.BansFilename, bans.Format(), "config: ban @bob"))
+	// A ban list mallory signed herself does not verify against the admin key.
+	forged, err := forum.SignBanList(mallory, nil)
+	must(err)
+	must(r.CommitFile(mallory, forum.BansFilename, forged.Format(), "config: unban @bob"))
+
+	entries, err := audit.Log(r, audit.Filter{})
+	if err != nil {
+		t.Fatalf("Log: %v", err)
+	}
+	var got []string
+	for _, e := range entries {
+		s := string(e.Action) + "@" + e.Actor
+		if !e.Verified {
+			s += "!"
+		}
+		got = append(got, s)
+	}
+	want := "config@mallory! config@alice tombstone@alice category@alice keys@alice request@bob init@alice"
+	if strings.Join(got, " ") != want {
+		t.Errorf("entries:\n got %s\nwant %s", strings.Join(got, " "), want)
+	}
+	if e := entries[2]; e.Target != "general/hello/0000_root.md" || !e.ByAdmin || len(e.Records) != 1 {
+		t.Errorf("tombstone entry = %+v", e)
+	}
+	if e := entries[0]; e.ByAdmin || len(e.Records) != 1 || e.Records[0].Err == "" {
+		t.Errorf("forged entry = %+v", e)
+	}
+
+	entries, err = audit.Log(r, audit.Filter{Actor: "alice", Action: audit.ActionKeys})
+	if err != nil {
+		t.Fatalf("Log: %v", err)
+	}
+	if len(entries) != 1 || entries[0].Target != "approve join request from bob" {
+		t.Errorf("filtered entries = %+v", entries)
+	}
+
+	entries, err = audit.Log(r, audit.Filter{Since: time.Now().Add(time.Hour)})
+	if err != nil {
+		t.Fatalf("Log: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("entries since an hour from now = %d, want 0", len(entries))
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	day, _ := audit.ParseTime("2026-03-01", false)
+	end, _ := audit.ParseTime("2026-03-01", true)
+	if !day.Equal(time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)) || end.Sub(day) != 24*time.Hour {
+		t.Errorf("ParseTime: got %v and %v", day, end)
+	}
+	if _, err := audit.ParseTime("2026-03-01T10:00:00+02:00", false); err != nil {
+		t.Errorf("RFC 3339: %v", err)
+	}
+	if _, err := audit.ParseTime("yesterday", false); err == nil {
+		t.Error("ParseTime accepted \"yesterday\"")
+	}
+}
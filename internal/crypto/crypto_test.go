@@ -1,8 +1,11 @@
 package crypto_test
 
 import (
+	"errors"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/gosub/gitorum/internal/crypto"
@@ -111,6 +114,43 @@ func TestLoadOrCreate_CreatesNew(t *testing.T) {
 	}
 }
 
+func TestStore(t *testing.T) {
+	dir := t.TempDir()
+	store := &crypto.Store{Dir: filepath.Join(dir, "identities"), Default: filepath.Join(dir, "identity.toml")}
+	if names, err := store.List(); err != nil || len(names) != 0 {
+		t.Fatalf("List on an empty store: %v, %v", names, err)
+	}
+
+	def, _ := crypto.Generate("alice")
+	if err := def.Save(store.Default); err != nil {
+		t.Fatal(err)
+	}
+	work, _ := crypto.Generate("alice-work")
+	if err := store.Add("work", work); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	if err := store.Add("work", def); err == nil {
+		t.Error("Add over an existing identity: want error")
+	}
+	if err := store.Add("../evil", def); err == nil {
+		t.Error("Add with a path as name: want error")
+	}
+
+	names, err := store.List()
+	if err != nil || len(names) != 2 || names[0] != crypto.DefaultName || names[1] != "work" {
+		t.Fatalf("List: %v, %v", names, err)
+	}
+	if got, err := store.Load("work"); err != nil || got.PublicKey != work.PublicKey {
+		t.Errorf("Load(work): %v", err)
+	}
+	if got, err := store.Load(crypto.DefaultName); err != nil || got.PublicKey != def.PublicKey {
+		t.Errorf("Load(default): %v", err)
+	}
+	if _, err := store.Load("home"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Load of a missing identity: got %v, want fs.ErrNotExist", err)
+	}
+}
+
 // ---- sign / verify ----
 
 func TestSign_Verify_RoundTrip(t *testing.T) {
@@ -270,3 +310,36 @@ func TestVerifyWithPublicKeyB64(t *testing.T) {
 		t.Error("expected error with wrong public key")
 	}
 }
+
+func TestSSHSignature(t *testing.T) {
+	id, err := crypto.Generate("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := []byte("tree 1234\nauthor alice\n\ncommit message\n")
+	sig, err := id.SignSSH(crypto.GitNamespace, msg)
+	if err != nil {
+		t.Fatalf("SignSSH: %v", err)
+	}
+	if !strings.HasPrefix(string(sig), "-----BEGIN SSH SIGNATURE-----\n") {
+		t.Errorf("not armored: %s", sig)
+	}
+	pub, err := crypto.VerifySSH(sig, crypto.GitNamespace, msg)
+	if err != nil || pub != id.PublicKey {
+		t.Fatalf("VerifySSH: %q, %v", pub, err)
+	}
+	if _, err := crypto.VerifySSH(sig, crypto.GitNamespace, append(msg, '!')); err == nil {
+		t.Error("tampered message: want error")
+	}
+	if _, err := crypto.VerifySSH(sig, "file", msg); err == nil {
+		t.Error("other namespace: want error")
+	}
+	if _, err := crypto.VerifySSH([]byte("-----BEGIN PGP SIGNATURE-----\n\n-----END PGP SIGNATURE-----\n"), crypto.GitNamespace, msg); err == nil {
+		t.Error("PGP signature: want error")
+	}
+
+	sshKey, err := crypto.SSHPublicKey(id.PublicKey)
+	if err != nil || !strings.HasPrefix(sshKey, "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5") {
+		t.Errorf("SSHPublicKey: %q, %v", sshKey, err)
+	}
+}
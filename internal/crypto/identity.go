@@ -67,15 +67,27 @@ func (id *Identity) PubKey() (ed25519.PublicKey, error) {
 // Fingerprint returns a short human-readable identifier derived from the
 // public key (first 8 bytes of base64, no padding concerns).
 func (id *Identity) Fingerprint() string {
-	if len(id.PublicKey) >= 8 {
-		return id.PublicKey[:8]
+	return Fingerprint(id.PublicKey)
+}
+
+// Fingerprint returns the fingerprint of a base64 public key, as
+// Identity.Fingerprint does for an identity's own key.
+func Fingerprint(pubkeyB64 string) string {
+	if len(pubkeyB64) >= 8 {
+		return pubkeyB64[:8]
 	}
-	return id.PublicKey
+	return pubkeyB64
 }
 
 // DefaultIdentityPath returns the platform-appropriate path for the identity
 // file, respecting XDG_CONFIG_HOME.
 func DefaultIdentityPath() string {
+	return filepath.Join(configDir(), "identity.toml")
+}
+
+// configDir returns the gitorum configuration directory, respecting
+// XDG_CONFIG_HOME.
+func configDir() string {
 	base := os.Getenv("XDG_CONFIG_HOME")
 	if base == "" {
 		home, err := os.UserHomeDir()
@@ -85,7 +97,7 @@ func DefaultIdentityPath() string {
 			base = filepath.Join(home, ".config")
 		}
 	}
-	return filepath.Join(base, "gitorum", "identity.toml")
+	return filepath.Join(base, "gitorum")
 }
 
 // Save writes the identity to path, creating parent directories as needed.
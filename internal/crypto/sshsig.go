@@ -0,0 +1,169 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+)
+
+// GitNamespace is the SSH signature namespace git uses for commits.
+const GitNamespace = "git"
+
+// SSH signature format constants, from OpenSSH's PROTOCOL.sshsig.
+const (
+	sshsigMagic   = "SSHSIG"
+	sshsigVersion = 1
+	sshsigHash    = "sha512"
+	sshsigPEM     = "SSH SIGNATURE"
+	sshEd25519    = "ssh-ed25519"
+)
+
+// SSHPublicKey converts a base64-encoded Ed25519 public key to OpenSSH
+// format ("ssh-ed25519 AAAA..."), as used in git's allowed signers file.
+func SSHPublicKey(pubB64 string) (string, error) {
+	b, err := base64.StdEncoding.DecodeString(pubB64)
+	if err != nil {
+		return "", fmt.Errorf("decode public key: %w", err)
+	}
+	if len(b) != ed25519.PublicKeySize {
+		return "", fmt.Errorf("public key: expected %d bytes, got %d", ed25519.PublicKeySize, len(b))
+	}
+	return sshEd25519 + " " + base64.StdEncoding.EncodeToString(sshPublicKeyBlob(b)), nil
+}
+
+// SignSSH signs message in the SSH signature format written by
+// 'ssh-keygen -Y sign' and read by git for commits signed with
+// gpg.format=ssh, and returns the armored signature.
+func (id *Identity) SignSSH(namespace string, message []byte) ([]byte, error) {
+	priv, err := id.PrivKey()
+	if err != nil {
+		return nil, err
+	}
+	pub := priv.Public().(ed25519.PublicKey)
+	sig := ed25519.Sign(priv, sshsigSignedData(namespace, message))
+
+	var blob bytes.Buffer
+	blob.WriteString(sshsigMagic)
+	binary.Write(&blob, binary.BigEndian, uint32(sshsigVersion))
+	writeSSHString(&blob, sshPublicKeyBlob(pub))
+	writeSSHString(&blob, []byte(namespace))
+	writeSSHString(&blob, nil) // reserved
+	writeSSHString(&blob, []byte(sshsigHash))
+	var sigBlob bytes.Buffer
+	writeSSHString(&sigBlob, []byte(sshEd25519))
+	writeSSHString(&sigBlob, sig)
+	writeSSHString(&blob, sigBlob.Bytes())
+
+	return armorSSH(blob.Bytes()), nil
+}
+
+// VerifySSH checks an armored SSH signature of message made under
+// namespace, and returns the base64-encoded Ed25519 public key that made
+// it. Only Ed25519 signatures are supported.
+func VerifySSH(armored []byte, namespace string, message []byte) (string, error) {
+	block, _ := pem.Decode(bytes.TrimSpace(armored))
+	if block == nil || block.Type != sshsigPEM {
+		return "", errors.New("not an SSH signature")
+	}
+	b := block.Bytes
+	if !bytes.HasPrefix(b, []byte(sshsigMagic)) || len(b) < len(sshsigMagic)+4 {
+		return "", errors.New("SSH signature: bad magic")
+	}
+	b = b[len(sshsigMagic):]
+	if v := binary.BigEndian.Uint32(b); v != sshsigVersion {
+		return "", fmt.Errorf("SSH signature: unsupported version %d", v)
+	}
+	b = b[4:]
+
+	var fields [5][]byte // public key, namespace, reserved, hash, signature
+	for i := range fields {
+		var err error
+		if fields[i], b, err = readSSHString(b); err != nil {
+			return "", fmt.Errorf("SSH signature: %w", err)
+		}
+	}
+	keyBlob, ns, hash, sigBlob := fields[0], string(fields[1]), string(fields[3]), fields[4]
+	if ns != namespace {
+		return "", fmt.Errorf("SSH signature: namespace %q, want %q", ns, namespace)
+	}
+	if hash != sshsigHash {
+		return "", fmt.Errorf("SSH signature: unsupported hash %q", hash)
+	}
+
+	keyType, rest, err := readSSHString(keyBlob)
+	if err != nil || string(keyType) != sshEd25519 {
+		return "", errors.New("SSH signature: only ssh-ed25519 keys are supported")
+	}
+	pub, _, err := readSSHString(rest)
+	if err != nil || len(pub) != ed25519.PublicKeySize {
+		return "", errors.New("SSH signature: malformed public key")
+	}
+	sigType, rest, err := readSSHString(sigBlob)
+	if err != nil || string(sigType) != sshEd25519 {
+		return "", errors.New("SSH signature: only ssh-ed25519 signatures are supported")
+	}
+	sig, _, err := readSSHString(rest)
+	if err != nil {
+		return "", errors.New("SSH signature: malformed signature")
+	}
+	if !ed25519.Verify(ed25519.PublicKey(pub), sshsigSignedData(namespace, message), sig) {
+		return "", errors.New("signature verification failed")
+	}
+	return base64.StdEncoding.EncodeToString(pub), nil
+}
+
+// sshsigSignedData is what an SSH signature actually signs: a hash of the
+// message wrapped with the namespace.
+func sshsigSignedData(namespace string, message []byte) []byte {
+	h := sha512.Sum512(message)
+	var buf bytes.Buffer
+	buf.WriteString(sshsigMagic)
+	writeSSHString(&buf, []byte(namespace))
+	writeSSHString(&buf, nil) // reserved
+	writeSSHString(&buf, []byte(sshsigHash))
+	writeSSHString(&buf, h[:])
+	return buf.Bytes()
+}
+
+func sshPublicKeyBlob(pub ed25519.PublicKey) []byte {
+	var buf bytes.Buffer
+	writeSSHString(&buf, []byte(sshEd25519))
+	writeSSHString(&buf, pub)
+	return buf.Bytes()
+}
+
+// armorSSH wraps a signature blob the way ssh-keygen does: base64 in
+// 70-column lines between BEGIN and END markers.
+func armorSSH(blob []byte) []byte {
+	enc := base64.StdEncoding.EncodeToString(blob)
+	var buf bytes.Buffer
+	buf.WriteString("-----BEGIN " + sshsigPEM + "-----\n")
+	for len(enc) > 70 {
+		buf.WriteString(enc[:70] + "\n")
+		enc = enc[70:]
+	}
+	buf.WriteString(enc + "\n")
+	buf.WriteString("-----END " + sshsigPEM + "-----\n")
+	return buf.Bytes()
+}
+
+func writeSSHString(buf *bytes.Buffer, s []byte) {
+	binary.Write(buf, binary.BigEndian, uint32(len(s)))
+	buf.Write(s)
+}
+
+func readSSHString(b []byte) (s, rest []byte, err error) {
+	if len(b) < 4 {
+		return nil, nil, errors.New("truncated")
+	}
+	n := binary.BigEndian.Uint32(b)
+	if uint64(len(b)-4) < uint64(n) {
+		return nil, nil, errors.New("truncated")
+	}
+	return b[4 : 4+n], b[4+n:], nil
+}
@@ -0,0 +1,85 @@
+package crypto
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// DefaultName is the store name of the default identity file.
+const DefaultName = "default"
+
+var nameRe = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*$`)
+
+// Store is a directory of named identities, one TOML file each, e.g.
+// ~/.config/gitorum/identities/work.toml. The default identity file
+// (identity.toml) is part of the store under the name "default".
+type Store struct {
+	Dir     string // holds {name}.toml
+	Default string // path of the default identity file
+}
+
+// DefaultStore returns the store in the gitorum configuration directory.
+func DefaultStore() *Store {
+	return &Store{Dir: filepath.Join(configDir(), "identities"), Default: DefaultIdentityPath()}
+}
+
+// Path returns the file of the identity called name.
+func (s *Store) Path(name string) (string, error) {
+	if name == DefaultName {
+		return s.Default, nil
+	}
+	if !nameRe.MatchString(name) {
+		return "", fmt.Errorf("invalid identity name %q: use lowercase letters, digits, dots, dashes and underscores", name)
+	}
+	return filepath.Join(s.Dir, name+".toml"), nil
+}
+
+// List returns the names of the stored identities, sorted, with "default"
+// first when the default identity file exists.
+func (s *Store) List() ([]string, error) {
+	var names []string
+	entries, err := os.ReadDir(s.Dir)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("read identity store: %w", err)
+	}
+	for _, e := range entries {
+		name, ok := strings.CutSuffix(e.Name(), ".toml")
+		if ok && !e.IsDir() && nameRe.MatchString(name) && name != DefaultName {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	if _, err := os.Stat(s.Default); err == nil {
+		names = append([]string{DefaultName}, names...)
+	}
+	return names, nil
+}
+
+// Load reads the identity called name.
+func (s *Store) Load(name string) (*Identity, error) {
+	path, err := s.Path(name)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil, fmt.Errorf("no identity %q: %w", name, fs.ErrNotExist)
+	}
+	return LoadIdentity(path)
+}
+
+// Add saves id under name. Existing identities are never overwritten.
+func (s *Store) Add(name string, id *Identity) error {
+	path, err := s.Path(name)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("identity %q already exists", name)
+	}
+	return id.Save(path)
+}
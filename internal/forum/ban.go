@@ -0,0 +1,179 @@
+package forum
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/gosub/gitorum/internal/crypto"
+)
+
+// BansFilename is the admin-signed ban list at the repository root.
+const BansFilename = "bans.toml"
+
+// Flags set on posts by the ban and mute lists.
+const (
+	FlagBanned = "banned" // author is banned by the admin
+	FlagMuted  = "muted"  // author is on the local identity's mute list
+)
+
+// Ban bars a user from the forum. The user is matched by username and by
+// the fingerprint of PubKey, so re-joining under another name with the same
+// key does not escape the ban. Since records when the ban was made; it does
+// not spare earlier posts, as a post's timestamp is set by its author.
+type Ban struct {
+	Username string `toml:"username" json:"username"`
+	PubKey   string `toml:"pubkey" json:"pubkey"` // base64 key at the time of the ban; may be empty
+	Reason   string `toml:"reason" json:"reason"`
+	Since    string `toml:"since" json:"since"` // RFC3339 time of the ban
+}
+
+// BanList is the content of bans.toml. The whole list is signed with the
+// admin key and replaced on every change.
+type BanList struct {
+	Bans      []Ban  `toml:"ban"`
+	SignedBy  string `toml:"signed_by"`
+	Timestamp string `toml:"timestamp"`
+	Signature string `toml:"signature"`
+}
+
+// SignBanList creates a ban list signed by adminID.
+func SignBanList(adminID *crypto.Identity, bans []Ban) (*BanList, error) {
+	for _, b := range bans {
+		if b.Username == "" {
+			return nil, errors.New("ban without username")
+		}
+		if _, err := time.Parse(time.RFC3339, b.Since); err != nil {
+			return nil, fmt.Errorf("ban of %s: since: %w", b.Username, err)
+		}
+	}
+	l := &BanList{
+		Bans:      bans,
+		SignedBy:  adminID.Username,
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+	}
+	priv, err := adminID.PrivKey()
+	if err != nil {
+		return nil, fmt.Errorf("get private key: %w", err)
+	}
+	l.Signature = crypto.Sign(priv, l.canonical())
+	return l, nil
+}
+
+// LoadBanList reads and verifies bans.toml at path. A missing file yields an
+// empty list; a file not signed with adminPubkey is an error.
+func LoadBanList(path, adminPubkey string) (*BanList, error) {
+	var l BanList
+	if _, err := toml.DecodeFile(path, &l); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return &BanList{}, nil
+		}
+		return nil, fmt.Errorf("read %s: %w", filepath.Base(path), err)
+	}
+	if adminPubkey == "" {
+		return nil, errors.New("bans: no admin key")
+	}
+	if err := crypto.VerifyWithPublicKeyB64(adminPubkey, l.canonical(), l.Signature); err != nil {
+		return nil, fmt.Errorf("bans: %w", err)
+	}
+	return &l, nil
+}
+
+// Format serializes the list to the bans.toml format.
+func (l *BanList) Format() []byte {
+	var sb strings.Builder
+	_ = toml.NewEncoder(&sb).Encode(l)
+	return []byte(sb.String())
+}
+
+func (l *BanList) canonical() []byte {
+	bans, _ := json.Marshal(l.Bans)
+	return crypto.CanonicalForm(map[string]string{
+		"bans":      string(bans),
+		"signed_by": l.SignedBy,
+		"timestamp": l.Timestamp,
+	}, "")
+}
+
+// Find returns the ban covering posts by author with key fingerprint
+// fingerprint, or nil.
+func (l *BanList) Find(author, fingerprint string) *Ban {
+	if l == nil {
+		return nil
+	}
+	for i, b := range l.Bans {
+		if b.Username == author || (b.PubKey != "" && fingerprint != "" && crypto.Fingerprint(b.PubKey) == fingerprint) {
+			return &l.Bans[i]
+		}
+	}
+	return nil
+}
+
+// With returns the bans of l with b added, replacing any earlier ban of the
+// same username.
+func (l *BanList) With(b Ban) []Ban {
+	bans, _ := l.Without(b.Username)
+	return append(bans, b)
+}
+
+// Without returns the bans of l minus the one of username, and whether such a
+// ban existed.
+func (l *BanList) Without(username string) ([]Ban, bool) {
+	var bans []Ban
+	found := false
+	for _, b := range l.Bans {
+		if b.Username == username {
+			found = true
+			continue
+		}
+		bans = append(bans, b)
+	}
+	return bans, found
+}
+
+// hidePost flags p when its author is banned or muted under opts, or when
+// it lacks the proof of work opts requires of posts that do not verify
+// against keys/: by authors without a key, with a bad signature, or
+// malformed. Vouched-for authors are exempt (see workExempt). It reports
+// whether p was flagged.
+func (opts LoadOptions) hidePost(p *Post) bool {
+	if p.Tombstoned || p.Flag != "" {
+		return false
+	}
+	if b := opts.Bans.Find(p.Author, p.PubKey); b != nil {
+		p.Flag = FlagBanned
+		p.FlagReason = "author is banned"
+		if b.Reason != "" {
+			p.FlagReason += ": " + b.Reason
+		}
+		return true
+	}
+	if opts.Muted[p.Author] {
+		p.Flag = FlagMuted
+		p.FlagReason = "you muted this user"
+		return true
+	}
+	if opts.Work > 0 && p.SigStatus != SigValid && p.WorkBits < opts.Work && !opts.workExempt(p) {
+		p.Flag = FlagNoWork
+		p.FlagReason = fmt.Sprintf("post is not signed by an approved key and carries %d of the %d bits of proof of work required", p.WorkBits, opts.Work)
+		return true
+	}
+	return false
+}
+
+// workExempt reports whether p is exempt from the proof of work: its
+// author has no key in keys/ and opts.Trust reaches p's key through at
+// least one vouch. The root of the web of trust does not exempt itself.
+func (opts LoadOptions) workExempt(p *Post) bool {
+	if p.SigStatus != SigMissing {
+		return false
+	}
+	k := opts.Trust.Vouched(p)
+	return k != nil && len(k.Path) > 0
+}
@@ -3,31 +3,45 @@ package forum
 import (
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
 	"sort"
 
 	"github.com/BurntSushi/toml"
 )
 
+// MetaFilename is the file that marks a directory as a category.
+const MetaFilename = "META.toml"
+
 // categoryMeta is the raw TOML structure of a META.toml file.
 type categoryMeta struct {
-	Name        string `toml:"name"`
-	Description string `toml:"description"`
+	Name        string       `toml:"name"`
+	Description string       `toml:"description"`
+	Order       int          `toml:"order,omitempty"`
+	Policy      *WritePolicy `toml:"policy,omitempty"`
 }
 
-// Category represents a forum category.
+// Category represents a forum category. Categories nest: a subcategory is a
+// directory with its own META.toml inside its parent's directory, and its
+// Slug is the slash-separated path from the repository root, e.g.
+// "projects/gitorum".
 type Category struct {
 	Slug        string
 	Name        string
 	Description string
-	ThreadSlugs []string // slugs of valid threads (have 0000_root.md), sorted
+	Order       int          // position among siblings, lowest first; ties sort by slug
+	Parent      string       // slug of the parent category; empty at the top level
+	Children    []string     // slugs of direct subcategories, in display order
+	ThreadSlugs []string     // slugs of valid threads (have 0000_root.md), sorted
+	Policy      *WritePolicy // write policy from META.toml, not yet verified; nil when open
 }
 
-// LoadCategory reads META.toml from dir and enumerates valid thread
-// subdirectories (those that contain a 0000_root.md file).
+// LoadCategory reads META.toml from dir and enumerates its subdirectories.
+// A subdirectory with a META.toml is a subcategory; one with a 0000_root.md
+// is a thread. Anything else is ignored.
 func LoadCategory(slug, dir string) (*Category, error) {
-	var meta categoryMeta
-	if _, err := toml.DecodeFile(filepath.Join(dir, "META.toml"), &meta); err != nil {
+	meta, err := readCategoryMeta(dir)
+	if err != nil {
 		return nil, fmt.Errorf("read META.toml for category %q: %w", slug, err)
 	}
 
@@ -40,16 +54,41 @@ func LoadCategory(slug, dir string) (*Category, error) {
 		Slug:        slug,
 		Name:        meta.Name,
 		Description: meta.Description,
+		Order:       meta.Order,
+		Policy:      meta.Policy,
 	}
+	if parent := path.Dir(slug); parent != "." {
+		c.Parent = parent
+	}
+	order := map[string]int{}
 	for _, entry := range entries {
 		if !entry.IsDir() {
 			continue
 		}
-		rootPath := filepath.Join(dir, entry.Name(), RootFilename)
-		if _, err := os.Stat(rootPath); err == nil {
+		sub := filepath.Join(dir, entry.Name())
+		if child, err := readCategoryMeta(sub); err == nil {
+			childSlug := path.Join(slug, entry.Name())
+			c.Children = append(c.Children, childSlug)
+			order[childSlug] = child.Order
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(sub, RootFilename)); err == nil {
 			c.ThreadSlugs = append(c.ThreadSlugs, entry.Name())
 		}
 	}
 	sort.Strings(c.ThreadSlugs)
+	sort.Slice(c.Children, func(i, j int) bool {
+		a, b := c.Children[i], c.Children[j]
+		if order[a] != order[b] {
+			return order[a] < order[b]
+		}
+		return a < b
+	})
 	return c, nil
 }
+
+func readCategoryMeta(dir string) (categoryMeta, error) {
+	var meta categoryMeta
+	_, err := toml.DecodeFile(filepath.Join(dir, MetaFilename), &meta)
+	return meta, err
+}
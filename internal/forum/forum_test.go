@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
 	"testing"
 	"time"
@@ -561,6 +562,41 @@ func TestLoadCategory_EmptyCategory(t *testing.T) {
 	}
 }
 
+func TestLoadCategory_Subcategories(t *testing.T) {
+	dir := t.TempDir()
+	setupCategory(t, dir, "projects", "Projects", []string{"announcements"})
+	catDir := filepath.Join(dir, "projects")
+	setupCategory(t, catDir, "zeta", "Zeta", []string{"t1"})
+	setupCategory(t, catDir, "alpha", "Alpha", nil)
+	setupCategory(t, catDir, "first", "First", nil)
+	if err := os.WriteFile(filepath.Join(catDir, "first", "META.toml"), []byte("name = \"First\"\norder = -1\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cat, err := forum.LoadCategory("projects", catDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(cat.ThreadSlugs, []string{"announcements"}) {
+		t.Errorf("ThreadSlugs: got %v", cat.ThreadSlugs)
+	}
+	want := []string{"projects/first", "projects/alpha", "projects/zeta"}
+	if !slices.Equal(cat.Children, want) {
+		t.Errorf("Children: got %v, want %v", cat.Children, want)
+	}
+	if cat.Parent != "" {
+		t.Errorf("Parent: got %q", cat.Parent)
+	}
+
+	sub, err := forum.LoadCategory("projects/first", filepath.Join(catDir, "first"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sub.Parent != "projects" || sub.Order != -1 {
+		t.Errorf("subcategory: parent %q, order %d", sub.Parent, sub.Order)
+	}
+}
+
 // ---- Tombstone ----
 
 func TestTombstoneFilename(t *testing.T) {
@@ -670,3 +706,1391 @@ func TestScanThread_TombstonedReplyNotCounted(t *testing.T) {
 		t.Errorf("ReplyCount: got %d, want 0 (tombstoned reply excluded)", scan.ReplyCount)
 	}
 }
+
+// ---- Polls ----
+
+// writePoll signs a poll root into dir and returns its raw content.
+func writePoll(t *testing.T, dir string, id *crypto.Identity, options []string, closes string) []byte {
+	t.Helper()
+	post, err := forum.SignPoll(id, "Which one?", options, closes)
+	if err != nil {
+		t.Fatalf("SignPoll: %v", err)
+	}
+	content := post.Format()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, forum.RootFilename), content, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return content
+}
+
+// writeVote signs a vote for option and writes it into dir.
+func writeVote(t *testing.T, dir string, id *crypto.Identity, rootContent []byte, option string) string {
+	t.Helper()
+	vote, err := forum.SignVote(id, rootContent, option)
+	if err != nil {
+		t.Fatalf("SignVote: %v", err)
+	}
+	vote.Filename = forum.NewVoteFilename(id.Username, option)
+	if err := os.WriteFile(filepath.Join(dir, vote.Filename), vote.Format(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return vote.Filename
+}
+
+func TestSignPoll_RoundTripVerifies(t *testing.T) {
+	id := mustGenerate(t, "alice")
+	keysDir := t.TempDir()
+	writeKey(t, keysDir, "alice", id.PublicKey)
+
+	poll, err := forum.SignPoll(id, "Lunch?", []string{"Pizza", "Sushi \"deluxe\""}, "2030-01-01T00:00:00Z")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := forum.ParsePost(forum.RootFilename, poll.Format())
+	if err != nil {
+		t.Fatalf("ParsePost: %v", err)
+	}
+	if !parsed.IsPoll() || len(parsed.PollOptions) != 2 || parsed.PollOptions[1] != "Sushi \"deluxe\"" {
+		t.Errorf("PollOptions: got %q", parsed.PollOptions)
+	}
+	if parsed.PollCloses != "2030-01-01T00:00:00Z" {
+		t.Errorf("PollCloses: got %q", parsed.PollCloses)
+	}
+	parsed.VerifySignature(keysDir)
+	if parsed.SigStatus != forum.SigValid {
+		t.Fatalf("SigStatus: got %v – %s", parsed.SigStatus, parsed.SigError)
+	}
+
+	// The options are covered by the signature.
+	parsed.PollOptions[0] = "Burgers"
+	parsed.VerifySignature(keysDir)
+	if parsed.SigStatus != forum.SigInvalid {
+		t.Error("changing a poll option should invalidate the signature")
+	}
+}
+
+func TestSignPoll_InvalidOptions(t *testing.T) {
+	id := mustGenerate(t, "alice")
+	for _, opts := range [][]string{
+		nil,
+		{"only one"},
+		{"a", "a"},
+		{"a", ""},
+		{"a", "line\nbreak"},
+	} {
+		if _, err := forum.SignPoll(id, "q", opts, ""); err == nil {
+			t.Errorf("options %q: expected error", opts)
+		}
+	}
+	if _, err := forum.SignPoll(id, "q", []string{"a", "b"}, "tomorrow"); err == nil {
+		t.Error("expected error for malformed close time")
+	}
+}
+
+func TestTallyPoll(t *testing.T) {
+	alice := mustGenerate(t, "alice")
+	bob := mustGenerate(t, "bob")
+	mallory := mustGenerate(t, "mallory") // not in keys/
+	dir := t.TempDir()
+	keysDir := filepath.Join(dir, "keys")
+	threadDir := filepath.Join(dir, "poll")
+	writeKey(t, keysDir, "alice", alice.PublicKey)
+	writeKey(t, keysDir, "bob", bob.PublicKey)
+
+	root := writePoll(t, threadDir, alice, []string{"Yes", "No"}, "")
+	writeVote(t, threadDir, alice, root, "Yes")
+	writeVote(t, threadDir, bob, root, "Yes")
+	time.Sleep(2 * time.Millisecond)
+	writeVote(t, threadDir, bob, root, "No") // bob changes his mind
+	writeVote(t, threadDir, mallory, root, "No")
+
+	// A vote pointing at a different poll must not count.
+	otherRoot := []byte("some other poll")
+	writeVote(t, threadDir, alice, otherRoot, "No")
+
+	res, err := forum.TallyPoll(threadDir, keysDir)
+	if err != nil {
+		t.Fatalf("TallyPoll: %v", err)
+	}
+	if res.Closed || !res.ClosesAt.IsZero() {
+		t.Error("open-ended poll should not be closed")
+	}
+	got := map[string]int{}
+	for _, o := range res.Options {
+		got[o.Text] = o.Votes
+	}
+	if got["Yes"] != 1 || got["No"] != 1 {
+		t.Errorf("counts: got %v, want Yes=1 No=1", got)
+	}
+	if len(res.Voters) != 2 || res.Voters[0].Voter != "alice" || res.Voters[1].Voter != "bob" {
+		t.Fatalf("Voters: got %+v", res.Voters)
+	}
+	if res.Voters[1].Option != "No" {
+		t.Errorf("bob's counted vote: got %q, want latest (No)", res.Voters[1].Option)
+	}
+	if res.Voters[1].Signature == "" || res.Voters[1].Filename == "" {
+		t.Error("counted votes must expose signature and filename")
+	}
+	// bob's superseded vote, mallory's unapproved vote, alice's foreign vote.
+	if len(res.Rejected) != 3 {
+		t.Errorf("Rejected: got %d, want 3: %+v", len(res.Rejected), res.Rejected)
+	}
+}
+
+func TestTallyPoll_ReplyAsVoteRejected(t *testing.T) {
+	alice := mustGenerate(t, "alice")
+	bob := mustGenerate(t, "bob")
+	dir := t.TempDir()
+	keysDir := filepath.Join(dir, "keys")
+	threadDir := filepath.Join(dir, "poll")
+	writeKey(t, keysDir, "alice", alice.PublicKey)
+	writeKey(t, keysDir, "bob", bob.PublicKey)
+	root := writePoll(t, threadDir, alice, []string{"Yes", "No"}, "")
+
+	// bob's reply "Yes" to the poll, copied into a vote file.
+	reply, err := forum.SignPost(bob, forum.PostHash(root), "Yes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := forum.NewVoteFilename("bob", "Yes")
+	if err := os.WriteFile(filepath.Join(threadDir, name), reply.Format(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// And a vote of bob's, copied into a reply.
+	vote, err := forum.SignVote(bob, root, "No")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := forum.ParsePost("1_vote.md", vote.Format()); err == nil {
+		t.Error("a vote parsed as a post")
+	}
+
+	res, err := forum.TallyPoll(threadDir, keysDir)
+	if err != nil {
+		t.Fatalf("TallyPoll: %v", err)
+	}
+	if len(res.Voters) != 0 || len(res.Rejected) != 1 || res.Rejected[0].Reason != "not signed as a vote" {
+		t.Errorf("Voters %+v, Rejected %+v", res.Voters, res.Rejected)
+	}
+}
+
+func TestTallyPoll_VotesAfterCloseRejected(t *testing.T) {
+	alice := mustGenerate(t, "alice")
+	dir := t.TempDir()
+	keysDir := filepath.Join(dir, "keys")
+	threadDir := filepath.Join(dir, "poll")
+	writeKey(t, keysDir, "alice", alice.PublicKey)
+
+	closes := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	root := writePoll(t, threadDir, alice, []string{"Yes", "No"}, closes)
+	writeVote(t, threadDir, alice, root, "Yes")
+
+	res, err := forum.TallyPoll(threadDir, keysDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Closed {
+		t.Error("poll should be closed")
+	}
+	if len(res.Voters) != 0 || len(res.Rejected) != 1 {
+		t.Fatalf("Voters=%d Rejected=%d, want 0 and 1", len(res.Voters), len(res.Rejected))
+	}
+	if !strings.Contains(res.Rejected[0].Reason, "closed") {
+		t.Errorf("Reason: got %q", res.Rejected[0].Reason)
+	}
+}
+
+func TestTallyPoll_NotAPoll(t *testing.T) {
+	id := mustGenerate(t, "alice")
+	dir := t.TempDir()
+	signedPost(t, dir, forum.RootFilename, id, "", "Just a post")
+	if _, err := forum.TallyPoll(dir, filepath.Join(dir, "keys")); err == nil {
+		t.Error("expected error for a thread without a poll")
+	}
+}
+
+func TestTallyPollWith_Moderation(t *testing.T) {
+	dir := t.TempDir()
+	keysDir := filepath.Join(dir, "keys")
+	threadDir := filepath.Join(dir, "poll")
+	admin := mustGenerate(t, "alice")
+	bob := mustGenerate(t, "bob")
+	carol := mustGenerate(t, "carol")
+	dave := mustGenerate(t, "dave")
+	for _, id := range []*crypto.Identity{admin, bob, carol, dave} {
+		writeKey(t, keysDir, id.Username, id.PublicKey)
+	}
+	bans, err := forum.SignBanList(admin, []forum.Ban{{Username: "bob", PubKey: bob.PublicKey, Since: time.Now().UTC().Format(time.RFC3339)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t0 := time.Now().Add(-time.Hour)
+	root := writePoll(t, threadDir, admin, []string{"Yes", "No"}, "")
+	rootHash := forum.PostHash(root)
+	signedAt(t, threadDir, "1_lock.state", admin, rootHash, "locked = true", t0.Add(10*time.Second))
+	signedAt(t, threadDir, "1_bob.vote", bob, rootHash, "Yes", t0) // backdated, still banned
+	signedAt(t, threadDir, "2_carol.vote", carol, rootHash, "Yes", t0.Add(20*time.Second))
+	signedAt(t, threadDir, "3_dave.vote", dave, rootHash, "No", t0.Add(5*time.Second))
+	signedAt(t, threadDir, "4_alice.vote", admin, rootHash, "No", t0.Add(20*time.Second))
+
+	res, err := forum.TallyPollWith(threadDir, keysDir, forum.LoadOptions{AdminPubkey: admin.PublicKey, Bans: bans})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var voters []string
+	for _, v := range res.Voters {
+		voters = append(voters, v.Voter)
+	}
+	if strings.Join(voters, ",") != "alice,dave" {
+		t.Errorf("voters: got %v, want alice,dave", voters)
+	}
+	reasons := map[string]string{}
+	for _, v := range res.Rejected {
+		reasons[v.Voter] = v.Reason
+	}
+	if !strings.Contains(reasons["bob"], "banned") || !strings.Contains(reasons["carol"], "locked") {
+		t.Errorf("rejected: got %v", reasons)
+	}
+
+	// The same thread read through LoadThreadWith is tallied the same way.
+	thread, err := forum.LoadThreadWith("cat", "poll", threadDir, keysDir, forum.LoadOptions{AdminPubkey: admin.PublicKey, Bans: bans})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if thread.Poll == nil || len(thread.Poll.Voters) != 2 || len(thread.Poll.Rejected) != 2 {
+		t.Errorf("LoadThreadWith poll: got %+v", thread.Poll)
+	}
+
+	// A write policy that bars dave from replying also discards his vote.
+	pm := forum.NewPermissions(&forum.WritePolicy{Replies: []string{"carol"}}, nil)
+	opts := forum.LoadOptions{AdminPubkey: admin.PublicKey, Permissions: pm}
+	if res, err = forum.TallyPollWith(threadDir, keysDir, opts); err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Voters) != 1 || res.Voters[0].Voter != "alice" {
+		t.Errorf("voters under policy: got %+v", res.Voters)
+	}
+}
+
+func TestLoadThread_PollTallied(t *testing.T) {
+	alice := mustGenerate(t, "alice")
+	dir := t.TempDir()
+	keysDir := filepath.Join(dir, "keys")
+	threadDir := filepath.Join(dir, "poll")
+	writeKey(t, keysDir, "alice", alice.PublicKey)
+
+	root := writePoll(t, threadDir, alice, []string{"Yes", "No"}, "")
+	writeVote(t, threadDir, alice, root, "No")
+
+	thread, err := forum.LoadThread("cat", "poll", threadDir, keysDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(thread.Posts) != 1 {
+		t.Errorf("Posts: got %d, want 1 (vote files are not posts)", len(thread.Posts))
+	}
+	if thread.Poll == nil {
+		t.Fatal("Poll is nil for a poll thread")
+	}
+	if thread.Poll.Options[1].Votes != 1 {
+		t.Errorf("No votes: got %d, want 1", thread.Poll.Options[1].Votes)
+	}
+}
+
+// ---- Mentions ----
+
+func TestMentions(t *testing.T) {
+	users := map[string]bool{"alice": true, "bob": true, "carol.b": true}
+	tests := []struct {
+		body string
+		want []string
+	}{
+		{"hi @alice and @bob", []string{"alice", "bob"}},
+		{"@alice @alice", []string{"alice"}},
+		{"thanks @bob.", []string{"bob"}},
+		{"ping @carol.b!", []string{"carol.b"}},
+		{"@mallory is not here", nil},
+		{"mail alice@bob.example", nil},
+		{"`@alice` in code", nil},
+		{"```\n@alice\n```", nil},
+	}
+	for _, tc := range tests {
+		if got := forum.Mentions(tc.body, users); !slices.Equal(got, tc.want) {
+			t.Errorf("Mentions(%q): got %v, want %v", tc.body, got, tc.want)
+		}
+	}
+}
+
+func TestLoadThread_MentionsLinked(t *testing.T) {
+	dir := t.TempDir()
+	keysDir := filepath.Join(dir, "keys")
+	threadDir := filepath.Join(dir, "thread")
+
+	alice := mustGenerate(t, "alice")
+	bob := mustGenerate(t, "bob")
+	writeKey(t, keysDir, "alice", alice.PublicKey)
+	writeKey(t, keysDir, "bob", bob.PublicKey)
+	signedPost(t, threadDir, forum.RootFilename, alice, "", "hey @bob, and @nobody, see [@bob](https://example.com)")
+
+	thread, err := forum.LoadThread("cat", "thread", threadDir, keysDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := thread.Root
+	if !slices.Equal(root.Mentions, []string{"bob"}) {
+		t.Errorf("Mentions: got %v", root.Mentions)
+	}
+	if !strings.Contains(root.BodyHTML, `<a class="mention" href="#/user/bob">@bob</a>`) {
+		t.Errorf("mention not linked: %s", root.BodyHTML)
+	}
+	if strings.Contains(root.BodyHTML, `href="#/user/nobody"`) {
+		t.Errorf("unknown user linked: %s", root.BodyHTML)
+	}
+	if strings.Contains(root.BodyHTML, `<a href="https://example.com"><a`) {
+		t.Errorf("nested link: %s", root.BodyHTML)
+	}
+	if root.SigStatus != forum.SigValid {
+		t.Errorf("SigStatus: got %v (%s)", root.SigStatus, root.SigError)
+	}
+}
+
+// ---- Thread state ----
+
+// signedAt writes a post signed by id with the given timestamp, so tests can
+// place posts, votes and state records on a timeline. Files named *.vote
+// are signed as votes.
+func signedAt(t *testing.T, dir, filename string, id *crypto.Identity, parent, body string, ts time.Time) []byte {
+	t.Helper()
+	priv, err := id.PrivKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &forum.Post{
+		Author:       id.Username,
+		PubKey:       id.Fingerprint(),
+		TimestampRaw: ts.UTC().Format(time.RFC3339),
+		Parent:       parent,
+		Body:         body,
+	}
+	fields := map[string]string{
+		"author":    p.Author,
+		"pubkey":    p.PubKey,
+		"timestamp": p.TimestampRaw,
+		"parent":    p.Parent,
+	}
+	if strings.HasSuffix(filename, forum.VoteExt) {
+		p.Kind = forum.KindVote
+		fields["kind"] = p.Kind
+	}
+	p.Signature = crypto.Sign(priv, crypto.CanonicalForm(fields, body))
+	content := p.Format()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, filename), content, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return content
+}
+
+func TestLoadThreadState(t *testing.T) {
+	dir := t.TempDir()
+	keysDir := filepath.Join(dir, "keys")
+	threadDir := filepath.Join(dir, "thread")
+	admin := mustGenerate(t, "alice")
+	bob := mustGenerate(t, "bob")
+	writeKey(t, keysDir, "alice", admin.PublicKey)
+	writeKey(t, keysDir, "bob", bob.PublicKey)
+
+	t0 := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	root := signedAt(t, threadDir, forum.RootFilename, admin, "", "# Topic", t0)
+	rootHash := forum.PostHash(root)
+	signedAt(t, threadDir, "1_lock.state", admin, rootHash, "pinned = true\nlocked = true", t0.Add(10*time.Second))
+	signedAt(t, threadDir, "2_unlock.state", admin, rootHash, "pinned = true\nlocked = false", t0.Add(30*time.Second))
+	signedAt(t, threadDir, "3_forged.state", bob, rootHash, "locked = true", t0.Add(35*time.Second))
+	signedAt(t, threadDir, "4_other.state", admin, strings.Repeat("0", 64), "archived = true", t0.Add(40*time.Second))
+
+	st, err := forum.LoadThreadState(threadDir, keysDir, admin.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !st.Pinned || st.Locked || st.Archived || st.Closed() {
+		t.Errorf("state: got %+v, want pinned only", st.StateRecord)
+	}
+	if !st.ChangedAt.Equal(t0.Add(30*time.Second)) || st.ChangedBy != "alice" {
+		t.Errorf("changed: got %v by %q", st.ChangedAt, st.ChangedBy)
+	}
+	for _, tc := range []struct {
+		offset time.Duration
+		want   bool
+	}{{5 * time.Second, false}, {10 * time.Second, true}, {20 * time.Second, true}, {30 * time.Second, false}, {50 * time.Second, false}} {
+		if got := st.ClosedAt(t0.Add(tc.offset)); got != tc.want {
+			t.Errorf("ClosedAt(+%v): got %v, want %v", tc.offset, got, tc.want)
+		}
+	}
+
+	// Without an admin key nothing is trusted.
+	st, err = forum.LoadThreadState(threadDir, keysDir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Pinned || !st.ChangedAt.IsZero() {
+		t.Errorf("no admin key: got %+v", st)
+	}
+}
+
+func TestLoadThreadWith_RepliesWhileLockedFlagged(t *testing.T) {
+	dir := t.TempDir()
+	keysDir := filepath.Join(dir, "keys")
+	threadDir := filepath.Join(dir, "thread")
+	admin := mustGenerate(t, "alice")
+	bob := mustGenerate(t, "bob")
+	writeKey(t, keysDir, "alice", admin.PublicKey)
+	writeKey(t, keysDir, "bob", bob.PublicKey)
+
+	t0 := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	root := signedAt(t, threadDir, forum.RootFilename, admin, "", "# Topic", t0)
+	rootHash := forum.PostHash(root)
+	signedAt(t, threadDir, "1_lock.state", admin, rootHash, "locked = true", t0.Add(10*time.Second))
+	signedAt(t, threadDir, "1772366405000_before.md", bob, rootHash, "before", t0.Add(5*time.Second))
+	signedAt(t, threadDir, "1772366420000_during.md", bob, rootHash, "during", t0.Add(20*time.Second))
+	signedAt(t, threadDir, "1772366425000_admin.md", admin, rootHash, "closing note", t0.Add(25*time.Second))
+
+	thread, err := forum.LoadThreadWith("cat", "thread", threadDir, keysDir, forum.LoadOptions{AdminPubkey: admin.PublicKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !thread.State.Locked {
+		t.Error("thread not locked")
+	}
+	flags := map[string]string{}
+	for _, p := range thread.Posts {
+		flags[p.Body] = p.Flag
+	}
+	want := map[string]string{"# Topic": "", "before": "", "during": forum.FlagLocked, "closing note": ""}
+	for body, flag := range want {
+		if flags[body] != flag {
+			t.Errorf("post %q: flag %q, want %q", body, flags[body], flag)
+		}
+	}
+
+	// LoadThread trusts no admin key, so nothing is flagged.
+	thread, err = forum.LoadThread("cat", "thread", threadDir, keysDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range thread.Posts {
+		if p.Flag != "" {
+			t.Errorf("LoadThread flagged %q", p.Body)
+		}
+	}
+}
+
+func TestLoadThreadWith_ArrivalTimes(t *testing.T) {
+	dir := t.TempDir()
+	keysDir := filepath.Join(dir, "keys")
+	threadDir := filepath.Join(dir, "poll")
+	admin := mustGenerate(t, "alice")
+	bob := mustGenerate(t, "bob")
+	carol := mustGenerate(t, "carol")
+	for _, id := range []*crypto.Identity{admin, bob, carol} {
+		writeKey(t, keysDir, id.Username, id.PublicKey)
+	}
+
+	// A poll closing at t0+30s, locked at t0+10s and unlocked at t0+20s.
+	t0 := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	poll, err := forum.SignPoll(admin, "Which one?", []string{"Yes", "No"}, t0.Add(30*time.Second).Format(time.RFC3339))
+	if err != nil {
+		t.Fatal(err)
+	}
+	root := poll.Format()
+	if err := os.MkdirAll(threadDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(threadDir, forum.RootFilename), root, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	rootHash := forum.PostHash(root)
+	signedAt(t, threadDir, "1_lock.state", admin, rootHash, "locked = true", t0.Add(10*time.Second))
+	signedAt(t, threadDir, "2_unlock.state", admin, rootHash, "locked = false", t0.Add(20*time.Second))
+
+	// Every post and vote claims t0+5s, before the lock; they arrived at
+	// the times below, or not at all for the uncommitted one.
+	arrivals := map[string]time.Time{}
+	for name, at := range map[string]time.Duration{
+		"1772366405000_early.md":  6 * time.Second,
+		"1772366405001_locked.md": 15 * time.Second,
+		"1_bob.vote":              15 * time.Second,
+		"2_carol.vote":            40 * time.Second,
+	} {
+		arrivals["poll/"+name] = t0.Add(at)
+	}
+	signedAt(t, threadDir, "1772366405000_early.md", bob, rootHash, "early", t0.Add(5*time.Second))
+	signedAt(t, threadDir, "1772366405001_locked.md", bob, rootHash, "locked", t0.Add(5*time.Second))
+	signedAt(t, threadDir, "1772366405002_uncommitted.md", bob, rootHash, "uncommitted", t0.Add(5*time.Second))
+	signedAt(t, threadDir, "1_bob.vote", bob, rootHash, "Yes", t0.Add(5*time.Second))
+	signedAt(t, threadDir, "2_carol.vote", carol, rootHash, "No", t0.Add(5*time.Second))
+
+	opts := forum.LoadOptions{AdminPubkey: admin.PublicKey, Arrivals: arrivals}
+	thread, err := forum.LoadThreadWith("cat", "poll", threadDir, keysDir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	flags := map[string]string{}
+	for _, p := range thread.Posts {
+		flags[p.Body] = p.Flag
+	}
+	// The uncommitted reply arrives now, after the unlock.
+	want := map[string]string{"early": "", "locked": forum.FlagLocked, "uncommitted": ""}
+	for body, flag := range want {
+		if flags[body] != flag {
+			t.Errorf("post %q: flag %q, want %q", body, flags[body], flag)
+		}
+	}
+	rejected := map[string]string{}
+	for _, v := range thread.Poll.Rejected {
+		rejected[v.Voter] = v.Reason
+	}
+	if len(thread.Poll.Voters) != 0 || rejected["bob"] != "cast while the thread was locked" || rejected["carol"] != "cast after the poll closed" {
+		t.Errorf("voters %+v, rejected %v", thread.Poll.Voters, rejected)
+	}
+}
+
+// ---- Write policy ----
+
+func TestWritePolicy_Verify(t *testing.T) {
+	admin := mustGenerate(t, "alice")
+	bob := mustGenerate(t, "bob")
+
+	p, err := forum.SignWritePolicy(admin, "news", []string{"@staff"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Verify("news", admin.PublicKey); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+	if err := p.Verify("general", admin.PublicKey); err == nil {
+		t.Error("policy verified for another category")
+	}
+	forged, err := forum.SignWritePolicy(bob, "news", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := forged.Verify("news", admin.PublicKey); err == nil {
+		t.Error("policy not signed by the admin verified")
+	}
+}
+
+func TestLoadThreadWith_PolicyFlagsPosts(t *testing.T) {
+	dir := t.TempDir()
+	keysDir := filepath.Join(dir, "keys")
+	admin := mustGenerate(t, "alice")
+	bob := mustGenerate(t, "bob")
+	carol := mustGenerate(t, "carol")
+	for _, id := range []*crypto.Identity{admin, bob, carol} {
+		writeKey(t, keysDir, id.Username, id.PublicKey)
+	}
+
+	setupCategory(t, dir, "news", "News", nil)
+	catDir := filepath.Join(dir, "news")
+	policy, err := forum.SignWritePolicy(admin, "news", []string{"@staff"}, []string{"@staff", "carol"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta, err := forum.CategoryMetaWithPolicy(catDir, policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(catDir, forum.MetaFilename), meta, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	roles, err := forum.SignRoles(admin, map[string][]string{"staff": {"dave"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rolesPath := filepath.Join(dir, forum.RolesFilename)
+	if err := os.WriteFile(rolesPath, roles.Format(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	later := time.Now().Add(time.Hour)
+	threadDir := filepath.Join(catDir, "scoop")
+	root := signedAt(t, threadDir, forum.RootFilename, bob, "", "# Scoop", later)
+	rootHash := forum.PostHash(root)
+	// Backdating a post does not get it past the policy.
+	signedAt(t, threadDir, "1_old.md", bob, rootHash, "backdated", time.Now().Add(-time.Hour))
+	signedAt(t, threadDir, "2_bob.md", bob, rootHash, "bob", later)
+	signedAt(t, threadDir, "3_carol.md", carol, rootHash, "carol", later)
+	signedAt(t, threadDir, "4_admin.md", admin, rootHash, "admin", later)
+
+	cat, err := forum.LoadCategory("news", catDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cat.Name != "News" || cat.Policy == nil {
+		t.Fatalf("category after policy rewrite: %+v", cat)
+	}
+	opts, err := forum.ThreadOptions(forum.LoadOptions{AdminPubkey: admin.PublicKey}, cat, rolesPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	thread, err := forum.LoadThreadWith("news", "scoop", threadDir, keysDir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"# Scoop":   forum.FlagPolicy,
+		"backdated": forum.FlagPolicy,
+		"bob":       forum.FlagPolicy,
+		"carol":     "",
+		"admin":     "",
+	}
+	for _, p := range thread.Posts {
+		if p.Flag != want[p.Body] {
+			t.Errorf("post %q: flag %q, want %q", p.Body, p.Flag, want[p.Body])
+		}
+	}
+
+	scan, err := forum.ScanThreadWith("scoop", threadDir, keysDir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scan.Root.Flag != forum.FlagPolicy {
+		t.Errorf("scan root flag: got %q", scan.Root.Flag)
+	}
+
+	// A policy signed by someone else is ignored.
+	if _, err := forum.ThreadOptions(forum.LoadOptions{AdminPubkey: bob.PublicKey}, cat, rolesPath); err == nil {
+		t.Error("ThreadOptions accepted a policy not signed by the admin")
+	}
+}
+
+func TestBanList_Verify(t *testing.T) {
+	admin := mustGenerate(t, "alice")
+	bob := mustGenerate(t, "bob")
+	path := filepath.Join(t.TempDir(), forum.BansFilename)
+
+	list, err := forum.LoadBanList(path, admin.PublicKey)
+	if err != nil || len(list.Bans) != 0 {
+		t.Fatalf("missing file: %+v, %v", list, err)
+	}
+
+	since := "2026-03-01T12:00:00Z"
+	list, err = forum.SignBanList(admin, []forum.Ban{{Username: "bob", PubKey: bob.PublicKey, Reason: "spam", Since: since}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, list.Format(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := forum.LoadBanList(path, admin.PublicKey)
+	if err != nil {
+		t.Fatalf("LoadBanList: %v", err)
+	}
+	if len(got.Bans) != 1 || got.Bans[0].Reason != "spam" {
+		t.Fatalf("bans: %+v", got.Bans)
+	}
+	if _, err := forum.LoadBanList(path, bob.PublicKey); err == nil {
+		t.Error("ban list verified with a non-admin key")
+	}
+
+	// The key fingerprint matches under another name.
+	if got.Find("bobby", bob.Fingerprint()) == nil {
+		t.Error("ban not matched by key fingerprint")
+	}
+	if got.Find("carol", "") != nil {
+		t.Error("ban matched another user")
+	}
+	if bans, found := got.Without("bob"); !found || len(bans) != 0 {
+		t.Errorf("Without: %v, %v", bans, found)
+	}
+
+	if _, err := forum.SignBanList(admin, []forum.Ban{{Username: "bob", Since: "yesterday"}}); err == nil {
+		t.Error("SignBanList accepted an invalid since")
+	}
+}
+
+func TestLoadThreadWith_BansAndMutes(t *testing.T) {
+	dir := t.TempDir()
+	keysDir := filepath.Join(dir, "keys")
+	admin := mustGenerate(t, "alice")
+	bob := mustGenerate(t, "bob")
+	carol := mustGenerate(t, "carol")
+	for _, id := range []*crypto.Identity{admin, bob, carol} {
+		writeKey(t, keysDir, id.Username, id.PublicKey)
+	}
+
+	now := time.Now()
+	list, err := forum.SignBanList(admin, []forum.Ban{{
+		Username: "bob",
+		PubKey:   bob.PublicKey,
+		Reason:   "spam",
+		Since:    now.UTC().Format(time.RFC3339),
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	threadDir := filepath.Join(dir, "general", "hello")
+	root := signedAt(t, threadDir, forum.RootFilename, admin, "", "# Hello", now.Add(-2*time.Hour))
+	rootHash := forum.PostHash(root)
+	signedAt(t, threadDir, "1_old.md", bob, rootHash, "backdated", now.Add(-time.Hour))
+	signedAt(t, threadDir, "2_bob.md", bob, rootHash, "after the ban", now.Add(time.Hour))
+	signedAt(t, threadDir, "3_carol.md", carol, rootHash, "carol", now.Add(time.Hour))
+
+	opts := forum.LoadOptions{AdminPubkey: admin.PublicKey, Bans: list, Muted: map[string]bool{"carol": true}}
+	thread, err := forum.LoadThreadWith("general", "hello", threadDir, keysDir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"# Hello":        "",
+		"backdated":      forum.FlagBanned,
+		"after the ban":  forum.FlagBanned,
+		"carol":          forum.FlagMuted,
+	}
+	for _, p := range thread.Posts {
+		if p.Flag != want[p.Body] {
+			t.Errorf("post %q: flag %q, want %q", p.Body, p.Flag, want[p.Body])
+		}
+	}
+
+	scan, err := forum.ScanThreadWith("hello", threadDir, keysDir, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scan.ReplyCount != 0 {
+		t.Errorf("scan reply count: got %d, want 0", scan.ReplyCount)
+	}
+	scan, err = forum.ScanThreadWith("hello", threadDir, keysDir, forum.LoadOptions{AdminPubkey: admin.PublicKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if scan.ReplyCount != 3 {
+		t.Errorf("scan reply count without bans: got %d, want 3", scan.ReplyCount)
+	}
+}
+
+func TestProofOfWork(t *testing.T) {
+	dir := t.TempDir()
+	keysDir := filepath.Join(dir, "keys")
+	admin := mustGenerate(t, "alice")
+	stranger := mustGenerate(t, "mallory")
+	writeKey(t, keysDir, admin.Username, admin.PublicKey)
+	signOpts := forum.SignOptions{Work: 12, KeysDir: keysDir}
+
+	// Approved authors do no work; others do, and it survives a round trip.
+	approved, err := forum.SignPostWith(admin, "", "# Hello", signOpts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if approved.Work != "" {
+		t.Errorf("approved author did work: %q", approved.Work)
+	}
+	worked, err := forum.SignPostWith(stranger, "", "with work", signOpts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := forum.ParsePost("1_worked.md", worked.Format())
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed.VerifySignature(keysDir)
+	if parsed.Work == "" || parsed.WorkBits < 12 || parsed.SigStatus != forum.SigMissing {
+		t.Errorf("worked post: work %q, %d bits, status %v", parsed.Work, parsed.WorkBits, parsed.SigStatus)
+	}
+
+	threadDir := filepath.Join(dir, "general", "hello")
+	write := func(name string, p *forum.Post) {
+		t.Helper()
+		if err := os.MkdirAll(threadDir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(threadDir, name), p.Format(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(forum.RootFilename, approved)
+	write("1_worked.md", worked)
+	lazy, err := forum.SignPost(stranger, "", "no work")
+	if err != nil {
+		t.Fatal(err)
+	}
+	write("2_lazy.md", lazy)
+	// Signing under an approved user's name with another key, or writing
+	// something that does not parse, does not get around the work.
+	impostor, err := forum.SignPost(mustGenerate(t, "alice"), "", "impostor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	write("3_impostor.md", impostor)
+	if err := os.WriteFile(filepath.Join(threadDir, "4_garbage.md"), []byte("not a post"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// A stranger the reader trusts through a vouch needs no work.
+	carol := mustGenerate(t, "carol")
+	vouched, err := forum.SignPost(carol, "", "vouched")
+	if err != nil {
+		t.Fatal(err)
+	}
+	write("5_vouched.md", vouched)
+	vouches, err := forum.SignVouchList(admin, []forum.Vouch{{Username: "carol", PubKey: carol.PublicKey, Since: "2026-03-01T12:00:00Z"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, forum.VouchesDir), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, filepath.FromSlash(forum.VouchPath("alice"))), vouches.Format(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name    string
+		trust   *forum.WebOfTrust
+		flagged []string // bodies of the flagged posts; "" for the malformed one
+		replies int
+	}{
+		{"no trust", nil, []string{"", "no work", "impostor", "vouched"}, 1},
+		{"alice's trust", forum.BuildWebOfTrust(filepath.Join(dir, forum.VouchesDir), "alice", admin.PublicKey, 2), []string{"", "no work", "impostor"}, 2},
+		// The root of a web of trust does not exempt its own posts.
+		{"mallory's trust", forum.BuildWebOfTrust(filepath.Join(dir, forum.VouchesDir), "mallory", stranger.PublicKey, 2), []string{"", "no work", "impostor", "vouched"}, 1},
+	} {
+		opts := forum.LoadOptions{AdminPubkey: admin.PublicKey, Work: 12, Trust: tc.trust}
+		thread, err := forum.LoadThreadWith("general", "hello", threadDir, keysDir, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var flagged []string
+		for _, p := range thread.Posts {
+			if p.Flag == forum.FlagNoWork {
+				flagged = append(flagged, p.Body)
+			} else if p.Flag != "" {
+				t.Errorf("%s: post %q: flag %q", tc.name, p.Body, p.Flag)
+			}
+		}
+		if strings.Join(flagged, "|") != strings.Join(tc.flagged, "|") {
+			t.Errorf("%s: flagged %q, want %q", tc.name, flagged, tc.flagged)
+		}
+		scan, err := forum.ScanThreadWith("hello", threadDir, keysDir, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if scan.ReplyCount != tc.replies {
+			t.Errorf("%s: scan reply count: got %d, want %d", tc.name, scan.ReplyCount, tc.replies)
+		}
+	}
+}
+
+func TestProfile_SignLoad(t *testing.T) {
+	dir := t.TempDir()
+	keysDir := filepath.Join(dir, "keys")
+	alice := mustGenerate(t, "alice")
+	mallory := mustGenerate(t, "mallory")
+	writeKey(t, keysDir, "alice", alice.PublicKey)
+	writeKey(t, keysDir, "mallory", mallory.PublicKey)
+	path := filepath.Join(dir, filepath.FromSlash(forum.ProfilePath("alice")))
+
+	empty, err := forum.LoadProfile(path, keysDir, "alice")
+	if err != nil || !empty.UpdatedAt.IsZero() {
+		t.Fatalf("missing profile: %+v, %v", empty, err)
+	}
+
+	fields := forum.ProfileFields{
+		DisplayName: "Alice Liddell",
+		Bio:         "Down the *rabbit hole*.",
+		Avatar:      strings.Repeat("ab", 32),
+		Contact:     "alice@example.com",
+		Timezone:    "Europe/London",
+	}
+	post, err := forum.SignProfile(alice, fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, post.Format(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	p, err := forum.LoadProfile(path, keysDir, "alice")
+	if err != nil {
+		t.Fatalf("LoadProfile: %v", err)
+	}
+	if p.ProfileFields != fields || p.UpdatedAt.IsZero() || !strings.Contains(p.BioHTML, "<em>rabbit hole</em>") {
+		t.Errorf("profile: %+v", p)
+	}
+
+	// A profile signed by someone else does not count.
+	forged, err := forum.SignProfile(mallory, forum.ProfileFields{DisplayName: "Alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, forged.Format(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := forum.LoadProfile(path, keysDir, "alice"); err == nil {
+		t.Error("LoadProfile accepted a profile signed by mallory")
+	}
+
+	for _, bad := range []forum.ProfileFields{
+		{Avatar: "not-a-hash"},
+		{Timezone: "Mars/Olympus_Mons"},
+		{DisplayName: "two\nlines"},
+		{Bio: strings.Repeat("x", 2001)},
+	} {
+		if _, err := forum.SignProfile(alice, bad); err == nil {
+			t.Errorf("SignProfile accepted %+v", bad)
+		}
+	}
+}
+
+func TestWebOfTrust(t *testing.T) {
+	dir := t.TempDir()
+	vouchesDir := filepath.Join(dir, forum.VouchesDir)
+	keysDir := filepath.Join(dir, "keys")
+	alice, bob, carol, dave := mustGenerate(t, "alice"), mustGenerate(t, "bob"), mustGenerate(t, "carol"), mustGenerate(t, "dave")
+	mallory := mustGenerate(t, "mallory")
+	since := "2026-03-01T12:00:00Z"
+	vouch := func(by *crypto.Identity, vouches ...forum.Vouch) {
+		t.Helper()
+		l, err := forum.SignVouchList(by, vouches)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.MkdirAll(vouchesDir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, filepath.FromSlash(forum.VouchPath(by.Username))), l.Format(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// alice → bob → carol → dave, and mallory, whom nobody vouches for,
+	// vouches for another key under dave's name.
+	vouch(alice, forum.Vouch{Username: "bob", PubKey: bob.PublicKey, Since: since})
+	vouch(bob, forum.Vouch{Username: "carol", PubKey: carol.PublicKey, Note: "met in person", Since: since})
+	vouch(carol, forum.Vouch{Username: "dave", PubKey: dave.PublicKey, Since: since})
+	vouch(mallory, forum.Vouch{Username: "dave", PubKey: mustGenerate(t, "dave").PublicKey, Since: since})
+
+	w := forum.BuildWebOfTrust(vouchesDir, "alice", alice.PublicKey, 2)
+	if len(w.Errors) != 0 {
+		t.Fatalf("errors: %v", w.Errors)
+	}
+	if k := w.Keys["carol"]; k == nil || k.PubKey != carol.PublicKey || strings.Join(k.Path, ",") != "bob,carol" {
+		t.Errorf("carol: %+v", k)
+	}
+	if w.Keys["dave"] != nil || w.Keys["mallory"] != nil {
+		t.Errorf("trusted beyond two hops or from outside: %v", w.Keys)
+	}
+	if k := forum.BuildWebOfTrust(vouchesDir, "alice", alice.PublicKey, 3).Keys["dave"]; k == nil || k.PubKey != dave.PublicKey {
+		t.Errorf("dave at three hops: %+v", k)
+	}
+
+	// Posts verify against the vouched key, not against any key that claims
+	// the name.
+	post, err := forum.SignPost(carol, "", "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k := w.Vouched(post); k == nil || k.Username != "carol" {
+		t.Errorf("Vouched(carol's post) = %+v", k)
+	}
+	fake, err := forum.SignPost(mustGenerate(t, "carol"), "", "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k := w.Vouched(fake); k != nil {
+		t.Errorf("Vouched accepted a post by another key named carol: %+v", k)
+	}
+
+	// A vouch list that does not verify is skipped and reported.
+	vouch(mustGenerate(t, "bob"), forum.Vouch{Username: "carol", PubKey: carol.PublicKey, Since: since})
+	w = forum.BuildWebOfTrust(vouchesDir, "alice", alice.PublicKey, 2)
+	if w.Keys["carol"] != nil || len(w.Errors) != 1 {
+		t.Errorf("forged list: keys %v, errors %v", w.Keys, w.Errors)
+	}
+
+	// Only vouches from users with a key in keys/ count towards approval.
+	vouch(bob, forum.Vouch{Username: "carol", PubKey: carol.PublicKey, Since: since})
+	writeKey(t, keysDir, "bob", bob.PublicKey)
+	writeKey(t, keysDir, "mallory", mallory.PublicKey)
+	got, err := forum.VouchesFor(vouchesDir, keysDir, "carol", carol.PublicKey)
+	if err != nil || len(got) != 1 || got[0].Username != "bob" || got[0].Since.Format(time.RFC3339) != since {
+		t.Errorf("VouchesFor(carol) = %v, %v", got, err)
+	}
+	got, err = forum.VouchesFor(vouchesDir, keysDir, "dave", dave.PublicKey)
+	if err != nil || len(got) != 0 {
+		t.Errorf("VouchesFor(dave) = %v, %v", got, err)
+	}
+
+	if _, err := forum.SignVouchList(alice, []forum.Vouch{{Username: "alice", PubKey: alice.PublicKey, Since: since}}); err == nil {
+		t.Error("SignVouchList accepted a vouch for oneself")
+	}
+	if _, err := forum.SignVouchList(alice, []forum.Vouch{{Username: "bob", PubKey: "not-a-key", Since: since}}); err == nil {
+		t.Error("SignVouchList accepted an invalid key")
+	}
+}
+
+// render returns the HTML that ParsePost produces for body.
+func render(t *testing.T, body string) string {
+	t.Helper()
+	post, err := forum.SignPost(mustGenerate(t, "alice"), "", body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := forum.ParsePost(forum.RootFilename, post.Format())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed.BodyHTML
+}
+
+func TestRender_GFM(t *testing.T) {
+	html := render(t, "# Intro\n\n## Intro\n\n"+
+		"- [x] done\n- [ ] todo\n\n"+
+		"| a | b |\n|:--|--:|\n| 1 | 2 |\n\n"+
+		"~~gone~~ https://example.com\n\n"+
+		"```go\nfunc main() {}\n```")
+	for _, want := range []string{
+		`<h1 id="h-intro">Intro <a class="heading-anchor" href="#h-intro">#</a></h1>`,
+		`<h2 id="h-intro-1">`,
+		`<input checked="" disabled="" type="checkbox"> done`,
+		`<th align="left">a</th>`,
+		`<td align="right">2</td>`,
+		`<del>gone</del>`,
+		`<a href="https://example.com" rel="nofollow noopener" target="_blank">https://example.com</a>`,
+		`<pre class="chroma">`,
+		`<span class="kd">func</span>`,
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("missing %s in:\n%s", want, html)
+		}
+	}
+}
+
+// hostileRe matches markup that could run script or load content from a
+// disallowed URL.
+var hostileRe = regexp.MustCompile(`<(script|iframe|svg|object|embed|style)|\son[a-z]+=["']|(href|src)="\s*(javascript|vbscript|data|ftp):`)
+
+func TestRender_HostileInput(t *testing.T) {
+	for _, body := range []string{
+		"[x](javascript:alert(1))",
+		"[x](JaVaScRiPt:alert(1))",
+		"[x](&#106;avascript:alert(1))",
+		"[x](  javascript:alert(1))",
+		"<javascript:alert(1)>",
+		"[x](vbscript:msgbox(1))",
+		"![x](data:image/svg+xml;base64,PHN2ZyBvbmxvYWQ9YWxlcnQoMSk+)",
+		"[x](data:text/html,<script>alert(1)</script>)",
+		"<script>alert(1)</script>",
+		"<img src=x onerror=alert(1)>",
+		"<a href=\"javascript:alert(1)\">x</a>",
+		"<iframe src=\"https://evil.example\"></iframe>",
+		"<svg onload=alert(1)>",
+		"[x](https://example.com \"a\\\" onmouseover=\\\"alert(1)\")",
+		"# <img src=x onerror=alert(1)>",
+		"```html\n<script>alert(1)</script>\n```",
+		"```\"><script>alert(1)</script>\nx\n```",
+		"[x](ftp://example.com/file)",
+	} {
+		html := strings.ToLower(render(t, body))
+		if m := hostileRe.FindString(html); m != "" {
+			t.Errorf("%q renders %q:\n%s", body, m, html)
+		}
+	}
+}
+
+func TestRender_URLSchemes(t *testing.T) {
+	t.Cleanup(func() { forum.SetRenderOptions(forum.DefaultRenderOptions()) })
+	opts := forum.DefaultRenderOptions()
+	opts.URLSchemes = []string{"https", "gemini"}
+	opts.Highlight = false
+	forum.SetRenderOptions(opts)
+
+	html := render(t, "[g](gemini://example.org) [h](http://example.org) [r](/relative)\n\n```go\nfunc main() {}\n```")
+	if !strings.Contains(html, `href="gemini://example.org"`) {
+		t.Errorf("gemini link dropped: %s", html)
+	}
+	if strings.Contains(html, "http://example.org") {
+		t.Errorf("http link kept: %s", html)
+	}
+	if !strings.Contains(html, `<a href="/relative">r</a>`) {
+		t.Errorf("relative link dropped: %s", html)
+	}
+	if strings.Contains(html, "chroma") {
+		t.Errorf("highlighted with Highlight off: %s", html)
+	}
+}
+
+// ---- Quotes ----
+
+func TestLoadThread_Quotes(t *testing.T) {
+	dir := t.TempDir()
+	keysDir := filepath.Join(dir, "keys")
+	otherDir := filepath.Join(dir, "general", "other")
+	threadDir := filepath.Join(dir, "general", "hello")
+
+	alice := mustGenerate(t, "alice")
+	bob := mustGenerate(t, "bob")
+	writeKey(t, keysDir, "alice", alice.PublicKey)
+	writeKey(t, keysDir, "bob", bob.PublicKey)
+	other := signedPost(t, otherDir, forum.RootFilename, alice, "", "Quotes *should* be\nverifiable.\n\nSecond paragraph.")
+	root := signedPost(t, threadDir, forum.RootFilename, alice, "", "Welcome to the forum!")
+	body := strings.Join([]string{
+		"> [!quote general/hello/0000_root.md]",
+		"> Welcome to the forum!",
+		"",
+		"> [!quote " + forum.PostHash(other) + "]",
+		"> Quotes should be verifiable.",
+		"",
+		"> [!quote general/hello/0000_root.md]",
+		"> Welcome to my forum!",
+		"",
+		"> [!quote general/nope/0000_root.md]",
+		"> Made up",
+		"",
+		"> an ordinary blockquote",
+	}, "\n")
+	signedPost(t, threadDir, "1000_reply.md", bob, forum.PostHash(root), body)
+
+	thread, err := forum.LoadThread("general", "hello", threadDir, keysDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply := thread.Posts[1]
+	if len(reply.Quotes) != 4 {
+		t.Fatalf("Quotes: got %d, want 4", len(reply.Quotes))
+	}
+	want := []forum.Quote{
+		{Category: "general", Thread: "hello", Filename: forum.RootFilename, Author: "alice", Found: true},
+		{Category: "general", Thread: "other", Filename: forum.RootFilename, Author: "alice", Found: true},
+		{Category: "general", Thread: "hello", Filename: forum.RootFilename, Author: "alice", Found: true, Altered: true},
+		{},
+	}
+	for i, q := range reply.Quotes {
+		w := want[i]
+		w.Ref = q.Ref
+		if *q != w {
+			t.Errorf("quote %d: got %+v, want %+v", i, *q, w)
+		}
+	}
+	html := reply.BodyHTML
+	if !strings.Contains(html, `<a href="#/cat/general/thread/hello/0000_root.md">@alice</a> <span class="quote-sig quote-sig-valid">signed</span>`) {
+		t.Errorf("quote source not rendered: %s", html)
+	}
+	if strings.Count(html, `<span class="quote-altered">altered</span>`) != 1 {
+		t.Errorf("want one altered marker: %s", html)
+	}
+	if !strings.Contains(html, "not found") || strings.Contains(html, "[!quote") {
+		t.Errorf("marker left in output: %s", html)
+	}
+	if !strings.Contains(html, "<blockquote>\n<p>an ordinary blockquote</p>") {
+		t.Errorf("ordinary blockquote changed: %s", html)
+	}
+}
+
+func TestLoadThreadWith_SharedIndex(t *testing.T) {
+	dir := t.TempDir()
+	keysDir := filepath.Join(dir, "keys")
+	otherDir := filepath.Join(dir, "general", "other")
+	threadDir := filepath.Join(dir, "general", "hello")
+	alice := mustGenerate(t, "alice")
+	writeKey(t, keysDir, "alice", alice.PublicKey)
+	other := signedPost(t, otherDir, forum.RootFilename, alice, "", "Quoted.")
+	signedPost(t, threadDir, forum.RootFilename, alice, "", "> [!quote "+forum.PostHash(other)+"]\n> Quoted.")
+
+	found := func(opts forum.LoadOptions) bool {
+		t.Helper()
+		thread, err := forum.LoadThreadWith("general", "hello", threadDir, keysDir, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(thread.Root.Quotes) == 1 && thread.Root.Quotes[0].Found
+	}
+	opts := forum.LoadOptions{Index: forum.NewPostIndex(dir, keysDir, "")}
+	if !found(opts) {
+		t.Fatal("quote not resolved")
+	}
+	// The shared index keeps what it read; a fresh one sees the change.
+	if err := os.Remove(filepath.Join(otherDir, forum.RootFilename)); err != nil {
+		t.Fatal(err)
+	}
+	if !found(opts) {
+		t.Error("shared index was not reused")
+	}
+	if found(forum.LoadOptions{}) {
+		t.Error("fresh index resolved a removed post")
+	}
+}
+
+// ---- Cross-links ----
+
+func TestLoadThread_CrossLinks(t *testing.T) {
+	dir := t.TempDir()
+	keysDir := filepath.Join(dir, "keys")
+	alice := mustGenerate(t, "alice")
+	writeKey(t, keysDir, "alice", alice.PublicKey)
+	setupCategory(t, dir, "general", "General", nil)
+	target := signedPost(t, filepath.Join(dir, "general", "target"), forum.RootFilename, alice, "", "# Target <thread>\n\nBody.")
+	signedPost(t, filepath.Join(dir, "general", "target"), "1000_reply.md", alice, forum.PostHash(target), "A reply.")
+
+	threadDir := filepath.Join(dir, "general", "source")
+	signedPost(t, threadDir, forum.RootFilename, alice, "", strings.Join([]string{
+		"See [[general/target]], [[general/target/1000_reply.md|this reply]],",
+		"[[general]], [[" + forum.PostHash(target) + "]] and [[general/nope]].",
+		"",
+		"`[[general/target]]`",
+	}, "\n"))
+
+	thread, err := forum.LoadThread("general", "source", threadDir, keysDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	html := thread.Root.BodyHTML
+	for _, want := range []string{
+		`<a class="xref xref-thread" href="#/cat/general/thread/target" title="Target &lt;thread&gt;">Target &lt;thread&gt;</a>`,
+		`<a class="xref xref-post" href="#/cat/general/thread/target/1000_reply.md" title="Target &lt;thread&gt;">this reply</a>`,
+		`<a class="xref xref-category" href="#/cat/general" title="General">General</a>`,
+		`href="#/cat/general/thread/target/0000_root.md" title="Target &lt;thread&gt;">Target &lt;thread&gt; › @alice</a>`,
+		`<span class="xref-missing" title="no such category, thread or post">[[general/nope]]</span>`,
+		`<code>[[general/target]]</code>`,
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("missing %s\nin: %s", want, html)
+		}
+	}
+}
+
+func TestBuildBacklinks(t *testing.T) {
+	dir := t.TempDir()
+	keysDir := filepath.Join(dir, "keys")
+	alice := mustGenerate(t, "alice")
+	mallory := mustGenerate(t, "mallory")
+	writeKey(t, keysDir, "alice", alice.PublicKey)
+
+	target := signedPost(t, filepath.Join(dir, "general", "target"), forum.RootFilename, alice, "", "Target")
+	signedPost(t, filepath.Join(dir, "general", "target"), "3000_self.md", alice, forum.PostHash(target), "[[general/target]]")
+	signedAt(t, filepath.Join(dir, "general", "b"), forum.RootFilename, alice, "", "# B\n\n[[general/target]] twice [[general/target]]",
+		time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC))
+	signedAt(t, filepath.Join(dir, "other", "a"), forum.RootFilename, alice, "",
+		"# A\n\n> [!quote "+forum.PostHash(target)+"]\n> Target",
+		time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	signedPost(t, filepath.Join(dir, "general", "forged"), forum.RootFilename, mallory, "", "[[general/target]]")
+
+	links := forum.BuildBacklinks(dir, keysDir, alice.PublicKey).To("general", "target")
+	var got []string
+	for _, l := range links {
+		got = append(got, fmt.Sprintf("%s/%s/%s %s %s %q", l.Category, l.Thread, l.Filename, l.Title, l.Author, l.Target))
+	}
+	want := []string{
+		`other/a/0000_root.md A alice "0000_root.md"`,
+		`general/b/0000_root.md B alice ""`,
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("backlinks:\ngot  %q\nwant %q", got, want)
+	}
+}
+
+// applyRelocation carries out a planned move or merge on disk.
+func applyRelocation(t *testing.T, root string, plan *forum.ThreadRelocation) {
+	t.Helper()
+	for from, to := range plan.Moves {
+		src := filepath.Join(root, filepath.FromSlash(from))
+		if to == "" {
+			if err := os.Remove(src); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		dst := filepath.Join(root, filepath.FromSlash(to))
+		if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Rename(src, dst); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(root, filepath.FromSlash(plan.RedirectPath)), plan.Redirect.Format(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRelocateThread(t *testing.T) {
+	dir := t.TempDir()
+	keysDir := filepath.Join(dir, "keys")
+	admin := mustGenerate(t, "admin")
+	mallory := mustGenerate(t, "mallory")
+	writeKey(t, keysDir, "admin", admin.PublicKey)
+	writeKey(t, keysDir, "mallory", mallory.PublicKey)
+	setupCategory(t, dir, "general", "General", nil)
+	setupCategory(t, dir, "archive", "Archive", nil)
+
+	oldDir := filepath.Join(dir, "general", "old")
+	root := signedPost(t, oldDir, forum.RootFilename, admin, "", "# Old")
+	signedPost(t, oldDir, "1000_reply.md", admin, forum.PostHash(root), "A reply.")
+	if err := os.WriteFile(filepath.Join(oldDir, "poll.toml"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	signedPost(t, filepath.Join(dir, "archive", "kept"), forum.RootFilename, admin, "", "# Kept")
+
+	if _, err := forum.PlanMove(admin, dir, "general", "old", "nowhere", "x"); err == nil {
+		t.Error("move into a missing category: no error")
+	}
+	if _, err := forum.PlanMove(admin, dir, "general", "old", "archive", "kept"); err == nil {
+		t.Error("move onto an existing thread: no error")
+	}
+	if _, err := forum.PlanMerge(admin, dir, "general", "old", "archive", "none"); err == nil {
+		t.Error("merge into a missing thread: no error")
+	}
+
+	plan, err := forum.PlanMove(admin, dir, "general", "old", "archive", "moved")
+	if err != nil {
+		t.Fatalf("PlanMove: %v", err)
+	}
+	applyRelocation(t, dir, plan)
+	to, ok := forum.FollowRedirects(dir, keysDir, admin.PublicKey, "general", "old")
+	if !ok || to != (forum.Redirected{Category: "archive", Thread: "moved", Root: forum.RootFilename}) {
+		t.Errorf("after move: %+v %v", to, ok)
+	}
+	if _, ok := forum.FollowRedirects(dir, keysDir, mallory.PublicKey, "general", "old"); ok {
+		t.Error("redirect followed with the wrong admin key")
+	}
+	moved, err := forum.LoadThread("archive", "moved", filepath.Join(dir, "archive", "moved"), keysDir)
+	if err != nil || len(moved.Posts) != 2 || moved.Posts[1].SigStatus != forum.SigValid {
+		t.Fatalf("moved thread: %+v %v", moved, err)
+	}
+
+	plan, err = forum.PlanMerge(admin, dir, "archive", "moved", "archive", "kept")
+	if err != nil {
+		t.Fatalf("PlanMerge: %v", err)
+	}
+	applyRelocation(t, dir, plan)
+	to, ok = forum.FollowRedirects(dir, keysDir, admin.PublicKey, "general", "old")
+	if !ok || to.Category != "archive" || to.Thread != "kept" || to.Root == forum.RootFilename {
+		t.Fatalf("after merge: %+v %v", to, ok)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "archive", "kept", "poll.toml")); !os.IsNotExist(err) {
+		t.Errorf("poll record carried into the merged thread: %v", err)
+	}
+
+	// Links to the old thread and its root post follow both redirects.
+	signedPost(t, filepath.Join(dir, "general", "links"), forum.RootFilename, admin, "",
+		"[[general/old]] and [[general/old/0000_root.md|the old root]]")
+	links, err := forum.LoadThreadWith("general", "links", filepath.Join(dir, "general", "links"), keysDir,
+		forum.LoadOptions{AdminPubkey: admin.PublicKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`href="#/cat/archive/thread/kept" title="Kept">Kept</a>`,
+		`href="#/cat/archive/thread/kept/` + to.Root + `" title="Kept">the old root</a>`,
+	} {
+		if !strings.Contains(links.Root.BodyHTML, want) {
+			t.Errorf("missing %s\nin: %s", want, links.Root.BodyHTML)
+		}
+	}
+}
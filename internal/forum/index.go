@@ -0,0 +1,262 @@
+package forum
+
+import (
+	"encoding/hex"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// PostIndex finds posts, threads and categories in a forum checkout for
+// quotes and cross-links. Posts can be named by PostHash or by
+// category/thread/filename; the tree is hashed once, on the first lookup by
+// hash, and each post is parsed and verified once. Paths of threads that
+// were moved or merged are followed through their redirect records.
+//
+// An index is safe for concurrent use and can be shared for as long as the
+// checkout does not change.
+type PostIndex struct {
+	root, keysDir string
+	adminPubkey   string // trusted signer of redirect records
+
+	mu     sync.Mutex        // guards byHash and posts
+	byHash map[string]string // PostHash → slash-separated path under root
+	posts  map[string]*Post  // path → verified post; nil when missing or deleted
+}
+
+// NewPostIndex returns an index of the forum checkout at root, verifying
+// posts against the keys in keysDir and redirects against adminPubkey.
+func NewPostIndex(root, keysDir, adminPubkey string) *PostIndex {
+	return &PostIndex{root: root, keysDir: keysDir, adminPubkey: adminPubkey, posts: map[string]*Post{}}
+}
+
+// Lookup returns the post ref names and its slash-separated path, or nil
+// when there is no such post or it has been deleted.
+func (ix *PostIndex) Lookup(ref string) (*Post, string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	rel := ref
+	if isPostHash(ref) {
+		ix.hashTree()
+		if rel = ix.byHash[ref]; rel == "" {
+			return nil, ""
+		}
+	} else if !validPostPath(ref) {
+		return nil, ""
+	} else {
+		rel = ix.relocate(rel)
+	}
+	if p, ok := ix.posts[rel]; ok {
+		return p, rel
+	}
+	p := ix.load(rel)
+	ix.posts[rel] = p
+	return p, rel
+}
+
+// relocate returns where the post at rel is now, following the redirects
+// of its thread when the thread has moved.
+func (ix *PostIndex) relocate(rel string) string {
+	cat, thread, file := splitPostPath(rel)
+	if _, err := os.Stat(filepath.Join(ix.root, filepath.FromSlash(path.Join(cat, thread)), RootFilename)); err == nil {
+		return rel
+	}
+	to, ok := FollowRedirects(ix.root, ix.keysDir, ix.adminPubkey, cat, thread)
+	if !ok {
+		return rel
+	}
+	if file == RootFilename {
+		file = to.Root
+	}
+	return path.Join(to.Category, to.Thread, file)
+}
+
+func (ix *PostIndex) load(rel string) *Post {
+	file := filepath.Join(ix.root, filepath.FromSlash(rel))
+	if _, err := os.Stat(file + ".tomb"); err == nil {
+		return nil
+	}
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil
+	}
+	p, err := ParsePost(path.Base(rel), content)
+	if err != nil {
+		return nil
+	}
+	p.VerifySignature(ix.keysDir)
+	return p
+}
+
+// hashTree records the hash of every post file under root.
+func (ix *PostIndex) hashTree() {
+	if ix.byHash != nil {
+		return
+	}
+	ix.byHash = map[string]string{}
+	walkPosts(ix.root, func(rel string, content []byte) {
+		ix.byHash[PostHash(content)] = rel
+	})
+}
+
+// walkPosts calls fn with the path and content of every post file under
+// root, skipping hidden directories, keys and profiles.
+func walkPosts(root string, fn func(rel string, content []byte)) {
+	_ = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		rel, _ := filepath.Rel(root, p)
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			if rel != "." && (strings.HasPrefix(d.Name(), ".") || rel == "keys" || rel == ProfilesDir) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !validPostPath(rel) {
+			return nil
+		}
+		if content, err := os.ReadFile(p); err == nil {
+			fn(rel, content)
+		}
+		return nil
+	})
+}
+
+// Target kinds of a cross-link.
+const (
+	TargetCategory = "category"
+	TargetThread   = "thread"
+	TargetPost     = "post"
+)
+
+// Target is the category, thread or post a cross-link or quote points at.
+type Target struct {
+	Kind     string // TargetCategory, TargetThread or TargetPost; empty when there is no such target
+	Category string
+	Thread   string // empty for categories
+	Filename string // set for posts
+	Title    string // category name, or the title of the thread
+	Author   string // author of the post, for posts
+}
+
+// Link returns the web UI route of t, or "" when it does not exist.
+func (t *Target) Link() string {
+	switch t.Kind {
+	case TargetCategory:
+		return "#/cat/" + url.PathEscape(t.Category)
+	case TargetThread:
+		return "#/cat/" + url.PathEscape(t.Category) + "/thread/" + url.PathEscape(t.Thread)
+	case TargetPost:
+		return "#/cat/" + url.PathEscape(t.Category) + "/thread/" + url.PathEscape(t.Thread) + "/" + url.PathEscape(t.Filename)
+	}
+	return ""
+}
+
+// Resolve returns what ref names: a post by PostHash or
+// category/thread/filename, a thread by category/thread, or a category by
+// its slug. The returned target has an empty Kind when nothing matches.
+func (ix *PostIndex) Resolve(ref string) *Target {
+	ref = strings.Trim(ref, "/")
+	if isPostHash(ref) || path.Ext(ref) == ".md" {
+		p, rel := ix.Lookup(ref)
+		if p == nil {
+			return &Target{}
+		}
+		cat, thread, file := splitPostPath(rel)
+		return &Target{
+			Kind:     TargetPost,
+			Category: cat,
+			Thread:   thread,
+			Filename: file,
+			Title:    ix.threadTitle(cat, thread),
+			Author:   p.Author,
+		}
+	}
+	if !validSlugPath(ref) {
+		return &Target{}
+	}
+	dir := filepath.Join(ix.root, filepath.FromSlash(ref))
+	if cat, thread := path.Split(ref); cat != "" {
+		cat = strings.TrimSuffix(cat, "/")
+		if _, err := os.Stat(filepath.Join(dir, RootFilename)); err == nil {
+			return &Target{Kind: TargetThread, Category: cat, Thread: thread, Title: ix.threadTitle(cat, thread)}
+		}
+		if to, ok := FollowRedirects(ix.root, ix.keysDir, ix.adminPubkey, cat, thread); ok {
+			return &Target{Kind: TargetThread, Category: to.Category, Thread: to.Thread, Title: ix.threadTitle(to.Category, to.Thread)}
+		}
+	}
+	if meta, err := readCategoryMeta(dir); err == nil {
+		name := meta.Name
+		if name == "" {
+			name = ref
+		}
+		return &Target{Kind: TargetCategory, Category: ref, Title: name}
+	}
+	return &Target{}
+}
+
+// threadTitle returns the title of a thread, or its slug when the root post
+// is missing or deleted.
+func (ix *PostIndex) threadTitle(category, thread string) string {
+	if root, _ := ix.Lookup(path.Join(category, thread, RootFilename)); root != nil {
+		if title := ThreadTitle(root.Body); title != "" {
+			return title
+		}
+	}
+	return thread
+}
+
+// ThreadTitle returns the title of a thread given its root post body: the
+// first non-empty line with any heading marker removed, cut to 100
+// characters. It returns "" for an empty body.
+func ThreadTitle(body string) string {
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(strings.TrimLeft(line, "#"))
+		if line == "" {
+			continue
+		}
+		if len([]rune(line)) > 100 {
+			line = string([]rune(line)[:100]) + "…"
+		}
+		return line
+	}
+	return ""
+}
+
+// splitPostPath splits a post path into category slug, thread slug and
+// filename.
+func splitPostPath(rel string) (category, thread, filename string) {
+	dir, filename := path.Split(rel)
+	category, thread = path.Split(strings.TrimSuffix(dir, "/"))
+	return strings.TrimSuffix(category, "/"), thread, filename
+}
+
+// validPostPath reports whether rel looks like category/.../thread/file.md.
+func validPostPath(rel string) bool {
+	return strings.Count(rel, "/") >= 2 && path.Ext(rel) == ".md" && validSlugPath(rel)
+}
+
+// validSlugPath reports whether rel is a slash-separated path of visible
+// names, with no empty, "." or ".." elements.
+func validSlugPath(rel string) bool {
+	if rel == "" {
+		return false
+	}
+	for _, p := range strings.Split(rel, "/") {
+		if p == "" || strings.HasPrefix(p, ".") {
+			return false
+		}
+	}
+	return true
+}
+
+func isPostHash(ref string) bool {
+	b, err := hex.DecodeString(ref)
+	return err == nil && len(b) == 32 && strings.ToLower(ref) == ref
+}
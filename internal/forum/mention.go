@@ -0,0 +1,139 @@
+package forum
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// KindMention is the goldmark node kind of an @username mention.
+var KindMention = ast.NewNodeKind("Mention")
+
+// mentionNode is an @username reference to a user whose key is in keys/.
+type mentionNode struct {
+	ast.BaseInline
+	Username string
+}
+
+func (n *mentionNode) Kind() ast.NodeKind { return KindMention }
+
+func (n *mentionNode) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Username": n.Username}, nil)
+}
+
+// knownUsersKey carries the set of usernames that may be mentioned through
+// the goldmark parser context. Without it no mentions are recognised, so
+// plain renders never link to users that do not exist.
+var knownUsersKey = parser.NewContextKey()
+
+// mentionParser recognises "@username" at the start of a word.
+type mentionParser struct{}
+
+func (mentionParser) Trigger() []byte { return []byte{'@'} }
+
+func (mentionParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	users, _ := pc.Get(knownUsersKey).(map[string]bool)
+	if len(users) == 0 {
+		return nil
+	}
+	if prev := block.PrecendingCharacter(); isMentionChar(prev) || prev == '@' {
+		return nil // part of an e-mail address or a longer word
+	}
+	line, _ := block.PeekLine()
+	name := mentionName(string(line[1:]))
+	if !users[name] {
+		return nil
+	}
+	block.Advance(1 + len(name))
+	return &mentionNode{Username: name}
+}
+
+// mentionName returns the username at the start of s. Dots and hyphens are
+// allowed inside a name but not at its end, so "@bob." ends a sentence.
+func mentionName(s string) string {
+	end := 0
+	for i, r := range s {
+		if !isMentionChar(r) && r != '.' && r != '-' {
+			break
+		}
+		if isMentionChar(r) {
+			end = i + len(string(r))
+		}
+	}
+	return s[:end]
+}
+
+func isMentionChar(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// mentionRenderer renders mentions as links to the user in the web UI.
+type mentionRenderer struct{}
+
+func (mentionRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindMention, func(w util.BufWriter, _ []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		name := n.(*mentionNode).Username
+		for p := n.Parent(); p != nil; p = p.Parent() {
+			if p.Kind() == ast.KindLink || p.Kind() == ast.KindAutoLink {
+				fmt.Fprintf(w, "@%s", html.EscapeString(name)) // links cannot nest
+				return ast.WalkContinue, nil
+			}
+		}
+		fmt.Fprintf(w, `<a class="mention" href="#/user/%s">@%s</a>`,
+			html.EscapeString(url.PathEscape(name)), html.EscapeString(name))
+		return ast.WalkContinue, nil
+	})
+}
+
+// KnownUsers returns the set of usernames with a public key in keysDir.
+// An unreadable directory yields an empty set.
+func KnownUsers(keysDir string) map[string]bool {
+	users := make(map[string]bool)
+	entries, err := os.ReadDir(keysDir)
+	if err != nil {
+		return users
+	}
+	for _, e := range entries {
+		if name, ok := strings.CutSuffix(e.Name(), ".pub"); ok && !e.IsDir() {
+			users[name] = true
+		}
+	}
+	return users
+}
+
+// Mentions returns the users in users that body mentions, in order of first
+// appearance. Mentions inside code spans and code blocks are ignored.
+func Mentions(body string, users map[string]bool) []string {
+	_, mentions := renderWithMentions(body, users)
+	return mentions
+}
+
+// ResolveMentions finds the @mentions of known users in the post body and
+// re-renders BodyHTML with each mention linked to the user.
+func (p *Post) ResolveMentions(users map[string]bool) {
+	p.ResolveReferences(users, nil)
+}
+
+// ResolveReferences is ResolveMentions that also resolves the post's quotes
+// and cross-links against index, setting Quotes, marking altered quotes and
+// linking cross-links to their targets in BodyHTML.
+func (p *Post) ResolveReferences(users map[string]bool, index *PostIndex) {
+	hasRefs := index != nil && (strings.Contains(p.Body, "[!quote") || strings.Contains(p.Body, "[["))
+	if !strings.Contains(p.Body, "@") && !hasRefs {
+		p.Mentions, p.Quotes = nil, nil
+		return
+	}
+	p.BodyHTML, p.Mentions, p.Quotes = renderPost(p.Body, users, index)
+}
@@ -0,0 +1,87 @@
+package forum
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// LoadOptions carries forum-wide settings that affect how threads are read.
+// The zero value reads threads without any moderation: nothing is trusted
+// as admin-signed.
+type LoadOptions struct {
+	AdminPubkey string          // base64 admin key from GITORUM.toml
+	Permissions *Permissions    // verified write policy of the category; nil when open
+	Bans        *BanList        // verified admin ban list; nil when there is none
+	Muted       map[string]bool // usernames muted by the local identity
+	Work        int             // proof of work, in bits, required of posts not signed by an approved key; 0 for none
+	// Trust is the local identity's web of trust. Posts by authors without
+	// a key in keys/ that it reaches through a vouch need no proof of work.
+	Trust *WebOfTrust
+	// Index resolves the quotes in posts. Callers that load many threads
+	// of an unchanged checkout can share one; a new one is built when nil.
+	Index *PostIndex
+	// Arrivals maps files, by slash-separated path from the repository
+	// root, to when they entered the history (see repo.Arrivals). Lock,
+	// archive and poll close checks go by arrival, since the time a post
+	// claims is set by its author and can be backdated. When nil, as for
+	// a directory outside git, the claimed time is all there is.
+	Arrivals map[string]time.Time
+}
+
+// arrivedAt returns when the file name of the thread directory dir entered
+// the history, claimed being the time it claims. A file that no commit
+// added yet arrives now.
+func (opts LoadOptions) arrivedAt(dir, keysDir, name string, claimed time.Time) time.Time {
+	if opts.Arrivals == nil {
+		return claimed
+	}
+	rel, err := filepath.Rel(filepath.Dir(keysDir), filepath.Join(dir, name)) // keys/ is at the repository root
+	if err == nil {
+		if at, ok := opts.Arrivals[filepath.ToSlash(rel)]; ok {
+			return at
+		}
+	}
+	return time.Now()
+}
+
+// hides reports whether opts may hide posts, in which case ScanThreadWith
+// has to read every reply rather than just count files.
+func (opts LoadOptions) hides() bool {
+	return (opts.Bans != nil && len(opts.Bans.Bans) > 0) || len(opts.Muted) > 0 || opts.Work > 0
+}
+
+// Moderation flags set on posts by LoadThreadWith. A flagged post is still
+// returned, with its signature status intact, so that clients can show or
+// collapse it as they see fit.
+const (
+	FlagLocked = "locked" // reply posted while the thread was locked or archived
+)
+
+// ThreadOptions returns opts with the write policy of cat applied, loading
+// roles from rolesPath. A policy that is not signed by the admin is ignored
+// and reported as an error alongside the unchanged options.
+func ThreadOptions(opts LoadOptions, cat *Category, rolesPath string) (LoadOptions, error) {
+	if cat.Policy == nil {
+		return opts, nil
+	}
+	if err := cat.Policy.Verify(cat.Slug, opts.AdminPubkey); err != nil {
+		return opts, fmt.Errorf("category %s: policy: %w", cat.Slug, err)
+	}
+	roles, err := LoadRoles(rolesPath, opts.AdminPubkey)
+	opts.Permissions = NewPermissions(cat.Policy, roles)
+	return opts, err
+}
+
+// VerifyAdmin verifies the post's signature and reports whether it is valid
+// and made with the admin key adminPubkey.
+func (p *Post) VerifyAdmin(keysDir, adminPubkey string) bool {
+	p.VerifySignature(keysDir)
+	if p.SigStatus != SigValid || adminPubkey == "" {
+		return false
+	}
+	data, err := os.ReadFile(filepath.Join(keysDir, p.Author+".pub"))
+	return err == nil && strings.TrimSpace(string(data)) == adminPubkey
+}
@@ -0,0 +1,215 @@
+package forum
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/gosub/gitorum/internal/crypto"
+)
+
+// RolesFilename is the admin-signed role list at the repository root.
+const RolesFilename = "roles.toml"
+
+// FlagPolicy marks a post whose author was not allowed to write it by the
+// category's write policy.
+const FlagPolicy = "policy"
+
+// WritePolicy restricts who may post in a category. It is stored as the
+// [policy] table of the category's META.toml and only takes effect when
+// signed with the admin key. Entries are usernames or "@role" references to
+// roles.toml; an empty list leaves that kind of post open to every user.
+type WritePolicy struct {
+	Threads   []string `toml:"threads"` // who may start threads
+	Replies   []string `toml:"replies"` // who may reply
+	SignedBy  string   `toml:"signed_by"`
+	Timestamp string   `toml:"timestamp"`
+	Signature string   `toml:"signature"`
+}
+
+// SignWritePolicy creates a write policy for category signed by adminID.
+// The category slug is covered by the signature, so the policy cannot be
+// copied to another category.
+func SignWritePolicy(adminID *crypto.Identity, category string, threads, replies []string) (*WritePolicy, error) {
+	p := &WritePolicy{
+		Threads:   threads,
+		Replies:   replies,
+		SignedBy:  adminID.Username,
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+	}
+	priv, err := adminID.PrivKey()
+	if err != nil {
+		return nil, fmt.Errorf("get private key: %w", err)
+	}
+	p.Signature = crypto.Sign(priv, p.canonical(category))
+	return p, nil
+}
+
+// Verify checks that the policy was signed for category with adminPubkey.
+func (p *WritePolicy) Verify(category, adminPubkey string) error {
+	if adminPubkey == "" {
+		return errors.New("no admin key")
+	}
+	if _, err := time.Parse(time.RFC3339, p.Timestamp); err != nil {
+		return fmt.Errorf("policy timestamp: %w", err)
+	}
+	return crypto.VerifyWithPublicKeyB64(adminPubkey, p.canonical(category), p.Signature)
+}
+
+func (p *WritePolicy) canonical(category string) []byte {
+	threads, _ := json.Marshal(p.Threads)
+	replies, _ := json.Marshal(p.Replies)
+	return crypto.CanonicalForm(map[string]string{
+		"category":  category,
+		"threads":   string(threads),
+		"replies":   string(replies),
+		"signed_by": p.SignedBy,
+		"timestamp": p.Timestamp,
+	}, "")
+}
+
+// CategoryMetaWithPolicy returns the content of the META.toml in dir with its
+// [policy] table replaced by p, or removed when p is nil. The other fields
+// are kept.
+func CategoryMetaWithPolicy(dir string, p *WritePolicy) ([]byte, error) {
+	meta, err := readCategoryMeta(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read META.toml: %w", err)
+	}
+	meta.Policy = p
+	var sb strings.Builder
+	if err := toml.NewEncoder(&sb).Encode(meta); err != nil {
+		return nil, fmt.Errorf("encode META.toml: %w", err)
+	}
+	return []byte(sb.String()), nil
+}
+
+// Roles maps role names to their member usernames. It is stored in
+// roles.toml and only trusted when signed with the admin key.
+type Roles struct {
+	Members   map[string][]string `toml:"members"`
+	SignedBy  string              `toml:"signed_by"`
+	Timestamp string              `toml:"timestamp"`
+	Signature string              `toml:"signature"`
+}
+
+// SignRoles creates a role list signed by adminID.
+func SignRoles(adminID *crypto.Identity, members map[string][]string) (*Roles, error) {
+	r := &Roles{
+		Members:   members,
+		SignedBy:  adminID.Username,
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+	}
+	priv, err := adminID.PrivKey()
+	if err != nil {
+		return nil, fmt.Errorf("get private key: %w", err)
+	}
+	r.Signature = crypto.Sign(priv, r.canonical())
+	return r, nil
+}
+
+// LoadRoles reads and verifies roles.toml at path. A missing file yields an
+// empty role list; a file not signed with adminPubkey is an error.
+func LoadRoles(path, adminPubkey string) (*Roles, error) {
+	var r Roles
+	if _, err := toml.DecodeFile(path, &r); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return &Roles{}, nil
+		}
+		return nil, fmt.Errorf("read %s: %w", filepath.Base(path), err)
+	}
+	if adminPubkey == "" {
+		return nil, errors.New("roles: no admin key")
+	}
+	if err := crypto.VerifyWithPublicKeyB64(adminPubkey, r.canonical(), r.Signature); err != nil {
+		return nil, fmt.Errorf("roles: %w", err)
+	}
+	return &r, nil
+}
+
+// Format serializes the role list to the roles.toml format.
+func (r *Roles) Format() []byte {
+	var sb strings.Builder
+	_ = toml.NewEncoder(&sb).Encode(r)
+	return []byte(sb.String())
+}
+
+func (r *Roles) canonical() []byte {
+	// json.Marshal sorts map keys, which keeps the form deterministic.
+	members, _ := json.Marshal(r.Members)
+	return crypto.CanonicalForm(map[string]string{
+		"members":   string(members),
+		"signed_by": r.SignedBy,
+		"timestamp": r.Timestamp,
+	}, "")
+}
+
+// Permissions is a verified write policy with its roles resolved.
+type Permissions struct {
+	threads []string
+	replies []string
+	roles   map[string][]string
+}
+
+// NewPermissions resolves a verified policy against roles, which may be nil.
+func NewPermissions(p *WritePolicy, roles *Roles) *Permissions {
+	pm := &Permissions{threads: p.Threads, replies: p.Replies}
+	if roles != nil {
+		pm.roles = roles.Members
+	}
+	return pm
+}
+
+// CanStartThread reports whether username may start threads. The admin is
+// always allowed and is not checked here.
+func (pm *Permissions) CanStartThread(username string) bool {
+	return pm.allows(pm.threads, username)
+}
+
+// CanReply reports whether username may reply. The admin is always allowed
+// and is not checked here.
+func (pm *Permissions) CanReply(username string) bool {
+	return pm.allows(pm.replies, username)
+}
+
+func (pm *Permissions) allows(list []string, username string) bool {
+	if len(list) == 0 {
+		return true
+	}
+	for _, entry := range list {
+		if role, ok := strings.CutPrefix(entry, "@"); ok {
+			if slices.Contains(pm.roles[role], username) {
+				return true
+			}
+		} else if entry == username {
+			return true
+		}
+	}
+	return false
+}
+
+// checkPost flags p when its author is not allowed to write it. Posts by
+// the admin are left alone. The policy applies to every post, whatever its
+// timestamp: the author sets it, so it cannot tell posts written before the
+// policy from backdated ones.
+func (pm *Permissions) checkPost(p *Post, isRoot bool, keysDir, adminPubkey string) {
+	if p.Tombstoned || p.Flag != "" {
+		return
+	}
+	allowed, reason := pm.CanReply(p.Author), "author may not reply in this category"
+	if isRoot {
+		allowed, reason = pm.CanStartThread(p.Author), "author may not start threads in this category"
+	}
+	if allowed || p.VerifyAdmin(keysDir, adminPubkey) {
+		return
+	}
+	p.Flag = FlagPolicy
+	p.FlagReason = reason
+}
@@ -0,0 +1,276 @@
+package forum
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gosub/gitorum/internal/crypto"
+)
+
+// VoteExt is the extension of signed vote files stored next to the posts of
+// a poll thread.
+const VoteExt = ".vote"
+
+// KindVote is the signed kind of a vote file.
+const KindVote = "vote"
+
+// PollResult is the tally of a poll thread.
+type PollResult struct {
+	Options  []PollOption
+	Voters   []*Vote   // counted votes, one per voter, sorted by username
+	Rejected []*Vote   // votes that were not counted; Reason says why
+	ClosesAt time.Time // zero when the poll never closes
+	Closed   bool      // true once ClosesAt has passed
+}
+
+// PollOption is one choice of a poll and the number of counted votes for it.
+type PollOption struct {
+	Text  string
+	Votes int
+}
+
+// Vote is a single signed vote file. The signature, key fingerprint and
+// filename are kept so that anyone can re-verify the tally.
+type Vote struct {
+	Voter     string
+	PubKey    string
+	Option    string
+	Timestamp time.Time
+	Filename  string
+	Signature string
+	Reason    string // why the vote was rejected; empty for counted votes
+}
+
+// SignPoll creates a root post that declares a poll. closes is an RFC3339
+// time after which votes are no longer counted, or "" for an open-ended poll.
+func SignPoll(id *crypto.Identity, body string, options []string, closes string) (*Post, error) {
+	return SignPollWith(id, body, options, closes, SignOptions{})
+}
+
+// SignPollWith is SignPoll with forum settings applied, as in SignPostWith.
+func SignPollWith(id *crypto.Identity, body string, options []string, closes string, opts SignOptions) (*Post, error) {
+	if err := ValidatePollOptions(options); err != nil {
+		return nil, err
+	}
+	if closes != "" {
+		if _, err := time.Parse(time.RFC3339, closes); err != nil {
+			return nil, fmt.Errorf("poll close time: %w", err)
+		}
+	}
+	return signPost(id, &Post{
+		Body:        body,
+		PollOptions: options,
+		PollCloses:  closes,
+	}, opts)
+}
+
+// ValidatePollOptions checks that options holds between 2 and 20 distinct,
+// non-empty, single-line choices.
+func ValidatePollOptions(options []string) error {
+	if len(options) < 2 || len(options) > 20 {
+		return fmt.Errorf("a poll needs between 2 and 20 options, got %d", len(options))
+	}
+	seen := make(map[string]bool, len(options))
+	for _, o := range options {
+		if strings.TrimSpace(o) == "" {
+			return fmt.Errorf("poll options must not be empty")
+		}
+		if strings.ContainsFunc(o, func(r rune) bool { return r < 0x20 || r == 0x7f }) {
+			return fmt.Errorf("poll option %q contains control characters", o)
+		}
+		if seen[o] {
+			return fmt.Errorf("duplicate poll option %q", o)
+		}
+		seen[o] = true
+	}
+	return nil
+}
+
+// SignVote creates a vote for option on the poll whose root file content is
+// rootContent. The vote is a post of kind KindVote whose parent is the root
+// hash and whose body is the chosen option; the signed kind keeps a reply
+// to the poll from being passed off as a vote. The caller must set Filename
+// (see NewVoteFilename) before writing to disk.
+func SignVote(id *crypto.Identity, rootContent []byte, option string) (*Post, error) {
+	return signPost(id, &Post{Kind: KindVote, Parent: PostHash(rootContent), Body: option}, SignOptions{})
+}
+
+// NewVoteFilename generates the filename for a new vote.
+// Format: {unix_millis}_{sha256_of_voter_and_option[:8]}.vote
+func NewVoteFilename(voter, option string) string {
+	h := sha256.Sum256([]byte(voter + "\n" + option))
+	return fmt.Sprintf("%d_%s%s", time.Now().UnixMilli(), hex.EncodeToString(h[:])[:8], VoteExt)
+}
+
+// TallyPoll reads the poll root and every vote file in dir and counts the
+// latest valid vote of each approved voter. A vote is valid when its
+// signature verifies against keys/, its parent is the hash of the root post,
+// its option is one of the declared options, and it was cast no later than
+// the close time. It returns an error if the root post is not a poll.
+func TallyPoll(dir, keysDir string) (*PollResult, error) {
+	return TallyPollWith(dir, keysDir, LoadOptions{})
+}
+
+// TallyPollWith is TallyPoll with forum settings applied, as LoadThreadWith
+// applies them to replies: votes by banned users, by users the category's
+// write policy bars from replying, and votes cast while the thread was
+// locked or archived are not counted. The admin's votes always are. With
+// opts.Arrivals, a vote counts as cast when it entered the history.
+func TallyPollWith(dir, keysDir string, opts LoadOptions) (*PollResult, error) {
+	return tallyPoll(dir, keysDir, opts, nil)
+}
+
+// tallyPoll implements TallyPollWith. st is the thread state, loaded from
+// dir when nil.
+func tallyPoll(dir, keysDir string, opts LoadOptions, st *ThreadState) (*PollResult, error) {
+	rootContent, err := os.ReadFile(filepath.Join(dir, RootFilename))
+	if err != nil {
+		return nil, fmt.Errorf("read root post: %w", err)
+	}
+	root, err := ParsePost(RootFilename, rootContent)
+	if err != nil {
+		return nil, fmt.Errorf("parse root post: %w", err)
+	}
+	if !root.IsPoll() {
+		return nil, fmt.Errorf("thread is not a poll")
+	}
+
+	res := &PollResult{}
+	for _, o := range root.PollOptions {
+		res.Options = append(res.Options, PollOption{Text: o})
+	}
+	if root.PollCloses != "" {
+		closes, err := time.Parse(time.RFC3339, root.PollCloses)
+		if err != nil {
+			return nil, fmt.Errorf("parse poll close time: %w", err)
+		}
+		res.ClosesAt = closes
+		res.Closed = time.Now().After(closes)
+	}
+	if st == nil {
+		if st, err = LoadThreadState(dir, keysDir, opts.AdminPubkey); err != nil {
+			return nil, fmt.Errorf("thread state: %w", err)
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read thread dir: %w", err)
+	}
+	rootHash := PostHash(rootContent)
+	latest := make(map[string]*Vote)
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, VoteExt) {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(dir, TombstoneFilename(name))); err == nil {
+			continue
+		}
+		vote, p := readVote(dir, name, keysDir, rootHash, root.PollOptions)
+		if vote.Reason == "" {
+			arrived := opts.arrivedAt(dir, keysDir, name, p.Timestamp)
+			if !res.ClosesAt.IsZero() && arrived.After(res.ClosesAt) {
+				vote.Reason = "cast after the poll closed"
+			} else {
+				vote.Reason = opts.voteRejection(p, keysDir, st, arrived)
+			}
+		}
+		if vote.Reason != "" {
+			res.Rejected = append(res.Rejected, vote)
+			continue
+		}
+		if prev, ok := latest[vote.Voter]; ok {
+			if !vote.supersedes(prev) {
+				vote.Reason = "superseded by a later vote"
+				res.Rejected = append(res.Rejected, vote)
+				continue
+			}
+			prev.Reason = "superseded by a later vote"
+			res.Rejected = append(res.Rejected, prev)
+		}
+		latest[vote.Voter] = vote
+	}
+
+	for _, v := range latest {
+		res.Voters = append(res.Voters, v)
+		for i := range res.Options {
+			if res.Options[i].Text == v.Option {
+				res.Options[i].Votes++
+			}
+		}
+	}
+	sort.Slice(res.Voters, func(i, j int) bool { return res.Voters[i].Voter < res.Voters[j].Voter })
+	sort.Slice(res.Rejected, func(i, j int) bool { return res.Rejected[i].Filename < res.Rejected[j].Filename })
+	return res, nil
+}
+
+// supersedes reports whether v was cast after other. Timestamps only have
+// second resolution, so ties are broken by the millisecond filename prefix.
+func (v *Vote) supersedes(other *Vote) bool {
+	if !v.Timestamp.Equal(other.Timestamp) {
+		return v.Timestamp.After(other.Timestamp)
+	}
+	return v.Filename > other.Filename
+}
+
+// readVote parses and validates a single vote file, returning the vote and
+// the post it was read from. Problems are reported through Vote.Reason
+// rather than as errors so they can be shown to users; the post is nil when
+// the file could not be parsed.
+func readVote(dir, name, keysDir, rootHash string, options []string) (*Vote, *Post) {
+	vote := &Vote{Filename: name}
+	content, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		vote.Reason = err.Error()
+		return vote, nil
+	}
+	p, err := ParsePost(name, content)
+	if err != nil {
+		vote.Reason = err.Error()
+		return vote, nil
+	}
+	vote.Voter = p.Author
+	vote.PubKey = p.PubKey
+	vote.Option = p.Body
+	vote.Timestamp = p.Timestamp
+	vote.Signature = p.Signature
+
+	p.VerifySignature(keysDir)
+	switch {
+	case p.SigStatus != SigValid:
+		vote.Reason = p.SigError
+	case p.Kind != KindVote:
+		vote.Reason = "not signed as a vote"
+	case p.Parent != rootHash:
+		vote.Reason = "vote does not reference this poll"
+	case !slices.Contains(options, p.Body):
+		vote.Reason = fmt.Sprintf("unknown option %q", p.Body)
+	}
+	return vote, p
+}
+
+// voteRejection returns why the valid vote p, which arrived at arrived, is
+// not counted under opts and the thread state st, or "" when it is.
+func (opts LoadOptions) voteRejection(p *Post, keysDir string, st *ThreadState, arrived time.Time) string {
+	if opts.Bans.Find(p.Author, p.PubKey) != nil {
+		return "voter is banned"
+	}
+	if p.VerifyAdmin(keysDir, opts.AdminPubkey) {
+		return ""
+	}
+	switch {
+	case opts.Permissions != nil && !opts.Permissions.CanReply(p.Author):
+		return "voter may not reply in this category"
+	case st.ClosedAt(arrived):
+		return "cast while the thread was locked"
+	}
+	return ""
+}
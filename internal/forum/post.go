@@ -5,6 +5,7 @@ package forum
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -24,6 +25,30 @@ const (
 	SigMissing                  // no public key found for this author
 )
 
+// name returns s as used in markup, e.g. a CSS class.
+func (s SigStatus) name() string {
+	switch s {
+	case SigValid:
+		return "valid"
+	case SigInvalid:
+		return "invalid"
+	default:
+		return "missing"
+	}
+}
+
+// describe returns s in words for readers.
+func (s SigStatus) describe() string {
+	switch s {
+	case SigValid:
+		return "signed"
+	case SigInvalid:
+		return "invalid signature"
+	default:
+		return "no key"
+	}
+}
+
 // rawFrontMatter holds the TOML-decoded fields from a post's +++ block.
 type rawFrontMatter struct {
 	Author    string `toml:"author"`
@@ -31,6 +56,11 @@ type rawFrontMatter struct {
 	Timestamp string `toml:"timestamp"`
 	Parent    string `toml:"parent"`
 	Signature string `toml:"signature"`
+	Kind      string `toml:"kind"`
+
+	PollOptions []string `toml:"poll_options"`
+	PollCloses  string   `toml:"poll_closes"`
+	Work        string   `toml:"work"`
 }
 
 // Post represents a parsed and optionally signature-verified post file.
@@ -42,16 +72,29 @@ type Post struct {
 	TimestampRaw string // raw value from file, used verbatim in canonical form
 	Parent       string // sha256 hex of parent file content; empty for root
 	Signature    string // base64-encoded ed25519 signature
+	Kind         string // what the file is when it is not a post, e.g. KindVote; empty for posts
+
+	// Poll fields, set only on poll root posts
+	PollOptions []string // choices voters may pick; empty for ordinary posts
+	PollCloses  string   // raw RFC3339 close time; empty when the poll never closes
+
+	Work string // proof-of-work stamp; empty when the post carries none
 
 	// Content
-	Body     string // raw Markdown body
-	BodyHTML string // body rendered to HTML by goldmark
+	Body     string   // raw Markdown body
+	BodyHTML string   // body rendered to HTML by goldmark
+	Hash     string   // sha256 hex of the file content, as referenced by replies
+	Mentions []string // known users mentioned with @username; set by ResolveMentions
+	Quotes   []*Quote // quotes of other posts; set by ResolveReferences
 
 	// Metadata
 	Filename   string    // e.g. "0000_root.md" or "1708123456789_a3f9c1b2.md"
 	SigStatus  SigStatus // set by VerifySignature
 	SigError   string    // human-readable reason when SigStatus != SigValid
+	WorkBits   int       // bits of proof of work in Work; set by VerifySignature
 	Tombstoned bool      // true when a tombstone file exists; body/author are cleared
+	Flag       string    // moderation flag set by LoadThreadWith (e.g. FlagLocked); empty otherwise
+	FlagReason string    // human-readable explanation of Flag
 }
 
 // ParsePost parses a .md file with TOML front matter fenced by +++.
@@ -63,6 +106,9 @@ func ParsePost(filename string, content []byte) (*Post, error) {
 	if err != nil {
 		return nil, fmt.Errorf("parse %s: %w", filename, err)
 	}
+	if fm.Kind != "" && filepath.Ext(filename) == ".md" {
+		return nil, fmt.Errorf("parse %s: a %s is not a post", filename, fm.Kind)
+	}
 	ts, err := time.Parse(time.RFC3339, fm.Timestamp)
 	if err != nil {
 		return nil, fmt.Errorf("parse timestamp in %s: %w", filename, err)
@@ -74,15 +120,22 @@ func ParsePost(filename string, content []byte) (*Post, error) {
 		TimestampRaw: fm.Timestamp,
 		Parent:       fm.Parent,
 		Signature:    fm.Signature,
+		Kind:         fm.Kind,
+		PollOptions:  fm.PollOptions,
+		PollCloses:   fm.PollCloses,
+		Work:         fm.Work,
 		Body:         body,
 		BodyHTML:     renderMarkdown(body),
+		Hash:         PostHash(content),
 		Filename:     filename,
 	}, nil
 }
 
 // VerifySignature looks up <keysDir>/<author>.pub, reconstructs the canonical
-// form, and verifies the signature. It sets SigStatus and SigError in place.
+// form, and verifies the signature. It sets SigStatus and SigError in place,
+// and WorkBits to the proof of work the post carries.
 func (p *Post) VerifySignature(keysDir string) {
+	p.WorkBits = p.workBits()
 	pubPath := filepath.Join(keysDir, p.Author+".pub")
 	data, err := os.ReadFile(pubPath)
 	if err != nil {
@@ -97,16 +150,7 @@ func (p *Post) VerifySignature(keysDir string) {
 	}
 	pubkeyB64 := strings.TrimSpace(string(data))
 
-	// Reconstruct canonical form. CanonicalForm excludes "signature" automatically.
-	fields := map[string]string{
-		"author":    p.Author,
-		"pubkey":    p.PubKey,
-		"timestamp": p.TimestampRaw,
-		"parent":    p.Parent,
-		"signature": p.Signature,
-	}
-	canonical := crypto.CanonicalForm(fields, p.Body)
-
+	canonical := crypto.CanonicalForm(p.canonicalFields(), p.Body)
 	if err := crypto.VerifyWithPublicKeyB64(pubkeyB64, canonical, p.Signature); err != nil {
 		p.SigStatus = SigInvalid
 		p.SigError = err.Error()
@@ -126,6 +170,10 @@ func (p *Post) VerifySignature(keysDir string) {
 //	+++
 //
 //	<body>
+//
+// Votes additionally carry a kind after parent, poll roots poll_options
+// and, optionally, poll_closes between parent and signature, and posts with
+// a proof of work a work stamp.
 func (p *Post) Format() []byte {
 	var sb strings.Builder
 	sb.WriteString("+++\n")
@@ -133,6 +181,22 @@ func (p *Post) Format() []byte {
 	fmt.Fprintf(&sb, "pubkey    = %q\n", p.PubKey)
 	fmt.Fprintf(&sb, "timestamp = %q\n", p.TimestampRaw)
 	fmt.Fprintf(&sb, "parent    = %q\n", p.Parent)
+	if p.Kind != "" {
+		fmt.Fprintf(&sb, "kind      = %q\n", p.Kind)
+	}
+	if len(p.PollOptions) > 0 {
+		quoted := make([]string, len(p.PollOptions))
+		for i, o := range p.PollOptions {
+			quoted[i] = fmt.Sprintf("%q", o)
+		}
+		fmt.Fprintf(&sb, "poll_options = [%s]\n", strings.Join(quoted, ", "))
+	}
+	if p.PollCloses != "" {
+		fmt.Fprintf(&sb, "poll_closes  = %q\n", p.PollCloses)
+	}
+	if p.Work != "" {
+		fmt.Fprintf(&sb, "work      = %q\n", p.Work)
+	}
 	fmt.Fprintf(&sb, "signature = %q\n", p.Signature)
 	sb.WriteString("+++\n\n")
 	sb.WriteString(p.Body)
@@ -150,34 +214,72 @@ func PostHash(content []byte) string {
 // parent is PostHash of the parent file content, or "" for a root post.
 // The caller must set Filename before writing to disk.
 func SignPost(id *crypto.Identity, parent, body string) (*Post, error) {
-	ts := time.Now().UTC()
-	tsRaw := ts.Format(time.RFC3339)
+	return SignPostWith(id, parent, body, SignOptions{})
+}
 
-	fields := map[string]string{
-		"author":    id.Username,
-		"pubkey":    id.Fingerprint(),
-		"timestamp": tsRaw,
-		"parent":    parent,
+// SignPostWith is SignPost with forum settings applied: when the forum
+// requires a proof of work of authors without an approved key and id has
+// none, it computes one before signing.
+func SignPostWith(id *crypto.Identity, parent, body string, opts SignOptions) (*Post, error) {
+	return signPost(id, &Post{Parent: parent, Body: body}, opts)
+}
+
+// IsPoll reports whether the post declares poll options.
+func (p *Post) IsPoll() bool {
+	return len(p.PollOptions) > 0
+}
+
+// signPost fills in the author, key and timestamp of p, adds a proof of
+// work when opts requires one, signs every field returned by
+// canonicalFields, and renders the body.
+func signPost(id *crypto.Identity, p *Post, opts SignOptions) (*Post, error) {
+	ts := time.Now().UTC()
+	p.Author = id.Username
+	p.PubKey = id.Fingerprint()
+	p.Timestamp = ts
+	p.TimestampRaw = ts.Format(time.RFC3339)
+	if opts.needsWork(id) {
+		if opts.Work > MaxWork {
+			return nil, fmt.Errorf("the forum requires %d bits of proof of work, more than the %d supported", opts.Work, MaxWork)
+		}
+		p.addWork(opts.Work)
 	}
-	canonical := crypto.CanonicalForm(fields, body)
 
 	priv, err := id.PrivKey()
 	if err != nil {
 		return nil, fmt.Errorf("get private key: %w", err)
 	}
-	sig := crypto.Sign(priv, canonical)
+	p.Signature = crypto.Sign(priv, crypto.CanonicalForm(p.canonicalFields(), p.Body))
+	p.BodyHTML = renderMarkdown(p.Body)
+	p.SigStatus = SigValid
+	return p, nil
+}
 
-	return &Post{
-		Author:       id.Username,
-		PubKey:       id.Fingerprint(),
-		Timestamp:    ts,
-		TimestampRaw: tsRaw,
-		Parent:       parent,
-		Signature:    sig,
-		Body:         body,
-		BodyHTML:     renderMarkdown(body),
-		SigStatus:    SigValid,
-	}, nil
+// canonicalFields returns the front matter fields covered by the signature.
+// Optional fields are only included when set, so posts written before those
+// fields existed keep verifying.
+func (p *Post) canonicalFields() map[string]string {
+	fields := map[string]string{
+		"author":    p.Author,
+		"pubkey":    p.PubKey,
+		"timestamp": p.TimestampRaw,
+		"parent":    p.Parent,
+	}
+	if p.Kind != "" {
+		fields["kind"] = p.Kind
+	}
+	if len(p.PollOptions) > 0 {
+		// JSON keeps the list unambiguous whatever the option text contains.
+		opts, _ := json.Marshal(p.PollOptions)
+		fields["poll_options"] = string(opts)
+	}
+	if p.PollCloses != "" {
+		fields["poll_closes"] = p.PollCloses
+	}
+	if p.Work != "" {
+		fields["work"] = p.Work
+	}
+	return fields
 }
 
 // TombstoneFilename returns the tombstone filename for a given post filename.
@@ -0,0 +1,133 @@
+package forum
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+	_ "time/tzdata" // validate timezones without relying on the host's database
+	"unicode/utf8"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/gosub/gitorum/internal/crypto"
+)
+
+// ProfilesDir is the repository directory holding one profile per user.
+const ProfilesDir = "profiles"
+
+// Limits on profile fields, in characters.
+const (
+	maxDisplayName = 64
+	maxBio         = 2000
+	maxContact     = 200
+)
+
+// ProfileFields is the body of a profile record, written as TOML.
+type ProfileFields struct {
+	DisplayName string `toml:"display_name" json:"display_name"`
+	Bio         string `toml:"bio" json:"bio"`         // Markdown
+	Avatar      string `toml:"avatar" json:"avatar"`   // SHA-256 hex of the avatar attachment
+	Contact     string `toml:"contact" json:"contact"` // free-form, e.g. an e-mail address
+	Timezone    string `toml:"timezone" json:"timezone"`
+}
+
+// Validate checks field lengths, that Avatar is a SHA-256 hex digest and
+// that Timezone is an IANA time zone name. Empty fields are allowed.
+func (f ProfileFields) Validate() error {
+	switch {
+	case utf8.RuneCountInString(f.DisplayName) > maxDisplayName:
+		return fmt.Errorf("display name is longer than %d characters", maxDisplayName)
+	case strings.ContainsAny(f.DisplayName, "\n\r"):
+		return errors.New("display name must be a single line")
+	case utf8.RuneCountInString(f.Bio) > maxBio:
+		return fmt.Errorf("bio is longer than %d characters", maxBio)
+	case utf8.RuneCountInString(f.Contact) > maxContact:
+		return fmt.Errorf("contact is longer than %d characters", maxContact)
+	}
+	if f.Avatar != "" {
+		if b, err := hex.DecodeString(f.Avatar); err != nil || len(b) != 32 || strings.ToLower(f.Avatar) != f.Avatar {
+			return errors.New("avatar must be a lowercase SHA-256 hex digest")
+		}
+	}
+	if f.Timezone != "" {
+		if _, err := time.LoadLocation(f.Timezone); err != nil || f.Timezone == "Local" {
+			return fmt.Errorf("unknown timezone %q", f.Timezone)
+		}
+	}
+	return nil
+}
+
+// Profile is a user's verified, self-signed profile.
+type Profile struct {
+	ProfileFields
+	Username  string
+	UpdatedAt time.Time // signing time; zero when the user has no profile
+	BioHTML   string    // Bio rendered to HTML
+}
+
+// ProfilePath returns the repository-relative path of username's profile.
+func ProfilePath(username string) string {
+	return path.Join(ProfilesDir, username+".toml")
+}
+
+// SignProfile creates a profile record for id. It uses the post format with
+// an empty parent and the fields as TOML body; every signature replaces the
+// whole profile.
+func SignProfile(id *crypto.Identity, f ProfileFields) (*Post, error) {
+	if err := f.Validate(); err != nil {
+		return nil, err
+	}
+	var sb strings.Builder
+	if err := toml.NewEncoder(&sb).Encode(f); err != nil {
+		return nil, fmt.Errorf("encode profile: %w", err)
+	}
+	// ParsePost drops the trailing newline, so leave it out of the signed body.
+	p, err := SignPost(id, "", strings.TrimSuffix(sb.String(), "\n"))
+	if err != nil {
+		return nil, err
+	}
+	p.Filename = path.Base(ProfilePath(id.Username))
+	return p, nil
+}
+
+// LoadProfile reads and verifies the profile of username at filePath
+// against the key in keysDir. A missing file yields an empty profile; a
+// record signed by someone else, or with a bad signature, is an error.
+func LoadProfile(filePath, keysDir, username string) (*Profile, error) {
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return &Profile{Username: username}, nil
+		}
+		return nil, fmt.Errorf("read profile: %w", err)
+	}
+	p, err := ParsePost(filepath.Base(filePath), content)
+	if err != nil {
+		return nil, err
+	}
+	if p.Author != username {
+		return nil, fmt.Errorf("profile of %s is signed by %s", username, p.Author)
+	}
+	p.VerifySignature(keysDir)
+	if p.SigStatus != SigValid {
+		return nil, fmt.Errorf("profile of %s: %s", username, p.SigError)
+	}
+	var f ProfileFields
+	if _, err := toml.Decode(p.Body, &f); err != nil {
+		return nil, fmt.Errorf("decode profile of %s: %w", username, err)
+	}
+	if err := f.Validate(); err != nil {
+		return nil, fmt.Errorf("profile of %s: %w", username, err)
+	}
+	return &Profile{
+		ProfileFields: f,
+		Username:      username,
+		UpdatedAt:     p.Timestamp,
+		BioHTML:       renderMarkdown(f.Bio),
+	}, nil
+}
@@ -0,0 +1,215 @@
+package forum
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// A quote is a blockquote whose first line is "[!quote REF]", where REF is
+// the PostHash of the quoted post or its category/thread/filename path:
+//
+//	> [!quote general/hello-world/0000_root.md]
+//	> Welcome to the forum!
+//
+// Other Markdown renderers show it as an ordinary blockquote.
+var quoteMarkerRe = regexp.MustCompile(`^\[!quote\s+([^\s\]]+)\]\s*$`)
+
+// Quote is a reference from one post to another, resolved against the
+// forum checkout.
+type Quote struct {
+	Ref       string // as written: a PostHash or category/thread/filename
+	Category  string // location of the quoted post; empty unless Found
+	Thread    string
+	Filename  string
+	Author    string
+	SigStatus SigStatus
+	Found     bool // Ref names an existing post that has not been deleted
+	Altered   bool // the quoted text does not appear in the quoted post
+}
+
+// KindQuote is the goldmark node kind of a verifiable quote.
+var KindQuote = ast.NewNodeKind("Quote")
+
+// quoteNode replaces the blockquote of a quote; its children are the quoted
+// blocks without the marker line.
+type quoteNode struct {
+	ast.BaseBlock
+	Quote *Quote
+}
+
+func (n *quoteNode) Kind() ast.NodeKind { return KindQuote }
+
+func (n *quoteNode) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Ref": n.Quote.Ref}, nil)
+}
+
+// postIndexKey carries the *PostIndex quotes are resolved against through
+// the goldmark parser context. Without it quotes render unresolved.
+var postIndexKey = parser.NewContextKey()
+
+// quoteTransformer turns marked blockquotes into quote nodes.
+type quoteTransformer struct{}
+
+func (quoteTransformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	index, _ := pc.Get(postIndexKey).(*PostIndex)
+	source := reader.Source()
+	var quotes []*ast.Blockquote
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if bq, ok := n.(*ast.Blockquote); ok && entering {
+			quotes = append(quotes, bq)
+		}
+		return ast.WalkContinue, nil
+	})
+	for _, bq := range quotes {
+		ref := stripQuoteMarker(bq, source)
+		if ref == "" {
+			continue
+		}
+		q := &quoteNode{Quote: &Quote{Ref: ref}}
+		for c := bq.FirstChild(); c != nil; c = bq.FirstChild() {
+			q.AppendChild(q, c)
+		}
+		bq.Parent().ReplaceChild(bq.Parent(), bq, q)
+		if index != nil {
+			index.resolve(q.Quote, plainText(q, source))
+		}
+	}
+}
+
+// stripQuoteMarker returns the reference of a quote marker on the first line
+// of bq and removes that line, or returns "" when bq is not a quote.
+func stripQuoteMarker(bq *ast.Blockquote, source []byte) string {
+	para, ok := bq.FirstChild().(*ast.Paragraph)
+	if !ok || para.Lines().Len() == 0 {
+		return ""
+	}
+	first := para.Lines().At(0)
+	m := quoteMarkerRe.FindSubmatch(first.Value(source))
+	if m == nil {
+		return ""
+	}
+	for c := para.FirstChild(); c != nil; {
+		next := c.NextSibling()
+		para.RemoveChild(para, c)
+		if t, ok := c.(*ast.Text); ok && (t.SoftLineBreak() || t.HardLineBreak() || t.Segment.Stop >= first.Stop) {
+			break
+		}
+		c = next
+	}
+	if para.FirstChild() == nil {
+		bq.RemoveChild(bq, para)
+	}
+	return string(m[1])
+}
+
+// plainText returns the text under n with formatting dropped and whitespace
+// collapsed, so that quotes compare equal however they are marked up.
+func plainText(n ast.Node, source []byte) string {
+	var sb strings.Builder
+	_ = ast.Walk(n, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := n.(type) {
+		case *ast.Text:
+			sb.Write(n.Segment.Value(source))
+			if n.SoftLineBreak() || n.HardLineBreak() {
+				sb.WriteByte(' ')
+			}
+		case *ast.String:
+			sb.Write(n.Value)
+		case *ast.AutoLink:
+			sb.Write(n.URL(source))
+		case *mentionNode:
+			sb.WriteString("@" + n.Username)
+		case *xrefNode:
+			sb.WriteString(n.text())
+		default:
+			if n.Type() == ast.TypeBlock {
+				lines := n.Lines()
+				if n.HasChildren() || lines == nil {
+					sb.WriteByte(' ')
+					break
+				}
+				for i := 0; i < lines.Len(); i++ {
+					seg := lines.At(i)
+					sb.Write(seg.Value(source))
+				}
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	return strings.Join(strings.Fields(sb.String()), " ")
+}
+
+// quoteRenderer renders a quote as a blockquote headed by the quoted
+// author, the state of their signature and a link to the quoted post.
+type quoteRenderer struct{}
+
+func (quoteRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindQuote, func(w util.BufWriter, _ []byte, n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			_, _ = w.WriteString("</blockquote>\n")
+			return ast.WalkContinue, nil
+		}
+		q := n.(*quoteNode).Quote
+		_, _ = w.WriteString(`<blockquote class="quote">` + "\n")
+		switch {
+		case q.Found:
+			fmt.Fprintf(w, `<p class="quote-source"><a href="%s">@%s</a> <span class="quote-sig quote-sig-%s">%s</span>`,
+				html.EscapeString(q.Link()), html.EscapeString(q.Author), q.SigStatus.name(), q.SigStatus.describe())
+			if q.Altered {
+				_, _ = w.WriteString(` <span class="quote-altered">altered</span>`)
+			}
+			_, _ = w.WriteString("</p>\n")
+		case q.Ref != "":
+			fmt.Fprintf(w, `<p class="quote-source"><span class="quote-missing">quoted post %s not found</span></p>`+"\n",
+				html.EscapeString(shortRef(q.Ref)))
+		}
+		return ast.WalkContinue, nil
+	})
+}
+
+// Link returns the web UI route of the quoted post, or "" when it was not
+// found.
+func (q *Quote) Link() string {
+	if !q.Found {
+		return ""
+	}
+	t := Target{Kind: TargetPost, Category: q.Category, Thread: q.Thread, Filename: q.Filename}
+	return t.Link()
+}
+
+// shortRef abbreviates a PostHash reference for display.
+func shortRef(ref string) string {
+	if isPostHash(ref) {
+		return ref[:12] + "…"
+	}
+	return ref
+}
+
+// resolve fills in q from the post it references. quoted is the plain text
+// of the quote; an empty quote cannot be altered.
+func (ix *PostIndex) resolve(q *Quote, quoted string) {
+	p, rel := ix.Lookup(q.Ref)
+	if p == nil {
+		return
+	}
+	q.Category, q.Thread, q.Filename = splitPostPath(rel)
+	q.Author = p.Author
+	q.SigStatus = p.SigStatus
+	q.Found = true
+	if quoted != "" {
+		src := []byte(p.Body)
+		original := plainText(mdRenderer.md.Parser().Parse(text.NewReader(src)), src)
+		q.Altered = !strings.Contains(original, quoted)
+	}
+}
@@ -0,0 +1,62 @@
+package forum
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/gosub/gitorum/internal/crypto"
+)
+
+// VerifyAdminRecord checks the signature of a file that only takes effect
+// when signed with the admin key adminPubkey: a tombstone, thread state or
+// redirect record, bans.toml, roles.toml, or a category META.toml with a
+// write policy. relPath is slash-separated from the repository root. It
+// reports false, and no error, when the file is not such a record.
+func VerifyAdminRecord(relPath string, content []byte, adminPubkey string) (bool, error) {
+	name := path.Base(relPath)
+	switch {
+	case strings.HasSuffix(name, ".tomb"), strings.HasSuffix(name, StateExt), name == RedirectFilename:
+		return true, verifyAdminPost(name, content, adminPubkey)
+	case relPath == BansFilename:
+		var l BanList
+		if _, err := toml.Decode(string(content), &l); err != nil {
+			return true, fmt.Errorf("parse %s: %w", name, err)
+		}
+		return true, verifyAdminKey(adminPubkey, l.canonical(), l.Signature)
+	case relPath == RolesFilename:
+		var r Roles
+		if _, err := toml.Decode(string(content), &r); err != nil {
+			return true, fmt.Errorf("parse %s: %w", name, err)
+		}
+		return true, verifyAdminKey(adminPubkey, r.canonical(), r.Signature)
+	case name == MetaFilename && path.Dir(relPath) != ".":
+		var meta categoryMeta
+		if _, err := toml.Decode(string(content), &meta); err != nil {
+			return true, fmt.Errorf("parse %s: %w", relPath, err)
+		}
+		if meta.Policy == nil {
+			return false, nil
+		}
+		return true, meta.Policy.Verify(path.Dir(relPath), adminPubkey)
+	}
+	return false, nil
+}
+
+func verifyAdminPost(name string, content []byte, adminPubkey string) error {
+	p, err := ParsePost(name, content)
+	if err != nil {
+		return err
+	}
+	return verifyAdminKey(adminPubkey, crypto.CanonicalForm(p.canonicalFields(), p.Body), p.Signature)
+}
+
+func verifyAdminKey(adminPubkey string, canonical []byte, signature string) error {
+	if adminPubkey == "" {
+		return errors.New("no admin key")
+	}
+	return crypto.VerifyWithPublicKeyB64(adminPubkey, canonical, signature)
+}
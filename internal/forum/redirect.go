@@ -0,0 +1,209 @@
+package forum
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/gosub/gitorum/internal/crypto"
+)
+
+// RedirectFilename is the admin-signed record left in the directory of a
+// thread that was moved, renamed or merged into another thread. A directory
+// holding only a redirect is not a thread, so the old location disappears
+// from listings while old links keep working.
+const RedirectFilename = "REDIRECT.toml"
+
+// Redirect actions.
+const (
+	RedirectMove  = "move"  // the thread directory was moved or renamed
+	RedirectMerge = "merge" // the thread's posts were merged into another thread
+)
+
+// maxRedirects bounds how many redirects are followed, so that a loop of
+// records cannot hang a lookup.
+const maxRedirects = 8
+
+// RedirectRecord is the body of a redirect record, written as TOML. The
+// record's parent is the hash of the thread's root post, which moves with
+// the thread unchanged.
+type RedirectRecord struct {
+	Action string `toml:"action"`         // RedirectMove or RedirectMerge
+	To     string `toml:"to"`             // category/thread that now holds the posts
+	Root   string `toml:"root,omitempty"` // merges only: filename of the old root post in To
+}
+
+// SignRedirect creates a redirect record for the thread whose root file
+// content is rootContent.
+func SignRedirect(adminID *crypto.Identity, rootContent []byte, rec RedirectRecord) (*Post, error) {
+	var sb strings.Builder
+	if err := toml.NewEncoder(&sb).Encode(rec); err != nil {
+		return nil, fmt.Errorf("encode redirect: %w", err)
+	}
+	// ParsePost drops the trailing newline, so leave it out of the signed body.
+	p, err := SignPost(adminID, PostHash(rootContent), strings.TrimSuffix(sb.String(), "\n"))
+	if err != nil {
+		return nil, err
+	}
+	p.Filename = RedirectFilename
+	return p, nil
+}
+
+// LoadRedirect reads the redirect record in the thread directory dir. It
+// returns nil when there is none, or when it is not signed by the admin
+// (adminPubkey, as in GITORUM.toml) or cannot be parsed.
+func LoadRedirect(dir, keysDir, adminPubkey string) *RedirectRecord {
+	content, err := os.ReadFile(filepath.Join(dir, RedirectFilename))
+	if err != nil || adminPubkey == "" {
+		return nil
+	}
+	p, err := ParsePost(RedirectFilename, content)
+	if err != nil || !p.VerifyAdmin(keysDir, adminPubkey) {
+		return nil
+	}
+	var rec RedirectRecord
+	if _, err := toml.Decode(p.Body, &rec); err != nil || !validSlugPath(rec.To) || strings.Count(rec.To, "/") < 1 {
+		return nil
+	}
+	return &rec
+}
+
+// Redirected is where a thread's posts are after following its redirects.
+type Redirected struct {
+	Category string
+	Thread   string
+	Root     string // filename of the original root post, which a merge renames
+}
+
+// FollowRedirects follows the redirect records from the thread directory
+// category/thread under root until it reaches a thread. It reports false
+// when there is no valid redirect at the start, or the chain ends nowhere.
+func FollowRedirects(root, keysDir, adminPubkey, category, thread string) (Redirected, bool) {
+	at := Redirected{Category: category, Thread: thread, Root: RootFilename}
+	for i := 0; i <= maxRedirects; i++ {
+		dir := filepath.Join(root, filepath.FromSlash(at.Category), at.Thread)
+		if _, err := os.Stat(filepath.Join(dir, RootFilename)); err == nil {
+			return at, i > 0
+		}
+		rec := LoadRedirect(dir, keysDir, adminPubkey)
+		if rec == nil {
+			break
+		}
+		if rec.Action == RedirectMerge && at.Root == RootFilename {
+			at.Root = rec.Root
+		}
+		cat, slug := path.Split(rec.To)
+		at.Category, at.Thread = strings.TrimSuffix(cat, "/"), slug
+	}
+	return Redirected{}, false
+}
+
+// ThreadRelocation is a planned move or merge of a thread, ready to be
+// committed: the files to rename, and the redirect record to write in the
+// old thread directory. Paths are slash-separated and relative to the
+// repository root.
+type ThreadRelocation struct {
+	Moves        map[string]string // old path → new path; an empty new path removes the file
+	Redirect     *Post
+	RedirectPath string
+}
+
+// PlanMove plans moving the thread fromCat/fromThread of the forum checkout
+// at root to toCat/toThread, which renames it when the category is the
+// same. Every file in the thread moves unchanged, so signatures and parent
+// hashes stay valid.
+func PlanMove(adminID *crypto.Identity, root, fromCat, fromThread, toCat, toThread string) (*ThreadRelocation, error) {
+	from, to := path.Join(fromCat, fromThread), path.Join(toCat, toThread)
+	rootContent, entries, err := readRelocation(root, from, to)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(filepath.Join(root, filepath.FromSlash(to))); err == nil {
+		return nil, fmt.Errorf("%s already exists", to)
+	}
+	plan := &ThreadRelocation{Moves: map[string]string{}, RedirectPath: path.Join(from, RedirectFilename)}
+	for _, name := range entries {
+		plan.Moves[path.Join(from, name)] = path.Join(to, name)
+	}
+	plan.Redirect, err = SignRedirect(adminID, rootContent, RedirectRecord{Action: RedirectMove, To: to})
+	return plan, err
+}
+
+// PlanMerge plans merging the thread fromCat/fromThread into the existing
+// thread toCat/toThread. Posts and tombstones move unchanged; the old root
+// post becomes an ordinary post of the target thread, named after its
+// timestamp. Thread state and poll records describe a thread that no longer
+// exists and are removed.
+func PlanMerge(adminID *crypto.Identity, root, fromCat, fromThread, toCat, toThread string) (*ThreadRelocation, error) {
+	from, to := path.Join(fromCat, fromThread), path.Join(toCat, toThread)
+	rootContent, entries, err := readRelocation(root, from, to)
+	if err != nil {
+		return nil, err
+	}
+	toDir := filepath.Join(root, filepath.FromSlash(to))
+	if _, err := os.Stat(filepath.Join(toDir, RootFilename)); err != nil {
+		return nil, fmt.Errorf("%s is not a thread", to)
+	}
+	rootPost, err := ParsePost(RootFilename, rootContent)
+	if err != nil {
+		return nil, fmt.Errorf("root post of %s: %w", from, err)
+	}
+	newRoot := fmt.Sprintf("%d_%s.md", rootPost.Timestamp.UnixMilli(), rootPost.Hash[:8])
+
+	plan := &ThreadRelocation{Moves: map[string]string{}, RedirectPath: path.Join(from, RedirectFilename)}
+	for _, name := range entries {
+		var target string
+		switch {
+		case name == RootFilename:
+			target = newRoot
+		case name == TombstoneFilename(RootFilename):
+			target = TombstoneFilename(newRoot)
+		case strings.HasSuffix(name, ".md") || strings.HasSuffix(name, ".md.tomb"):
+			target = name
+		default:
+			plan.Moves[path.Join(from, name)] = ""
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(toDir, target)); err == nil {
+			return nil, fmt.Errorf("%s/%s already exists", to, target)
+		}
+		plan.Moves[path.Join(from, name)] = path.Join(to, target)
+	}
+	plan.Redirect, err = SignRedirect(adminID, rootContent, RedirectRecord{Action: RedirectMerge, To: to, Root: newRoot})
+	return plan, err
+}
+
+// readRelocation checks that from is a thread that can be moved to to and
+// returns its root post content and the names of its files.
+func readRelocation(root, from, to string) ([]byte, []string, error) {
+	if from == to {
+		return nil, nil, errors.New("source and destination are the same thread")
+	}
+	if cat, _ := path.Split(to); cat == "" {
+		return nil, nil, fmt.Errorf("%s is not a category/thread path", to)
+	} else if _, err := readCategoryMeta(filepath.Join(root, filepath.FromSlash(cat))); err != nil {
+		return nil, nil, fmt.Errorf("category %s not found", strings.TrimSuffix(cat, "/"))
+	}
+	dir := filepath.Join(root, filepath.FromSlash(from))
+	rootContent, err := os.ReadFile(filepath.Join(dir, RootFilename))
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s is not a thread", from)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read %s: %w", from, err)
+	}
+	var names []string
+	for _, e := range entries {
+		if e.IsDir() {
+			return nil, nil, fmt.Errorf("%s contains a directory", from)
+		}
+		names = append(names, e.Name())
+	}
+	return rootContent, names, nil
+}
@@ -2,18 +2,239 @@ package forum
 
 import (
 	"bytes"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
 
+	chromahtml "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/microcosm-cc/bluemonday"
 	"github.com/yuin/goldmark"
+	highlighting "github.com/yuin/goldmark-highlighting/v2"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	gmhtml "github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
 )
 
-var mdRenderer = goldmark.New()
+// RenderOptions configures how post bodies are turned into HTML. GitHub
+// Flavored Markdown (tables, task lists, strikethrough, autolinks) is always
+// enabled, raw HTML is never passed through, and the output is sanitized.
+type RenderOptions struct {
+	Highlight      bool     // highlight fenced code blocks that name a known language
+	HeadingAnchors bool     // give headings an id and a link to themselves
+	URLSchemes     []string // schemes allowed in link and image URLs; relative URLs are always allowed
+}
+
+// DefaultRenderOptions returns the options used unless SetRenderOptions is
+// called: highlighting and anchors on, and only http, https and mailto URLs.
+func DefaultRenderOptions() RenderOptions {
+	return RenderOptions{
+		Highlight:      true,
+		HeadingAnchors: true,
+		URLSchemes:     []string{"http", "https", "mailto"},
+	}
+}
+
+// markdownRenderer is a goldmark pipeline followed by a sanitizer pass.
+type markdownRenderer struct {
+	md     goldmark.Markdown
+	policy *bluemonday.Policy
+}
+
+var mdRenderer = newMarkdownRenderer(DefaultRenderOptions())
+
+// SetRenderOptions replaces the rendering pipeline used for every post
+// parsed afterwards. It is meant to be called once at startup, before any
+// post is loaded.
+func SetRenderOptions(opts RenderOptions) {
+	mdRenderer = newMarkdownRenderer(opts)
+}
+
+func newMarkdownRenderer(opts RenderOptions) *markdownRenderer {
+	parserOpts := []parser.Option{
+		parser.WithInlineParsers(
+			util.Prioritized(mentionParser{}, 500),
+			util.Prioritized(xrefParser{}, 199), // before the link parser
+		),
+		parser.WithASTTransformers(util.Prioritized(quoteTransformer{}, 500)),
+	}
+	nodeRenderers := []util.PrioritizedValue{
+		util.Prioritized(mentionRenderer{}, 500),
+		util.Prioritized(quoteRenderer{}, 500),
+		util.Prioritized(xrefRenderer{}, 500),
+	}
+	if opts.HeadingAnchors {
+		parserOpts = append(parserOpts, parser.WithAutoHeadingID())
+		nodeRenderers = append(nodeRenderers, util.Prioritized(headingRenderer{}, 500))
+	}
+	extensions := []goldmark.Extender{
+		extension.NewTable(extension.WithTableCellAlignMethod(extension.TableCellAlignAttribute)),
+		extension.Strikethrough,
+		extension.Linkify,
+		extension.TaskList,
+	}
+	if opts.Highlight {
+		extensions = append(extensions, highlighting.NewHighlighting(
+			highlighting.WithFormatOptions(chromahtml.WithClasses(true)),
+		))
+	}
+	return &markdownRenderer{
+		md: goldmark.New(
+			goldmark.WithExtensions(extensions...),
+			goldmark.WithParserOptions(parserOpts...),
+			goldmark.WithRendererOptions(renderer.WithNodeRenderers(nodeRenderers...)),
+		),
+		policy: sanitizePolicy(opts.URLSchemes),
+	}
+}
+
+// classRe matches the class names the renderer emits: mentions, quotes,
+// cross-links, heading anchors, task list items and chroma token classes.
+var classRe = regexp.MustCompile(`^[a-zA-Z0-9_ -]+$`)
+
+// sanitizePolicy allows exactly the markup the renderer produces, with URLs
+// limited to relative ones and the given schemes.
+func sanitizePolicy(schemes []string) *bluemonday.Policy {
+	p := bluemonday.NewPolicy()
+	p.RequireParseableURLs(true)
+	p.AllowRelativeURLs(true)
+	p.AllowURLSchemes(schemes...)
+	p.RequireNoFollowOnFullyQualifiedLinks(true)
+	p.AddTargetBlankToFullyQualifiedLinks(true)
+
+	p.AllowElements("p", "br", "hr", "blockquote", "em", "strong", "del", "ul", "li",
+		"thead", "tbody", "tr")
+	p.AllowAttrs("id").Matching(regexp.MustCompile(`^h-[\pL\pN_-]+$`)).OnElements("h1", "h2", "h3", "h4", "h5", "h6")
+	p.AllowAttrs("start").Matching(bluemonday.Integer).OnElements("ol")
+	p.AllowAttrs("href", "title").OnElements("a")
+	p.AllowAttrs("title").OnElements("span")
+	p.AllowAttrs("src", "alt", "title").OnElements("img")
+	p.AllowAttrs("align").Matching(regexp.MustCompile(`^(left|center|right)$`)).OnElements("th", "td")
+	p.AllowAttrs("class").Matching(classRe).OnElements("a", "pre", "code", "span", "li", "blockquote", "p")
+	p.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	p.AllowAttrs("checked", "disabled").Matching(regexp.MustCompile(`^$`)).OnElements("input")
+	p.AllowElements("h1", "h2", "h3", "h4", "h5", "h6", "ol", "a", "img", "table", "th", "td",
+		"pre", "code", "span")
+	return p
+}
 
 // renderMarkdown converts Markdown source to an HTML string.
-// On error it returns the original body unmodified.
 func renderMarkdown(body string) string {
+	html, _ := renderWithMentions(body, nil)
+	return html
+}
+
+// renderWithMentions converts Markdown source to HTML, linking @mentions of
+// the given users, and returns the mentioned usernames in order of first
+// appearance. On error it returns the body as escaped text.
+func renderWithMentions(body string, users map[string]bool) (string, []string) {
+	html, mentions, _ := renderPost(body, users, nil)
+	return html, mentions
+}
+
+// renderPost is renderWithMentions that also resolves quotes and
+// cross-links against index, returning the quotes in order of appearance. A
+// nil index leaves both unresolved.
+func renderPost(body string, users map[string]bool, index *PostIndex) (string, []string, []*Quote) {
+	r := mdRenderer
+	src := []byte(body)
+	ctx := parser.NewContext(parser.WithIDs(newHeadingIDs()))
+	ctx.Set(knownUsersKey, users)
+	ctx.Set(postIndexKey, index)
+	doc := r.md.Parser().Parse(text.NewReader(src), parser.WithContext(ctx))
+
+	var mentions []string
+	var quotes []*Quote
+	seen := make(map[string]bool)
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := n.(type) {
+		case *mentionNode:
+			if !seen[n.Username] {
+				seen[n.Username] = true
+				mentions = append(mentions, n.Username)
+			}
+		case *quoteNode:
+			quotes = append(quotes, n.Quote)
+		}
+		return ast.WalkContinue, nil
+	})
+
 	var buf bytes.Buffer
-	if err := mdRenderer.Convert([]byte(body), &buf); err != nil {
-		return body
+	if err := r.md.Renderer().Render(&buf, src, doc); err != nil {
+		return "<p>" + html.EscapeString(body) + "</p>", nil, nil
+	}
+	return r.policy.Sanitize(buf.String()), mentions, quotes
+}
+
+// headingIDs generates heading ids prefixed with "h-", so that they cannot
+// clash with the ids the web UI gives its own elements.
+type headingIDs struct {
+	used map[string]bool
+}
+
+func newHeadingIDs() *headingIDs {
+	return &headingIDs{used: map[string]bool{}}
+}
+
+func (ids *headingIDs) Generate(value []byte, kind ast.NodeKind) []byte {
+	var sb strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(string(value)) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_':
+			if dash && sb.Len() > 0 {
+				sb.WriteByte('-')
+			}
+			sb.WriteRune(r)
+			dash = false
+		default:
+			dash = true
+		}
+	}
+	base := "h-" + sb.String()
+	if sb.Len() == 0 {
+		base = "h-section"
+	}
+	id := base
+	for i := 1; ids.used[id]; i++ {
+		id = fmt.Sprintf("%s-%d", base, i)
 	}
-	return buf.String()
+	ids.used[id] = true
+	return []byte(id)
+}
+
+func (ids *headingIDs) Put(value []byte) {
+	ids.used[string(value)] = true
+}
+
+// headingRenderer renders headings with a trailing link to themselves.
+type headingRenderer struct{}
+
+func (headingRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindHeading, func(w util.BufWriter, _ []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+		n := node.(*ast.Heading)
+		if entering {
+			fmt.Fprintf(w, "<h%d", n.Level)
+			if n.Attributes() != nil {
+				gmhtml.RenderAttributes(w, n, gmhtml.HeadingAttributeFilter)
+			}
+			_ = w.WriteByte('>')
+			return ast.WalkContinue, nil
+		}
+		if id, ok := n.AttributeString("id"); ok {
+			if b, ok := id.([]byte); ok {
+				fmt.Fprintf(w, ` <a class="heading-anchor" href="#%s">#</a>`, html.EscapeString(string(b)))
+			}
+		}
+		fmt.Fprintf(w, "</h%d>\n", n.Level)
+		return ast.WalkContinue, nil
+	})
 }
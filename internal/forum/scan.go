@@ -15,12 +15,22 @@ type ThreadScan struct {
 	Slug        string
 	Root        *Post  // nil if 0000_root.md is missing or unparseable
 	ReplyCount  int
-	LastReplyAt string // RFC3339 timestamp of newest reply, or root's timestamp
+	LastReplyAt string   // RFC3339 timestamp of newest reply, or root's timestamp
+	Filenames   []string // root and non-tombstoned replies, oldest first
+	State       *ThreadState
 }
 
 // ScanThread reads only 0000_root.md from dir and counts reply files.
 // It is much cheaper than LoadThread for building thread-list views.
 func ScanThread(slug, dir, keysDir string) (*ThreadScan, error) {
+	return ScanThreadWith(slug, dir, keysDir, LoadOptions{})
+}
+
+// ScanThreadWith is ScanThread with forum settings applied; it also loads
+// the admin-signed thread state, flags a root that breaks the category's
+// write policy, and leaves replies by banned or muted users, or lacking the
+// required proof of work, out of the counts.
+func ScanThreadWith(slug, dir, keysDir string, opts LoadOptions) (*ThreadScan, error) {
 	rootPath := filepath.Join(dir, RootFilename)
 	content, err := os.ReadFile(rootPath)
 	if err != nil {
@@ -31,6 +41,10 @@ func ScanThread(slug, dir, keysDir string) (*ThreadScan, error) {
 		return nil, fmt.Errorf("parse root post: %w", err)
 	}
 	root.VerifySignature(keysDir)
+	opts.hidePost(root)
+	if opts.Permissions != nil {
+		opts.Permissions.checkPost(root, true, keysDir, opts.AdminPubkey)
+	}
 
 	entries, err := os.ReadDir(dir)
 	if err != nil {
@@ -39,6 +53,7 @@ func ScanThread(slug, dir, keysDir string) (*ThreadScan, error) {
 
 	replyCount := 0
 	lastAt := root.TimestampRaw
+	filenames := []string{RootFilename}
 	for _, e := range entries {
 		name := e.Name()
 		if e.IsDir() || !strings.HasSuffix(name, ".md") || name == RootFilename {
@@ -48,20 +63,60 @@ func ScanThread(slug, dir, keysDir string) (*ThreadScan, error) {
 		if _, err := os.Stat(filepath.Join(dir, TombstoneFilename(name))); err == nil {
 			continue
 		}
+		if opts.hides() && hiddenReply(filepath.Join(dir, name), keysDir, opts) {
+			continue
+		}
 		replyCount++
+		filenames = append(filenames, name)
 		if ts, ok := parseFilenameTime(name); ok {
 			lastAt = ts.UTC().Format(time.RFC3339)
 		}
 	}
 
+	state, err := LoadThreadState(dir, keysDir, opts.AdminPubkey)
+	if err != nil {
+		return nil, err
+	}
+
 	return &ThreadScan{
 		Slug:        slug,
+		State:       state,
 		Root:        root,
 		ReplyCount:  replyCount,
 		LastReplyAt: lastAt,
+		Filenames:   filenames,
 	}, nil
 }
 
+// hiddenReply reports whether the reply at path is by a banned or muted
+// author, or lacks the proof of work opts requires. Unreadable replies are
+// not hidden.
+func hiddenReply(path, keysDir string, opts LoadOptions) bool {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return false
+	}
+	if opts.Work > 0 {
+		// Checking the work needs the signature status, so parse in full.
+		// A malformed reply carries no work.
+		p, err := ParsePost(filepath.Base(path), content)
+		if err != nil {
+			return opts.hidePost(&Post{Filename: filepath.Base(path), SigStatus: SigInvalid})
+		}
+		p.VerifySignature(keysDir)
+		return opts.hidePost(p)
+	}
+	fm, _, err := parseFrontMatter(content)
+	if err != nil {
+		return false
+	}
+	ts, err := time.Parse(time.RFC3339, fm.Timestamp)
+	if err != nil {
+		return false
+	}
+	return opts.hidePost(&Post{Author: fm.Author, PubKey: fm.PubKey, Timestamp: ts})
+}
+
 // parseFilenameTime extracts the UTC timestamp embedded in a reply filename.
 // Format: {unix_millis}_{hash8}.md
 func parseFilenameTime(name string) (time.Time, bool) {
@@ -0,0 +1,138 @@
+package forum
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/gosub/gitorum/internal/crypto"
+)
+
+// StateExt is the extension of admin-signed thread state records.
+const StateExt = ".state"
+
+// StateRecord is the body of a thread state record: a full snapshot of the
+// thread's moderation flags, written as TOML.
+type StateRecord struct {
+	Pinned   bool `toml:"pinned"`
+	Locked   bool `toml:"locked"`
+	Archived bool `toml:"archived"`
+}
+
+// ThreadState is the moderation state of a thread, derived from the valid
+// state records in its directory. The newest record wins.
+type ThreadState struct {
+	StateRecord
+	ChangedAt time.Time // time of the newest valid record; zero when there is none
+	ChangedBy string    // author of the newest valid record
+
+	closed []interval // periods during which the thread was locked or archived
+}
+
+type interval struct {
+	from, to time.Time // to is zero while the period is still open
+}
+
+// Closed reports whether the thread is currently locked or archived, in
+// which case it takes no new replies.
+func (st *ThreadState) Closed() bool {
+	return st.Locked || st.Archived
+}
+
+// ClosedAt reports whether the thread was locked or archived at time t.
+func (st *ThreadState) ClosedAt(t time.Time) bool {
+	for _, iv := range st.closed {
+		if !t.Before(iv.from) && (iv.to.IsZero() || t.Before(iv.to)) {
+			return true
+		}
+	}
+	return false
+}
+
+// SignThreadState creates a state record for the thread whose root file
+// content is rootContent. The record's parent is the root hash, which ties
+// it to that thread. The caller must set Filename (see NewStateFilename)
+// before writing to disk.
+func SignThreadState(adminID *crypto.Identity, rootContent []byte, rec StateRecord) (*Post, error) {
+	var sb strings.Builder
+	if err := toml.NewEncoder(&sb).Encode(rec); err != nil {
+		return nil, fmt.Errorf("encode state: %w", err)
+	}
+	// ParsePost drops the trailing newline, so leave it out of the signed body.
+	return SignPost(adminID, PostHash(rootContent), strings.TrimSuffix(sb.String(), "\n"))
+}
+
+// NewStateFilename generates the filename for a new state record.
+// Format: {unix_millis}_{sha256_of_body[:8]}.state
+func NewStateFilename(body string) string {
+	h := sha256.Sum256([]byte(body))
+	return fmt.Sprintf("%d_%s%s", time.Now().UnixMilli(), hex.EncodeToString(h[:])[:8], StateExt)
+}
+
+// LoadThreadState reads the state records in dir and replays those signed by
+// the forum admin (adminPubkey, as in GITORUM.toml) in chronological order.
+// Records that are not admin-signed, do not reference this thread's root, or
+// cannot be parsed are ignored. Without an admin key no record is trusted.
+func LoadThreadState(dir, keysDir, adminPubkey string) (*ThreadState, error) {
+	st := &ThreadState{}
+	if adminPubkey == "" {
+		return st, nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read thread dir: %w", err)
+	}
+	rootContent, err := os.ReadFile(filepath.Join(dir, RootFilename))
+	if err != nil {
+		return nil, fmt.Errorf("read root post: %w", err)
+	}
+	rootHash := PostHash(rootContent)
+
+	var records []*Post
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasSuffix(name, StateExt) {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			continue
+		}
+		p, err := ParsePost(name, content)
+		if err != nil || p.Parent != rootHash || !p.VerifyAdmin(keysDir, adminPubkey) {
+			continue
+		}
+		records = append(records, p)
+	}
+	sort.Slice(records, func(i, j int) bool {
+		if !records[i].Timestamp.Equal(records[j].Timestamp) {
+			return records[i].Timestamp.Before(records[j].Timestamp)
+		}
+		return records[i].Filename < records[j].Filename
+	})
+
+	for _, p := range records {
+		var rec StateRecord
+		if _, err := toml.Decode(p.Body, &rec); err != nil {
+			continue
+		}
+		wasClosed := st.Closed()
+		st.StateRecord = rec
+		st.ChangedAt = p.Timestamp
+		st.ChangedBy = p.Author
+		switch {
+		case !wasClosed && st.Closed():
+			st.closed = append(st.closed, interval{from: p.Timestamp})
+		case wasClosed && !st.Closed():
+			st.closed[len(st.closed)-1].to = p.Timestamp
+		}
+	}
+	return st, nil
+}
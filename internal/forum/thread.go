@@ -18,19 +18,35 @@ const RootFilename = "0000_root.md"
 type Thread struct {
 	Category string
 	Slug     string
-	Root     *Post   // convenience pointer; nil when the root post is missing
-	Posts    []*Post // root first, then replies sorted by Timestamp ascending
+	Root     *Post       // convenience pointer; nil when the root post is missing
+	Posts    []*Post     // root first, then replies sorted by Timestamp ascending
+	Poll     *PollResult // tally of the poll declared by the root; nil otherwise
+	State    *ThreadState
 }
 
 // LoadThread reads every .md file in dir, parses and signature-verifies each
 // one, then returns a Thread with posts sorted root-first, then by timestamp.
+// It applies no moderation; see LoadThreadWith.
 func LoadThread(category, slug, dir, keysDir string) (*Thread, error) {
+	return LoadThreadWith(category, slug, dir, keysDir, LoadOptions{})
+}
+
+// LoadThreadWith is LoadThread with forum settings applied: it loads the
+// admin-signed thread state and flags posts that break it or the category's
+// write policy, posts by banned or muted users, and posts by authors without
+// a key that lack the required proof of work.
+func LoadThreadWith(category, slug, dir, keysDir string, opts LoadOptions) (*Thread, error) {
 	entries, err := os.ReadDir(dir)
 	if err != nil {
 		return nil, fmt.Errorf("read thread dir %s: %w", dir, err)
 	}
 
 	t := &Thread{Category: category, Slug: slug}
+	users := KnownUsers(keysDir)
+	index := opts.Index
+	if index == nil {
+		index = NewPostIndex(filepath.Dir(keysDir), keysDir, opts.AdminPubkey) // keys/ is at the repository root
+	}
 
 	for _, entry := range entries {
 		name := entry.Name()
@@ -59,6 +75,7 @@ func LoadThread(category, slug, dir, keysDir string) (*Thread, error) {
 			post = &Post{Filename: name, SigStatus: SigInvalid, SigError: err.Error()}
 		} else {
 			post.VerifySignature(keysDir)
+			post.ResolveReferences(users, index)
 		}
 		t.Posts = append(t.Posts, post)
 	}
@@ -76,6 +93,37 @@ func LoadThread(category, slug, dir, keysDir string) (*Thread, error) {
 	if len(t.Posts) > 0 && t.Posts[0].Filename == RootFilename {
 		t.Root = t.Posts[0]
 	}
+	if t.Root != nil {
+		if t.State, err = LoadThreadState(dir, keysDir, opts.AdminPubkey); err != nil {
+			return nil, fmt.Errorf("thread state: %w", err)
+		}
+	} else {
+		t.State = &ThreadState{}
+	}
+	for _, p := range t.Posts {
+		opts.hidePost(p)
+	}
+	if opts.Permissions != nil {
+		for _, p := range t.Posts {
+			opts.Permissions.checkPost(p, p == t.Root, keysDir, opts.AdminPubkey)
+		}
+	}
+	for _, p := range t.Posts {
+		if p == t.Root || p.Tombstoned || p.Flag != "" {
+			continue
+		}
+		if t.State.ClosedAt(opts.arrivedAt(dir, keysDir, p.Filename, p.Timestamp)) && !p.VerifyAdmin(keysDir, opts.AdminPubkey) {
+			p.Flag = FlagLocked
+			p.FlagReason = "posted while the thread was locked"
+		}
+	}
+	if t.Root != nil && t.Root.IsPoll() && t.Root.SigStatus == SigValid {
+		poll, err := tallyPoll(dir, keysDir, opts, t.State)
+		if err != nil {
+			return nil, fmt.Errorf("tally poll: %w", err)
+		}
+		t.Poll = poll
+	}
 	return t, nil
 }
 
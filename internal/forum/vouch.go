@@ -0,0 +1,275 @@
+package forum
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/gosub/gitorum/internal/crypto"
+)
+
+// VouchesDir is the repository directory holding one vouch list per user.
+const VouchesDir = "vouches"
+
+// DefaultTrustHops is how far trust reaches through vouches by default: the
+// keys you vouch for and the keys they vouch for.
+const DefaultTrustHops = 2
+
+// Vouch is a user's statement that PubKey belongs to Username.
+type Vouch struct {
+	Username string `toml:"username" json:"username"`
+	PubKey   string `toml:"pubkey" json:"pubkey"` // base64 key vouched for
+	Note     string `toml:"note" json:"note"`     // e.g. how the key was checked
+	Since    string `toml:"since" json:"since"`   // RFC3339 time the vouch was made
+}
+
+// VouchList is the content of vouches/<username>.toml. The whole list is
+// signed by its owner and replaced on every change, so withdrawing a vouch
+// is removing it from the list.
+type VouchList struct {
+	Vouches   []Vouch `toml:"vouch"`
+	SignedBy  string  `toml:"signed_by"`
+	Timestamp string  `toml:"timestamp"`
+	Signature string  `toml:"signature"`
+}
+
+// VouchPath returns the repository-relative path of username's vouch list.
+func VouchPath(username string) string {
+	return path.Join(VouchesDir, username+".toml")
+}
+
+// SignVouchList creates a vouch list signed by id.
+func SignVouchList(id *crypto.Identity, vouches []Vouch) (*VouchList, error) {
+	for _, v := range vouches {
+		if v.Username == "" {
+			return nil, errors.New("vouch without username")
+		}
+		if v.Username == id.Username || v.PubKey == id.PublicKey {
+			return nil, errors.New("you cannot vouch for yourself")
+		}
+		if err := checkPubKey(v.PubKey); err != nil {
+			return nil, fmt.Errorf("vouch for %s: %w", v.Username, err)
+		}
+		if _, err := time.Parse(time.RFC3339, v.Since); err != nil {
+			return nil, fmt.Errorf("vouch for %s: since: %w", v.Username, err)
+		}
+	}
+	l := &VouchList{
+		Vouches:   vouches,
+		SignedBy:  id.Username,
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+	}
+	priv, err := id.PrivKey()
+	if err != nil {
+		return nil, fmt.Errorf("get private key: %w", err)
+	}
+	l.Signature = crypto.Sign(priv, l.canonical())
+	return l, nil
+}
+
+// LoadVouchList reads the vouch list of username at path and verifies it
+// against pubkey, username's base64 key. A missing file yields an empty
+// list; a list signed by someone else is an error.
+func LoadVouchList(path, username, pubkey string) (*VouchList, error) {
+	var l VouchList
+	if _, err := toml.DecodeFile(path, &l); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return &VouchList{}, nil
+		}
+		return nil, fmt.Errorf("read vouches of %s: %w", username, err)
+	}
+	if l.SignedBy != username {
+		return nil, fmt.Errorf("vouches of %s are signed by %s", username, l.SignedBy)
+	}
+	if err := crypto.VerifyWithPublicKeyB64(pubkey, l.canonical(), l.Signature); err != nil {
+		return nil, fmt.Errorf("vouches of %s: %w", username, err)
+	}
+	return &l, nil
+}
+
+// Format serializes the list to the vouch list format.
+func (l *VouchList) Format() []byte {
+	var sb strings.Builder
+	_ = toml.NewEncoder(&sb).Encode(l)
+	return []byte(sb.String())
+}
+
+func (l *VouchList) canonical() []byte {
+	vouches, _ := json.Marshal(l.Vouches)
+	return crypto.CanonicalForm(map[string]string{
+		"vouches":   string(vouches),
+		"signed_by": l.SignedBy,
+		"timestamp": l.Timestamp,
+	}, "")
+}
+
+// Find returns the vouch for username, or nil.
+func (l *VouchList) Find(username string) *Vouch {
+	for i, v := range l.Vouches {
+		if v.Username == username {
+			return &l.Vouches[i]
+		}
+	}
+	return nil
+}
+
+// With returns the vouches of l with v added, replacing any earlier vouch
+// for the same username.
+func (l *VouchList) With(v Vouch) []Vouch {
+	vouches, _ := l.Without(v.Username)
+	return append(vouches, v)
+}
+
+// Without returns the vouches of l minus the one for username, and whether
+// such a vouch existed.
+func (l *VouchList) Without(username string) ([]Vouch, bool) {
+	var vouches []Vouch
+	found := false
+	for _, v := range l.Vouches {
+		if v.Username == username {
+			found = true
+			continue
+		}
+		vouches = append(vouches, v)
+	}
+	return vouches, found
+}
+
+func checkPubKey(pubkeyB64 string) error {
+	b, err := base64.StdEncoding.DecodeString(pubkeyB64)
+	if err != nil {
+		return fmt.Errorf("decode public key: %w", err)
+	}
+	if len(b) != ed25519.PublicKeySize {
+		return fmt.Errorf("public key: expected %d bytes, got %d", ed25519.PublicKeySize, len(b))
+	}
+	return nil
+}
+
+// TrustedKey is a key reached through vouches from the root of a web of
+// trust.
+type TrustedKey struct {
+	Username string
+	PubKey   string
+	// Path lists the users along the chain of vouches, from the first one
+	// the root vouched for down to Username; its length is the number of
+	// hops.
+	Path []string
+}
+
+// WebOfTrust is the set of keys one identity trusts: those it vouches for
+// directly, and those vouched for by keys it trusts, up to a number of
+// hops.
+type WebOfTrust struct {
+	Root string
+	Keys map[string]*TrustedKey // by username; includes Root with an empty Path
+	// Errors lists vouch lists along the way that could not be read or did
+	// not verify; they are skipped.
+	Errors []string
+}
+
+// BuildWebOfTrust walks the vouch lists in vouchesDir breadth first from
+// root, whose base64 key is rootPubkey, up to hops vouches away. Each vouch
+// list is checked against the key through which it was reached. When
+// several keys are vouched for under the same username, the one closest to
+// root wins, and between equally close ones the vouch from the
+// alphabetically first user.
+func BuildWebOfTrust(vouchesDir, root, rootPubkey string, hops int) *WebOfTrust {
+	self := &TrustedKey{Username: root, PubKey: rootPubkey}
+	w := &WebOfTrust{Root: root, Keys: map[string]*TrustedKey{root: self}}
+	frontier := []*TrustedKey{self}
+	for hop := 0; hop < hops && len(frontier) > 0; hop++ {
+		var next []*TrustedKey
+		for _, k := range frontier {
+			l, err := LoadVouchList(filepath.Join(vouchesDir, k.Username+".toml"), k.Username, k.PubKey)
+			if err != nil {
+				w.Errors = append(w.Errors, err.Error())
+				continue
+			}
+			for _, v := range l.Vouches {
+				if w.Keys[v.Username] != nil || checkPubKey(v.PubKey) != nil {
+					continue
+				}
+				t := &TrustedKey{
+					Username: v.Username,
+					PubKey:   v.PubKey,
+					Path:     append(append([]string(nil), k.Path...), v.Username),
+				}
+				w.Keys[v.Username] = t
+				next = append(next, t)
+			}
+		}
+		sort.Slice(next, func(i, j int) bool { return next[i].Username < next[j].Username })
+		frontier = next
+	}
+	return w
+}
+
+// Vouched returns the trusted key that made p's signature, or nil when
+// p's author is not in the web of trust or the signature does not verify
+// with the trusted key.
+func (w *WebOfTrust) Vouched(p *Post) *TrustedKey {
+	if w == nil || p.Tombstoned {
+		return nil
+	}
+	k := w.Keys[p.Author]
+	if k == nil || crypto.Fingerprint(k.PubKey) != p.PubKey {
+		return nil
+	}
+	if crypto.VerifyWithPublicKeyB64(k.PubKey, crypto.CanonicalForm(p.canonicalFields(), p.Body), p.Signature) != nil {
+		return nil
+	}
+	return k
+}
+
+// Voucher is an approved user's vouch for a key.
+type Voucher struct {
+	Username string    // the user who vouches
+	Since    time.Time // when they made the vouch
+}
+
+// VouchesFor returns, sorted by username, the users with a key in keysDir
+// whose vouch list in vouchesDir verifies against that key and vouches for
+// pubkey as username's key.
+func VouchesFor(vouchesDir, keysDir, username, pubkey string) ([]Voucher, error) {
+	entries, err := os.ReadDir(vouchesDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read vouches dir: %w", err)
+	}
+	var out []Voucher
+	for _, e := range entries {
+		voucher, ok := strings.CutSuffix(e.Name(), ".toml")
+		if e.IsDir() || !ok || voucher == username {
+			continue
+		}
+		key, err := os.ReadFile(filepath.Join(keysDir, voucher+".pub"))
+		if err != nil {
+			continue // only approved users count
+		}
+		l, err := LoadVouchList(filepath.Join(vouchesDir, e.Name()), voucher, strings.TrimSpace(string(key)))
+		if err != nil {
+			continue
+		}
+		v := l.Find(username)
+		if v == nil || v.PubKey != pubkey {
+			continue
+		}
+		if since, err := time.Parse(time.RFC3339, v.Since); err == nil {
+			out = append(out, Voucher{Username: voucher, Since: since})
+		}
+	}
+	return out, nil // ReadDir sorts by name
+}
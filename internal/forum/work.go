@@ -0,0 +1,86 @@
+package forum
+
+import (
+	"crypto/sha256"
+	"math/bits"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gosub/gitorum/internal/crypto"
+)
+
+// FlagNoWork marks a post that does not verify against keys/ and does not
+// carry the proof of work the forum requires of such posts.
+const FlagNoWork = "no-work"
+
+// MaxWork is the largest proof of work, in bits, a forum may require. It
+// already costs about 2^32 hashes per post; GITORUM.toml can be edited by
+// hand or pulled from a peer, so signers refuse anything beyond it rather
+// than search for a stamp that may never be found.
+const MaxWork = 32
+
+// SignOptions carries forum settings that affect how posts are signed. The
+// zero value signs without proof of work.
+type SignOptions struct {
+	// Work is the proof of work, in leading zero bits, the forum requires
+	// of posts by authors whose key is not in KeysDir; 0 for none.
+	Work    int
+	KeysDir string
+}
+
+// needsWork reports whether posts signed by id under opts have to carry a
+// proof of work.
+func (opts SignOptions) needsWork(id *crypto.Identity) bool {
+	if opts.Work <= 0 {
+		return false
+	}
+	data, err := os.ReadFile(filepath.Join(opts.KeysDir, id.Username+".pub"))
+	return err != nil || strings.TrimSpace(string(data)) != id.PublicKey
+}
+
+// A proof of work is a hashcash-style stamp: the work field holds a counter
+// such that SHA-256 of the post's canonical form (without the work field)
+// followed by the counter starts with enough zero bits. The stamp covers
+// the author, timestamp, parent and body, so it cannot be reused for
+// another post, and it is itself covered by the signature.
+
+// addWork finds a stamp worth at least n bits and stores it in p.Work. It
+// takes about 2^n hashes.
+func (p *Post) addWork(n int) {
+	p.Work = ""
+	base := crypto.CanonicalForm(p.canonicalFields(), p.Body)
+	for i := uint64(0); ; i++ {
+		stamp := strconv.FormatUint(i, 36)
+		if workBits(base, stamp) >= n {
+			p.Work = stamp
+			return
+		}
+	}
+}
+
+// workBits returns the bits of work in p's stamp, 0 when it has none.
+func (p *Post) workBits() int {
+	if p.Work == "" {
+		return 0
+	}
+	fields := p.canonicalFields()
+	delete(fields, "work")
+	return workBits(crypto.CanonicalForm(fields, p.Body), p.Work)
+}
+
+// workBits counts the leading zero bits of SHA-256(base || stamp).
+func workBits(base []byte, stamp string) int {
+	h := sha256.New()
+	h.Write(base)
+	h.Write([]byte(stamp))
+	n := 0
+	for _, b := range h.Sum(nil) {
+		if b != 0 {
+			return n + bits.LeadingZeros8(b)
+		}
+		n += 8
+	}
+	return n
+}
@@ -0,0 +1,202 @@
+package forum
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// A cross-link is written [[REF]] or [[REF|label]], where REF is a category
+// slug, category/thread, category/thread/filename or the PostHash of a post.
+// Resolved links show the label, or else the title of what they point at.
+var xrefRe = regexp.MustCompile(`^\[\[([^\s|\[\]]+)(?:\|([^\[\]\n]*))?\]\]`)
+
+// KindXRef is the goldmark node kind of a cross-link.
+var KindXRef = ast.NewNodeKind("XRef")
+
+// xrefNode is a cross-link; Target is nil until it is resolved.
+type xrefNode struct {
+	ast.BaseInline
+	Ref    string
+	Label  string
+	Target *Target
+}
+
+func (n *xrefNode) Kind() ast.NodeKind { return KindXRef }
+
+func (n *xrefNode) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Ref": n.Ref, "Label": n.Label}, nil)
+}
+
+// xrefParser recognises "[[...]]". It runs before the link parser, which
+// would otherwise read the brackets as a link.
+type xrefParser struct{}
+
+func (xrefParser) Trigger() []byte { return []byte{'['} }
+
+func (xrefParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, _ := block.PeekLine()
+	m := xrefRe.FindSubmatch(line)
+	if m == nil {
+		return nil
+	}
+	block.Advance(len(m[0]))
+	n := &xrefNode{Ref: string(m[1]), Label: strings.TrimSpace(string(m[2]))}
+	if index, _ := pc.Get(postIndexKey).(*PostIndex); index != nil {
+		n.Target = index.Resolve(n.Ref)
+	}
+	return n
+}
+
+// xrefRenderer renders cross-links as links to the web UI.
+type xrefRenderer struct{}
+
+func (xrefRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindXRef, func(w util.BufWriter, _ []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		n := node.(*xrefNode)
+		t := n.Target
+		inLink := false
+		for p := n.Parent(); p != nil; p = p.Parent() {
+			inLink = inLink || p.Kind() == ast.KindLink || p.Kind() == ast.KindAutoLink
+		}
+		switch {
+		case t == nil || inLink:
+			_, _ = w.WriteString(html.EscapeString(n.text()))
+		case t.Kind == "":
+			fmt.Fprintf(w, `<span class="xref-missing" title="no such category, thread or post">[[%s]]</span>`,
+				html.EscapeString(n.text()))
+		default:
+			label := n.Label
+			if label == "" {
+				label = t.Title
+				if t.Kind == TargetPost {
+					label += " › @" + t.Author
+				}
+			}
+			fmt.Fprintf(w, `<a class="xref xref-%s" href="%s" title="%s">%s</a>`,
+				t.Kind, html.EscapeString(t.Link()), html.EscapeString(t.Title), html.EscapeString(label))
+		}
+		return ast.WalkContinue, nil
+	})
+}
+
+// text is how an unresolved link reads: its label, or else its reference.
+func (n *xrefNode) text() string {
+	if n.Label != "" {
+		return n.Label
+	}
+	return n.Ref
+}
+
+// Backlink is a post that links to, or quotes from, another thread.
+type Backlink struct {
+	Category  string // location of the linking post
+	Thread    string
+	Filename  string
+	Title     string // title of the linking post's thread
+	Author    string
+	Timestamp time.Time
+	Target    string // filename of the referenced post; empty for links to the thread itself
+}
+
+// Backlinks indexes, for every thread, the validly signed posts in other
+// threads that link to it or quote one of its posts.
+type Backlinks struct {
+	byThread map[string][]Backlink // category/thread → links, oldest first
+}
+
+// BuildBacklinks scans every post in the forum checkout at root, verifying
+// signatures against keysDir and redirects against adminPubkey.
+func BuildBacklinks(root, keysDir, adminPubkey string) *Backlinks {
+	b := &Backlinks{byThread: map[string][]Backlink{}}
+	index := NewPostIndex(root, keysDir, adminPubkey)
+	walkPosts(root, func(rel string, content []byte) {
+		if !bytes.Contains(content, []byte("[[")) && !bytes.Contains(content, []byte("[!quote")) {
+			return
+		}
+		if _, err := os.Stat(filepath.Join(root, filepath.FromSlash(rel)) + ".tomb"); err == nil {
+			return
+		}
+		p, err := ParsePost(path.Base(rel), content)
+		if err != nil {
+			return
+		}
+		if p.VerifySignature(keysDir); p.SigStatus != SigValid {
+			return
+		}
+		cat, thread, file := splitPostPath(rel)
+		seen := map[string]bool{}
+		for _, ref := range references(p.Body) {
+			t := index.Resolve(ref)
+			if t.Kind != TargetThread && t.Kind != TargetPost {
+				continue
+			}
+			key := path.Join(t.Category, t.Thread)
+			if key == path.Join(cat, thread) || seen[key+"/"+t.Filename] {
+				continue
+			}
+			seen[key+"/"+t.Filename] = true
+			b.byThread[key] = append(b.byThread[key], Backlink{
+				Category:  cat,
+				Thread:    thread,
+				Filename:  file,
+				Title:     index.threadTitle(cat, thread),
+				Author:    p.Author,
+				Timestamp: p.Timestamp,
+				Target:    t.Filename,
+			})
+		}
+	})
+	for _, links := range b.byThread {
+		sort.Slice(links, func(i, j int) bool {
+			if !links[i].Timestamp.Equal(links[j].Timestamp) {
+				return links[i].Timestamp.Before(links[j].Timestamp)
+			}
+			return path.Join(links[i].Category, links[i].Thread, links[i].Filename) <
+				path.Join(links[j].Category, links[j].Thread, links[j].Filename)
+		})
+	}
+	return b
+}
+
+// To returns the backlinks to a thread, oldest first.
+func (b *Backlinks) To(category, thread string) []Backlink {
+	return b.byThread[path.Join(category, thread)]
+}
+
+// references returns the refs of the cross-links and quotes in body, in
+// order of appearance. Links inside code are ignored.
+func references(body string) []string {
+	src := []byte(body)
+	doc := mdRenderer.md.Parser().Parse(text.NewReader(src))
+	var refs []string
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := n.(type) {
+		case *xrefNode:
+			refs = append(refs, n.Ref)
+		case *quoteNode:
+			refs = append(refs, n.Quote.Ref)
+		}
+		return ast.WalkContinue, nil
+	})
+	return refs
+}
@@ -0,0 +1,418 @@
+// Package lan advertises forums on the local network with multicast DNS
+// service discovery (DNS-SD, RFC 6763) and keeps track of the peers that
+// advertise theirs, so that participants without internet access can find
+// each other and sync directly.
+//
+// Each advertised forum is a _gitorum._tcp service instance whose TXT
+// record carries the forum ID, the forum and user names, and the path of
+// the read-only git endpoint on the advertised port. Peers are reached at
+// the address their announcements come from, so no A records are needed.
+package lan
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// ServiceType is the DNS-SD service type of a gitorum forum.
+const ServiceType = "_gitorum._tcp.local."
+
+// DefaultGroup is the mDNS multicast group and port.
+var DefaultGroup = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+
+// ForumID identifies a forum on the network: the first 16 hex digits of the
+// SHA-256 of its admin's public key. Two copies of a forum share it.
+func ForumID(adminPubkey string) string {
+	sum := sha256.Sum256([]byte(adminPubkey))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Service is a forum advertised by this process.
+type Service struct {
+	Instance string // unique service instance name; see InstanceName
+	ForumID  string
+	Name     string // forum name from GITORUM.toml
+	User     string // username of whoever serves it; may be empty
+	Port     int    // port of the git endpoint
+	Path     string // path of the git endpoint, e.g. /f/home/git/
+}
+
+// Peer is a forum advertised by another process on the network.
+type Peer struct {
+	Service
+	Addr     string // IP address its announcements come from
+	LastSeen time.Time
+}
+
+// URL returns the git URL of the peer's endpoint.
+func (p Peer) URL() string {
+	return "http://" + net.JoinHostPort(p.Addr, strconv.Itoa(p.Port)) + p.Path
+}
+
+// InstanceName returns a service instance name for user's copy of a forum,
+// made unique on the network with a random suffix.
+func InstanceName(user, forum string) string {
+	var b [3]byte
+	rand.Read(b[:])
+	base := strings.Trim(nonLabelRe.ReplaceAllString(strings.ToLower(user+"-"+forum), "-"), "-")
+	if len(base) > 40 {
+		base = base[:40]
+	}
+	if base == "" {
+		base = "gitorum"
+	}
+	return base + "-" + hex.EncodeToString(b[:])
+}
+
+var nonLabelRe = regexp.MustCompile(`[^a-z0-9]+`)
+
+// Discovery advertises services and listens for the announcements of
+// peers. Set its fields before Start.
+type Discovery struct {
+	// Group is the multicast group and port; DefaultGroup when nil.
+	Group *net.UDPAddr
+	// Interval is how often services are announced and peers queried;
+	// 30 seconds when zero. A peer not heard from for three intervals is
+	// forgotten.
+	Interval time.Duration
+
+	mu       sync.Mutex // guards services and peers
+	services []Service
+	peers    map[string]*Peer // by instance name
+
+	recv, send *net.UDPConn
+	done       chan struct{}
+	wg         sync.WaitGroup
+}
+
+// Advertise adds a service to announce, and announces it at once when the
+// discovery is running.
+func (d *Discovery) Advertise(s Service) {
+	d.mu.Lock()
+	d.services = append(d.services, s)
+	running := d.send != nil
+	d.mu.Unlock()
+	if running {
+		d.announce([]Service{s}, d.ttl())
+	}
+}
+
+// Peers returns the peers advertising the forum with the given ID, most
+// recently seen first.
+func (d *Discovery) Peers(forumID string) []Peer {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var out []Peer
+	for _, p := range d.peers {
+		if p.ForumID == forumID && time.Since(p.LastSeen) < d.ttl() {
+			out = append(out, *p)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].LastSeen.After(out[j].LastSeen) })
+	return out
+}
+
+// Peer returns the peer with the given instance name.
+func (d *Discovery) Peer(instance string) (Peer, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	p, ok := d.peers[instance]
+	if !ok || time.Since(p.LastSeen) >= d.ttl() {
+		return Peer{}, false
+	}
+	return *p, true
+}
+
+// Start joins the multicast group, announces the services, queries for
+// peers, and keeps doing so until Close.
+func (d *Discovery) Start() error {
+	group := d.group()
+	recv, err := net.ListenMulticastUDP("udp4", nil, group)
+	if err != nil {
+		return fmt.Errorf("join %s: %w", group, err)
+	}
+	// ListenMulticastUDP disables multicast loopback, so announcements go
+	// out on a socket of their own, where it stays on for peers on this
+	// host.
+	send, err := net.ListenUDP("udp4", &net.UDPAddr{})
+	if err != nil {
+		recv.Close()
+		return fmt.Errorf("open mDNS socket: %w", err)
+	}
+	d.mu.Lock()
+	d.recv, d.send = recv, send
+	d.peers = map[string]*Peer{}
+	d.done = make(chan struct{})
+	d.mu.Unlock()
+
+	d.wg.Add(2)
+	go d.listen()
+	go d.tick()
+	return nil
+}
+
+// Close says goodbye, so peers forget the services at once, and stops.
+func (d *Discovery) Close() error {
+	d.mu.Lock()
+	services := d.services
+	d.mu.Unlock()
+	if d.send == nil {
+		return nil
+	}
+	d.announce(services, 0)
+	close(d.done)
+	err := errors.Join(d.recv.Close(), d.send.Close())
+	d.wg.Wait()
+	return err
+}
+
+func (d *Discovery) group() *net.UDPAddr {
+	if d.Group != nil {
+		return d.Group
+	}
+	return DefaultGroup
+}
+
+func (d *Discovery) interval() time.Duration {
+	if d.Interval > 0 {
+		return d.Interval
+	}
+	return 30 * time.Second
+}
+
+// ttl is how long a record stays valid: three announcement intervals.
+func (d *Discovery) ttl() time.Duration { return 3 * d.interval() }
+
+func (d *Discovery) tick() {
+	defer d.wg.Done()
+	t := time.NewTicker(d.interval())
+	defer t.Stop()
+	for {
+		d.mu.Lock()
+		services := d.services
+		d.mu.Unlock()
+		d.announce(services, d.ttl())
+		d.query()
+		select {
+		case <-d.done:
+			return
+		case <-t.C:
+		}
+	}
+}
+
+func (d *Discovery) listen() {
+	defer d.wg.Done()
+	buf := make([]byte, 9000)
+	for {
+		n, from, err := d.recv.ReadFromUDP(buf)
+		if err != nil {
+			select {
+			case <-d.done:
+				return
+			default:
+			}
+			log.Printf("lan: read: %v", err)
+			time.Sleep(time.Second)
+			continue
+		}
+		d.handle(buf[:n], from)
+	}
+}
+
+// handle answers queries for the service type and records the peers
+// announced in responses.
+func (d *Discovery) handle(packet []byte, from *net.UDPAddr) {
+	var msg dnsmessage.Message
+	if err := msg.Unpack(packet); err != nil {
+		return
+	}
+	if !msg.Response {
+		for _, q := range msg.Questions {
+			if strings.EqualFold(q.Name.String(), ServiceType) && (q.Type == dnsmessage.TypePTR || q.Type == dnsmessage.TypeALL) {
+				d.mu.Lock()
+				services := d.services
+				d.mu.Unlock()
+				d.announce(services, d.ttl())
+				return
+			}
+		}
+		return
+	}
+
+	mine := map[string]bool{}
+	d.mu.Lock()
+	for _, s := range d.services {
+		mine[s.Instance] = true
+	}
+	d.mu.Unlock()
+	for _, ann := range parseAnnouncement(append(msg.Answers, msg.Additionals...)) {
+		if mine[ann.Instance] {
+			continue // our own announcement, looped back
+		}
+		d.mu.Lock()
+		if ann.goodbye {
+			delete(d.peers, ann.Instance)
+		} else if ann.ForumID != "" && ann.Port > 0 && strings.HasPrefix(ann.Path, "/") {
+			d.peers[ann.Instance] = &Peer{Service: ann.Service, Addr: from.IP.String(), LastSeen: time.Now()}
+		}
+		d.mu.Unlock()
+	}
+}
+
+func (d *Discovery) query() {
+	msg := dnsmessage.Message{
+		Questions: []dnsmessage.Question{{
+			Name:  dnsmessage.MustNewName(ServiceType),
+			Type:  dnsmessage.TypePTR,
+			Class: dnsmessage.ClassINET,
+		}},
+	}
+	d.write(msg)
+}
+
+// announce sends the records of services, valid for ttl; a zero ttl
+// withdraws them.
+func (d *Discovery) announce(services []Service, ttl time.Duration) {
+	if len(services) == 0 {
+		return
+	}
+	msg, err := announcement(services, ttl)
+	if err != nil {
+		log.Printf("lan: %v", err)
+		return
+	}
+	d.write(msg)
+}
+
+func (d *Discovery) write(msg dnsmessage.Message) {
+	packet, err := msg.Pack()
+	if err != nil {
+		log.Printf("lan: pack: %v", err)
+		return
+	}
+	if _, err := d.send.WriteToUDP(packet, d.group()); err != nil {
+		log.Printf("lan: send: %v", err)
+	}
+}
+
+// announcement builds the mDNS response advertising services: for each,
+// a PTR record from the service type to the instance, and the instance's
+// SRV and TXT records.
+func announcement(services []Service, ttl time.Duration) (dnsmessage.Message, error) {
+	msg := dnsmessage.Message{Header: dnsmessage.Header{Response: true, Authoritative: true}}
+	secs := uint32((ttl + time.Second - 1) / time.Second) // a zero TTL is a goodbye
+	for _, s := range services {
+		instance, err := dnsmessage.NewName(s.Instance + "." + ServiceType)
+		if err != nil {
+			return msg, fmt.Errorf("instance name %q: %w", s.Instance, err)
+		}
+		host, err := dnsmessage.NewName(s.Instance + ".local.")
+		if err != nil {
+			return msg, fmt.Errorf("host name %q: %w", s.Instance, err)
+		}
+		msg.Answers = append(msg.Answers,
+			dnsmessage.Resource{
+				Header: dnsmessage.ResourceHeader{Name: dnsmessage.MustNewName(ServiceType), Class: dnsmessage.ClassINET, TTL: secs},
+				Body:   &dnsmessage.PTRResource{PTR: instance},
+			},
+			dnsmessage.Resource{
+				Header: dnsmessage.ResourceHeader{Name: instance, Class: dnsmessage.ClassINET, TTL: secs},
+				Body:   &dnsmessage.SRVResource{Port: uint16(s.Port), Target: host},
+			},
+			dnsmessage.Resource{
+				Header: dnsmessage.ResourceHeader{Name: instance, Class: dnsmessage.ClassINET, TTL: secs},
+				Body: &dnsmessage.TXTResource{TXT: []string{
+					"forum=" + s.ForumID,
+					"name=" + truncate(s.Name, 200),
+					"user=" + truncate(s.User, 200),
+					"path=" + truncate(s.Path, 200),
+				}},
+			},
+		)
+	}
+	return msg, nil
+}
+
+// announced is a service read back from an announcement.
+type announced struct {
+	Service
+	goodbye bool // the records were withdrawn
+}
+
+// parseAnnouncement collects the services described by the SRV and TXT
+// records of a response.
+func parseAnnouncement(records []dnsmessage.Resource) []announced {
+	byName := map[string]*announced{}
+	var order []string
+	get := func(name dnsmessage.Name) *announced {
+		full := name.String()
+		instance, ok := strings.CutSuffix(strings.ToLower(full), "."+ServiceType)
+		if !ok {
+			return nil
+		}
+		if a, ok := byName[instance]; ok {
+			return a
+		}
+		a := &announced{Service: Service{Instance: full[:len(instance)]}}
+		byName[instance] = a
+		order = append(order, instance)
+		return a
+	}
+	for _, rec := range records {
+		switch body := rec.Body.(type) {
+		case *dnsmessage.PTRResource:
+			if a := get(body.PTR); a != nil && rec.Header.TTL == 0 {
+				a.goodbye = true
+			}
+		case *dnsmessage.SRVResource:
+			if a := get(rec.Header.Name); a != nil {
+				a.Port = int(body.Port)
+				a.goodbye = a.goodbye || rec.Header.TTL == 0
+			}
+		case *dnsmessage.TXTResource:
+			a := get(rec.Header.Name)
+			if a == nil {
+				continue
+			}
+			for _, kv := range body.TXT {
+				k, v, _ := strings.Cut(kv, "=")
+				switch k {
+				case "forum":
+					a.ForumID = v
+				case "name":
+					a.Name = v
+				case "user":
+					a.User = v
+				case "path":
+					a.Path = v
+				}
+			}
+		}
+	}
+	out := make([]announced, 0, len(order))
+	for _, name := range order {
+		out = append(out, *byName[name])
+	}
+	return out
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n]
+}
@@ -0,0 +1,79 @@
+package lan_test
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gosub/gitorum/internal/lan"
+)
+
+// groupForTest returns the mDNS group on a free port, so tests neither
+// disturb nor hear the real mDNS traffic.
+func groupForTest(t *testing.T) *net.UDPAddr {
+	t.Helper()
+	probe, err := net.ListenUDP("udp4", &net.UDPAddr{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer probe.Close()
+	return &net.UDPAddr{IP: lan.DefaultGroup.IP, Port: probe.LocalAddr().(*net.UDPAddr).Port}
+}
+
+func TestDiscovery(t *testing.T) {
+	group := groupForTest(t)
+	forumID := lan.ForumID("admin-key")
+	if forumID != lan.ForumID("admin-key") || len(forumID) != 16 || forumID == lan.ForumID("other-key") {
+		t.Fatalf("ForumID: %q", forumID)
+	}
+
+	alice := &lan.Discovery{Group: group, Interval: 100 * time.Millisecond}
+	bob := &lan.Discovery{Group: group, Interval: 100 * time.Millisecond}
+	bob.Advertise(lan.Service{
+		Instance: lan.InstanceName("bob", "Home Forum"),
+		ForumID:  forumID,
+		Name:     "Home Forum",
+		User:     "bob",
+		Port:     9418,
+		Path:     "/f/home/git/",
+	})
+	bob.Advertise(lan.Service{Instance: lan.InstanceName("bob", "other"), ForumID: lan.ForumID("other-key"), Port: 9418, Path: "/git/"})
+	for _, d := range []*lan.Discovery{alice, bob} {
+		if err := d.Start(); err != nil {
+			t.Skipf("no multicast here: %v", err)
+		}
+	}
+	defer alice.Close()
+
+	var peers []lan.Peer
+	deadline := time.Now().Add(3 * time.Second)
+	for len(peers) == 0 {
+		if time.Now().After(deadline) {
+			t.Skip("no multicast here: bob never showed up")
+		}
+		time.Sleep(20 * time.Millisecond)
+		peers = alice.Peers(forumID)
+	}
+	p := peers[0]
+	if len(peers) != 1 || p.User != "bob" || p.Name != "Home Forum" || p.Port != 9418 {
+		t.Fatalf("peers: %+v", peers)
+	}
+	if want := "http://" + net.JoinHostPort(p.Addr, "9418") + "/f/home/git/"; p.URL() != want {
+		t.Errorf("URL: got %s, want %s", p.URL(), want)
+	}
+	if got := bob.Peers(forumID); len(got) != 0 {
+		t.Errorf("bob sees himself: %+v", got)
+	}
+
+	// Goodbye packets remove bob at once.
+	if err := bob.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	deadline = time.Now().Add(time.Second)
+	for len(alice.Peers(forumID)) != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("bob still listed after closing")
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
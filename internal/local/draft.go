@@ -0,0 +1,101 @@
+package local
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Draft is an unfinished post: a reply to a thread, or a new thread in a
+// category when Thread is empty. There is at most one draft per thread and
+// one new-thread draft per category.
+type Draft struct {
+	Category string    `toml:"category"`
+	Thread   string    `toml:"thread,omitempty"` // empty for a new thread
+	Slug     string    `toml:"slug,omitempty"`   // new threads: the slug typed so far
+	Parent   string    `toml:"parent,omitempty"` // replies: filename of the post replied to
+	Body     string    `toml:"body"`
+	Updated  time.Time `toml:"updated"`
+}
+
+// ID identifies a draft: "reply:{category}/{thread}" or "new:{category}".
+func (d Draft) ID() string {
+	return DraftID(d.Category, d.Thread)
+}
+
+// DraftID returns the ID of the draft for a thread, or for a new thread in
+// category when thread is empty.
+func DraftID(category, thread string) string {
+	if thread == "" {
+		return "new:" + category
+	}
+	return "reply:" + category + "/" + thread
+}
+
+// ParseDraftID splits a draft ID into its category and thread.
+func ParseDraftID(id string) (category, thread string, err error) {
+	if rest, ok := strings.CutPrefix(id, "new:"); ok && rest != "" {
+		return rest, "", nil
+	}
+	if rest, ok := strings.CutPrefix(id, "reply:"); ok {
+		if i := strings.LastIndex(rest, "/"); i > 0 && i < len(rest)-1 {
+			return rest[:i], rest[i+1:], nil
+		}
+	}
+	return "", "", fmt.Errorf("invalid draft %q: want reply:<category>/<thread> or new:<category>", id)
+}
+
+// Drafts holds the unfinished posts of one identity. They live under
+// .git/gitorum/drafts/ and are never committed.
+type Drafts struct {
+	path string
+
+	Drafts []Draft `toml:"drafts"` // most recently updated first
+}
+
+// LoadDrafts reads the drafts of username from the repository at repoPath.
+func LoadDrafts(repoPath, username string) (*Drafts, error) {
+	path, err := userFile(repoPath, "drafts", username)
+	if err != nil {
+		return nil, err
+	}
+	d := &Drafts{path: path}
+	if err := load(path, d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// Get returns the draft with the given ID.
+func (d *Drafts) Get(id string) (Draft, bool) {
+	for _, dr := range d.Drafts {
+		if dr.ID() == id {
+			return dr, true
+		}
+	}
+	return Draft{}, false
+}
+
+// Put stores dr, replacing any draft with the same ID, and moves it to the
+// front. A zero Updated time is set to now.
+func (d *Drafts) Put(dr Draft) {
+	if dr.Updated.IsZero() {
+		dr.Updated = time.Now().UTC()
+	}
+	d.Discard(dr.ID())
+	d.Drafts = slices.Insert(d.Drafts, 0, dr)
+}
+
+// Discard removes the draft with the given ID. It reports whether there was
+// one.
+func (d *Drafts) Discard(id string) bool {
+	n := len(d.Drafts)
+	d.Drafts = slices.DeleteFunc(d.Drafts, func(dr Draft) bool { return dr.ID() == id })
+	return len(d.Drafts) != n
+}
+
+// Save writes the drafts back to disk.
+func (d *Drafts) Save() error {
+	return save(d.path, d)
+}
@@ -0,0 +1,26 @@
+package local
+
+import "path/filepath"
+
+// IdentityChoice records which identity from the identity store is used in
+// a repository. An empty Name means none was chosen.
+type IdentityChoice struct {
+	path string
+
+	Name string `toml:"name"`
+}
+
+// LoadIdentityChoice reads the identity chosen for the repository at
+// repoPath.
+func LoadIdentityChoice(repoPath string) (*IdentityChoice, error) {
+	c := &IdentityChoice{path: filepath.Join(Dir(repoPath), "identity.toml")}
+	if err := load(c.path, c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Save writes the choice back to disk.
+func (c *IdentityChoice) Save() error {
+	return save(c.path, c)
+}
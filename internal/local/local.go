@@ -0,0 +1,60 @@
+// Package local persists state that lives next to a forum repository but is
+// never committed: read markers, notifications and similar bookkeeping that
+// only matters to the person running the server.
+//
+// Everything is stored as TOML under .git/gitorum/ so that it travels with
+// the clone, is ignored by git, and can be inspected with a text editor.
+package local
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Dir returns the directory holding local state for the repository whose
+// working tree is at repoPath.
+func Dir(repoPath string) string {
+	return filepath.Join(repoPath, ".git", "gitorum")
+}
+
+// userFile returns the path of a per-user state file, e.g.
+// .git/gitorum/read/alice.toml.
+func userFile(repoPath, kind, username string) (string, error) {
+	if username == "" || strings.ContainsAny(username, `/\`) || username == "." || username == ".." {
+		return "", fmt.Errorf("invalid username %q", username)
+	}
+	return filepath.Join(Dir(repoPath), kind, username+".toml"), nil
+}
+
+// load decodes the TOML file at path into v. A missing file leaves v
+// untouched and is not an error.
+func load(path string, v any) error {
+	if _, err := toml.DecodeFile(path, v); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("read %s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
+
+// save writes v as TOML to path atomically, creating parent directories.
+func save(path string, v any) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("create state dir: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return fmt.Errorf("create temp file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if err := toml.NewEncoder(tmp).Encode(v); err != nil {
+		tmp.Close()
+		return fmt.Errorf("encode %s: %w", filepath.Base(path), err)
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
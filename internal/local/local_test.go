@@ -0,0 +1,181 @@
+package local_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gosub/gitorum/internal/local"
+)
+
+func TestReadState_RoundTrip(t *testing.T) {
+	dir := t.TempDir()
+
+	rs, err := local.LoadReadState(dir, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := []string{"0000_root.md", "1700000000000_aaaaaaaa.md", "1700000000001_bbbbbbbb.md"}
+	if n := rs.Unread("general", "hello", files); n != 3 {
+		t.Errorf("Unread before marking: got %d, want 3", n)
+	}
+	rs.MarkRead("general", "hello", files[2], files[1])
+	rs.MarkRead("general", "hello", files[1]) // already seen
+	if err := rs.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := local.LoadReadState(dir, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reloaded.IsSeen("general", "hello", files[1]) || reloaded.IsSeen("general", "hello", files[0]) {
+		t.Errorf("seen after reload: %v", reloaded.Seen)
+	}
+	// The root sorts before the posts seen and is still unread.
+	if n := reloaded.Unread("general", "hello", files); n != 1 {
+		t.Errorf("Unread after reload: got %d, want 1", n)
+	}
+
+	// State is per identity.
+	other, err := local.LoadReadState(dir, "bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := other.Unread("general", "hello", files); n != 3 {
+		t.Errorf("bob Unread: got %d, want 3", n)
+	}
+	if _, err := os.Stat(filepath.Join(local.Dir(dir), "read", "alice.toml")); err != nil {
+		t.Errorf("state file: %v", err)
+	}
+}
+
+func TestLoadReadState_RejectsBadUsername(t *testing.T) {
+	for _, name := range []string{"", ".", "..", "a/b", `a\b`} {
+		if _, err := local.LoadReadState(t.TempDir(), name); err == nil {
+			t.Errorf("LoadReadState(%q): expected error", name)
+		}
+	}
+}
+
+func TestInbox_AddAndSeen(t *testing.T) {
+	dir := t.TempDir()
+	in, err := local.LoadInbox(dir, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	older := local.Notification{Category: "general", Thread: "hello", Filename: "1_a.md", Kind: local.KindMention, Timestamp: time.Unix(100, 0)}
+	newer := local.Notification{Category: "general", Thread: "hello", Filename: "2_b.md", Kind: local.KindReply, Timestamp: time.Unix(200, 0)}
+	if !in.Add(older) || !in.Add(newer) {
+		t.Fatal("Add: expected new notifications to be added")
+	}
+	if in.Add(older) {
+		t.Error("Add: duplicate notification added")
+	}
+	in.MarkSeen(older.ID())
+	in.Checked["general/hello"] = "2_b.md"
+	if err := in.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := local.LoadInbox(dir, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := reloaded.Unseen(); got != 1 {
+		t.Errorf("Unseen: got %d, want 1", got)
+	}
+	sorted := reloaded.Sorted()
+	if len(sorted) != 2 || sorted[0].Filename != "2_b.md" {
+		t.Errorf("Sorted: got %+v", sorted)
+	}
+	if reloaded.Checked["general/hello"] != "2_b.md" {
+		t.Errorf("Checked: got %v", reloaded.Checked)
+	}
+	reloaded.MarkSeen()
+	if got := reloaded.Unseen(); got != 0 {
+		t.Errorf("Unseen after MarkSeen(): got %d", got)
+	}
+}
+
+func TestMuteList(t *testing.T) {
+	dir := t.TempDir()
+	m, err := local.LoadMuteList(dir, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !m.Mute("mallory") || !m.Mute("bob") || m.Mute("bob") {
+		t.Error("Mute: unexpected change report")
+	}
+	if err := m.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := local.LoadMuteList(dir, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := reloaded.Users; len(got) != 2 || got[0] != "bob" || got[1] != "mallory" {
+		t.Errorf("Users after reload: got %v", got)
+	}
+	if !reloaded.Unmute("bob") || reloaded.Unmute("bob") {
+		t.Error("Unmute: unexpected change report")
+	}
+	if set := reloaded.Set(); !set["mallory"] || set["bob"] {
+		t.Errorf("Set: got %v", set)
+	}
+
+	if other, _ := local.LoadMuteList(dir, "carol"); len(other.Users) != 0 {
+		t.Errorf("mute lists are per identity, carol got %v", other.Users)
+	}
+}
+
+func TestDrafts(t *testing.T) {
+	dir := t.TempDir()
+	d, err := local.LoadDrafts(dir, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Put(local.Draft{Category: "general", Slug: "hello", Body: "# Hello"})
+	d.Put(local.Draft{Category: "general", Thread: "intro", Parent: "p1.md", Body: "first"})
+	d.Put(local.Draft{Category: "general", Thread: "intro", Body: "second"})
+	if err := d.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	reloaded, err := local.LoadDrafts(dir, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(reloaded.Drafts); got != 2 {
+		t.Fatalf("drafts after reload: got %d, want 2", got)
+	}
+	if id := reloaded.Drafts[0].ID(); id != "reply:general/intro" {
+		t.Errorf("most recent draft: got %s", id)
+	}
+	if dr, ok := reloaded.Get("reply:general/intro"); !ok || dr.Body != "second" || dr.Parent != "" {
+		t.Errorf("Get reply: got %+v, %v", dr, ok)
+	}
+	if dr, ok := reloaded.Get(local.DraftID("general", "")); !ok || dr.Slug != "hello" {
+		t.Errorf("Get new thread: got %+v, %v", dr, ok)
+	}
+	if !reloaded.Discard("new:general") || reloaded.Discard("new:general") {
+		t.Error("Discard: unexpected change report")
+	}
+
+	for id, want := range map[string][2]string{
+		"new:meta/dev":          {"meta/dev", ""},
+		"reply:meta/dev/thread": {"meta/dev", "thread"},
+	} {
+		cat, thread, err := local.ParseDraftID(id)
+		if err != nil || cat != want[0] || thread != want[1] {
+			t.Errorf("ParseDraftID(%q) = %q, %q, %v", id, cat, thread, err)
+		}
+	}
+	for _, id := range []string{"", "new:", "reply:general", "reply:/x", "draft:general"} {
+		if _, _, err := local.ParseDraftID(id); err == nil {
+			t.Errorf("ParseDraftID(%q): want error", id)
+		}
+	}
+}
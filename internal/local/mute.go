@@ -0,0 +1,59 @@
+package local
+
+import "slices"
+
+// MuteList is the set of users whose posts one identity does not want to
+// see. It only affects that identity's own view of the forum.
+type MuteList struct {
+	path string
+
+	Users []string `toml:"users"` // sorted usernames
+}
+
+// LoadMuteList reads the mute list of username from the repository at
+// repoPath. A user who has muted nobody gets an empty list.
+func LoadMuteList(repoPath, username string) (*MuteList, error) {
+	path, err := userFile(repoPath, "mutes", username)
+	if err != nil {
+		return nil, err
+	}
+	m := &MuteList{path: path}
+	if err := load(path, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Mute adds user to the list. It reports whether the list changed.
+func (m *MuteList) Mute(user string) bool {
+	i, found := slices.BinarySearch(m.Users, user)
+	if found {
+		return false
+	}
+	m.Users = slices.Insert(m.Users, i, user)
+	return true
+}
+
+// Unmute removes user from the list. It reports whether the list changed.
+func (m *MuteList) Unmute(user string) bool {
+	i, found := slices.BinarySearch(m.Users, user)
+	if !found {
+		return false
+	}
+	m.Users = slices.Delete(m.Users, i, i+1)
+	return true
+}
+
+// Set returns the muted users as a set.
+func (m *MuteList) Set() map[string]bool {
+	set := make(map[string]bool, len(m.Users))
+	for _, u := range m.Users {
+		set[u] = true
+	}
+	return set
+}
+
+// Save writes the mute list back to disk.
+func (m *MuteList) Save() error {
+	return save(m.path, m)
+}
@@ -0,0 +1,109 @@
+package local
+
+import (
+	"sort"
+	"time"
+)
+
+// Notification kinds, from most to least specific. A post that qualifies
+// for several kinds is reported once, with the first matching kind.
+const (
+	KindMention     = "mention"      // the post mentions me with @username
+	KindReply       = "reply"        // the post replies to one of my posts
+	KindThreadReply = "thread_reply" // the post is a reply in a thread I started
+)
+
+// Notification is one post that concerns the local identity.
+type Notification struct {
+	Category  string    `toml:"category"`
+	Thread    string    `toml:"thread"`
+	Title     string    `toml:"title"`
+	Filename  string    `toml:"filename"`
+	Kind      string    `toml:"kind"`
+	Author    string    `toml:"author"`
+	Timestamp time.Time `toml:"timestamp"`
+	Seen      bool      `toml:"seen"`
+}
+
+// ID identifies the notification by the post it refers to.
+func (n Notification) ID() string {
+	return n.Category + "/" + n.Thread + "/" + n.Filename
+}
+
+// Inbox holds the notifications of one identity together with, for each
+// thread, the newest post already examined, so that only posts that arrived
+// since the previous pass are looked at again.
+type Inbox struct {
+	path string
+
+	// Checked maps "<category>/<thread>" to the filename of the newest post
+	// already examined.
+	Checked map[string]string `toml:"checked"`
+	Items   []Notification    `toml:"items"`
+}
+
+// LoadInbox reads the notifications of username from the repository at
+// repoPath.
+func LoadInbox(repoPath, username string) (*Inbox, error) {
+	path, err := userFile(repoPath, "notifications", username)
+	if err != nil {
+		return nil, err
+	}
+	in := &Inbox{path: path}
+	if err := load(path, in); err != nil {
+		return nil, err
+	}
+	if in.Checked == nil {
+		in.Checked = make(map[string]string)
+	}
+	return in, nil
+}
+
+// Add records n unless a notification for the same post already exists.
+// It reports whether n was added.
+func (in *Inbox) Add(n Notification) bool {
+	for _, existing := range in.Items {
+		if existing.ID() == n.ID() {
+			return false
+		}
+	}
+	in.Items = append(in.Items, n)
+	return true
+}
+
+// Sorted returns the notifications newest first.
+func (in *Inbox) Sorted() []Notification {
+	items := append([]Notification(nil), in.Items...)
+	sort.SliceStable(items, func(i, j int) bool { return items[i].Timestamp.After(items[j].Timestamp) })
+	return items
+}
+
+// Unseen counts the notifications not marked seen.
+func (in *Inbox) Unseen() int {
+	n := 0
+	for _, item := range in.Items {
+		if !item.Seen {
+			n++
+		}
+	}
+	return n
+}
+
+// MarkSeen marks the notifications with the given IDs as seen, or all of
+// them when no ID is given.
+func (in *Inbox) MarkSeen(ids ...string) {
+	want := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		want[id] = true
+	}
+	for i := range in.Items {
+		if len(ids) == 0 || want[in.Items[i].ID()] {
+			in.Items[i].Seen = true
+		}
+	}
+}
+
+// Save writes the inbox back to disk.
+func (in *Inbox) Save() error {
+	return save(in.path, in)
+}
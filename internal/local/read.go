@@ -0,0 +1,66 @@
+package local
+
+import "slices"
+
+// ReadState records, for one identity, the posts seen in each thread. Posts
+// are tracked by filename rather than by a marker: a reply that arrives by
+// sync can carry an older millisecond prefix than posts already read, and
+// must still count as unread.
+type ReadState struct {
+	path string
+
+	// Seen maps "<category>/<thread>" to the sorted filenames of the posts
+	// seen there.
+	Seen map[string][]string `toml:"seen"`
+}
+
+// LoadReadState reads the read state of username from the repository at
+// repoPath. A user who has never read anything gets an empty state.
+func LoadReadState(repoPath, username string) (*ReadState, error) {
+	path, err := userFile(repoPath, "read", username)
+	if err != nil {
+		return nil, err
+	}
+	rs := &ReadState{path: path}
+	if err := load(path, rs); err != nil {
+		return nil, err
+	}
+	if rs.Seen == nil {
+		rs.Seen = make(map[string][]string)
+	}
+	return rs, nil
+}
+
+// IsSeen reports whether the post filename of the thread has been seen.
+func (rs *ReadState) IsSeen(cat, thread, filename string) bool {
+	_, found := slices.BinarySearch(rs.Seen[cat+"/"+thread], filename)
+	return found
+}
+
+// MarkRead records the posts filenames of the thread as seen.
+func (rs *ReadState) MarkRead(cat, thread string, filenames ...string) {
+	key := cat + "/" + thread
+	seen := rs.Seen[key]
+	for _, f := range filenames {
+		if i, found := slices.BinarySearch(seen, f); !found {
+			seen = slices.Insert(seen, i, f)
+		}
+	}
+	rs.Seen[key] = seen
+}
+
+// Unread counts the filenames of the thread that have not been seen.
+func (rs *ReadState) Unread(cat, thread string, filenames []string) int {
+	n := 0
+	for _, f := range filenames {
+		if !rs.IsSeen(cat, thread, f) {
+			n++
+		}
+	}
+	return n
+}
+
+// Save writes the read state back to disk.
+func (rs *ReadState) Save() error {
+	return save(rs.path, rs)
+}
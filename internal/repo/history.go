@@ -0,0 +1,383 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/go-git/go-git/v5/utils/merkletrie"
+)
+
+// UserActivity is what the git history records about a user.
+type UserActivity struct {
+	JoinedAt time.Time // time of the commit that added keys/{username}.pub; zero if none did
+	Posts    int       // post files added in commits authored by the user
+}
+
+// UserActivity returns the activity of username. The whole history is
+// scanned once per HEAD and the result is cached for every user.
+func (r *Repo) UserActivity(username string) (UserActivity, error) {
+	head := r.HeadHash()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.activity == nil || r.activityHead != head {
+		activity, err := r.scanActivity()
+		if err != nil {
+			return UserActivity{}, err
+		}
+		r.activity, r.activityHead = activity, head
+	}
+	return r.activity[username], nil
+}
+
+// scanActivity walks every commit reachable from HEAD and diffs it against
+// its first parent. A post is a .md file inside a thread directory
+// ({category}/.../{thread}/{file}.md); it is credited to the commit author,
+// which is the posting identity for commits made by gitorum.
+func (r *Repo) scanActivity() (map[string]UserActivity, error) {
+	activity := map[string]UserActivity{}
+	head, err := r.git.Head()
+	if err != nil {
+		return activity, nil // empty repository
+	}
+	commits, err := r.git.Log(&gogit.LogOptions{From: head.Hash()})
+	if err != nil {
+		return nil, fmt.Errorf("git log: %w", err)
+	}
+	err = commits.ForEach(func(c *object.Commit) error {
+		changes, err := firstParentChanges(c)
+		if err != nil {
+			return err
+		}
+		for _, ch := range changes {
+			if action, err := ch.Action(); err != nil || action != merkletrie.Insert {
+				continue
+			}
+			name := ch.To.Name
+			if user, ok := strings.CutPrefix(name, "keys/"); ok && path.Ext(user) == ".pub" {
+				user = strings.TrimSuffix(user, ".pub")
+				a := activity[user]
+				if a.JoinedAt.IsZero() || c.Committer.When.Before(a.JoinedAt) {
+					a.JoinedAt = c.Committer.When
+				}
+				activity[user] = a
+				continue
+			}
+			if path.Ext(name) == ".md" && strings.Count(name, "/") >= 2 {
+				a := activity[c.Author.Name]
+				a.Posts++
+				activity[c.Author.Name] = a
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return activity, nil
+}
+
+// Arrivals maps every file added on the first-parent line of HEAD to the
+// time it arrived there: the committer time of the commit that added it or
+// last changed it, or for a file that a merge brought in, of the merge.
+// Renames keep the time of the original file, so moving a thread does not
+// make its posts new. The map is computed once per HEAD and shared between
+// callers, which must not modify it.
+func (r *Repo) Arrivals() (map[string]time.Time, error) {
+	head := r.HeadHash()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.arrivals == nil || r.arrivalsHead != head {
+		arrivals, err := r.scanArrivals()
+		if err != nil {
+			return nil, err
+		}
+		r.arrivals, r.arrivalsHead = arrivals, head
+	}
+	return r.arrivals, nil
+}
+
+// scanArrivals implements Arrivals, replaying the first-parent line of HEAD
+// oldest first.
+func (r *Repo) scanArrivals() (map[string]time.Time, error) {
+	arrivals := map[string]time.Time{}
+	head, err := r.git.Head()
+	if err != nil {
+		return arrivals, nil // empty repository
+	}
+	c, err := r.git.CommitObject(head.Hash())
+	if err != nil {
+		return nil, fmt.Errorf("head commit: %w", err)
+	}
+	var line []*object.Commit
+	for {
+		line = append(line, c)
+		if c.NumParents() == 0 {
+			break
+		}
+		if c, err = c.Parent(0); err != nil {
+			return nil, fmt.Errorf("parent of %s: %w", line[len(line)-1].Hash, err)
+		}
+	}
+	for i := len(line) - 1; i >= 0; i-- {
+		c := line[i]
+		changes, err := firstParentChanges(c)
+		if err != nil {
+			return nil, err
+		}
+		for _, ch := range changes {
+			action, err := ch.Action()
+			if err != nil {
+				return nil, fmt.Errorf("diff %s: %w", c.Hash, err)
+			}
+			switch action {
+			case merkletrie.Insert:
+				if from, ok := arrivals[renamedFrom(changes, ch.To.TreeEntry.Hash)]; ok {
+					arrivals[ch.To.Name] = from
+				} else {
+					arrivals[ch.To.Name] = c.Committer.When
+				}
+			case merkletrie.Modify:
+				arrivals[ch.To.Name] = c.Committer.When
+			}
+		}
+	}
+	return arrivals, nil
+}
+
+// firstParentChanges diffs commit c against its first parent, or against
+// the empty tree for a root commit.
+func firstParentChanges(c *object.Commit) (object.Changes, error) {
+	to, err := c.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("tree of %s: %w", c.Hash, err)
+	}
+	from := &object.Tree{}
+	if c.NumParents() > 0 {
+		parent, err := c.Parent(0)
+		if err != nil {
+			return nil, fmt.Errorf("parent of %s: %w", c.Hash, err)
+		}
+		if from, err = parent.Tree(); err != nil {
+			return nil, fmt.Errorf("tree of %s: %w", parent.Hash, err)
+		}
+	}
+	changes, err := object.DiffTree(from, to)
+	if err != nil {
+		return nil, fmt.Errorf("diff %s: %w", c.Hash, err)
+	}
+	return changes, nil
+}
+
+// File change kinds in a LogEntry.
+const (
+	ChangeAdd    = "add"
+	ChangeModify = "modify"
+	ChangeDelete = "delete"
+)
+
+// FileChange is a file a commit added, modified or deleted, compared with
+// its first parent.
+type FileChange struct {
+	Path   string
+	Action string // ChangeAdd, ChangeModify or ChangeDelete
+}
+
+// LogEntry is a commit of the forum history: its signature, its full
+// message and the files it changed.
+type LogEntry struct {
+	CommitVerification
+	Message string
+	Merge   bool // a merge commit, e.g. made by sync; Changes are against the first parent
+	Changes []FileChange
+}
+
+// Log returns every commit reachable from HEAD, newest first, with its
+// signature checked against the keys in keys/.
+func (r *Repo) Log() ([]LogEntry, error) {
+	head, err := r.git.Head()
+	if err != nil {
+		return nil, nil // empty repository
+	}
+	keys, err := r.keyOwners()
+	if err != nil {
+		return nil, err
+	}
+	commits, err := r.git.Log(&gogit.LogOptions{From: head.Hash()})
+	if err != nil {
+		return nil, fmt.Errorf("git log: %w", err)
+	}
+	var out []LogEntry
+	err = commits.ForEach(func(c *object.Commit) error {
+		changes, err := firstParentChanges(c)
+		if err != nil {
+			return err
+		}
+		e := LogEntry{
+			CommitVerification: verifyCommit(c, keys),
+			Message:            c.Message,
+			Merge:              c.NumParents() > 1,
+		}
+		for _, ch := range changes {
+			action, err := ch.Action()
+			if err != nil {
+				return fmt.Errorf("diff %s: %w", c.Hash, err)
+			}
+			switch action {
+			case merkletrie.Insert:
+				e.Changes = append(e.Changes, FileChange{Path: ch.To.Name, Action: ChangeAdd})
+			case merkletrie.Modify:
+				e.Changes = append(e.Changes, FileChange{Path: ch.To.Name, Action: ChangeModify})
+			case merkletrie.Delete:
+				e.Changes = append(e.Changes, FileChange{Path: ch.From.Name, Action: ChangeDelete})
+			}
+		}
+		out = append(out, e)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ReadFileAt returns the content of relPath (slash-separated) as of commit
+// hash.
+func (r *Repo) ReadFileAt(hash, relPath string) ([]byte, error) {
+	tree, err := r.commitTree(plumbing.NewHash(hash))
+	if err != nil {
+		return nil, err
+	}
+	f, err := tree.File(relPath)
+	if err != nil {
+		return nil, fmt.Errorf("%s at %s: %w", relPath, hash, err)
+	}
+	content, err := f.Contents()
+	if err != nil {
+		return nil, fmt.Errorf("%s at %s: %w", relPath, hash, err)
+	}
+	return []byte(content), nil
+}
+
+// ErrNotInHistory is returned by Provenance for a file no commit added.
+var ErrNotInHistory = errors.New("file is not in the git history")
+
+// Kinds of FileTouch.
+const (
+	TouchModify = "modify" // the content changed
+	TouchMove   = "move"   // renamed with its content unchanged, e.g. by a thread move
+)
+
+// FileTouch is a commit that changed a file after it was added.
+type FileTouch struct {
+	CommitVerification
+	Kind string // TouchModify or TouchMove
+	From string // moves only: the previous path
+}
+
+// Provenance is how a file entered the history and what happened to it
+// since.
+type Provenance struct {
+	Introduced CommitVerification // the commit that added the file
+	Path       string             // where Introduced added it, before any moves
+	Later      []FileTouch        // oldest first
+}
+
+// Provenance traces relPath (slash-separated) back from HEAD to the commit
+// that added it, following renames. Merge commits only count as adding the
+// file when no other commit did, since the merges sync makes copy files
+// added by a peer's own commits.
+func (r *Repo) Provenance(relPath string) (Provenance, error) {
+	head, err := r.git.Head()
+	if err != nil {
+		return Provenance{}, ErrNotInHistory
+	}
+	keys, err := r.keyOwners()
+	if err != nil {
+		return Provenance{}, err
+	}
+	commits, err := r.git.Log(&gogit.LogOptions{From: head.Hash(), Order: gogit.LogOrderCommitterTime})
+	if err != nil {
+		return Provenance{}, fmt.Errorf("git log: %w", err)
+	}
+
+	prov := Provenance{Path: relPath}
+	var merged *object.Commit // the newest merge that added the file
+	found := false
+	err = commits.ForEach(func(c *object.Commit) error {
+		changes, err := firstParentChanges(c)
+		if err != nil {
+			return err
+		}
+		for _, ch := range changes {
+			action, err := ch.Action()
+			if err != nil || ch.To.Name != prov.Path {
+				continue
+			}
+			if action == merkletrie.Modify {
+				prov.Later = append(prov.Later, FileTouch{CommitVerification: verifyCommit(c, keys), Kind: TouchModify})
+				break
+			}
+			if action != merkletrie.Insert {
+				continue
+			}
+			if c.NumParents() > 1 {
+				if merged == nil {
+					merged = c
+				}
+				break
+			}
+			if from := renamedFrom(changes, ch.To.TreeEntry.Hash); from != "" {
+				prov.Later = append(prov.Later, FileTouch{CommitVerification: verifyCommit(c, keys), Kind: TouchMove, From: from})
+				prov.Path = from
+				break
+			}
+			prov.Introduced, found = verifyCommit(c, keys), true
+			return storer.ErrStop
+		}
+		return nil
+	})
+	if err != nil {
+		return Provenance{}, err
+	}
+	if !found {
+		if merged == nil {
+			return Provenance{}, ErrNotInHistory
+		}
+		prov.Introduced = verifyCommit(merged, keys)
+	}
+	for i, j := 0, len(prov.Later)-1; i < j; i, j = i+1, j-1 {
+		prov.Later[i], prov.Later[j] = prov.Later[j], prov.Later[i]
+	}
+	return prov, nil
+}
+
+// LastChanged returns the time of the newest commit that added or changed
+// relPath (slash-separated), or ErrNotInHistory when no commit did.
+func (r *Repo) LastChanged(relPath string) (time.Time, error) {
+	prov, err := r.Provenance(relPath)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if n := len(prov.Later); n > 0 {
+		return prov.Later[n-1].When, nil
+	}
+	return prov.Introduced.When, nil
+}
+
+// renamedFrom returns the path of a file with content blob that changes
+// delete, or "" when there is none.
+func renamedFrom(changes object.Changes, blob plumbing.Hash) string {
+	for _, ch := range changes {
+		if action, err := ch.Action(); err == nil && action == merkletrie.Delete && ch.From.TreeEntry.Hash == blob {
+			return ch.From.Name
+		}
+	}
+	return ""
+}
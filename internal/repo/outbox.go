@@ -0,0 +1,338 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/plumbing/object"
+
+	"github.com/gosub/gitorum/internal/crypto"
+)
+
+// Outbox is what the local repository holds that its push remotes do not.
+type Outbox struct {
+	Commits     []PendingCommit // local commits missing from a push remote, oldest first
+	Uncommitted []string        // changed or untracked files in the working tree, sorted
+	LastPushAt  time.Time       // zero until this process attempts a push
+	LastPushErr string          // error of that attempt; empty when it succeeded
+}
+
+// PendingCommit is a local commit that has not reached every push remote.
+type PendingCommit struct {
+	Hash      string
+	Message   string
+	Author    string
+	When      time.Time
+	Files     []string // paths the commit adds, changes or removes, sorted
+	PushError string   // error of the last push attempted after the commit was made
+	// PushedTo names the push remotes that already have the commit, which
+	// is then published and can no longer be discarded.
+	PushedTo []string
+}
+
+// Outbox lists the local commits that a remote with the push or mirror
+// role does not have yet and the uncommitted files in the working tree.
+// With no such remote nothing is ever pushed and the outbox is empty.
+func (r *Repo) Outbox() (Outbox, error) {
+	var out Outbox
+	r.mu.Lock()
+	out.LastPushAt, out.LastPushErr = r.pushAt, r.pushErr
+	r.mu.Unlock()
+
+	remotes, err := r.pushRemotes()
+	if err != nil {
+		return out, err
+	}
+	if len(remotes) == 0 {
+		return out, nil
+	}
+
+	wt, err := r.git.Worktree()
+	if err != nil {
+		return out, fmt.Errorf("worktree: %w", err)
+	}
+	status, err := wt.Status()
+	if err != nil {
+		return out, fmt.Errorf("status: %w", err)
+	}
+	for path, st := range status {
+		if st.Staging != gogit.Unmodified || st.Worktree != gogit.Unmodified {
+			out.Uncommitted = append(out.Uncommitted, path)
+		}
+	}
+	sort.Strings(out.Uncommitted)
+
+	pending, pushedTo, err := r.pendingCommits()
+	if err != nil {
+		return out, err
+	}
+	for _, c := range pending {
+		files, err := r.commitFilesChanged(c)
+		if err != nil {
+			return out, err
+		}
+		pc := PendingCommit{
+			Hash:     c.Hash.String(),
+			Message:  c.Message,
+			Author:   c.Author.Name,
+			When:     c.Committer.When,
+			Files:    files,
+			PushedTo: pushedTo[c.Hash],
+		}
+		if out.LastPushErr != "" && !out.LastPushAt.Before(c.Committer.When) {
+			pc.PushError = out.LastPushErr
+		}
+		out.Commits = append(out.Commits, pc)
+	}
+	return out, nil
+}
+
+// DiscardCommit removes an unpushed commit from the local history. The
+// commits made after it are replayed on top of its parent with their
+// original authors, times and messages, and committed and signed anew by
+// identity, as git does on a rebase. The working tree must be clean, and no
+// push remote may have the commit or any later one: rewriting published
+// history would not remove them, as the next pull would bring them back.
+func (r *Repo) DiscardCommit(identity *crypto.Identity, hash string) error {
+	pending, pushedTo, err := r.pendingCommits()
+	if err != nil {
+		return err
+	}
+	i := slices.IndexFunc(pending, func(c *object.Commit) bool { return c.Hash.String() == hash })
+	if i < 0 {
+		return fmt.Errorf("commit %s is not pending", hash)
+	}
+	for _, c := range pending[i:] {
+		if c.NumParents() != 1 {
+			return fmt.Errorf("cannot discard across merge commit %s", c.Hash)
+		}
+		if remotes := pushedTo[c.Hash]; len(remotes) > 0 {
+			return fmt.Errorf("commit %s has already been pushed to %s", c.Hash, strings.Join(remotes, ", "))
+		}
+	}
+
+	wt, err := r.git.Worktree()
+	if err != nil {
+		return fmt.Errorf("worktree: %w", err)
+	}
+	status, err := wt.Status()
+	if err != nil {
+		return fmt.Errorf("status: %w", err)
+	}
+	if !status.IsClean() {
+		return errors.New("working tree has uncommitted changes; discard them first")
+	}
+
+	// Read what the later commits change before the reset drops them.
+	type replay struct {
+		commit *object.Commit
+		files  map[string][]byte // nil content removes the file
+	}
+	var replays []replay
+	for _, c := range pending[i+1:] {
+		paths, err := r.commitFilesChanged(c)
+		if err != nil {
+			return err
+		}
+		tree, err := c.Tree()
+		if err != nil {
+			return fmt.Errorf("tree of %s: %w", c.Hash, err)
+		}
+		files := map[string][]byte{}
+		for _, p := range paths {
+			f, err := tree.File(p)
+			if errors.Is(err, object.ErrFileNotFound) {
+				files[p] = nil
+				continue
+			} else if err != nil {
+				return fmt.Errorf("%s in %s: %w", p, c.Hash, err)
+			}
+			content, err := f.Contents()
+			if err != nil {
+				return fmt.Errorf("read %s in %s: %w", p, c.Hash, err)
+			}
+			files[p] = []byte(content)
+		}
+		replays = append(replays, replay{c, files})
+	}
+
+	if err := wt.Reset(&gogit.ResetOptions{Commit: pending[i].ParentHashes[0], Mode: gogit.HardReset}); err != nil {
+		return fmt.Errorf("reset: %w", err)
+	}
+	for _, rp := range replays {
+		for p, content := range rp.files {
+			abs := filepath.Join(r.Path, filepath.FromSlash(p))
+			if content == nil {
+				if _, err := wt.Remove(p); err != nil && !errors.Is(err, index.ErrEntryNotFound) {
+					return fmt.Errorf("git rm %s: %w", p, err)
+				}
+				continue
+			}
+			if err := os.MkdirAll(filepath.Dir(abs), 0o755); err != nil {
+				return fmt.Errorf("create dirs for %s: %w", p, err)
+			}
+			if err := os.WriteFile(abs, content, 0o644); err != nil {
+				return fmt.Errorf("write %s: %w", p, err)
+			}
+			if _, err := wt.Add(p); err != nil {
+				return fmt.Errorf("git add %s: %w", p, err)
+			}
+		}
+		author := rp.commit.Author
+		committer := object.Signature{
+			Name:  identity.Username,
+			Email: commitEmail(identity.Username),
+			When:  rp.commit.Committer.When,
+		}
+		if _, err := wt.Commit(rp.commit.Message, &gogit.CommitOptions{
+			Author:            &author,
+			Committer:         &committer,
+			AllowEmptyCommits: true,
+			Signer:            commitSigner{identity},
+		}); err != nil {
+			return fmt.Errorf("replay %s: %w", rp.commit.Hash, err)
+		}
+	}
+	return nil
+}
+
+// DiscardUncommitted reverts an uncommitted change to relPath: a file that
+// HEAD has is restored, any other file is deleted.
+func (r *Repo) DiscardUncommitted(relPath string) error {
+	wt, err := r.git.Worktree()
+	if err != nil {
+		return fmt.Errorf("worktree: %w", err)
+	}
+	status, err := wt.Status()
+	if err != nil {
+		return fmt.Errorf("status: %w", err)
+	}
+	if st, ok := status[relPath]; !ok || (st.Staging == gogit.Unmodified && st.Worktree == gogit.Unmodified) {
+		return fmt.Errorf("%s has no uncommitted changes", relPath)
+	}
+
+	abs := filepath.Join(r.Path, filepath.FromSlash(relPath))
+	var content []byte
+	if head, err := r.git.Head(); err == nil {
+		tree, err := r.commitTree(head.Hash())
+		if err != nil {
+			return err
+		}
+		if f, err := tree.File(relPath); err == nil {
+			s, err := f.Contents()
+			if err != nil {
+				return fmt.Errorf("read %s at HEAD: %w", relPath, err)
+			}
+			content = []byte(s)
+		}
+	}
+	if content == nil {
+		if err := os.Remove(abs); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("remove %s: %w", relPath, err)
+		}
+		if _, err := wt.Remove(relPath); err != nil && !errors.Is(err, index.ErrEntryNotFound) {
+			return fmt.Errorf("unstage %s: %w", relPath, err)
+		}
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(abs), 0o755); err != nil {
+		return fmt.Errorf("create dirs for %s: %w", relPath, err)
+	}
+	if err := os.WriteFile(abs, content, 0o644); err != nil {
+		return fmt.Errorf("restore %s: %w", relPath, err)
+	}
+	if _, err := wt.Add(relPath); err != nil {
+		return fmt.Errorf("git add %s: %w", relPath, err)
+	}
+	return nil
+}
+
+// pendingCommits returns the commits reachable from HEAD but not from the
+// tracking ref of the current branch of every push remote, oldest first,
+// and for each the push remotes that do have it. A remote the branch was
+// never pushed to is missing every commit; with no push remote none is
+// pending.
+func (r *Repo) pendingCommits() ([]*object.Commit, map[plumbing.Hash][]string, error) {
+	head, err := r.git.Head()
+	if err != nil {
+		return nil, nil, nil // empty repository
+	}
+	remotes, err := r.pushRemotes()
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(remotes) == 0 {
+		return nil, nil, nil
+	}
+	pushedTo := map[plumbing.Hash][]string{}
+	for _, rm := range remotes {
+		remoteRef, err := r.git.Reference(plumbing.NewRemoteReferenceName(rm.Name, head.Name().Short()), true)
+		if err != nil {
+			continue
+		}
+		iter, err := r.git.Log(&gogit.LogOptions{From: remoteRef.Hash()})
+		if err != nil {
+			return nil, nil, fmt.Errorf("git log %s: %w", rm.Name, err)
+		}
+		if err := iter.ForEach(func(c *object.Commit) error {
+			pushedTo[c.Hash] = append(pushedTo[c.Hash], rm.Name)
+			return nil
+		}); err != nil {
+			return nil, nil, fmt.Errorf("git log %s: %w", rm.Name, err)
+		}
+	}
+
+	iter, err := r.git.Log(&gogit.LogOptions{From: head.Hash()})
+	if err != nil {
+		return nil, nil, fmt.Errorf("git log: %w", err)
+	}
+	var pending []*object.Commit
+	if err := iter.ForEach(func(c *object.Commit) error {
+		if len(pushedTo[c.Hash]) < len(remotes) {
+			pending = append(pending, c)
+		}
+		return nil
+	}); err != nil {
+		return nil, nil, fmt.Errorf("git log: %w", err)
+	}
+	slices.Reverse(pending)
+	return pending, pushedTo, nil
+}
+
+// commitFilesChanged returns the paths c adds, changes or removes relative to
+// its first parent, sorted.
+func (r *Repo) commitFilesChanged(c *object.Commit) ([]string, error) {
+	to, err := c.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("tree of %s: %w", c.Hash, err)
+	}
+	from := &object.Tree{}
+	if c.NumParents() > 0 {
+		if from, err = r.commitTree(c.ParentHashes[0]); err != nil {
+			return nil, err
+		}
+	}
+	changes, err := object.DiffTree(from, to)
+	if err != nil {
+		return nil, fmt.Errorf("diff %s: %w", c.Hash, err)
+	}
+	var files []string
+	for _, ch := range changes {
+		if ch.To.Name != "" {
+			files = append(files, ch.To.Name)
+		} else {
+			files = append(files, ch.From.Name)
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
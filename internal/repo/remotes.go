@@ -0,0 +1,487 @@
+package repo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/utils/merkletrie"
+
+	"github.com/gosub/gitorum/internal/crypto"
+)
+
+// Remote roles. Pull remotes are peers whose new posts are merged on sync;
+// push remotes receive every local commit; mirrors do both.
+const (
+	RolePull   = "pull"
+	RolePush   = "push"
+	RoleMirror = "mirror"
+)
+
+// roleKey is the git config option holding a remote's role, e.g.
+// remote.backup.gitorumrole = push.
+const roleKey = "gitorumrole"
+
+// Remote is a configured git remote and its role.
+type Remote struct {
+	Name string
+	URL  string
+	Role string
+}
+
+// Pulls reports whether sync fetches from the remote.
+func (rm Remote) Pulls() bool { return rm.Role == RolePull || rm.Role == RoleMirror }
+
+// Pushes reports whether sync pushes to the remote.
+func (rm Remote) Pushes() bool { return rm.Role == RolePush || rm.Role == RoleMirror }
+
+// RemoteStatus is a remote with the outcome of the last pull from and push
+// to it made by this process.
+type RemoteStatus struct {
+	Remote
+	Synced      bool      // HEAD matches the remote's copy of the current branch
+	LastPullAt  time.Time // zero until pulled
+	LastPullErr string
+	Rejected    []string  // files the last pull did not merge: not new, or conflicting
+	LastPushAt  time.Time // zero until pushed
+	LastPushErr string
+}
+
+// ValidRole reports whether role is one of the remote roles.
+func ValidRole(role string) bool {
+	return role == RolePull || role == RolePush || role == RoleMirror
+}
+
+// defaultRole is the role of a remote with none configured: origin is a
+// mirror, as it has always been, and any other remote a peer to pull from.
+func defaultRole(name string) string {
+	if name == "origin" {
+		return RoleMirror
+	}
+	return RolePull
+}
+
+// Remotes returns the configured remotes, origin first and the others by
+// name.
+func (r *Repo) Remotes() ([]Remote, error) {
+	cfg, err := r.git.Config()
+	if err != nil {
+		return nil, fmt.Errorf("config: %w", err)
+	}
+	var remotes []Remote
+	for name, rc := range cfg.Remotes {
+		rm := Remote{Name: name, Role: defaultRole(name)}
+		if len(rc.URLs) > 0 {
+			rm.URL = rc.URLs[0]
+		}
+		if role := cfg.Raw.Section("remote").Subsection(name).Option(roleKey); ValidRole(role) {
+			rm.Role = role
+		}
+		remotes = append(remotes, rm)
+	}
+	sort.Slice(remotes, func(i, j int) bool {
+		if (remotes[i].Name == "origin") != (remotes[j].Name == "origin") {
+			return remotes[i].Name == "origin"
+		}
+		return remotes[i].Name < remotes[j].Name
+	})
+	return remotes, nil
+}
+
+// pushRemotes returns the remotes with the push or mirror role, in the
+// order of Remotes.
+func (r *Repo) pushRemotes() ([]Remote, error) {
+	remotes, err := r.Remotes()
+	if err != nil {
+		return nil, err
+	}
+	var out []Remote
+	for _, rm := range remotes {
+		if rm.Pushes() {
+			out = append(out, rm)
+		}
+	}
+	return out, nil
+}
+
+// SetRemoteRole sets the role of an existing remote.
+func (r *Repo) SetRemoteRole(name, role string) error {
+	if !ValidRole(role) {
+		return fmt.Errorf("invalid role %q: use %s, %s or %s", role, RolePull, RolePush, RoleMirror)
+	}
+	cfg, err := r.git.Config()
+	if err != nil {
+		return fmt.Errorf("config: %w", err)
+	}
+	if _, ok := cfg.Remotes[name]; !ok {
+		return fmt.Errorf("no remote %q", name)
+	}
+	cfg.Raw.Section("remote").Subsection(name).SetOption(roleKey, role)
+	if err := r.git.SetConfig(cfg); err != nil {
+		return fmt.Errorf("save config: %w", err)
+	}
+	return nil
+}
+
+// RemoveRemote deletes a remote and what this process remembers about it.
+func (r *Repo) RemoveRemote(name string) error {
+	if err := r.git.DeleteRemote(name); err != nil {
+		return fmt.Errorf("remove remote %q: %w", name, err)
+	}
+	r.mu.Lock()
+	delete(r.remoteStatus, name)
+	r.mu.Unlock()
+	return nil
+}
+
+// RemoteStatus returns every remote with the outcome of its last pull and
+// push.
+func (r *Repo) RemoteStatus() ([]RemoteStatus, error) {
+	remotes, err := r.Remotes()
+	if err != nil {
+		return nil, err
+	}
+	head, _ := r.git.Head()
+	var out []RemoteStatus
+	for _, rm := range remotes {
+		st := RemoteStatus{Remote: rm}
+		r.mu.Lock()
+		if last, ok := r.remoteStatus[rm.Name]; ok {
+			st = *last
+			st.Remote = rm
+		}
+		r.mu.Unlock()
+		if head != nil {
+			ref, err := r.git.Reference(plumbing.NewRemoteReferenceName(rm.Name, head.Name().Short()), true)
+			st.Synced = err == nil && ref.Hash() == head.Hash()
+		}
+		out = append(out, st)
+	}
+	return out, nil
+}
+
+// Pull fetches from every remote with the pull or mirror role and merges
+// what each changed (see mergeBranch). A mirror is a full copy of the forum:
+// it is fast-forwarded to whenever possible and merged three ways
+// otherwise. From a pull remote only new posts, votes and thread state
+// records are taken (see mergeable); files it changed, removed or is not
+// trusted with are left alone and listed in its RemoteStatus. Merge commits are made and signed by identity. A 30-second
+// timeout applies to each remote. A failing remote does not stop the
+// others; their errors are joined.
+func (r *Repo) Pull(identity *crypto.Identity) error {
+	remotes, err := r.Remotes()
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, rm := range remotes {
+		if !rm.Pulls() {
+			continue
+		}
+		rejected, err := r.pullFrom(identity, rm)
+		r.updateStatus(rm.Name, func(st *RemoteStatus) {
+			st.LastPullAt, st.LastPullErr, st.Rejected = time.Now(), "", rejected
+			if err != nil {
+				st.LastPullErr = err.Error()
+			}
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("pull %s: %w", rm.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Push pushes to every remote with the push or mirror role. Returns nil if
+// there is none or all are up to date. A 30-second timeout applies to each
+// remote. The outcome is remembered and reported by Outbox and
+// RemoteStatus.
+func (r *Repo) Push() error {
+	remotes, err := r.Remotes()
+	if err != nil {
+		return err
+	}
+	var errs []error
+	pushed := false
+	for _, rm := range remotes {
+		if !rm.Pushes() {
+			continue
+		}
+		pushed = true
+		ctx, cancel := context.WithTimeout(context.Background(), gitTimeout)
+		err := r.git.PushContext(ctx, &gogit.PushOptions{RemoteName: rm.Name})
+		cancel()
+		if err == gogit.NoErrAlreadyUpToDate {
+			err = nil
+		}
+		r.updateStatus(rm.Name, func(st *RemoteStatus) {
+			st.LastPushAt, st.LastPushErr = time.Now(), ""
+			if err != nil {
+				st.LastPushErr = err.Error()
+			}
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("push %s: %w", rm.Name, err))
+		}
+	}
+	if !pushed {
+		return nil
+	}
+	err = errors.Join(errs...)
+	r.mu.Lock()
+	r.pushAt, r.pushErr = time.Now(), ""
+	if err != nil {
+		r.pushErr = err.Error()
+	}
+	r.mu.Unlock()
+	return err
+}
+
+func (r *Repo) updateStatus(name string, update func(*RemoteStatus)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.remoteStatus == nil {
+		r.remoteStatus = map[string]*RemoteStatus{}
+	}
+	st, ok := r.remoteStatus[name]
+	if !ok {
+		st = &RemoteStatus{}
+		r.remoteStatus[name] = st
+	}
+	update(st)
+}
+
+// PullPeer fetches from a git URL that is not a configured remote, such as
+// a peer found on the local network, and merges what it added exactly as
+// Pull does for a remote with the pull role. name keeps the peer's
+// tracking refs (refs/remotes/{name}/...) apart from the remotes'. It
+// returns the paths it rejected.
+func (r *Repo) PullPeer(identity *crypto.Identity, name, url string) ([]string, error) {
+	rm := gogit.NewRemote(r.git.Storer, &config.RemoteConfig{
+		Name:  name,
+		URLs:  []string{url},
+		Fetch: []config.RefSpec{config.RefSpec("+refs/heads/*:refs/remotes/" + name + "/*")},
+	})
+	return r.fetchAndMerge(identity, rm, false)
+}
+
+// pullFrom fetches the current branch from a remote and merges what it
+// changed since the last common commit. It returns the paths it rejected.
+func (r *Repo) pullFrom(identity *crypto.Identity, rm Remote) ([]string, error) {
+	remote, err := r.git.Remote(rm.Name)
+	if err != nil {
+		return nil, err
+	}
+	return r.fetchAndMerge(identity, remote, rm.Role == RoleMirror)
+}
+
+func (r *Repo) fetchAndMerge(identity *crypto.Identity, remote *gogit.Remote, trusted bool) ([]string, error) {
+	name := remote.Config().Name
+	head, err := r.git.Head()
+	if err != nil {
+		return nil, fmt.Errorf("head: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), gitTimeout)
+	defer cancel()
+	if err := remote.FetchContext(ctx, &gogit.FetchOptions{RemoteName: name}); err != nil && err != gogit.NoErrAlreadyUpToDate {
+		return nil, fmt.Errorf("fetch: %w", err)
+	}
+	ref, err := r.git.Reference(plumbing.NewRemoteReferenceName(name, head.Name().Short()), true)
+	if err != nil {
+		return nil, nil // the remote does not have this branch yet
+	}
+	return r.mergeBranch(identity, name, head.Hash(), ref.Hash(), trusted)
+}
+
+// mergeBranch brings what the branch theirs changed since its merge base
+// with ours into the current branch. A trusted branch is fast-forwarded to
+// when ours has nothing of its own; otherwise every file it added, changed
+// or removed is merged three ways, and a file that both sides changed
+// differently fails the merge. From an untrusted branch only the mergeable
+// files it added are taken. A merge commit, signed by identity, records
+// that theirs was merged, so the next pull starts from it; when some of its
+// changes were rejected, the files taken are committed on their own
+// instead, since git would otherwise count the rejected changes as merged
+// and a push would undo them where they came from.
+func (r *Repo) mergeBranch(identity *crypto.Identity, remote string, ours, theirs plumbing.Hash, trusted bool) ([]string, error) {
+	if ours == theirs {
+		return nil, nil
+	}
+	oursCommit, err := r.git.CommitObject(ours)
+	if err != nil {
+		return nil, fmt.Errorf("commit %s: %w", ours, err)
+	}
+	theirsCommit, err := r.git.CommitObject(theirs)
+	if err != nil {
+		return nil, fmt.Errorf("commit %s: %w", theirs, err)
+	}
+	bases, err := oursCommit.MergeBase(theirsCommit)
+	if err != nil {
+		return nil, fmt.Errorf("merge base: %w", err)
+	}
+	baseTree := &object.Tree{}
+	var base plumbing.Hash
+	if len(bases) > 0 {
+		base = bases[0].Hash
+		if base == theirs {
+			return nil, nil // already merged
+		}
+		if baseTree, err = bases[0].Tree(); err != nil {
+			return nil, fmt.Errorf("tree of %s: %w", base, err)
+		}
+	}
+
+	wt, err := r.git.Worktree()
+	if err != nil {
+		return nil, fmt.Errorf("worktree: %w", err)
+	}
+	if base == ours && trusted {
+		if err := wt.Reset(&gogit.ResetOptions{Commit: theirs, Mode: gogit.MergeReset}); err != nil {
+			return nil, fmt.Errorf("fast-forward: %w", err)
+		}
+		return nil, nil
+	}
+
+	ourTree, err := oursCommit.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("tree of %s: %w", ours, err)
+	}
+	theirTree, err := theirsCommit.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("tree of %s: %w", theirs, err)
+	}
+	changes, err := object.DiffTree(baseTree, theirTree)
+	if err != nil {
+		return nil, fmt.Errorf("diff: %w", err)
+	}
+
+	taken := map[string]*object.File{} // nil removes the file
+	var rejected, conflicts []string
+	for _, ch := range changes {
+		action, err := ch.Action()
+		if err != nil {
+			return nil, err
+		}
+		p := ch.To.Name
+		if action == merkletrie.Delete {
+			p = ch.From.Name
+		}
+		if !trusted && (action != merkletrie.Insert || !mergeable(ourTree, p)) {
+			rejected = append(rejected, p)
+			continue
+		}
+		var f *object.File
+		var theirHash plumbing.Hash
+		if action != merkletrie.Delete {
+			if f, err = theirTree.File(p); err != nil {
+				return nil, fmt.Errorf("%s: %w", p, err)
+			}
+			theirHash = f.Hash
+		}
+		switch mine := blobAt(ourTree, p); {
+		case mine == theirHash:
+			// ours already has the change
+		case mine == blobAt(baseTree, p):
+			taken[p] = f
+		case trusted:
+			conflicts = append(conflicts, p)
+		default:
+			rejected = append(rejected, p) // added on both sides
+		}
+	}
+	if len(conflicts) > 0 {
+		sort.Strings(conflicts)
+		return nil, fmt.Errorf("%s and the local branch both changed %s; merge them with git", remote, strings.Join(conflicts, ", "))
+	}
+	sort.Strings(rejected)
+
+	for p, f := range taken {
+		abs := filepath.Join(r.Path, filepath.FromSlash(p))
+		if f == nil {
+			if err := os.Remove(abs); err != nil && !os.IsNotExist(err) {
+				return nil, fmt.Errorf("remove %s: %w", p, err)
+			}
+			if _, err := wt.Remove(p); err != nil && !errors.Is(err, index.ErrEntryNotFound) {
+				return nil, fmt.Errorf("git rm %s: %w", p, err)
+			}
+			continue
+		}
+		content, err := f.Contents()
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", p, err)
+		}
+		if err := os.MkdirAll(filepath.Dir(abs), 0o755); err != nil {
+			return nil, fmt.Errorf("create dirs for %s: %w", p, err)
+		}
+		if err := os.WriteFile(abs, []byte(content), 0o644); err != nil {
+			return nil, fmt.Errorf("write %s: %w", p, err)
+		}
+		if _, err := wt.Add(p); err != nil {
+			return nil, fmt.Errorf("git add %s: %w", p, err)
+		}
+	}
+	parents := []plumbing.Hash{ours, theirs}
+	msg := fmt.Sprintf("sync: merge %d changed files from %s", len(taken), remote)
+	if len(rejected) > 0 {
+		if len(taken) == 0 {
+			return rejected, nil
+		}
+		parents = []plumbing.Hash{ours}
+		msg = fmt.Sprintf("sync: copy %d new files from %s", len(taken), remote)
+	}
+	sig := &object.Signature{Name: identity.Username, Email: commitEmail(identity.Username), When: time.Now()}
+	if _, err := wt.Commit(msg, &gogit.CommitOptions{
+		Author:            sig,
+		Committer:         sig,
+		Parents:           parents,
+		AllowEmptyCommits: true,
+		Signer:            commitSigner{identity},
+	}); err != nil {
+		return nil, fmt.Errorf("merge commit: %w", err)
+	}
+	return rejected, nil
+}
+
+// blobAt returns the hash of the file at p in tree, or the zero hash when
+// tree has no such file.
+func blobAt(tree *object.Tree, p string) plumbing.Hash {
+	f, err := tree.File(p)
+	if err != nil {
+		return plumbing.ZeroHash
+	}
+	return f.Hash
+}
+
+// mergeable reports whether an untrusted remote may add the file at p: a
+// post, vote or thread state record in a thread directory of a category
+// that ours already has. Keys, vouches, join requests, profiles, bans,
+// roles, category metadata and the forum configuration only ever arrive
+// from a mirror.
+func mergeable(ours *object.Tree, p string) bool {
+	switch path.Ext(p) {
+	case ".md", ".vote", ".state":
+	default:
+		return false
+	}
+	thread := path.Dir(p)
+	category := path.Dir(thread)
+	if category == "." || strings.HasPrefix(path.Base(thread), ".") {
+		return false
+	}
+	if _, err := ours.File(category + "/META.toml"); err != nil {
+		return false
+	}
+	_, err := ours.File(thread + "/META.toml")
+	return err != nil // a subcategory is not a thread
+}
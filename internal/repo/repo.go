@@ -2,18 +2,21 @@
 package repo
 
 import (
-	"context"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	gogit "github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
 	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/utils/merkletrie"
 
 	"github.com/BurntSushi/toml"
 	"github.com/gosub/gitorum/internal/crypto"
@@ -27,6 +30,12 @@ type ForumMeta struct {
 	Description     string `toml:"description"`
 	AdminPubkey     string `toml:"admin_pubkey"`
 	AutoApproveKeys bool   `toml:"auto_approve_keys"` // approve join requests automatically on sync
+	// ProofOfWork is the proof of work, in leading zero bits, required of
+	// posts by authors without a key in keys/; 0 disables the requirement.
+	ProofOfWork int `toml:"proof_of_work,omitempty"`
+	// AutoApproveVouches approves a join request on sync once this many
+	// users with a key in keys/ vouch for the requested key; 0 disables it.
+	AutoApproveVouches int `toml:"auto_approve_vouches,omitempty"`
 }
 
 // JoinRequest represents a pending key request stored in requests/<username>.pub.
@@ -40,6 +49,15 @@ type Repo struct {
 	// Path is the absolute path to the repository working tree.
 	Path string
 	git  *gogit.Repository
+
+	mu           sync.Mutex // guards the activity and arrival caches and the sync outcomes
+	activity     map[string]UserActivity
+	activityHead string                   // HEAD the cache was computed at
+	pushAt       time.Time                // time of the last push attempt
+	pushErr      string                   // its error, empty when it succeeded
+	remoteStatus map[string]*RemoteStatus // last pull and push per remote
+	arrivals     map[string]time.Time     // when each file arrived; see Arrivals
+	arrivalsHead string                   // HEAD the arrivals were computed at
 }
 
 // Init creates a new forum repository at path.
@@ -103,7 +121,8 @@ func (r *Repo) ReadMeta() (*ForumMeta, error) {
 }
 
 // AddRemote adds a named remote (e.g. "origin") pointing at url.
-// If a remote with that name already exists it is replaced.
+// If a remote with that name already exists it is replaced, role included;
+// see SetRemoteRole.
 func (r *Repo) AddRemote(name, url string) error {
 	// Remove existing remote if present (ignore error).
 	_ = r.git.DeleteRemote(name)
@@ -215,18 +234,23 @@ func (r *Repo) RejectJoinRequest(adminIdentity *crypto.Identity, username string
 }
 
 // CreateCategory creates a new forum category by writing META.toml and
-// committing it to the repository.
-func (r *Repo) CreateCategory(identity *crypto.Identity, slug, name, description string) error {
-	catDir := filepath.Join(r.Path, slug)
+// committing it. slug may be a slash-separated path, e.g. "projects/gitorum",
+// to create a subcategory of an existing category. order positions the
+// category among its siblings and is omitted from META.toml when zero.
+func (r *Repo) CreateCategory(identity *crypto.Identity, slug, name, description string, order int) error {
+	catDir := filepath.Join(r.Path, filepath.FromSlash(slug))
 	if err := os.MkdirAll(catDir, 0o755); err != nil {
 		return fmt.Errorf("create category dir: %w", err)
 	}
 	content := fmt.Sprintf("name = %q\ndescription = %q\n", name, description)
+	if order != 0 {
+		content += fmt.Sprintf("order = %d\n", order)
+	}
 	if err := os.WriteFile(filepath.Join(catDir, "META.toml"), []byte(content), 0o644); err != nil {
 		return fmt.Errorf("write META.toml: %w", err)
 	}
 	return r.commitFiles(identity, fmt.Sprintf("category: add %s", slug),
-		filepath.Join(slug, "META.toml"))
+		filepath.Join(filepath.FromSlash(slug), "META.toml"))
 }
 
 // UpdateMeta rewrites GITORUM.toml with new metadata and commits the change.
@@ -239,37 +263,56 @@ func (r *Repo) UpdateMeta(identity *crypto.Identity, meta ForumMeta) error {
 
 // Categories returns the slugs of all forum categories found in the working
 // tree (directories that contain a META.toml file), sorted alphabetically.
+// Subcategories are directories with a META.toml inside a category; their
+// slug is the slash-separated path from the repository root.
 func (r *Repo) Categories() ([]string, error) {
-	entries, err := os.ReadDir(r.Path)
-	if err != nil {
-		return nil, fmt.Errorf("read repo root: %w", err)
-	}
 	var cats []string
-	for _, e := range entries {
-		if !e.IsDir() {
-			continue
+	var walk func(rel string) error
+	walk = func(rel string) error {
+		entries, err := os.ReadDir(filepath.Join(r.Path, filepath.FromSlash(rel)))
+		if err != nil {
+			return err
 		}
-		if _, err := os.Stat(filepath.Join(r.Path, e.Name(), "META.toml")); err == nil {
-			cats = append(cats, e.Name())
+		for _, e := range entries {
+			if !e.IsDir() || strings.HasPrefix(e.Name(), ".") {
+				continue
+			}
+			slug := e.Name()
+			if rel != "" {
+				slug = rel + "/" + slug
+			}
+			if _, err := os.Stat(filepath.Join(r.Path, filepath.FromSlash(slug), "META.toml")); err == nil {
+				cats = append(cats, slug)
+				if err := walk(slug); err != nil {
+					return err
+				}
+			}
 		}
+		return nil
+	}
+	if err := walk(""); err != nil {
+		return nil, fmt.Errorf("read categories: %w", err)
 	}
 	sort.Strings(cats)
 	return cats, nil
 }
 
-// IsSynced reports whether the local HEAD matches the remote tracking ref for
-// origin and the working tree is clean.  Returns (true, "") when no remote is
-// configured (nothing to sync).
+// IsSynced reports whether the working tree is clean and the local HEAD
+// matches the tracking ref of every remote with the push or mirror role.
+// remoteURL is origin's URL, or "" without one. Returns true when no remote
+// receives pushes (nothing to sync).
 func (r *Repo) IsSynced() (synced bool, remoteURL string) {
 	cfg, err := r.git.Config()
 	if err != nil {
 		return true, ""
 	}
-	origin, ok := cfg.Remotes["origin"]
-	if !ok || len(origin.URLs) == 0 {
-		return true, ""
+	if origin, ok := cfg.Remotes["origin"]; ok && len(origin.URLs) > 0 {
+		remoteURL = origin.URLs[0]
+	}
+	remotes, err := r.pushRemotes()
+	if err != nil || len(remotes) == 0 {
+		return true, remoteURL
 	}
-	remoteURL = origin.URLs[0]
 
 	// Check for uncommitted changes first.
 	wt, err := r.git.Worktree()
@@ -283,102 +326,131 @@ func (r *Repo) IsSynced() (synced bool, remoteURL string) {
 	if err != nil {
 		return false, remoteURL
 	}
-	remoteRef, err := r.git.Reference(
-		plumbing.NewRemoteReferenceName("origin", head.Name().Short()), true)
-	if err != nil {
-		// Remote ref not found means we haven't pushed yet.
-		return false, remoteURL
+	for _, rm := range remotes {
+		remoteRef, err := r.git.Reference(
+			plumbing.NewRemoteReferenceName(rm.Name, head.Name().Short()), true)
+		if err != nil || remoteRef.Hash() != head.Hash() {
+			// A missing ref means we haven't pushed there yet.
+			return false, remoteURL
+		}
 	}
-	return head.Hash() == remoteRef.Hash(), remoteURL
+	return true, remoteURL
 }
 
 // CommitPost writes content to relPath (relative to the repo root), stages
 // that single file, and creates a commit.
 func (r *Repo) CommitPost(identity *crypto.Identity, relPath string, content []byte) error {
+	return r.CommitFile(identity, relPath, content, "post: add "+relPath)
+}
+
+// CommitFile writes content to relPath (relative to the repo root), stages
+// that single file, and commits it with message.
+func (r *Repo) CommitFile(identity *crypto.Identity, relPath string, content []byte, message string) error {
 	absPath := filepath.Join(r.Path, relPath)
 	if err := os.MkdirAll(filepath.Dir(absPath), 0o755); err != nil {
 		return fmt.Errorf("create dirs for %s: %w", relPath, err)
 	}
 	if err := os.WriteFile(absPath, content, 0o644); err != nil {
-		return fmt.Errorf("write post file: %w", err)
+		return fmt.Errorf("write %s: %w", relPath, err)
 	}
+	return r.commitFiles(identity, message, relPath)
+}
+
+// CommitMoves renames files and writes new ones in a single commit. moves
+// maps old paths to new ones, and an empty new path removes the file;
+// writes are applied after the moves. Paths are relative to the repository
+// root. Files that were never committed are moved all the same.
+func (r *Repo) CommitMoves(identity *crypto.Identity, message string, moves map[string]string, writes map[string][]byte) error {
+	var changed []string
+	for from, to := range moves {
+		absFrom := filepath.Join(r.Path, filepath.FromSlash(from))
+		if to == "" {
+			if err := os.Remove(absFrom); err != nil {
+				return fmt.Errorf("remove %s: %w", from, err)
+			}
+			changed = append(changed, from)
+			continue
+		}
+		absTo := filepath.Join(r.Path, filepath.FromSlash(to))
+		if err := os.MkdirAll(filepath.Dir(absTo), 0o755); err != nil {
+			return fmt.Errorf("create dirs for %s: %w", to, err)
+		}
+		if err := os.Rename(absFrom, absTo); err != nil {
+			return fmt.Errorf("move %s: %w", from, err)
+		}
+		changed = append(changed, from, to)
+	}
+	for rel, content := range writes {
+		absPath := filepath.Join(r.Path, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(absPath), 0o755); err != nil {
+			return fmt.Errorf("create dirs for %s: %w", rel, err)
+		}
+		if err := os.WriteFile(absPath, content, 0o644); err != nil {
+			return fmt.Errorf("write %s: %w", rel, err)
+		}
+		changed = append(changed, rel)
+	}
+
 	wt, err := r.git.Worktree()
 	if err != nil {
 		return fmt.Errorf("worktree: %w", err)
 	}
-	if _, err := wt.Add(relPath); err != nil {
-		return fmt.Errorf("git add %s: %w", relPath, err)
-	}
-	sig := &object.Signature{
-		Name:  identity.Username,
-		Email: identity.Username + "@gitorum.local",
-		When:  time.Now(),
-	}
-	if _, err := wt.Commit("post: add "+relPath, &gogit.CommitOptions{
-		Author:    sig,
-		Committer: sig,
-	}); err != nil {
-		return fmt.Errorf("git commit: %w", err)
+	var staged []string
+	for _, rel := range changed {
+		if _, err := os.Stat(filepath.Join(r.Path, filepath.FromSlash(rel))); err == nil {
+			staged = append(staged, rel)
+			continue
+		}
+		if _, err := wt.Remove(rel); err != nil && !errors.Is(err, index.ErrEntryNotFound) {
+			return fmt.Errorf("git rm %s: %w", rel, err)
+		}
 	}
-	return nil
+	return r.commitFiles(identity, message, staged...)
 }
 
-// Pull fetches from origin and fast-forward merges into the current branch.
-// Returns nil when there is no remote configured or the ref is already up to
-// date. Merge conflicts are returned as errors. A 30-second timeout applies.
-func (r *Repo) Pull() error {
-	cfg, err := r.git.Config()
+// HeadHash returns the hash of the commit HEAD points to, or "" when the
+// repository has no commits.
+func (r *Repo) HeadHash() string {
+	head, err := r.git.Head()
 	if err != nil {
-		return err
-	}
-	if _, ok := cfg.Remotes["origin"]; !ok {
-		return nil
+		return ""
 	}
+	return head.Hash().String()
+}
 
+// AddedFiles returns the paths of files present at HEAD that did not exist
+// at commit since, sorted. An empty since lists every file at HEAD. Callers
+// record HeadHash before a Pull to learn which files the pull brought in.
+func (r *Repo) AddedFiles(since string) ([]string, error) {
 	head, err := r.git.Head()
 	if err != nil {
-		return fmt.Errorf("head: %w", err)
+		return nil, fmt.Errorf("head: %w", err)
 	}
-
-	wt, err := r.git.Worktree()
+	if head.Hash().String() == since {
+		return nil, nil
+	}
+	to, err := r.commitTree(head.Hash())
 	if err != nil {
-		return fmt.Errorf("worktree: %w", err)
+		return nil, err
 	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), gitTimeout)
-	defer cancel()
-
-	err = wt.PullContext(ctx, &gogit.PullOptions{
-		RemoteName:    "origin",
-		ReferenceName: head.Name(),
-		Force:         false,
-	})
-	if err == gogit.NoErrAlreadyUpToDate {
-		return nil
+	from := &object.Tree{}
+	if since != "" {
+		if from, err = r.commitTree(plumbing.NewHash(since)); err != nil {
+			return nil, err
+		}
 	}
-	return err
-}
-
-// Push attempts to push to the origin remote. Returns nil if there is no
-// remote configured or the ref is already up to date. A 30-second timeout
-// applies.
-func (r *Repo) Push() error {
-	cfg, err := r.git.Config()
+	changes, err := object.DiffTree(from, to)
 	if err != nil {
-		return err
-	}
-	if _, ok := cfg.Remotes["origin"]; !ok {
-		return nil
+		return nil, fmt.Errorf("diff trees: %w", err)
 	}
-
-	ctx, cancel := context.WithTimeout(context.Background(), gitTimeout)
-	defer cancel()
-
-	err = r.git.PushContext(ctx, &gogit.PushOptions{RemoteName: "origin"})
-	if err == nil || err == gogit.NoErrAlreadyUpToDate {
-		return nil
+	var added []string
+	for _, c := range changes {
+		if action, err := c.Action(); err == nil && action == merkletrie.Insert {
+			added = append(added, c.To.Name)
+		}
 	}
-	return err
+	sort.Strings(added)
+	return added, nil
 }
 
 // Git returns the underlying go-git repository for advanced callers.
@@ -408,7 +480,20 @@ func (r *Repo) writePublicKey(username, pubkeyB64 string) error {
 	return os.WriteFile(path, []byte(pubkeyB64+"\n"), 0o644)
 }
 
-// commitFiles stages the specified relative paths and creates a commit.
+func (r *Repo) commitTree(hash plumbing.Hash) (*object.Tree, error) {
+	commit, err := r.git.CommitObject(hash)
+	if err != nil {
+		return nil, fmt.Errorf("commit %s: %w", hash, err)
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("tree of %s: %w", hash, err)
+	}
+	return tree, nil
+}
+
+// commitFiles stages the specified relative paths and creates a commit
+// signed with the identity's key.
 func (r *Repo) commitFiles(identity *crypto.Identity, message string, relPaths ...string) error {
 	wt, err := r.git.Worktree()
 	if err != nil {
@@ -421,12 +506,13 @@ func (r *Repo) commitFiles(identity *crypto.Identity, message string, relPaths .
 	}
 	sig := &object.Signature{
 		Name:  identity.Username,
-		Email: identity.Username + "@gitorum.local",
+		Email: commitEmail(identity.Username),
 		When:  time.Now(),
 	}
 	if _, err := wt.Commit(message, &gogit.CommitOptions{
 		Author:    sig,
 		Committer: sig,
+		Signer:    commitSigner{identity},
 	}); err != nil {
 		return fmt.Errorf("git commit: %w", err)
 	}
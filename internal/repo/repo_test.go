@@ -1,6 +1,8 @@
 package repo_test
 
 import (
+	"errors"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -336,7 +338,7 @@ func TestRepo_PullPush(t *testing.T) {
 	}
 
 	// Alice pulls.
-	if err := r.Pull(); err != nil {
+	if err := r.Pull(id); err != nil {
 		t.Fatalf("Pull: %v", err)
 	}
 
@@ -347,7 +349,7 @@ func TestRepo_PullPush(t *testing.T) {
 	}
 
 	// Pull again — must be a no-op (already up to date).
-	if err := r.Pull(); err != nil {
+	if err := r.Pull(id); err != nil {
 		t.Errorf("second Pull (already up to date): %v", err)
 	}
 }
@@ -512,3 +514,797 @@ func checkFile(t *testing.T, path string) {
 		t.Errorf("expected file %s to exist: %v", path, err)
 	}
 }
+
+// ---- AddedFiles ----
+
+func TestAddedFiles(t *testing.T) {
+	id := newIdentity(t, "alice")
+	r, err := repo.Init(t.TempDir(), repo.ForumMeta{Name: "Forum", AdminPubkey: id.PublicKey}, id)
+	if err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	before := r.HeadHash()
+	if before == "" {
+		t.Fatal("HeadHash: empty after Init")
+	}
+
+	if err := r.CommitPost(id, "general/hello/0000_root.md", []byte("hello")); err != nil {
+		t.Fatalf("CommitPost: %v", err)
+	}
+	added, err := r.AddedFiles(before)
+	if err != nil {
+		t.Fatalf("AddedFiles: %v", err)
+	}
+	if len(added) != 1 || added[0] != "general/hello/0000_root.md" {
+		t.Errorf("AddedFiles since init: got %v", added)
+	}
+
+	if added, err := r.AddedFiles(r.HeadHash()); err != nil || len(added) != 0 {
+		t.Errorf("AddedFiles since HEAD: got %v, %v", added, err)
+	}
+
+	all, err := r.AddedFiles("")
+	if err != nil {
+		t.Fatalf("AddedFiles(\"\"): %v", err)
+	}
+	want := []string{"GITORUM.toml", "general/hello/0000_root.md", "keys/alice.pub"}
+	if strings.Join(all, ",") != strings.Join(want, ",") {
+		t.Errorf("AddedFiles(\"\"): got %v, want %v", all, want)
+	}
+}
+
+func TestCategories_Nested(t *testing.T) {
+	id := newIdentity(t, "alice")
+	r, err := repo.Init(t.TempDir(), repo.ForumMeta{Name: "Forum", AdminPubkey: id.PublicKey}, id)
+	if err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	for _, slug := range []string{"projects", "projects/gitorum", "projects/gitorum/bugs", "general"} {
+		if err := r.CreateCategory(id, slug, slug, "", 0); err != nil {
+			t.Fatalf("CreateCategory %s: %v", slug, err)
+		}
+	}
+	// A thread directory is not descended into.
+	if err := r.CommitPost(id, "general/hello/0000_root.md", []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	cats, err := r.Categories()
+	if err != nil {
+		t.Fatalf("Categories: %v", err)
+	}
+	want := "general,projects,projects/gitorum,projects/gitorum/bugs"
+	if got := strings.Join(cats, ","); got != want {
+		t.Errorf("Categories: got %s, want %s", got, want)
+	}
+	if synced, _ := r.IsSynced(); !synced {
+		t.Error("working tree not clean after CreateCategory")
+	}
+}
+
+func TestUserActivity(t *testing.T) {
+	alice := newIdentity(t, "alice")
+	bob := newIdentity(t, "bob")
+	r, err := repo.Init(t.TempDir(), repo.ForumMeta{Name: "Forum", AdminPubkey: alice.PublicKey}, alice)
+	if err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	if err := r.CommitPost(alice, "general/hello/0000_root.md", []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	a, err := r.UserActivity("alice")
+	if err != nil {
+		t.Fatalf("UserActivity: %v", err)
+	}
+	if a.JoinedAt.IsZero() || a.Posts != 1 {
+		t.Errorf("alice: %+v", a)
+	}
+	if b, _ := r.UserActivity("bob"); !b.JoinedAt.IsZero() || b.Posts != 0 {
+		t.Errorf("bob before joining: %+v", b)
+	}
+
+	// The cache follows HEAD.
+	if err := r.WritePublicKey(alice, "bob", bob.PublicKey); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.CommitPost(bob, "general/hello/1_reply.md", []byte("hi")); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.CommitFile(bob, "profiles/bob.toml", []byte("profile"), "profile: update @bob"); err != nil {
+		t.Fatal(err)
+	}
+	b, err := r.UserActivity("bob")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.JoinedAt.Before(a.JoinedAt) || b.Posts != 1 {
+		t.Errorf("bob after joining: %+v", b)
+	}
+}
+
+func TestCommitMoves(t *testing.T) {
+	id := newIdentity(t, "alice")
+	r, err := repo.Init(t.TempDir(), repo.ForumMeta{Name: "Forum", AdminPubkey: id.PublicKey}, id)
+	if err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	if err := r.CommitPost(id, "general/old/0000_root.md", []byte("root")); err != nil {
+		t.Fatalf("CommitPost: %v", err)
+	}
+	if err := r.CommitPost(id, "general/old/poll.toml", []byte("poll")); err != nil {
+		t.Fatalf("CommitPost: %v", err)
+	}
+	// A file that was never committed moves too.
+	if err := os.WriteFile(filepath.Join(r.Path, "general", "old", "1_aaaaaaaa.md"), []byte("reply"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	before := r.HeadHash()
+
+	moves := map[string]string{
+		"general/old/0000_root.md":  "general/new/0000_root.md",
+		"general/old/1_aaaaaaaa.md": "general/new/1_aaaaaaaa.md",
+		"general/old/poll.toml":     "",
+	}
+	writes := map[string][]byte{"general/old/REDIRECT.toml": []byte("redirect")}
+	if err := r.CommitMoves(id, "thread: move", moves, writes); err != nil {
+		t.Fatalf("CommitMoves: %v", err)
+	}
+
+	added, err := r.AddedFiles(before)
+	if err != nil {
+		t.Fatalf("AddedFiles: %v", err)
+	}
+	want := []string{"general/new/0000_root.md", "general/new/1_aaaaaaaa.md", "general/old/REDIRECT.toml"}
+	if strings.Join(added, ",") != strings.Join(want, ",") {
+		t.Errorf("AddedFiles: got %v, want %v", added, want)
+	}
+
+	ref, err := r.Git().Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	commit, err := r.Git().CommitObject(ref.Hash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, gone := range []string{"general/old/0000_root.md", "general/old/poll.toml"} {
+		if _, err := tree.File(gone); err == nil {
+			t.Errorf("%s still in the tree", gone)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(r.Path, "general", "old", "poll.toml")); !os.IsNotExist(err) {
+		t.Errorf("poll.toml not removed: %v", err)
+	}
+}
+
+func TestOutbox(t *testing.T) {
+	id := newIdentity(t, "alice")
+	r, err := repo.Init(t.TempDir(), repo.ForumMeta{Name: "Forum", AdminPubkey: id.PublicKey}, id)
+	if err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	if out, err := r.Outbox(); err != nil || len(out.Commits) != 0 {
+		t.Fatalf("Outbox without origin: %+v, %v", out, err)
+	}
+	if err := r.AddRemote("origin", newBareRemote(t)); err != nil {
+		t.Fatalf("AddRemote: %v", err)
+	}
+	if err := r.Push(); err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+
+	for _, p := range []string{"general/a/0000_root.md", "general/b/0000_root.md", "general/c/0000_root.md"} {
+		if err := r.CommitPost(id, p, []byte(p)); err != nil {
+			t.Fatalf("CommitPost: %v", err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(r.Path, "stray.txt"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out, err := r.Outbox()
+	if err != nil {
+		t.Fatalf("Outbox: %v", err)
+	}
+	if len(out.Commits) != 3 || out.Commits[0].Files[0] != "general/a/0000_root.md" || out.Commits[0].Author != "alice" {
+		t.Fatalf("Commits: %+v", out.Commits)
+	}
+	if strings.Join(out.Uncommitted, ",") != "stray.txt" {
+		t.Errorf("Uncommitted: %v", out.Uncommitted)
+	}
+
+	if err := r.DiscardCommit(id, out.Commits[1].Hash); err == nil {
+		t.Error("DiscardCommit with a dirty working tree: want error")
+	}
+	if err := r.DiscardUncommitted("stray.txt"); err != nil {
+		t.Fatalf("DiscardUncommitted: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(r.Path, "stray.txt")); !os.IsNotExist(err) {
+		t.Errorf("stray.txt not removed: %v", err)
+	}
+	if err := r.DiscardCommit(id, out.Commits[1].Hash); err != nil {
+		t.Fatalf("DiscardCommit: %v", err)
+	}
+	out, err = r.Outbox()
+	if err != nil {
+		t.Fatalf("Outbox: %v", err)
+	}
+	if len(out.Commits) != 2 || out.Commits[1].Files[0] != "general/c/0000_root.md" || len(out.Uncommitted) != 0 {
+		t.Fatalf("after discard: %+v", out)
+	}
+	if _, err := os.Stat(filepath.Join(r.Path, "general", "b")); !os.IsNotExist(err) {
+		t.Errorf("discarded post still on disk: %v", err)
+	}
+	if v, err := r.VerifyCommit(out.Commits[1].Hash); err != nil || v.Status != repo.CommitSigValid {
+		t.Errorf("replayed commit: %+v, %v", v, err)
+	}
+	if err := r.DiscardCommit(id, "0123456789abcdef0123456789abcdef01234567"); err == nil {
+		t.Error("DiscardCommit of an unknown commit: want error")
+	}
+
+	// A failed push is reported against the commits it did not deliver.
+	cfg, err := r.Git().Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+	good := cfg.Remotes["origin"].URLs
+	cfg.Remotes["origin"].URLs = []string{filepath.Join(t.TempDir(), "missing")}
+	if err := r.Git().SetConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Push(); err == nil {
+		t.Fatal("Push to a missing remote: want error")
+	}
+	if out, _ = r.Outbox(); out.LastPushErr == "" || out.Commits[0].PushError == "" {
+		t.Errorf("push error not reported: %+v", out)
+	}
+
+	cfg.Remotes["origin"].URLs = good
+	if err := r.Git().SetConfig(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Push(); err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+	if out, _ = r.Outbox(); len(out.Commits) != 0 || out.LastPushErr != "" {
+		t.Errorf("after push: %+v", out)
+	}
+}
+
+// TestOutbox_PushRemotes verifies that pending commits are counted against
+// the remotes that receive pushes, whatever their names.
+func TestOutbox_PushRemotes(t *testing.T) {
+	id := newIdentity(t, "alice")
+	r, err := repo.Init(t.TempDir(), repo.ForumMeta{Name: "Forum", AdminPubkey: id.PublicKey}, id)
+	if err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	for _, name := range []string{"origin", "backup"} {
+		if err := r.AddRemote(name, newBareRemote(t)); err != nil {
+			t.Fatalf("AddRemote %s: %v", name, err)
+		}
+	}
+	if err := r.SetRemoteRole("origin", repo.RolePull); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.SetRemoteRole("backup", repo.RolePush); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.CommitPost(id, "general/a/0000_root.md", []byte("a")); err != nil {
+		t.Fatal(err)
+	}
+	if out, err := r.Outbox(); err != nil || len(out.Commits) != 2 {
+		t.Fatalf("before push: %+v, %v", out, err)
+	}
+	if synced, _ := r.IsSynced(); synced {
+		t.Error("synced before push")
+	}
+
+	if err := r.Push(); err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+	if out, err := r.Outbox(); err != nil || len(out.Commits) != 0 {
+		t.Errorf("after push: %+v, %v", out, err)
+	}
+	if synced, _ := r.IsSynced(); !synced {
+		t.Error("not synced after push")
+	}
+}
+
+// ---- Remotes ----
+
+func TestRemotes(t *testing.T) {
+	id := newIdentity(t, "alice")
+	r, err := repo.Init(t.TempDir(), repo.ForumMeta{Name: "Forum", AdminPubkey: id.PublicKey}, id)
+	if err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	if err := r.CreateCategory(id, "general", "General", "", 0); err != nil {
+		t.Fatalf("CreateCategory: %v", err)
+	}
+	peer := newBareRemote(t)
+	if err := r.AddRemote("origin", newBareRemote(t)); err != nil {
+		t.Fatalf("AddRemote origin: %v", err)
+	}
+	if err := r.AddRemote("peer", peer); err != nil {
+		t.Fatalf("AddRemote peer: %v", err)
+	}
+	if err := r.SetRemoteRole("peer", "everything"); err == nil {
+		t.Error("SetRemoteRole with an unknown role: want error")
+	}
+	if err := r.SetRemoteRole("peer", repo.RolePush); err != nil {
+		t.Fatalf("SetRemoteRole: %v", err)
+	}
+	remotes, err := r.Remotes()
+	if err != nil || len(remotes) != 2 || remotes[0].Role != repo.RoleMirror || remotes[1].Role != repo.RolePush {
+		t.Fatalf("Remotes: %+v, %v", remotes, err)
+	}
+	if err := r.Push(); err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+
+	// The peer adds a post, approves its own key and edits the forum
+	// metadata; only the post may come back.
+	bobDir := t.TempDir()
+	bobGr, err := gogit.PlainClone(bobDir, false, &gogit.CloneOptions{URL: peer})
+	if err != nil {
+		t.Fatalf("PlainClone: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(bobDir, "general", "bobs-post"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(bobDir, "general", "bobs-post", "0000_root.md"), []byte("hello from bob"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(bobDir, "GITORUM.toml"), []byte("name = \"Bob's forum\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(bobDir, "keys", "bob.pub"), []byte(newIdentity(t, "bob").PublicKey), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	bobWt, err := bobGr.Worktree()
+	if err != nil {
+		t.Fatalf("bob worktree: %v", err)
+	}
+	if err := bobWt.AddGlob("."); err != nil {
+		t.Fatalf("bob add: %v", err)
+	}
+	bobSig := &object.Signature{Name: "bob", Email: "bob@gitorum.local", When: time.Now()}
+	if _, err := bobWt.Commit("post: add bobs-post", &gogit.CommitOptions{Author: bobSig, Committer: bobSig}); err != nil {
+		t.Fatalf("bob commit: %v", err)
+	}
+	if err := bobGr.Push(&gogit.PushOptions{RemoteName: "origin"}); err != nil {
+		t.Fatalf("bob push: %v", err)
+	}
+
+	// Meanwhile alice posts too, and one peer is unreachable.
+	if err := r.CommitPost(id, "general/alices-post/0000_root.md", []byte("hello from alice")); err != nil {
+		t.Fatalf("CommitPost: %v", err)
+	}
+	if err := r.AddRemote("dead", filepath.Join(t.TempDir(), "missing")); err != nil {
+		t.Fatalf("AddRemote dead: %v", err)
+	}
+	if err := r.SetRemoteRole("peer", repo.RolePull); err != nil {
+		t.Fatalf("SetRemoteRole: %v", err)
+	}
+	if err := r.Pull(id); err == nil || !strings.Contains(err.Error(), "dead") {
+		t.Fatalf("Pull with an unreachable peer: want its error, got %v", err)
+	}
+
+	if b, err := os.ReadFile(filepath.Join(r.Path, "general", "bobs-post", "0000_root.md")); err != nil || string(b) != "hello from bob" {
+		t.Errorf("peer post not merged: %q, %v", b, err)
+	}
+	if meta, err := r.ReadMeta(); err != nil || meta.Name != "Forum" {
+		t.Errorf("peer edit of GITORUM.toml merged: %+v, %v", meta, err)
+	}
+	if _, err := os.Stat(filepath.Join(r.Path, "keys", "bob.pub")); !os.IsNotExist(err) {
+		t.Errorf("peer key merged: %v", err)
+	}
+	status, err := r.RemoteStatus()
+	if err != nil {
+		t.Fatalf("RemoteStatus: %v", err)
+	}
+	for _, st := range status {
+		switch st.Name {
+		case "peer":
+			if st.LastPullAt.IsZero() || st.LastPullErr != "" || strings.Join(st.Rejected, ",") != "GITORUM.toml,keys/bob.pub" {
+				t.Errorf("peer status: %+v", st)
+			}
+		case "dead":
+			if st.LastPullErr == "" {
+				t.Errorf("dead status: %+v", st)
+			}
+		}
+	}
+
+	// The merge commit records the peer's head, so pulling again changes
+	// nothing.
+	head := r.HeadHash()
+	if err := r.RemoveRemote("dead"); err != nil {
+		t.Fatalf("RemoveRemote: %v", err)
+	}
+	if err := r.Pull(id); err != nil {
+		t.Fatalf("second Pull: %v", err)
+	}
+	if r.HeadHash() != head {
+		t.Error("second Pull made a new commit")
+	}
+}
+
+// TestPullPeer verifies that a peer that is not a configured remote only
+// adds posts to existing categories: its keys and configuration are
+// rejected and its history is never fast-forwarded to.
+func TestPull_MirrorDiverged(t *testing.T) {
+	id := newIdentity(t, "alice")
+	bare := newBareRemote(t)
+	r, err := repo.Init(t.TempDir(), repo.ForumMeta{Name: "Forum", AdminPubkey: id.PublicKey}, id)
+	if err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	if err := r.AddRemote("origin", bare); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Push(); err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+
+	// The admin changes the forum settings on the mirror from another
+	// checkout, while a post waits to be pushed locally.
+	other := t.TempDir()
+	if _, err := gogit.PlainClone(other, false, &gogit.CloneOptions{URL: bare}); err != nil {
+		t.Fatalf("PlainClone: %v", err)
+	}
+	r2, err := repo.Open(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta, err := r2.ReadMeta()
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta.ProofOfWork = 8
+	if err := r2.UpdateMeta(id, *meta); err != nil {
+		t.Fatal(err)
+	}
+	if err := r2.CommitFile(id, "bans.toml", []byte("mirror"), "ban"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r2.Push(); err != nil {
+		t.Fatalf("Push from the other checkout: %v", err)
+	}
+	if err := r.CommitPost(id, "general/a/0000_root.md", []byte("a")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.Pull(id); err != nil {
+		t.Fatalf("Pull: %v", err)
+	}
+	if meta, err := r.ReadMeta(); err != nil || meta.ProofOfWork != 8 {
+		t.Errorf("after pull: %+v, %v", meta, err)
+	}
+	if _, err := os.Stat(filepath.Join(r.Path, "general", "a", "0000_root.md")); err != nil {
+		t.Errorf("local post lost: %v", err)
+	}
+	if err := r.Push(); err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+	if err := r2.Pull(id); err != nil {
+		t.Fatalf("Pull into the other checkout: %v", err)
+	}
+	if meta, err := r2.ReadMeta(); err != nil || meta.ProofOfWork != 8 {
+		t.Errorf("mirror after push: %+v, %v", meta, err)
+	}
+
+	// Both sides changing the same file fails the pull and keeps HEAD.
+	if err := r2.CommitFile(id, "bans.toml", []byte("theirs"), "ban"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r2.Push(); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.CommitFile(id, "bans.toml", []byte("ours"), "ban"); err != nil {
+		t.Fatal(err)
+	}
+	head := r.HeadHash()
+	if err := r.Pull(id); err == nil || !strings.Contains(err.Error(), "bans.toml") {
+		t.Errorf("conflicting pull: %v", err)
+	}
+	if r.HeadHash() != head {
+		t.Error("conflicting pull moved HEAD")
+	}
+}
+
+func TestPullPeer(t *testing.T) {
+	id := newIdentity(t, "alice")
+	r, err := repo.Init(t.TempDir(), repo.ForumMeta{Name: "Forum", AdminPubkey: id.PublicKey}, id)
+	if err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	if err := r.CreateCategory(id, "general", "General", "", 0); err != nil {
+		t.Fatalf("CreateCategory: %v", err)
+	}
+
+	peerDir := t.TempDir()
+	peerGr, err := gogit.PlainClone(peerDir, false, &gogit.CloneOptions{URL: r.Path})
+	if err != nil {
+		t.Fatalf("PlainClone: %v", err)
+	}
+	mallory := newIdentity(t, "mallory")
+	files := map[string]string{
+		"general/from-peer/0000_root.md": "hello from the peer",
+		"keys/mallory.pub":               mallory.PublicKey,
+		"vouches/mallory.toml":           "vouch",
+		"bans.toml":                      "ban",
+		"elsewhere/thread/0000_root.md":  "no such category",
+		"general/META.toml":              "name = \"Mine\"\n",
+	}
+	for p, content := range files {
+		abs := filepath.Join(peerDir, filepath.FromSlash(p))
+		if err := os.MkdirAll(filepath.Dir(abs), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(abs, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wt, err := peerGr.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := wt.AddGlob("."); err != nil {
+		t.Fatal(err)
+	}
+	sig := &object.Signature{Name: "mallory", Email: "mallory@gitorum.local", When: time.Now()}
+	peerHead, err := wt.Commit("post: from-peer", &gogit.CommitOptions{Author: sig, Committer: sig})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rejected, err := r.PullPeer(id, "lan-peer", peerDir)
+	if err != nil {
+		t.Fatalf("PullPeer: %v", err)
+	}
+	want := "bans.toml,elsewhere/thread/0000_root.md,general/META.toml,keys/mallory.pub,vouches/mallory.toml"
+	if got := strings.Join(rejected, ","); got != want {
+		t.Errorf("rejected:\n got %s\nwant %s", got, want)
+	}
+	if r.HeadHash() == peerHead.String() {
+		t.Error("fast-forwarded to the peer")
+	}
+	// With changes rejected, the peer's commit must not be recorded as
+	// merged, or a push would carry it without them.
+	log, err := r.Log()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if log[0].Merge || !strings.HasPrefix(log[0].Message, "sync: copy 1 new files") {
+		t.Errorf("sync commit: %+v", log[0])
+	}
+	if v, err := r.VerifyCommit(r.HeadHash()); err != nil || v.Status != repo.CommitSigValid || v.Committer != "alice" {
+		t.Errorf("merge commit: %+v, %v", v, err)
+	}
+	if _, err := os.Stat(filepath.Join(r.Path, "general", "from-peer", "0000_root.md")); err != nil {
+		t.Errorf("peer post not merged: %v", err)
+	}
+	for _, p := range []string{"keys/mallory.pub", "vouches/mallory.toml", "bans.toml"} {
+		if _, err := os.Stat(filepath.Join(r.Path, filepath.FromSlash(p))); !os.IsNotExist(err) {
+			t.Errorf("%s merged from the peer: %v", p, err)
+		}
+	}
+}
+
+// ---- Commit signatures ----
+
+// honestSigner signs commits with any key, as git would.
+type honestSigner struct{ id *crypto.Identity }
+
+func (s honestSigner) Sign(message io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(message)
+	if err != nil {
+		return nil, err
+	}
+	return s.id.SignSSH(crypto.GitNamespace, data)
+}
+
+// badSigner signs something other than the commit.
+type badSigner struct{ id *crypto.Identity }
+
+func (s badSigner) Sign(io.Reader) ([]byte, error) {
+	return s.id.SignSSH(crypto.GitNamespace, []byte("something else"))
+}
+
+func TestVerifyCommits(t *testing.T) {
+	alice, bob, mallory := newIdentity(t, "alice"), newIdentity(t, "bob"), newIdentity(t, "mallory")
+	r, err := repo.Init(t.TempDir(), repo.ForumMeta{Name: "Forum", AdminPubkey: alice.PublicKey}, alice)
+	if err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	if err := r.WritePublicKey(alice, "bob", bob.PublicKey); err != nil {
+		t.Fatalf("WritePublicKey: %v", err)
+	}
+	if err := r.CommitPost(bob, "general/a/0000_root.md", []byte("a")); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.CommitPost(mallory, "general/b/0000_root.md", []byte("b")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Commits made behind gitorum's back: unsigned, impersonating bob, and
+	// with a signature that does not match.
+	gr, err := gogit.PlainOpen(r.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wt, err := gr.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bobSig := &object.Signature{Name: "bob", Email: "bob@gitorum.local", When: time.Now()}
+	for _, signer := range []gogit.Signer{nil, honestSigner{mallory}, badSigner{bob}} {
+		if _, err := wt.Commit("sneaky", &gogit.CommitOptions{
+			Author: bobSig, Committer: bobSig, Signer: signer, AllowEmptyCommits: true,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	commits, err := r.VerifyCommits()
+	if err != nil {
+		t.Fatalf("VerifyCommits: %v", err)
+	}
+	var got []string
+	for _, c := range commits {
+		got = append(got, c.Committer+":"+string(c.Status))
+	}
+	want := "bob:invalid bob:unknown bob:unsigned mallory:unknown bob:valid alice:valid alice:valid"
+	if strings.Join(got, " ") != want {
+		t.Errorf("statuses:\n got %s\nwant %s", strings.Join(got, " "), want)
+	}
+
+	signers, err := r.AllowedSigners()
+	if err != nil {
+		t.Fatalf("AllowedSigners: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(signers), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], `alice@gitorum.local namespaces="git" ssh-ed25519 `) {
+		t.Errorf("AllowedSigners:\n%s", signers)
+	}
+}
+
+func TestProvenance(t *testing.T) {
+	alice, bob := newIdentity(t, "alice"), newIdentity(t, "bob")
+	r, err := repo.Init(t.TempDir(), repo.ForumMeta{Name: "Forum", AdminPubkey: alice.PublicKey}, alice)
+	if err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	if err := r.WritePublicKey(alice, "bob", bob.PublicKey); err != nil {
+		t.Fatalf("WritePublicKey: %v", err)
+	}
+	if err := r.CommitPost(bob, "general/old/0000_root.md", []byte("root")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Provenance("general/old/missing.md"); !errors.Is(err, repo.ErrNotInHistory) {
+		t.Errorf("missing file: err = %v, want ErrNotInHistory", err)
+	}
+	moves := map[string]string{"general/old/0000_root.md": "general/new/0000_root.md"}
+	if err := r.CommitMoves(alice, "thread: move general/old to general/new", moves, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.CommitFile(alice, "general/new/0000_root.md", []byte("edited"), "post: edit"); err != nil {
+		t.Fatal(err)
+	}
+
+	prov, err := r.Provenance("general/new/0000_root.md")
+	if err != nil {
+		t.Fatalf("Provenance: %v", err)
+	}
+	if prov.Path != "general/old/0000_root.md" || prov.Introduced.Committer != "bob" || prov.Introduced.Status != repo.CommitSigValid {
+		t.Errorf("introduced: %+v at %s", prov.Introduced, prov.Path)
+	}
+	var later []string
+	for _, touch := range prov.Later {
+		later = append(later, touch.Kind+":"+touch.Committer+":"+touch.From)
+	}
+	if want := "move:alice:general/old/0000_root.md modify:alice:"; strings.Join(later, " ") != want {
+		t.Errorf("later: got %s, want %s", strings.Join(later, " "), want)
+	}
+}
+
+func TestArrivals(t *testing.T) {
+	alice := newIdentity(t, "alice")
+	r, err := repo.Init(t.TempDir(), repo.ForumMeta{Name: "Forum", AdminPubkey: alice.PublicKey}, alice)
+	if err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	if err := r.CommitPost(alice, "general/old/0000_root.md", []byte("root")); err != nil {
+		t.Fatal(err)
+	}
+	before, err := r.Arrivals()
+	if err != nil {
+		t.Fatalf("Arrivals: %v", err)
+	}
+	posted := before["general/old/0000_root.md"]
+	if posted.IsZero() {
+		t.Fatalf("no arrival for the post: %v", before)
+	}
+
+	// Commit times have second resolution.
+	time.Sleep(1100 * time.Millisecond)
+	moves := map[string]string{"general/old/0000_root.md": "general/new/0000_root.md"}
+	if err := r.CommitMoves(alice, "thread: move general/old to general/new", moves, nil); err != nil {
+		t.Fatal(err)
+	}
+	arrivals, err := r.Arrivals()
+	if err != nil {
+		t.Fatalf("Arrivals: %v", err)
+	}
+	if got := arrivals["general/new/0000_root.md"]; !got.Equal(posted) {
+		t.Errorf("moved post arrived at %v, want %v", got, posted)
+	}
+
+	if err := r.CommitFile(alice, "general/new/0000_root.md", []byte("edited"), "post: edit"); err != nil {
+		t.Fatal(err)
+	}
+	if arrivals, err = r.Arrivals(); err != nil {
+		t.Fatalf("Arrivals: %v", err)
+	}
+	prov, err := r.Provenance("general/new/0000_root.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := arrivals["general/new/0000_root.md"], prov.Later[len(prov.Later)-1].When; !got.Equal(want) || !got.After(posted) {
+		t.Errorf("edited post arrived at %v, want %v", got, want)
+	}
+}
+
+func TestDiscardCommit_PartlyPushed(t *testing.T) {
+	id := newIdentity(t, "alice")
+	r, err := repo.Init(t.TempDir(), repo.ForumMeta{Name: "Forum", AdminPubkey: id.PublicKey}, id)
+	if err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	if err := r.AddRemote("origin", newBareRemote(t)); err != nil {
+		t.Fatal(err)
+	}
+	// backup is unreachable, so pushes only reach origin.
+	if err := r.AddRemote("backup", filepath.Join(t.TempDir(), "missing")); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.SetRemoteRole("backup", repo.RolePush); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.CommitPost(id, "general/a/0000_root.md", []byte("a")); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Push(); err == nil {
+		t.Fatal("push to the missing backup succeeded")
+	}
+	if err := r.CommitPost(id, "general/b/0000_root.md", []byte("b")); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := r.Outbox()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Commits) != 3 {
+		t.Fatalf("outbox: %+v", out.Commits)
+	}
+	published, local := out.Commits[1], out.Commits[2]
+	if strings.Join(published.PushedTo, ",") != "origin" || local.PushedTo != nil {
+		t.Errorf("pushed to: %v and %v", published.PushedTo, local.PushedTo)
+	}
+	if err := r.DiscardCommit(id, published.Hash); err == nil || !strings.Contains(err.Error(), "origin") {
+		t.Errorf("discard of a commit on origin: %v", err)
+	}
+	if err := r.DiscardCommit(id, local.Hash); err != nil {
+		t.Errorf("discard of a local commit: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(r.Path, "general", "a", "0000_root.md")); err != nil {
+		t.Errorf("published post gone: %v", err)
+	}
+}
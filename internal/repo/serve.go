@@ -0,0 +1,114 @@
+package repo
+
+import (
+	"compress/gzip"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/pktline"
+	"github.com/go-git/go-git/v5/plumbing/protocol/packp"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/server"
+)
+
+// GitHandler serves the repository read-only over git's smart HTTP
+// protocol, so peers can fetch from it with gitorum or plain git:
+//
+//	git clone http://host:port/<where the handler is mounted>/
+//
+// Only upload-pack, used by fetch and clone, is offered; pushes are
+// refused.
+func (r *Repo) GitHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /info/refs", r.handleInfoRefs)
+	mux.HandleFunc("POST /git-upload-pack", r.handleUploadPack)
+	return mux
+}
+
+// repoLoader hands the repository's storer to the go-git server whatever
+// endpoint is asked for.
+type repoLoader struct{ s storer.Storer }
+
+func (l repoLoader) Load(*transport.Endpoint) (storer.Storer, error) { return l.s, nil }
+
+func (r *Repo) uploadPackSession() (transport.UploadPackSession, error) {
+	ep, err := transport.NewEndpoint("/")
+	if err != nil {
+		return nil, err
+	}
+	return server.NewServer(repoLoader{r.git.Storer}).NewUploadPackSession(ep, nil)
+}
+
+// GET /info/refs?service=git-upload-pack
+func (r *Repo) handleInfoRefs(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Query().Get("service") != "git-upload-pack" {
+		http.Error(w, "only git-upload-pack is served", http.StatusForbidden)
+		return
+	}
+	sess, err := r.uploadPackSession()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer sess.Close()
+	ar, err := sess.AdvertisedReferencesContext(req.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	ar.Prefix = [][]byte{[]byte("# service=git-upload-pack"), pktline.Flush}
+	w.Header().Set("Content-Type", "application/x-git-upload-pack-advertisement")
+	w.Header().Set("Cache-Control", "no-cache")
+	if err := ar.Encode(w); err != nil {
+		log.Printf("git info/refs: %v", err)
+	}
+}
+
+// POST /git-upload-pack
+func (r *Repo) handleUploadPack(w http.ResponseWriter, req *http.Request) {
+	body := io.Reader(req.Body)
+	if req.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(req.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer gz.Close()
+		body = gz
+	}
+	upr := packp.NewUploadPackRequest()
+	if err := upr.Decode(body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// A peer announces the commits it has, most of which are its own and
+	// unknown here; only the shared ones can trim the pack.
+	var haves []plumbing.Hash
+	for _, h := range upr.Haves {
+		if r.git.Storer.HasEncodedObject(h) == nil {
+			haves = append(haves, h)
+		}
+	}
+	upr.Haves = haves
+
+	sess, err := r.uploadPackSession()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer sess.Close()
+	resp, err := sess.UploadPack(req.Context(), upr)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer resp.Close()
+	w.Header().Set("Content-Type", "application/x-git-upload-pack-result")
+	w.Header().Set("Cache-Control", "no-cache")
+	if err := resp.Encode(w); err != nil {
+		log.Printf("git upload-pack: %v", err)
+	}
+}
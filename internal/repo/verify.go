@@ -0,0 +1,178 @@
+package repo
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+
+	"github.com/gosub/gitorum/internal/crypto"
+)
+
+// CommitSigStatus is the outcome of verifying a commit's signature.
+type CommitSigStatus string
+
+const (
+	CommitSigValid   CommitSigStatus = "valid"    // signed with the key keys/ has for the committer
+	CommitSigUnknown CommitSigStatus = "unknown"  // a good signature, but not by the committer's key in keys/
+	CommitSigInvalid CommitSigStatus = "invalid"  // the signature does not verify
+	CommitUnsigned   CommitSigStatus = "unsigned" // no signature
+)
+
+// CommitVerification is a commit and whether its signature checks out.
+type CommitVerification struct {
+	Hash      string
+	Subject   string // first line of the message
+	Committer string
+	When      time.Time
+	Status    CommitSigStatus
+	Signer    string // user whose key in keys/ made the signature, if any
+	SignerKey string // base64 public key that made a good signature
+	Err       string // why an invalid signature failed
+}
+
+// commitEmail is the email gitorum commits as username with.
+func commitEmail(username string) string { return username + "@gitorum.local" }
+
+// commitSigner signs commits with an identity's key in the SSH signature
+// format, so that plain git can verify them too (gpg.format=ssh).
+type commitSigner struct{ id *crypto.Identity }
+
+func (s commitSigner) Sign(message io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(message)
+	if err != nil {
+		return nil, err
+	}
+	return s.id.SignSSH(crypto.GitNamespace, data)
+}
+
+// VerifyCommits checks the signature of every commit reachable from HEAD,
+// newest first, against the keys in keys/.
+func (r *Repo) VerifyCommits() ([]CommitVerification, error) {
+	head, err := r.git.Head()
+	if err != nil {
+		return nil, nil // empty repository
+	}
+	keys, err := r.keyOwners()
+	if err != nil {
+		return nil, err
+	}
+	iter, err := r.git.Log(&gogit.LogOptions{From: head.Hash()})
+	if err != nil {
+		return nil, fmt.Errorf("git log: %w", err)
+	}
+	var out []CommitVerification
+	if err := iter.ForEach(func(c *object.Commit) error {
+		out = append(out, verifyCommit(c, keys))
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("git log: %w", err)
+	}
+	return out, nil
+}
+
+// VerifyCommit checks the signature of one commit against the keys in
+// keys/.
+func (r *Repo) VerifyCommit(hash string) (CommitVerification, error) {
+	c, err := r.git.CommitObject(plumbing.NewHash(hash))
+	if err != nil {
+		return CommitVerification{}, fmt.Errorf("commit %s: %w", hash, err)
+	}
+	keys, err := r.keyOwners()
+	if err != nil {
+		return CommitVerification{}, err
+	}
+	return verifyCommit(c, keys), nil
+}
+
+func verifyCommit(c *object.Commit, keys map[string]string) CommitVerification {
+	v := CommitVerification{
+		Hash:      c.Hash.String(),
+		Subject:   strings.SplitN(c.Message, "\n", 2)[0],
+		Committer: c.Committer.Name,
+		When:      c.Committer.When,
+		Status:    CommitUnsigned,
+	}
+	if c.PGPSignature == "" {
+		return v
+	}
+	encoded := &plumbing.MemoryObject{}
+	if err := c.EncodeWithoutSignature(encoded); err != nil {
+		v.Status, v.Err = CommitSigInvalid, err.Error()
+		return v
+	}
+	rd, err := encoded.Reader()
+	if err != nil {
+		v.Status, v.Err = CommitSigInvalid, err.Error()
+		return v
+	}
+	payload, err := io.ReadAll(rd)
+	if err != nil {
+		v.Status, v.Err = CommitSigInvalid, err.Error()
+		return v
+	}
+	pub, err := crypto.VerifySSH([]byte(c.PGPSignature), crypto.GitNamespace, payload)
+	if err != nil {
+		v.Status, v.Err = CommitSigInvalid, err.Error()
+		return v
+	}
+	v.Signer, v.SignerKey = keys[pub], pub
+	if v.Signer != "" && v.Signer == c.Committer.Name {
+		v.Status = CommitSigValid
+	} else {
+		v.Status = CommitSigUnknown
+	}
+	return v
+}
+
+// AllowedSigners returns the keys in keys/ as a git allowed signers file,
+// with which plain git verifies the commits gitorum signs:
+//
+//	git -c gpg.ssh.allowedSignersFile=<file> log --show-signature
+func (r *Repo) AllowedSigners() (string, error) {
+	keys, err := r.keyOwners()
+	if err != nil {
+		return "", err
+	}
+	var lines []string
+	for pub, username := range keys {
+		sshKey, err := crypto.SSHPublicKey(pub)
+		if err != nil {
+			return "", fmt.Errorf("key of %s: %w", username, err)
+		}
+		lines = append(lines, fmt.Sprintf("%s namespaces=\"%s\" %s\n", commitEmail(username), crypto.GitNamespace, sshKey))
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, ""), nil
+}
+
+// keyOwners maps each public key in keys/ to its user.
+func (r *Repo) keyOwners() (map[string]string, error) {
+	entries, err := os.ReadDir(filepath.Join(r.Path, "keys"))
+	if errors.Is(err, os.ErrNotExist) {
+		return map[string]string{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("read keys: %w", err)
+	}
+	owners := map[string]string{}
+	for _, e := range entries {
+		username, ok := strings.CutSuffix(e.Name(), ".pub")
+		if !ok || e.IsDir() {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(r.Path, "keys", e.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("read key of %s: %w", username, err)
+		}
+		owners[strings.TrimSpace(string(data))] = username
+	}
+	return owners, nil
+}
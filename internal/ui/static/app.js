@@ -6,17 +6,39 @@
 
 // ── State ────────────────────────────────────────────────────────────────────
 let STATUS = {};
+let REPLY_TO = null; // post being replied to in the open thread: { filename, author }
+let THREAD = {};     // the open thread: { catSlug, threadSlug, poll, state, posts, unseen }
+let THREAD_SORT = 'activity';
+let USER = null;      // the user whose profile is shown
+let CATEGORIES = [];  // category tree from /api/categories
+let CATEGORY_POLICY = null; // write policy of the open category, if any
+let DRAFT_TIMER = null;     // pending draft autosave
+let FORUMS = [];      // forums served by this server, the default first
+let FORUM = localStorage.getItem('gitorum.forum') || ''; // selected forum; '' is the default
 
 // ── Bootstrap ────────────────────────────────────────────────────────────────
 window.addEventListener('DOMContentLoaded', async () => {
   await refreshStatus();
   window.addEventListener('hashchange', route);
+  document.addEventListener('click', scrollToHeading);
   route();
 });
 
+// Heading anchors in post bodies point at ids inside the same post. Following
+// them would replace the route, so scroll to the heading instead.
+function scrollToHeading(e) {
+  const a = e.target.closest('.post-body a[href^="#h-"]');
+  if (!a) return;
+  e.preventDefault();
+  const target = a.closest('.post').querySelector(`[id="${CSS.escape(a.getAttribute('href').slice(1))}"]`);
+  if (target) target.scrollIntoView({ behavior: 'smooth' });
+}
+
 // ── API ──────────────────────────────────────────────────────────────────────
+// apiFetch calls the API of the selected forum.
 async function apiFetch(path, opts = {}) {
-  const res = await fetch('/api' + path, {
+  const base = FORUM ? `/f/${encodeURIComponent(FORUM)}/api` : '/api';
+  const res = await fetch(base + path, {
     headers: { 'Content-Type': 'application/json' },
     ...opts,
   });
@@ -29,11 +51,38 @@ async function apiFetch(path, opts = {}) {
 }
 
 // ── Status & sidebar ─────────────────────────────────────────────────────────
+// refreshForums loads the forums this server serves and fills the switcher,
+// which is only shown when there is more than one.
+async function refreshForums() {
+  const res  = await fetch('/api/forums').catch(() => null);
+  const data = res && res.ok ? await res.json() : { forums: [], total_unread: 0 };
+  FORUMS = data.forums || [];
+  if (FORUM && !FORUMS.some(f => f.name === FORUM && !f.default)) FORUM = '';
+
+  $('forum-switcher').hidden = FORUMS.length < 2;
+  $('forum-total').innerHTML = unreadBadge(data.total_unread);
+  $('forum-select').innerHTML = FORUMS.map(f => {
+    const selected = FORUM ? f.name === FORUM : f.default;
+    return `<option value="${f.default ? '' : esc(f.name)}"${selected ? ' selected' : ''}>${esc(f.title)}${f.unread ? ` (${f.unread} new)` : ''}</option>`;
+  }).join('');
+}
+
+async function switchForum(name) {
+  FORUM = name;
+  localStorage.setItem('gitorum.forum', name);
+  await refreshStatus();
+  if (location.hash === '' || location.hash === '#/') route();
+  else location.hash = '#/';
+}
+
 async function refreshStatus() {
+  await refreshForums();
   STATUS = await apiFetch('/status').catch(() => ({}));
 
   $('forum-name').textContent = STATUS.forum_name || 'Gitorum';
-  $('identity').textContent   = STATUS.username ? `@${STATUS.username}` : '(anonymous)';
+  $('identity').innerHTML     = (STATUS.username
+    ? `<a href="#/user/${encodeURIComponent(STATUS.username)}">@${esc(STATUS.username)}</a>`
+    : '(anonymous)') + ' <a href="#" class="identity-switch" onclick="showIdentities(); return false">switch</a>';
   $('admin-panel').hidden      = !STATUS.is_admin;
 
   // Fetch pending join requests and update button badge if admin.
@@ -62,15 +111,45 @@ async function refreshStatus() {
       ? 'Last sync: ' + relTime(STATUS.last_sync_at)
       : '';
   }
+  renderRemotes(STATUS.remotes || []);
 
   const cats = await apiFetch('/categories').catch(() => ({ categories: [] }));
-  const ul = $('cat-list');
-  ul.innerHTML = '';
-  (cats.categories || []).forEach(c => {
-    const li = document.createElement('li');
-    li.innerHTML = `<a href="#/cat/${c.slug}">${esc(c.name)}</a>`;
-    ul.appendChild(li);
-  });
+  CATEGORIES = cats.categories || [];
+  $('cat-list').innerHTML = catTreeHtml(CATEGORIES);
+  const unread = CATEGORIES.reduce((n, c) => n + (c.total_unread || 0), 0);
+  $('whats-new').innerHTML = `What's new${unreadBadge(unread)}`;
+
+  const notes = STATUS.username
+    ? await apiFetch('/notifications').catch(() => ({ unseen: 0 }))
+    : { unseen: 0 };
+  $('notifications-link').hidden   = !STATUS.username;
+  $('mutes-link').hidden           = !STATUS.username;
+  $('trust-link').hidden           = !STATUS.username;
+  $('drafts-link').hidden          = !STATUS.username;
+  $('outbox-link').hidden          = !STATUS.pending;
+  $('outbox-link').innerHTML       = `Outbox${unreadBadge(STATUS.pending || 0)}`;
+  $('notifications-link').innerHTML = `Notifications${unreadBadge(notes.unseen)}`;
+
+  const lan = await apiFetch('/lan/peers').catch(() => ({ enabled: false, peers: [] }));
+  $('nearby-link').hidden    = !lan.enabled;
+  $('nearby-link').innerHTML = lan.peers.length ? `Nearby (${lan.peers.length})` : 'Nearby';
+}
+
+// renderRemotes lists each remote under the sync row once there is more
+// than one, with the outcome of its last pull and push.
+function renderRemotes(remotes) {
+  const list = $('remote-list');
+  list.hidden = remotes.length < 2;
+  list.innerHTML = remotes.map(r => {
+    const err = r.last_pull_error || r.last_push_error;
+    const notes = [r.url];
+    if (r.last_pull_error) notes.push('pull: ' + r.last_pull_error);
+    if (r.last_push_error) notes.push('push: ' + r.last_push_error);
+    if ((r.rejected || []).length) notes.push('not merged: ' + r.rejected.join(', '));
+    const color = err ? 'red' : r.synced ? 'green' : 'grey';
+    return `<li title="${esc(notes.join('\n'))}"><span class="dot ${color}"></span>${esc(r.name)}
+      <span class="remote-role">${esc(r.role)}</span></li>`;
+  }).join('');
 }
 
 async function triggerSync() {
@@ -99,13 +178,28 @@ function route() {
   if (!STATUS.initialized) return viewSetup();
 
   const hash  = location.hash.replace(/^#\/?/, '');
-  const parts = hash ? hash.split('/') : [];
+  let parts;
+  try {
+    // Subcategory slugs contain slashes, so each part is URI-encoded.
+    parts = hash ? hash.split('/').map(decodeURIComponent) : [];
+  } catch (e) {
+    parts = [];
+  }
 
   if (parts.length === 0)                                          return viewCategories();
   if (parts[0] === 'cat' && parts.length === 2)                   return viewThreadList(parts[1]);
   if (parts[0] === 'cat' && parts.length === 4
       && parts[2] === 'thread')                                    return viewThread(parts[1], parts[3]);
+  if (parts[0] === 'cat' && parts.length === 5
+      && parts[2] === 'thread')                                    return viewThread(parts[1], parts[3], parts[4]);
   if (parts[0] === 'new-thread' && parts.length === 2)            return viewNewThread(parts[1]);
+  if (parts[0] === 'new' && parts.length === 1)                   return viewWhatsNew();
+  if (parts[0] === 'notifications' && parts.length === 1)         return viewNotifications();
+  if (parts[0] === 'drafts' && parts.length === 1)                return viewDrafts();
+  if (parts[0] === 'outbox' && parts.length === 1)                return viewOutbox();
+  if (parts[0] === 'nearby' && parts.length === 1)                return viewNearby();
+  if (parts[0] === 'audit' && parts.length === 1)                 return viewAudit();
+  if (parts[0] === 'user' && parts.length === 2)                  return viewUser(parts[1]);
   viewCategories();
 }
 
@@ -176,98 +270,160 @@ async function viewCategories() {
       ? '<p class="empty">No categories yet. Use <strong>Admin › New Category</strong> in the sidebar to create one.</p>'
       : '<p class="empty">No categories yet.</p>';
   } else {
-    h += '<div class="card-list">';
-    cats.forEach(c => {
-      h += `<div class="card">
-        <h2><a href="#/cat/${c.slug}">${esc(c.name)}</a></h2>
-        <p>${esc(c.description)}</p>
-        <small>${c.thread_count} thread${c.thread_count === 1 ? '' : 's'}</small>
-      </div>`;
-    });
-    h += '</div>';
+    h += `<div class="card-list">${cats.map(categoryCard).join('')}</div>`;
   }
   render(h);
 }
 
 async function viewThreadList(catSlug) {
-  const data = await apiFetch(`/categories/${catSlug}/threads`).catch(e => {
+  const data = await apiFetch(`/categories/${catPath(catSlug)}/threads?sort=${THREAD_SORT}`).catch(e => {
     render(`<p class="error-msg">Could not load threads: ${esc(e.message)}</p>`);
     return null;
   });
   if (!data) return;
 
   const threads = data.threads || [];
+  const subs    = data.subcategories || [];
   const catName = data.category_name || catSlug;
+  const sorts   = { activity: 'Latest activity', newest: 'Newest', replies: 'Most replies', alpha: 'A–Z' };
 
-  let h = `<nav class="breadcrumb"><a href="#/">Home</a> › ${esc(catName)}</nav>`;
+  const crumbs = (data.parents || []).map(p => `<a href="#/cat/${catPath(p.slug)}">${esc(p.name)}</a> › `).join('');
+  let h = `<nav class="breadcrumb"><a href="#/">Home</a> › ${crumbs}${esc(catName)}</nav>`;
   h += `<div class="view-header">
     <h1>${esc(catName)}</h1>
-    <a class="btn btn-primary" href="#/new-thread/${catSlug}">+ New Thread</a>
+    <div class="view-actions">
+      <select id="thread-sort" onchange="THREAD_SORT = this.value; viewThreadList('${esc(catSlug)}')">
+        ${Object.entries(sorts).map(([k, v]) => `<option value="${k}"${k === data.sort ? ' selected' : ''}>${v}</option>`).join('')}
+      </select>
+      ${STATUS.is_admin ? `<button class="btn" onclick="showCategoryPolicy('${esc(catSlug)}')">Permissions</button>` : ''}
+      ${data.can_post ? `<a class="btn btn-primary" href="#/new-thread/${catPath(catSlug)}">+ New Thread</a>` : ''}
+    </div>
   </div>`;
+  CATEGORY_POLICY = data.policy || null;
+  if (data.policy) h += `<p class="view-note">${policyNote(data.policy)}</p>`;
 
+  if (subs.length) {
+    h += `<div class="card-list subcategories">${subs.map(categoryCard).join('')}</div>`;
+  }
   if (!threads.length) {
     h += '<p class="empty">No threads yet. Be the first to post!</p>';
   } else {
-    h += '<div class="card-list">';
-    threads.forEach(t => {
-      h += `<div class="card">
-        <h2><a href="#/cat/${catSlug}/thread/${t.slug}">${esc(t.title)}</a></h2>
-        <small>
-          by <strong>${esc(t.author)}</strong> ·
-          ${t.reply_count} repl${t.reply_count === 1 ? 'y' : 'ies'} ·
-          ${relTime(t.last_reply_at)}
-        </small>
-      </div>`;
-    });
-    h += '</div>';
+    h += `<div class="card-list" id="thread-list">${threads.map(t => threadCard(catSlug, t)).join('')}</div>`;
+    h += loadMoreButton(data.next_cursor, `loadMoreThreads('${esc(catSlug)}')`);
   }
   render(h);
 }
 
-async function viewThread(catSlug, threadSlug) {
-  const data = await apiFetch(`/threads/${catSlug}/${threadSlug}`).catch(e => {
+async function loadMoreThreads(catSlug) {
+  const btn = $('load-more');
+  btn.disabled = true;
+  try {
+    const data = await apiFetch(`/categories/${catPath(catSlug)}/threads?sort=${THREAD_SORT}&cursor=${btn.dataset.cursor}`);
+    $('thread-list').insertAdjacentHTML('beforeend', (data.threads || []).map(t => threadCard(catSlug, t)).join(''));
+    btn.outerHTML = loadMoreButton(data.next_cursor, `loadMoreThreads('${esc(catSlug)}')`);
+  } catch (e) {
+    alert('Could not load more threads: ' + e.message);
+    btn.disabled = false;
+  }
+}
+
+// categoryCard shows a category with its thread count summed over all of
+// its subcategories, and links to the direct subcategories.
+function categoryCard(c) {
+  const children = (c.children || []).map(sc =>
+    `<a href="#/cat/${catPath(sc.slug)}">${esc(sc.name)}</a>${unreadBadge(sc.total_unread)}`).join(' · ');
+  return `<div class="card">
+    <h2><a href="#/cat/${catPath(c.slug)}">${esc(c.name)}</a></h2>
+    <p>${esc(c.description)}</p>
+    ${children ? `<p class="subcategory-links">${children}</p>` : ''}
+    <small>${c.total_threads} thread${c.total_threads === 1 ? '' : 's'}${unreadBadge(c.total_unread)}</small>
+  </div>`;
+}
+
+// catTreeHtml renders the sidebar category list, nesting subcategories.
+function catTreeHtml(cats) {
+  return cats.map(c => `<li>
+    <a href="#/cat/${catPath(c.slug)}">${esc(c.name)}${unreadBadge(c.total_unread)}</a>
+    ${c.children && c.children.length ? `<ul>${catTreeHtml(c.children)}</ul>` : ''}
+  </li>`).join('');
+}
+
+// flatCategories lists the category tree depth-first with each depth.
+function flatCategories(cats, depth = 0) {
+  return cats.flatMap(c => [{ cat: c, depth }, ...flatCategories(c.children || [], depth + 1)]);
+}
+
+function threadCard(catSlug, t) {
+  return `<div class="card">
+    <h2><a href="#/cat/${catPath(catSlug)}/thread/${t.slug}">${esc(t.title)}</a>${t.is_poll ? ' <span class="badge badge-poll">poll</span>' : ''}${stateBadges(t)}${t.flag ? ` <span class="badge badge-warn" title="Posted against the category's write policy">${esc(t.flag)}</span>` : ''}${unreadBadge(t.unread_count)}</h2>
+    <small>
+      by <strong>${esc(t.author)}</strong> ·
+      ${t.reply_count} repl${t.reply_count === 1 ? 'y' : 'ies'} ·
+      ${relTime(t.last_reply_at)}
+    </small>
+  </div>`;
+}
+
+// viewThread shows the first page of posts. With focusFilename it keeps
+// loading pages until that post is shown and scrolls to it; with toEnd it
+// loads every page and scrolls to the bottom.
+async function viewThread(catSlug, threadSlug, focusFilename, toEnd) {
+  const data = await apiFetch(`/threads/${catPath(catSlug)}/${threadSlug}`).catch(e => {
     render(`<p class="error-msg">Could not load thread: ${esc(e.message)}</p>`);
     return null;
   });
   if (!data) return;
+  if (data.redirect) {
+    // The thread was moved or merged; show it under its new address.
+    if (focusFilename === '0000_root.md') focusFilename = data.redirect.root;
+    catSlug    = data.category;
+    threadSlug = data.slug;
+    const focus = focusFilename ? `/${focusFilename}` : '';
+    history.replaceState(null, '', `#/cat/${catPath(catSlug)}/thread/${threadSlug}${focus}`);
+  }
 
+  const state = data.state || {};
+  THREAD = { catSlug, threadSlug, poll: data.poll, state, posts: {}, unseen: [] };
   const posts = data.posts || [];
   let h = `<nav class="breadcrumb">
     <a href="#/">Home</a> ›
-    <a href="#/cat/${catSlug}">${esc(catSlug)}</a> ›
-    ${esc(threadSlug)}
+    <a href="#/cat/${catPath(catSlug)}">${esc(catSlug)}</a> ›
+    ${esc(threadSlug)}${stateBadges(state)}
   </nav>`;
 
-  posts.forEach((p, i) => {
-    if (p.tombstoned) {
-      h += `<article class="post post-deleted${i === 0 ? ' post-root' : ''}">
-        <header class="post-meta">
-          <span class="author">[deleted]</span>
-        </header>
-        <div class="post-body">${p.body_html}</div>
-      </article>`;
-      return;
-    }
-    const deleteBtn = STATUS.is_admin
-      ? `<button class="btn btn-danger btn-sm" onclick="adminDelete('${esc(catSlug)}','${esc(threadSlug)}','${esc(p.filename)}')">Delete</button>`
-      : '';
-    h += `<article class="post${i === 0 ? ' post-root' : ''}">
-      <header class="post-meta">
-        <span class="author">${esc(p.author)}</span>
-        ${sigBadge(p)}
-        <time class="ts" title="${esc(p.timestamp)}">${relTime(p.timestamp)}</time>
-        ${deleteBtn}
-      </header>
-      <div class="post-body">${p.body_html}</div>
-    </article>`;
-  });
+  if (STATUS.is_admin) {
+    const toggle = (flag, on, off) =>
+      `<button class="btn btn-sm" onclick="setThreadState('${flag}', ${!state[flag]})">${state[flag] ? off : on}</button>`;
+    h += `<div class="view-actions thread-admin">
+      ${toggle('pinned', 'Pin', 'Unpin')}
+      ${toggle('locked', 'Lock', 'Unlock')}
+      ${toggle('archived', 'Archive', 'Unarchive')}
+      <button class="btn btn-sm" onclick="showRelocateThread(false)">Move</button>
+      <button class="btn btn-sm" onclick="showRelocateThread(true)">Merge</button>
+    </div>`;
+  }
+
+  h += `<div id="post-list">${posts.map(postHtml).join('')}</div>`;
+  h += loadMoreButton(data.next_cursor, 'loadMorePosts()', data.total_posts - posts.length);
+  h += backlinksHtml(data.backlinks);
 
-  h += STATUS.username
+  const closed = state.locked || state.archived;
+  h += closed && !STATUS.is_admin
+    ? `<section class="reply-form">
+        <p class="empty">This thread is ${state.archived ? 'archived' : 'locked'} and no longer takes replies.</p>
+      </section>`
+    : STATUS.username && !data.can_reply
+    ? `<section class="reply-form">
+        <p class="empty">You may not reply in this category.</p>
+      </section>`
+    : STATUS.username
     ? `<section class="reply-form">
         <h3>Post a Reply</h3>
-        <textarea id="reply-body" rows="6" placeholder="Your reply (Markdown supported)…"></textarea>
+        <div id="reply-to" class="reply-to" hidden></div>
+        <textarea id="reply-body" rows="6" placeholder="Your reply (Markdown supported)…" oninput="saveDraftSoon()"></textarea>
         <div class="form-actions">
           <button class="btn btn-primary" onclick="submitReply('${esc(catSlug)}','${esc(threadSlug)}')">Submit Reply</button>
+          <span id="draft-status" class="draft-status"></span>
         </div>
       </section>`
     : `<section class="reply-form">
@@ -275,17 +431,133 @@ async function viewThread(catSlug, threadSlug) {
       </section>`;
 
   render(h);
+  REPLY_TO = null;
+  if ($('reply-body')) await restoreDraft(catSlug, threadSlug);
+  while ($('load-more') && (toEnd || (focusFilename && !document.getElementById('post-' + focusFilename)))) {
+    if (!await loadMorePosts()) break;
+  }
+  if (focusFilename) {
+    const el = document.getElementById('post-' + focusFilename);
+    if (el) el.scrollIntoView();
+  } else if (toEnd) {
+    window.scrollTo(0, document.body.scrollHeight);
+  }
+  markThreadSeen();
+}
+
+// loadMorePosts appends the next page of posts to the open thread. It
+// reports whether a page was loaded.
+async function loadMorePosts() {
+  const btn = $('load-more');
+  if (!btn) return false;
+  btn.disabled = true;
+  try {
+    const { catSlug, threadSlug } = THREAD;
+    const data  = await apiFetch(`/threads/${catPath(catSlug)}/${threadSlug}?cursor=${btn.dataset.cursor}`);
+    const posts = data.posts || [];
+    $('post-list').insertAdjacentHTML('beforeend', posts.map(postHtml).join(''));
+    const shown = document.querySelectorAll('#post-list .post').length;
+    btn.outerHTML = loadMoreButton(data.next_cursor, 'loadMorePosts()', data.total_posts - shown);
+    markThreadSeen();
+    return true;
+  } catch (e) {
+    alert('Could not load more posts: ' + e.message);
+    btn.disabled = false;
+    return false;
+  }
+}
+
+function postHtml(p) {
+  const { catSlug, threadSlug, poll } = THREAD;
+  const root = p.filename === '0000_root.md' ? ' post-root' : '';
+  if (p.tombstoned) {
+    return `<article class="post post-deleted${root}" id="post-${esc(p.filename)}">
+      <header class="post-meta">
+        <span class="author">[deleted]</span>
+      </header>
+      <div class="post-body">${p.body_html}</div>
+    </article>`;
+  }
+  THREAD.posts[p.filename] = p;
+  const replyBtn = STATUS.username
+    ? `<button class="btn btn-sm" onclick="setReplyTo('${esc(p.filename)}','${esc(p.author)}')">Reply</button>
+       <button class="btn btn-sm" onclick="quotePost('${esc(p.filename)}')">Quote</button>
+       <button class="btn btn-sm" onclick="linkPost('${esc(p.filename)}')" title="Insert a link to this post">Link</button>`
+    : '';
+  const deleteBtn = STATUS.is_admin
+    ? `<button class="btn btn-danger btn-sm" onclick="adminDelete('${esc(catSlug)}','${esc(threadSlug)}','${esc(p.filename)}')">Delete</button>`
+    : '';
+  const muteBtn = STATUS.username && p.author !== STATUS.username
+    ? `<button class="btn btn-sm" onclick="setMuted('${esc(p.author)}',true)">Mute</button>`
+    : '';
+  if (p.unread) THREAD.unseen.push(p.filename);
+  const unread = p.unread && p.author !== STATUS.username;
+  if (p.flag) {
+    const unmuteBtn = p.flag === 'muted'
+      ? `<button class="btn btn-sm" onclick="setMuted('${esc(p.author)}',false)">Unmute</button>`
+      : '';
+    return `<details class="post post-flagged" id="post-${esc(p.filename)}">
+      <summary class="post-meta">
+        <span class="author">${esc(p.author)}</span>
+        <span class="badge badge-warn">${esc(p.flag)}</span>
+        <span class="flag-reason">${esc(p.flag_reason)}</span>
+        ${unmuteBtn}
+      </summary>
+      <div class="post-body">${p.body_html}</div>
+    </details>`;
+  }
+  let h = `<article class="post${root}${unread ? ' post-unread' : ''}" id="post-${esc(p.filename)}">
+    <header class="post-meta">
+      ${authorLink(p)}
+      ${sigBadge(p)}
+      ${provenanceBtn(catSlug, threadSlug, p)}
+      <time class="ts" title="${esc(p.timestamp)}">${relTime(p.timestamp)}</time>
+      ${replyBtn}
+      ${muteBtn}
+      ${deleteBtn}
+    </header>
+    <div class="post-body">${p.body_html}</div>
+  </article>`;
+  if (root && poll) h += pollBox(poll, catSlug, threadSlug);
+  return h;
+}
+
+function authorLink(p) {
+  const handle = p.author_name && p.author_name !== p.author ? ` <small>@${esc(p.author)}</small>` : '';
+  return `<a class="author" href="#/user/${encodeURIComponent(p.author)}">${esc(p.author_name || p.author)}</a>${handle}`;
+}
+
+// backlinksHtml lists the posts in other threads that link to or quote the
+// open thread.
+function backlinksHtml(links) {
+  if (!links || !links.length) return '';
+  const { catSlug, threadSlug } = THREAD;
+  return `<section class="backlinks">
+    <h3>Referenced from</h3>
+    <ul>${links.map(l => `<li>
+      <a href="#/cat/${catPath(l.category)}/thread/${l.thread}/${l.filename}">${esc(l.title)}</a>
+      <small>by @${esc(l.author)} · ${relTime(l.timestamp)}${l.target
+        ? ` · about <a href="#/cat/${catPath(catSlug)}/thread/${threadSlug}/${l.target}">this post</a>` : ''}</small>
+    </li>`).join('')}</ul>
+  </section>`;
+}
+
+function loadMoreButton(cursor, onclick, remaining) {
+  if (!cursor) return '';
+  const label = remaining > 0 ? `Load more (${remaining} remaining)` : 'Load more';
+  return `<button class="btn load-more" id="load-more" data-cursor="${esc(cursor)}" onclick="${onclick}">${label}</button>`;
 }
 
-function viewNewThread(catSlug) {
+async function viewNewThread(catSlug) {
   render(`
     <nav class="breadcrumb">
       <a href="#/">Home</a> ›
-      <a href="#/cat/${catSlug}">${esc(catSlug)}</a> ›
+      <a href="#/cat/${catPath(catSlug)}">${esc(catSlug)}</a> ›
       New Thread
     </nav>
     <h1 style="margin-bottom:1.25rem">New Thread</h1>
-    <form class="new-thread-form" onsubmit="submitNewThread(event,'${esc(catSlug)}')">
+    <form class="new-thread-form" id="new-thread-form" data-category="${esc(catSlug)}"
+      onsubmit="submitNewThread(event,'${esc(catSlug)}')" oninput="saveDraftSoon()">
       <label>Title
         <input type="text" id="nt-title" required placeholder="Thread title">
       </label>
@@ -295,11 +567,367 @@ function viewNewThread(catSlug) {
       <label>Body <small style="font-weight:400">(Markdown supported)</small>
         <textarea id="nt-body" rows="10" placeholder="Write your post here…"></textarea>
       </label>
+      <label>Poll options <small style="font-weight:400">(optional, one per line)</small>
+        <textarea id="nt-poll-options" rows="3" placeholder="Yes&#10;No"></textarea>
+      </label>
+      <label>Poll closes <small style="font-weight:400">(optional)</small>
+        <input type="datetime-local" id="nt-poll-closes">
+      </label>
       <div class="form-actions">
         <button type="submit" class="btn btn-primary">Create Thread</button>
         <a class="btn" href="#/cat/${esc(catSlug)}">Cancel</a>
+        <span id="draft-status" class="draft-status"></span>
       </div>
     </form>`);
+  await restoreDraft(catSlug, '');
+}
+
+async function viewWhatsNew() {
+  const data = await apiFetch('/new').catch(e => {
+    render(`<p class="error-msg">Could not load new posts: ${esc(e.message)}</p>`);
+    return null;
+  });
+  if (!data) return;
+
+  const threads = data.threads || [];
+  let h = `<nav class="breadcrumb"><a href="#/">Home</a> › What's new</nav>`;
+  h += `<div class="view-header">
+    <h1>What's new</h1>
+    ${threads.length ? '<button class="btn" onclick="markAllRead()">Mark all read</button>' : ''}
+  </div>`;
+  if (data.last_sync_at) {
+    h += `<p class="view-note">Last sync ${relTime(data.last_sync_at)}</p>`;
+  }
+
+  if (!threads.length) {
+    h += '<p class="empty">Nothing new. You are all caught up.</p>';
+  } else {
+    h += '<div class="card-list">';
+    threads.forEach(t => {
+      h += `<div class="card">
+        <h2><a href="#/cat/${catPath(t.category)}/thread/${t.slug}">${esc(t.title)}</a>${unreadBadge(t.unread_count)}</h2>
+        <small>
+          in <a href="#/cat/${catPath(t.category)}">${esc(t.category_name)}</a> ·
+          by <strong>${esc(t.author)}</strong> ·
+          ${relTime(t.last_reply_at)}
+        </small>
+      </div>`;
+    });
+    h += '</div>';
+  }
+  render(h);
+}
+
+async function viewNotifications() {
+  const data = await apiFetch('/notifications').catch(e => {
+    render(`<p class="error-msg">Could not load notifications: ${esc(e.message)}</p>`);
+    return null;
+  });
+  if (!data) return;
+
+  const items = data.notifications || [];
+  let h = `<nav class="breadcrumb"><a href="#/">Home</a> › Notifications</nav>`;
+  h += `<div class="view-header">
+    <h1>Notifications</h1>
+    ${data.unseen ? '<button class="btn" onclick="markNotificationsSeen()">Mark all seen</button>' : ''}
+  </div>`;
+
+  if (!items.length) {
+    h += '<p class="empty">No notifications yet.</p>';
+  } else {
+    const what = {
+      mention:      'mentioned you in',
+      reply:        'replied to your post in',
+      thread_reply: 'replied in your thread',
+    };
+    h += '<div class="card-list">';
+    items.forEach(n => {
+      const href = `#/cat/${catPath(n.category)}/thread/${n.thread}/${n.filename}`;
+      h += `<div class="card notification${n.seen ? '' : ' notification-unseen'}">
+        <strong>@${esc(n.author)}</strong> ${what[n.kind] || esc(n.kind)}
+        <a href="${esc(href)}" onclick="event.preventDefault(); openNotification('${esc(n.id)}','${esc(href)}')">${esc(n.title)}</a>
+        <small> · ${relTime(n.timestamp)}</small>
+      </div>`;
+    });
+    h += '</div>';
+  }
+  render(h);
+}
+
+async function viewDrafts() {
+  const data = await apiFetch('/drafts').catch(e => {
+    render(`<p class="error-msg">Could not load drafts: ${esc(e.message)}</p>`);
+    return null;
+  });
+  if (!data) return;
+
+  const drafts = data.drafts || [];
+  let h = `<nav class="breadcrumb"><a href="#/">Home</a> › Drafts</nav>`;
+  h += `<div class="view-header"><h1>Drafts</h1></div>`;
+  if (!drafts.length) {
+    h += '<p class="empty">No drafts. Unfinished replies and threads are saved here as you type.</p>';
+  } else {
+    h += '<div class="card-list">';
+    drafts.forEach(d => {
+      const href  = d.thread ? `#/cat/${catPath(d.category)}/thread/${d.thread}` : `#/new-thread/${catPath(d.category)}`;
+      const where = d.thread ? `Reply in ${esc(d.category)}/${esc(d.thread)}` : `New thread in ${esc(d.category)}`;
+      const first = d.body.trim().split('\n')[0];
+      h += `<div class="card">
+        <h2><a href="${esc(href)}">${where}</a></h2>
+        <p>${esc(first.length > 120 ? first.slice(0, 120) + '…' : first)}</p>
+        <small>Saved ${relTime(d.updated)} ·
+          <button class="btn btn-sm" onclick="discardDraft('${esc(d.category)}','${esc(d.thread || '')}', true)">Discard</button></small>
+      </div>`;
+    });
+    h += '</div>';
+  }
+  render(h);
+}
+
+async function viewOutbox() {
+  const data = await apiFetch('/outbox').catch(e => {
+    render(`<p class="error-msg">Could not load outbox: ${esc(e.message)}</p>`);
+    return null;
+  });
+  if (!data) return;
+
+  let h = `<nav class="breadcrumb"><a href="#/">Home</a> › Outbox</nav>`;
+  h += `<div class="view-header"><h1>Outbox</h1>
+    <button class="btn btn-sm" onclick="retryPush()">Retry push</button></div>`;
+  if (data.last_push_error) {
+    h += `<p class="error-msg">Last push ${relTime(data.last_push_at)} failed: ${esc(data.last_push_error)}</p>`;
+  }
+  if (!data.commits.length && !data.uncommitted.length) {
+    h += '<p class="empty">Nothing waiting to be pushed.</p>';
+    return render(h);
+  }
+  h += '<div class="card-list">';
+  data.commits.forEach(c => {
+    const posts = (c.posts || []).map(p =>
+      `<a href="#/cat/${catPath(p.category)}/thread/${p.thread}/${p.filename}">${esc(p.category)}/${esc(p.thread)}/${esc(p.filename)}</a>`);
+    const other = c.files.length - posts.length;
+    h += `<div class="card">
+      <h2>${esc(c.message.split('\n')[0])}</h2>
+      <p>${posts.join('<br>')}${other > 0 ? `${posts.length ? '<br>' : ''}${other} other file${other === 1 ? '' : 's'}` : ''}</p>
+      ${c.push_error ? `<p class="outbox-error">Not pushed: ${esc(c.push_error)}</p>` : ''}
+      <small>@${esc(c.author)} · ${relTime(c.when)} · <code>${esc(c.hash.slice(0, 8))}</code> ·
+        ${c.pushed_to ? `already on ${c.pushed_to.map(esc).join(', ')}`
+          : `<button class="btn btn-sm" onclick="discardPending({ commit: '${esc(c.hash)}' })">Discard</button>`}</small>
+    </div>`;
+  });
+  data.uncommitted.forEach(f => {
+    h += `<div class="card">
+      <h2><code>${esc(f)}</code></h2>
+      <small>Uncommitted change ·
+        <button class="btn btn-sm" onclick="discardPending({ path: '${esc(f)}' })">Discard</button></small>
+    </div>`;
+  });
+  h += '</div>';
+  render(h);
+}
+
+// AUDIT_FILTER holds the filters of the audit log view.
+let AUDIT_FILTER = { actor: '', action: '', since: '', until: '' };
+const AUDIT_ACTIONS = ['init', 'keys', 'request', 'category', 'config', 'thread', 'tombstone'];
+
+function auditQuery(cursor) {
+  const q = new URLSearchParams();
+  Object.entries(AUDIT_FILTER).forEach(([k, v]) => { if (v) q.set(k, v); });
+  if (cursor) q.set('cursor', cursor);
+  return q.toString();
+}
+
+async function viewAudit() {
+  const data = await apiFetch('/admin/audit?' + auditQuery()).catch(e => {
+    render(`<p class="error-msg">Could not load the audit log: ${esc(e.message)}</p>`);
+    return null;
+  });
+  if (!data) return;
+
+  const f = AUDIT_FILTER;
+  let h = `<nav class="breadcrumb"><a href="#/">Home</a> › Audit log</nav>`;
+  h += `<div class="view-header"><h1>Audit log</h1></div>
+    <form class="audit-filter" onsubmit="filterAudit(event)">
+      <input type="text" id="audit-actor" placeholder="actor" value="${esc(f.actor)}">
+      <select id="audit-action">
+        <option value="">all actions</option>
+        ${AUDIT_ACTIONS.map(a => `<option${a === f.action ? ' selected' : ''}>${a}</option>`).join('')}
+      </select>
+      <input type="date" id="audit-since" value="${esc(f.since)}" title="from">
+      <input type="date" id="audit-until" value="${esc(f.until)}" title="until">
+      <button type="submit" class="btn btn-sm">Filter</button>
+    </form>`;
+  if (!data.entries.length) {
+    h += '<p class="empty">No matching actions.</p>';
+    return render(h);
+  }
+  h += `<p class="view-note">${data.total} action${data.total === 1 ? '' : 's'}</p>`;
+  h += `<div class="card-list" id="audit-list">${data.entries.map(auditCard).join('')}</div>`;
+  h += loadMoreButton(data.next_cursor, 'loadMoreAudit()');
+  render(h);
+}
+
+function auditCard(e) {
+  const badge = e.verified
+    ? `<span class="badge badge-ok" title="Commit signed by @${esc(e.actor)}${e.by_admin ? ' with the admin key' : ''}">&#10003; ${e.by_admin ? 'admin' : 'signed'}</span>`
+    : `<span class="badge badge-err" title="Commit signature: ${esc(e.commit_sig)}">&#10007; unverified</span>`;
+  const bad = (e.records || []).filter(r => r.error).map(r =>
+    `<p class="outbox-error"><code>${esc(r.path)}</code>: ${esc(r.error)}</p>`).join('');
+  return `<div class="card">
+    <h2><span class="badge audit-action">${esc(e.action)}</span> ${esc(e.target)} ${badge}</h2>
+    ${bad}
+    <small><a href="#/user/${encodeURIComponent(e.actor)}">@${esc(e.actor)}</a> · ${relTime(e.when)} · <code>${esc(e.hash.slice(0, 8))}</code></small>
+  </div>`;
+}
+
+function filterAudit(ev) {
+  ev.preventDefault();
+  AUDIT_FILTER = {
+    actor:  $('audit-actor').value.trim().replace(/^@/, ''),
+    action: $('audit-action').value,
+    since:  $('audit-since').value,
+    until:  $('audit-until').value,
+  };
+  viewAudit();
+}
+
+async function loadMoreAudit() {
+  const btn = $('load-more');
+  btn.disabled = true;
+  try {
+    const data = await apiFetch('/admin/audit?' + auditQuery(btn.dataset.cursor));
+    $('audit-list').insertAdjacentHTML('beforeend', data.entries.map(auditCard).join(''));
+    btn.outerHTML = loadMoreButton(data.next_cursor, 'loadMoreAudit()');
+  } catch (e) {
+    alert('Could not load more actions: ' + e.message);
+    btn.disabled = false;
+  }
+}
+
+async function viewNearby() {
+  const data = await apiFetch('/lan/peers').catch(e => {
+    render(`<p class="error-msg">Could not list peers: ${esc(e.message)}</p>`);
+    return null;
+  });
+  if (!data) return;
+
+  let h = `<nav class="breadcrumb"><a href="#/">Home</a> › Nearby</nav>`;
+  h += `<div class="view-header"><h1>Nearby</h1></div>`;
+  if (!data.enabled) {
+    h += '<p class="empty">Start the server with <code>--lan</code> to find peers on the local network.</p>';
+    return render(h);
+  }
+  if (!data.peers.length) {
+    h += '<p class="empty">No one else on the local network is serving this forum.</p>';
+    return render(h);
+  }
+  h += '<div class="card-list">';
+  data.peers.forEach(p => {
+    h += `<div class="card">
+      <h2>${p.user ? '@' + esc(p.user) : esc(p.instance)}</h2>
+      <p>${esc(p.name)} · <code>${esc(p.url)}</code></p>
+      <small>Seen ${relTime(p.last_seen)} ·
+        <button class="btn btn-sm" onclick="syncPeer('${esc(p.instance)}')">Sync</button></small>
+    </div>`;
+  });
+  h += '</div>';
+  render(h);
+}
+
+async function viewUser(username) {
+  const u = await apiFetch('/users/' + encodeURIComponent(username)).catch(e => {
+    render(`<p class="error-msg">Could not load @${esc(username)}: ${esc(e.message)}</p>`);
+    return null;
+  });
+  if (!u) return;
+
+  const p    = u.profile;
+  const name = p.display_name || '@' + u.username;
+  const editBtn = u.username === STATUS.username
+    ? '<button class="btn" onclick="showEditProfile()">Edit profile</button>'
+    : '';
+  let h = `<nav class="breadcrumb"><a href="#/">Home</a> › @${esc(u.username)}</nav>
+    <div class="view-header">
+      <h1>${esc(name)}</h1>
+      ${editBtn}
+    </div>
+    <dl class="profile-facts">
+      <dt>Username</dt><dd>@${esc(u.username)}</dd>
+      <dt>Key</dt><dd><code title="${esc(u.pubkey)}">${esc(u.fingerprint)}</code></dd>
+      <dt>Joined</dt><dd>${u.joined_at ? `<time title="${esc(u.joined_at)}">${relTime(u.joined_at)}</time>` : 'unknown'}</dd>
+      <dt>Posts</dt><dd>${u.post_count}</dd>`;
+  if (p.contact)  h += `<dt>Contact</dt><dd>${esc(p.contact)}</dd>`;
+  if (p.timezone) h += `<dt>Timezone</dt><dd>${esc(p.timezone)} (${esc(localTimeIn(p.timezone))})</dd>`;
+  if (p.avatar)   h += `<dt>Avatar</dt><dd><code>${esc(p.avatar.slice(0, 12))}…</code></dd>`;
+  if (u.vouched_by.length) {
+    h += `<dt>Vouched for by</dt><dd>${u.vouched_by.map(v => `<a href="#/user/${encodeURIComponent(v)}">@${esc(v)}</a>`).join(', ')}</dd>`;
+  }
+  h += '</dl>';
+  if (STATUS.username && u.username !== STATUS.username) {
+    h += `<div class="form-actions"><button class="btn btn-sm" onclick="vouchFor('${esc(u.username)}')">Vouch for this key</button></div>`;
+  }
+  if (u.profile_error) {
+    h += `<p class="error-msg">Profile not shown: ${esc(u.profile_error)}</p>`;
+  } else if (p.bio_html) {
+    h += `<div class="post-body profile-bio">${p.bio_html}</div>`;
+  } else if (!p.updated_at) {
+    h += '<p class="empty">No profile published yet.</p>';
+  }
+  USER = u;
+  render(h);
+}
+
+function localTimeIn(tz) {
+  try {
+    return new Date().toLocaleTimeString([], { timeZone: tz, hour: '2-digit', minute: '2-digit' }) + ' there now';
+  } catch (_) {
+    return 'unknown zone';
+  }
+}
+
+function showEditProfile() {
+  const p = USER.profile;
+  openModal(`
+    <h2>Edit Profile</h2>
+    <p class="view-note">Your profile is signed with your key and committed to the forum.</p>
+    <label>Display name
+      <input type="text" id="pf-name" maxlength="64" value="${esc(p.display_name)}">
+    </label>
+    <label>Bio (Markdown)
+      <textarea id="pf-bio" rows="5">${esc(p.bio)}</textarea>
+    </label>
+    <label>Contact
+      <input type="text" id="pf-contact" value="${esc(p.contact)}" placeholder="e-mail, chat handle…">
+    </label>
+    <label>Timezone
+      <input type="text" id="pf-tz" value="${esc(p.timezone)}" placeholder="${esc(Intl.DateTimeFormat().resolvedOptions().timeZone)}">
+    </label>
+    <label>Avatar (SHA-256 of the attachment)
+      <input type="text" id="pf-avatar" value="${esc(p.avatar)}">
+    </label>
+    <div class="form-actions">
+      <button class="btn btn-primary" onclick="submitProfile()">Save</button>
+      <button class="btn" onclick="closeModal()">Cancel</button>
+    </div>`);
+}
+
+async function submitProfile() {
+  try {
+    await apiFetch('/profile', {
+      method: 'POST',
+      body:   JSON.stringify({
+        display_name: $('pf-name').value,
+        bio:          $('pf-bio').value,
+        contact:      $('pf-contact').value,
+        timezone:     $('pf-tz').value,
+        avatar:       $('pf-avatar').value,
+      }),
+    });
+    closeModal();
+    await viewUser(STATUS.username);
+  } catch (e) {
+    alert('Error: ' + e.message);
+  }
 }
 
 // ── Actions ──────────────────────────────────────────────────────────────────
@@ -310,15 +938,15 @@ async function submitReply(catSlug, threadSlug) {
 
   const btn = document.querySelector('.reply-form .btn-primary');
   if (btn) btn.disabled = true;
+  clearTimeout(DRAFT_TIMER);
 
   try {
-    await apiFetch(`/threads/${catSlug}/${threadSlug}/reply`, {
+    await apiFetch(`/threads/${catPath(catSlug)}/${threadSlug}/reply`, {
       method: 'POST',
-      body:   JSON.stringify({ body }),
+      body:   JSON.stringify({ body, parent: REPLY_TO ? REPLY_TO.filename : '' }),
     });
     bodyEl.value = '';
-    await viewThread(catSlug, threadSlug);
-    window.scrollTo(0, document.body.scrollHeight);
+    await viewThread(catSlug, threadSlug, '', true);
   } catch (e) {
     alert('Submit failed: ' + e.message);
     if (btn) btn.disabled = false;
@@ -333,12 +961,227 @@ async function submitNewThread(e, catSlug) {
   const body    = title ? `# ${title}\n\n${bodyRaw}` : bodyRaw;
   if (!slug || !body) return;
 
+  clearTimeout(DRAFT_TIMER);
+  const pollOptions = $('nt-poll-options').value.split('\n').map(o => o.trim()).filter(Boolean);
+  const closesRaw   = $('nt-poll-closes').value;
+  const pollCloses  = closesRaw ? new Date(closesRaw).toISOString().replace(/\.\d{3}Z$/, 'Z') : '';
+
   try {
     await apiFetch('/threads', {
       method: 'POST',
-      body:   JSON.stringify({ category: catSlug, slug, body }),
+      body:   JSON.stringify({ category: catSlug, slug, body, poll_options: pollOptions, poll_closes: pollCloses }),
+    });
+    location.hash = `#/cat/${catPath(catSlug)}/thread/${slug}`;
+  } catch (e) {
+    alert('Error: ' + e.message);
+  }
+}
+
+// currentDraft returns the reply or new thread being written on screen, or
+// null when there is no form. A new thread's title is kept as a heading.
+function currentDraft() {
+  if ($('reply-body')) {
+    return {
+      category: THREAD.catSlug,
+      thread:   THREAD.threadSlug,
+      parent:   REPLY_TO ? REPLY_TO.filename : '',
+      body:     $('reply-body').value,
+    };
+  }
+  const form = $('new-thread-form');
+  if (!form) return null;
+  const title = $('nt-title').value.trim();
+  const body  = $('nt-body').value;
+  return {
+    category: form.dataset.category,
+    slug:     $('nt-slug').value.trim(),
+    body:     title ? `# ${title}\n\n${body}` : body,
+  };
+}
+
+// saveDraftSoon saves what is being written once typing pauses. Clearing
+// the form discards the draft.
+function saveDraftSoon() {
+  const draft = currentDraft();
+  if (!draft) return;
+  clearTimeout(DRAFT_TIMER);
+  DRAFT_TIMER = setTimeout(async () => {
+    const status = $('draft-status');
+    try {
+      if (!draft.body.trim()) {
+        await discardDraft(draft.category, draft.thread || '');
+      } else {
+        await apiFetch('/draft', { method: 'POST', body: JSON.stringify(draft) });
+        if (status) status.textContent = 'Draft saved';
+      }
+    } catch (e) {
+      if (status) status.textContent = 'Could not save draft: ' + e.message;
+    }
+  }, 1000);
+}
+
+// restoreDraft fills the form on screen with a saved draft, if there is one.
+async function restoreDraft(catSlug, threadSlug) {
+  if (!STATUS.username) return;
+  const q = `category=${encodeURIComponent(catSlug)}&thread=${encodeURIComponent(threadSlug)}`;
+  const d = await apiFetch('/draft?' + q).catch(() => null);
+  if (!d) return;
+  if (threadSlug) {
+    $('reply-body').value = d.body;
+    const parent = d.parent && THREAD.posts[d.parent];
+    if (parent) setReplyTo(d.parent, parent.author);
+  } else {
+    const m = d.body.match(/^# (.*)\n\n?/);
+    $('nt-title').value = m ? m[1] : '';
+    $('nt-body').value  = m ? d.body.slice(m[0].length) : d.body;
+    $('nt-slug').value  = d.slug || '';
+  }
+  $('draft-status').innerHTML = `Draft from ${relTime(d.updated)} restored
+    <button class="btn btn-sm" type="button" onclick="discardDraft('${esc(catSlug)}','${esc(threadSlug)}')">Discard</button>`;
+}
+
+// discardDraft deletes a saved draft and clears the form it came from, or
+// reloads the drafts list when reload is set.
+async function discardDraft(category, thread, reload) {
+  clearTimeout(DRAFT_TIMER);
+  try {
+    await apiFetch('/draft/discard', { method: 'POST', body: JSON.stringify({ category, thread }) });
+  } catch (e) {
+    alert('Could not discard draft: ' + e.message);
+    return;
+  }
+  if (reload) return viewDrafts();
+  const status = $('draft-status');
+  if (!status || !status.querySelector('button')) return;
+  status.textContent = '';
+  if ($('reply-body')) {
+    $('reply-body').value = '';
+    setReplyTo(null);
+  } else if ($('new-thread-form')) {
+    $('new-thread-form').reset();
+  }
+}
+
+async function retryPush() {
+  try {
+    await apiFetch('/outbox/retry', { method: 'POST' });
+  } catch (e) {
+    alert('Push failed: ' + e.message);
+  }
+  await refreshStatus();
+  viewOutbox();
+}
+
+async function syncPeer(instance) {
+  try {
+    const res = await apiFetch('/lan/sync', { method: 'POST', body: JSON.stringify({ instance }) });
+    let msg = `Merged ${res.added} new file${res.added === 1 ? '' : 's'}.`;
+    if ((res.rejected || []).length) msg += `\nNot merged, changed by the peer: ${res.rejected.join(', ')}`;
+    alert(msg);
+  } catch (e) {
+    alert('Sync failed: ' + e.message);
+  }
+  await refreshStatus();
+  viewNearby();
+}
+
+// discardPending drops an unpushed commit ({ commit }) or reverts an
+// uncommitted file ({ path }).
+async function discardPending(req) {
+  const what = req.commit ? 'this unpushed commit' : `the changes to ${req.path}`;
+  if (!confirm(`Discard ${what}? This cannot be undone.`)) return;
+  try {
+    await apiFetch('/outbox/discard', { method: 'POST', body: JSON.stringify(req) });
+  } catch (e) {
+    alert('Could not discard: ' + e.message);
+    return;
+  }
+  await refreshStatus();
+  viewOutbox();
+}
+
+async function castVote(catSlug, threadSlug, option) {
+  try {
+    await apiFetch(`/threads/${catPath(catSlug)}/${threadSlug}/vote`, {
+      method: 'POST',
+      body:   JSON.stringify({ option }),
+    });
+    await viewThread(catSlug, threadSlug);
+  } catch (e) {
+    alert('Vote failed: ' + e.message);
+  }
+}
+
+function setReplyTo(filename, author) {
+  REPLY_TO = filename ? { filename, author } : null;
+  const el = $('reply-to');
+  if (!el) return;
+  el.hidden = !REPLY_TO;
+  el.innerHTML = REPLY_TO
+    ? `Replying to <strong>@${esc(author)}</strong> <button class="btn btn-sm" onclick="setReplyTo(null)">×</button>`
+    : '';
+  if (REPLY_TO) $('reply-body').focus();
+}
+
+// quotePost appends a verifiable quote of a post in the open thread to the
+// reply box. The quote can be trimmed; changing its words marks it altered.
+function quotePost(filename) {
+  const p  = THREAD.posts[filename];
+  const el = $('reply-body');
+  if (!p || !el) return;
+  const lines = p.body.split('\n').map(l => l ? '> ' + l : '>');
+  const sep   = el.value && !el.value.endsWith('\n\n') ? '\n\n' : '';
+  el.value += `${sep}> [!quote ${THREAD.catSlug}/${THREAD.threadSlug}/${filename}]\n${lines.join('\n')}\n\n`;
+  el.focus();
+  saveDraftSoon();
+}
+
+// linkPost inserts a cross-link to a post in the open thread into the reply
+// box.
+function linkPost(filename) {
+  const el = $('reply-body');
+  if (!el) return;
+  el.setRangeText(`[[${THREAD.catSlug}/${THREAD.threadSlug}/${filename}]]`, el.selectionStart, el.selectionEnd, 'end');
+  el.focus();
+  saveDraftSoon();
+}
+
+async function openNotification(id, href) {
+  await apiFetch('/notifications/seen', { method: 'POST', body: JSON.stringify({ ids: [id] }) }).catch(() => {});
+  location.hash = href;
+  refreshStatus();
+}
+
+async function markNotificationsSeen() {
+  try {
+    await apiFetch('/notifications/seen', { method: 'POST' });
+    await refreshStatus();
+    await viewNotifications();
+  } catch (e) {
+    alert('Error: ' + e.message);
+  }
+}
+
+// markThreadSeen marks the unread posts loaded so far in the open thread as
+// read.
+async function markThreadSeen() {
+  const { catSlug, threadSlug, unseen } = THREAD;
+  if (!STATUS.username || !unseen.length) return;
+  THREAD.unseen = [];
+  try {
+    await apiFetch(`/threads/${catPath(catSlug)}/${threadSlug}/read`, {
+      method: 'POST',
+      body:   JSON.stringify({ filenames: unseen }),
     });
-    location.hash = `#/cat/${catSlug}/thread/${slug}`;
+    await refreshStatus();
+  } catch (_) { /* read markers are best effort */ }
+}
+
+async function markAllRead() {
+  try {
+    await apiFetch('/new/read', { method: 'POST' });
+    await refreshStatus();
+    await viewWhatsNew();
   } catch (e) {
     alert('Error: ' + e.message);
   }
@@ -357,6 +1200,219 @@ async function adminDelete(catSlug, threadSlug, filename) {
   }
 }
 
+async function setMuted(username, muted) {
+  if (muted && !confirm(`Mute @${username}? Their posts will be collapsed for you only.`)) return;
+  try {
+    await apiFetch(muted ? '/mute' : '/unmute', { method: 'POST', body: JSON.stringify({ username }) });
+    await viewThread(THREAD.catSlug, THREAD.threadSlug);
+  } catch (e) {
+    alert('Error: ' + e.message);
+  }
+}
+
+async function showMutes() {
+  let data;
+  try {
+    data = await apiFetch('/mutes');
+  } catch (e) {
+    alert('Error: ' + e.message);
+    return;
+  }
+  let h = '<h2>Muted Users</h2>';
+  if (!data.users.length) {
+    h += '<p class="empty" style="margin:.75rem 0">You have not muted anyone.</p>';
+  }
+  data.users.forEach(u => {
+    h += `<div class="join-req-item">
+      <strong>@${esc(u)}</strong>
+      <button class="btn btn-sm" onclick="unmuteFromList('${esc(u)}')">Unmute</button>
+    </div>`;
+  });
+  h += '<div class="form-actions" style="margin-top:.75rem"><button class="btn" onclick="closeModal()">Close</button></div>';
+  openModal(h);
+}
+
+async function showTrust() {
+  let data;
+  try {
+    data = await apiFetch('/trust');
+  } catch (e) {
+    alert('Error: ' + e.message);
+    return;
+  }
+  let h = `<h2>Vouches</h2>
+    <p class="view-note">Vouching states that a key belongs to a user. Posts signed with keys you vouch for,
+    or that people you trust vouch for (up to ${data.hops} hops), are marked as vouched for.</p>`;
+  if (!data.vouches.length) {
+    h += '<p class="empty" style="margin:.75rem 0">You vouch for nobody.</p>';
+  }
+  data.vouches.forEach(v => {
+    h += `<div class="join-req-item">
+      <span><strong>@${esc(v.username)}</strong> <small><code title="${esc(v.pubkey)}">${esc(v.pubkey.slice(0, 8))}</code>${v.note ? ' · ' + esc(v.note) : ''}</small></span>
+      <button class="btn btn-sm" onclick="withdrawVouch('${esc(v.username)}')">Withdraw</button>
+    </div>`;
+  });
+  const others = data.trusted.filter(k => k.path.length > 1);
+  if (others.length) {
+    h += '<h3>Trusted through others</h3>';
+    others.forEach(k => {
+      h += `<div class="join-req-item">
+        <span><strong>@${esc(k.username)}</strong> <small><code title="${esc(k.pubkey)}">${esc(k.fingerprint)}</code>
+        via @${k.path.slice(0, -1).map(esc).join(' → @')}</small></span>
+        ${k.approved ? '<span class="badge badge-ok">approved</span>' : ''}
+      </div>`;
+    });
+  }
+  (data.errors || []).forEach(e => { h += `<p class="error-msg">${esc(e)}</p>`; });
+  h += `<h3>Vouch for a key</h3>
+    <label>Username <input type="text" id="vouch-username"></label>
+    <label>Public key (base64; leave empty to use the key in keys/ or the join request)
+      <input type="text" id="vouch-pubkey">
+    </label>
+    <label>Note <input type="text" id="vouch-note" placeholder="how you checked the key is theirs"></label>
+    <div class="form-actions">
+      <button class="btn btn-primary" onclick="submitVouch()">Vouch</button>
+      <button class="btn" onclick="closeModal()">Close</button>
+    </div>`;
+  openModal(h);
+}
+
+async function submitVouch() {
+  const username = $('vouch-username').value.trim();
+  if (!username) { alert('Username is required.'); return; }
+  try {
+    await apiFetch('/vouch', {
+      method: 'POST',
+      body:   JSON.stringify({ username, pubkey: $('vouch-pubkey').value.trim(), note: $('vouch-note').value }),
+    });
+    await showTrust();
+  } catch (e) {
+    alert('Error: ' + e.message);
+  }
+}
+
+// vouchFor vouches for the key a user has in keys/, from their profile.
+async function vouchFor(username) {
+  const note = prompt(`Vouch that the key shown is @${username}'s? Optionally say how you checked:`, '');
+  if (note === null) return;
+  try {
+    await apiFetch('/vouch', { method: 'POST', body: JSON.stringify({ username, note }) });
+    await viewUser(username);
+  } catch (e) {
+    alert('Error: ' + e.message);
+  }
+}
+
+async function withdrawVouch(username) {
+  if (!confirm(`Withdraw your vouch for @${username}?`)) return;
+  try {
+    await apiFetch('/unvouch', { method: 'POST', body: JSON.stringify({ username }) });
+    await showTrust();
+  } catch (e) {
+    alert('Error: ' + e.message);
+  }
+}
+
+async function showIdentities() {
+  let data;
+  try {
+    data = await apiFetch('/identities');
+  } catch (e) {
+    alert('Error: ' + e.message);
+    return;
+  }
+  let h = '<h2>Identities</h2>';
+  if (!data.identities.length) {
+    h += '<p class="empty" style="margin:.75rem 0">No identities. Create one with <code>gitorum id add</code>.</p>';
+  }
+  data.identities.forEach(id => {
+    h += `<div class="join-req-item">
+      <span><strong>@${esc(id.username)}</strong> <small>${esc(id.name)} · ${esc(id.fingerprint)}</small></span>
+      ${id.active ? '<span class="badge">in use</span>'
+        : `<button class="btn btn-sm" onclick="useIdentity('${esc(id.name)}')">Use here</button>`}
+    </div>`;
+  });
+  h += '<div class="form-actions" style="margin-top:.75rem"><button class="btn" onclick="closeModal()">Close</button></div>';
+  openModal(h);
+}
+
+// useIdentity switches the identity the open forum is used as.
+async function useIdentity(name) {
+  try {
+    await apiFetch('/identity', { method: 'POST', body: JSON.stringify({ name }) });
+  } catch (e) {
+    alert('Error: ' + e.message);
+    return;
+  }
+  closeModal();
+  await refreshStatus();
+  route();
+}
+
+async function unmuteFromList(username) {
+  try {
+    await apiFetch('/unmute', { method: 'POST', body: JSON.stringify({ username }) });
+    await showMutes();
+  } catch (e) {
+    alert('Error: ' + e.message);
+  }
+}
+
+async function setThreadState(flag, value) {
+  const { catSlug, threadSlug } = THREAD;
+  try {
+    await apiFetch('/admin/thread-state', {
+      method: 'POST',
+      body:   JSON.stringify({ category: catSlug, thread: threadSlug, [flag]: value }),
+    });
+    await viewThread(catSlug, threadSlug);
+  } catch (e) {
+    alert('Could not change thread state: ' + e.message);
+  }
+}
+
+// showRelocateThread asks where to move the open thread, or which thread to
+// merge it into. A move within the same category renames the thread.
+function showRelocateThread(merge) {
+  const { catSlug, threadSlug } = THREAD;
+  openModal(`
+    <h2>${merge ? 'Merge Thread' : 'Move Thread'}</h2>
+    <p class="view-note">${merge
+      ? 'All posts move into the target thread, ordered by time. This thread\'s state and poll are dropped.'
+      : 'Old links to this thread keep working.'}</p>
+    <label>Category
+      <select id="rt-category">
+        ${flatCategories(CATEGORIES).map(({ cat, depth }) =>
+          `<option value="${esc(cat.slug)}"${cat.slug === catSlug ? ' selected' : ''}>${'— '.repeat(depth)}${esc(cat.name)}</option>`).join('')}
+      </select>
+    </label>
+    <label>${merge ? 'Target thread slug' : 'Thread slug'}
+      <input type="text" id="rt-thread" value="${merge ? '' : esc(threadSlug)}">
+    </label>
+    <div class="form-actions">
+      <button class="btn btn-primary" onclick="submitRelocateThread(${merge})">${merge ? 'Merge' : 'Move'}</button>
+      <button class="btn" onclick="closeModal()">Cancel</button>
+    </div>`);
+}
+
+async function submitRelocateThread(merge) {
+  const { catSlug, threadSlug } = THREAD;
+  const toCategory = $('rt-category').value;
+  const toThread   = $('rt-thread').value.trim();
+  if (!toThread) { alert('A thread slug is required.'); return; }
+  try {
+    await apiFetch(merge ? '/admin/merge' : '/admin/move', {
+      method: 'POST',
+      body:   JSON.stringify({ category: catSlug, thread: threadSlug, to_category: toCategory, to_thread: toThread }),
+    });
+    closeModal();
+    await refreshStatus();
+    location.hash = `#/cat/${catPath(toCategory)}/thread/${toThread}`;
+  } catch (e) {
+    alert('Error: ' + e.message);
+  }
+}
+
 function showAdminAddKey() {
   openModal(`
     <h2>Add User Key</h2>
@@ -384,6 +1440,16 @@ function showAdminCreateCategory() {
     <label>Description <small style="font-weight:400">(optional)</small>
       <input type="text" id="nc-desc" placeholder="What this category is about…">
     </label>
+    <label>Parent
+      <select id="nc-parent">
+        <option value="">(top level)</option>
+        ${flatCategories(CATEGORIES).map(({ cat, depth }) =>
+          `<option value="${esc(cat.slug)}">${'— '.repeat(depth)}${esc(cat.name)}</option>`).join('')}
+      </select>
+    </label>
+    <label>Order <small style="font-weight:400">(lower comes first among siblings)</small>
+      <input type="number" id="nc-order" value="0">
+    </label>
     <div class="form-actions">
       <button class="btn btn-primary" onclick="submitCreateCategory()">Create</button>
       <button class="btn" onclick="closeModal()">Cancel</button>
@@ -394,21 +1460,153 @@ async function submitCreateCategory() {
   const slug        = $('nc-slug').value.trim();
   const name        = $('nc-name').value.trim();
   const description = $('nc-desc').value.trim();
+  const parent      = $('nc-parent').value;
+  const order       = parseInt($('nc-order').value, 10) || 0;
   if (!slug || !name) { alert('Slug and name are required.'); return; }
   try {
     await apiFetch('/categories', {
       method: 'POST',
-      body:   JSON.stringify({ slug, name, description }),
+      body:   JSON.stringify({ slug, name, description, parent, order }),
     });
     closeModal();
     await refreshStatus();
-    location.hash = `#/cat/${slug}`;
+    location.hash = `#/cat/${catPath(parent ? parent + '/' + slug : slug)}`;
     route();
   } catch (e) {
     alert('Error: ' + e.message);
   }
 }
 
+// policyNote describes a category write policy in words.
+function policyNote(policy) {
+  const who = list => list.length ? list.map(esc).join(', ') : 'anyone';
+  return `New threads: ${who(policy.threads)} · Replies: ${who(policy.replies)}`;
+}
+
+function showCategoryPolicy(catSlug) {
+  const p = CATEGORY_POLICY || { threads: [], replies: [] };
+  openModal(`
+    <h2>Permissions</h2>
+    <p class="view-note">Comma-separated usernames and <code>@role</code> names. Leave empty to let anyone post.</p>
+    <label>May start threads
+      <input type="text" id="cp-threads" value="${esc(p.threads.join(', '))}" placeholder="@staff">
+    </label>
+    <label>May reply
+      <input type="text" id="cp-replies" value="${esc(p.replies.join(', '))}" placeholder="anyone">
+    </label>
+    <div class="form-actions">
+      <button class="btn btn-primary" onclick="submitCategoryPolicy('${esc(catSlug)}')">Save</button>
+      <button class="btn" onclick="closeModal()">Cancel</button>
+    </div>`);
+}
+
+async function submitCategoryPolicy(catSlug) {
+  const split = v => v.split(',').map(x => x.trim()).filter(Boolean);
+  try {
+    await apiFetch('/admin/category-policy', {
+      method: 'POST',
+      body:   JSON.stringify({ category: catSlug, threads: split($('cp-threads').value), replies: split($('cp-replies').value) }),
+    });
+    closeModal();
+    await viewThreadList(catSlug);
+  } catch (e) {
+    alert('Error: ' + e.message);
+  }
+}
+
+async function showRoles() {
+  let data;
+  try {
+    data = await apiFetch('/admin/roles');
+  } catch (e) {
+    alert('Error: ' + e.message);
+    return;
+  }
+  const lines = Object.entries(data.roles || {}).map(([role, users]) => `${role}: ${users.join(', ')}`);
+  openModal(`
+    <h2>Roles</h2>
+    <p class="view-note">One role per line, e.g. <code>staff: alice, bob</code>. Use <code>@staff</code> in category permissions.</p>
+    <textarea id="roles-text" rows="6">${esc(lines.join('\n'))}</textarea>
+    <div class="form-actions">
+      <button class="btn btn-primary" onclick="submitRoles()">Save</button>
+      <button class="btn" onclick="closeModal()">Cancel</button>
+    </div>`);
+}
+
+async function submitRoles() {
+  const roles = {};
+  for (const line of $('roles-text').value.split('\n')) {
+    const i = line.indexOf(':');
+    if (i < 0) continue;
+    roles[line.slice(0, i).trim()] = line.slice(i + 1).split(',').map(x => x.trim()).filter(Boolean);
+  }
+  try {
+    await apiFetch('/admin/roles', { method: 'POST', body: JSON.stringify({ roles }) });
+    closeModal();
+  } catch (e) {
+    alert('Error: ' + e.message);
+  }
+}
+
+async function showBans() {
+  let data;
+  try {
+    data = await apiFetch('/bans');
+  } catch (e) {
+    alert('Error: ' + e.message);
+    return;
+  }
+  let h = '<h2>Bans</h2>';
+  if (!data.bans.length) {
+    h += '<p class="empty" style="margin:.75rem 0">Nobody is banned.</p>';
+  }
+  data.bans.forEach(b => {
+    h += `<div class="join-req-item">
+      <strong>@${esc(b.username)}</strong> since ${esc(b.since)}
+      <div class="view-note">${esc(b.reason || 'no reason given')}</div>
+      <button class="btn btn-sm" onclick="submitUnban('${esc(b.username)}')">Unban</button>
+    </div>`;
+  });
+  h += `<label>Username
+      <input type="text" id="ban-username" placeholder="username">
+    </label>
+    <label>Reason
+      <input type="text" id="ban-reason" placeholder="optional">
+    </label>
+    <label>Banned since (RFC3339, empty for now)
+      <input type="text" id="ban-since" placeholder="2026-03-01T12:00:00Z">
+    </label>
+    <div class="form-actions">
+      <button class="btn btn-danger" onclick="submitBan()">Ban</button>
+      <button class="btn" onclick="closeModal()">Close</button>
+    </div>`;
+  openModal(h);
+}
+
+async function submitBan() {
+  const username = $('ban-username').value.trim();
+  if (!username) { alert('Username is required.'); return; }
+  try {
+    await apiFetch('/admin/ban', {
+      method: 'POST',
+      body:   JSON.stringify({ username, reason: $('ban-reason').value, since: $('ban-since').value.trim() }),
+    });
+    await showBans();
+  } catch (e) {
+    alert('Error: ' + e.message);
+  }
+}
+
+async function submitUnban(username) {
+  if (!confirm(`Lift the ban on @${username}?`)) return;
+  try {
+    await apiFetch('/admin/unban', { method: 'POST', body: JSON.stringify({ username }) });
+    await showBans();
+  } catch (e) {
+    alert('Error: ' + e.message);
+  }
+}
+
 async function showJoinRequests() {
   let data;
   try {
@@ -428,6 +1626,7 @@ async function showJoinRequests() {
       h += `<div class="join-req-item">
         <strong>@${esc(req.username)}</strong>
         <div class="join-req-key">${esc(req.pubkey)}</div>
+        ${req.vouched_by.length ? `<small>Vouched for by @${req.vouched_by.map(esc).join(', @')}</small>` : ''}
         <div class="form-actions" style="margin-top:.4rem">
           <button class="btn btn-primary btn-sm" onclick="approveJoinRequest('${esc(req.username)}')">Approve</button>
           <button class="btn btn-danger btn-sm"  onclick="rejectJoinRequest('${esc(req.username)}')">Reject</button>
@@ -494,6 +1693,7 @@ function closeModal() {
 
 // ── Utilities ────────────────────────────────────────────────────────────────
 function $(id) { return document.getElementById(id); }
+function catPath(slug) { return encodeURIComponent(slug); }
 function render(html) { $('view').innerHTML = html; }
 
 function esc(s) {
@@ -505,14 +1705,123 @@ function esc(s) {
 }
 
 function sigBadge(post) {
-  switch (post.sig_status) {
-    case 'valid':   return `<span class="badge badge-ok"   title="Signature verified">✓ signed</span>`;
+  switch (post.trust || post.sig_status) {
+    case 'approved':
+    case 'valid':   return `<span class="badge badge-ok"   title="Signature verified with a key approved by the admin">✓ signed</span>`;
+    case 'vouched': return `<span class="badge badge-vouched" title="${esc(vouchPathText(post.trust_path))}">✓ vouched</span>`;
     case 'invalid': return `<span class="badge badge-err"  title="${esc(post.sig_error)}">✗ invalid sig</span>`;
-    case 'missing': return `<span class="badge badge-warn" title="${esc(post.sig_error)}">? no key</span>`;
+    case 'unknown':
+    case 'missing': return `<span class="badge badge-warn" title="${esc(post.sig_error)}; nobody you trust vouches for it">? unknown key</span>`;
     default:        return '';
   }
 }
 
+// vouchPathText describes how a key came into the web of trust.
+function vouchPathText(path) {
+  if (!path || !path.length) return 'Signed with your own key';
+  if (path.length === 1) return `Signed with a key you vouch for`;
+  return `Signed with a key vouched for via @${path.slice(0, -1).join(' → @')}`;
+}
+
+// provenanceBtn opens a popover with what the git history says about a
+// post: when it was committed, by whom, and whether it changed since.
+function provenanceBtn(catSlug, threadSlug, p) {
+  return `<span class="provenance"><button class="provenance-btn" title="Where this post came from"
+    onclick="toggleProvenance(this, '${esc(catSlug)}', '${esc(threadSlug)}', '${esc(p.filename)}')">&#9432;</button></span>`;
+}
+
+async function toggleProvenance(btn, catSlug, threadSlug, filename) {
+  const open = btn.parentElement.querySelector('.provenance-pop');
+  if (open) return open.remove();
+  const pop = document.createElement('div');
+  pop.className   = 'provenance-pop';
+  pop.textContent = 'Loading…';
+  btn.parentElement.appendChild(pop);
+  try {
+    const d = await apiFetch(`/threads/${catPath(catSlug)}/${threadSlug}/posts/${encodeURIComponent(filename)}/provenance`);
+    pop.innerHTML = provenanceHTML(d);
+  } catch (e) {
+    pop.innerHTML = `<span class="outbox-error">${esc(e.message)}</span>`;
+  }
+}
+
+function provenanceHTML(d) {
+  const commit = c => `<code>${esc(c.hash.slice(0, 8))}</code> by @${esc(c.committer)}
+    <span title="${esc(c.when)}">${relTime(c.when)}</span> · ${commitSigBadge(c)}`;
+  let h = `<dl>
+    <dt>Claims</dt><dd><span title="${esc(d.claimed_at)}">${relTime(d.claimed_at)}</span></dd>
+    <dt>Committed</dt><dd>${commit(d.introduced)}</dd>`;
+  if (d.later.some(c => c.change === 'move')) h += `<dt>As</dt><dd><code>${esc(d.path)}</code></dd>`;
+  h += '</dl>';
+  if (d.later.length) {
+    h += `<p class="${d.modified ? 'outbox-error' : ''}">${d.modified ? 'Changed after it was committed:' : 'Moved since:'}</p><ul>`;
+    d.later.forEach(c => {
+      const what = c.change === 'move' ? `moved from <code>${esc(c.from)}</code>` : 'content changed';
+      h += `<li>${what} in ${commit(c)}</li>`;
+    });
+    h += '</ul>';
+  } else {
+    h += '<p class="provenance-ok">Unchanged since it was committed.</p>';
+  }
+  return h;
+}
+
+function commitSigBadge(c) {
+  switch (c.signature) {
+    case 'valid':    return '<span class="badge badge-ok">signed</span>';
+    case 'unknown':  return `<span class="badge badge-warn" title="Not signed with the committer's key in keys/">signed${c.signer ? ' by @' + esc(c.signer) : ''}</span>`;
+    case 'invalid':  return '<span class="badge badge-err">invalid signature</span>';
+    default:         return '<span class="badge badge-warn">unsigned</span>';
+  }
+}
+
+// stateBadges marks pinned, locked and archived threads; s is a thread
+// summary or a thread's state.
+function stateBadges(s) {
+  let h = '';
+  if (s.pinned)   h += ' <span class="badge badge-pin">pinned</span>';
+  if (s.archived) h += ' <span class="badge badge-state">archived</span>';
+  else if (s.locked) h += ' <span class="badge badge-state">locked</span>';
+  return h;
+}
+
+function unreadBadge(n) {
+  return n ? ` <span class="badge badge-unread" title="${n} unread">${n} new</span>` : '';
+}
+
+function pollBox(poll, catSlug, threadSlug) {
+  const total   = poll.options.reduce((n, o) => n + o.votes, 0);
+  const canVote = STATUS.username && !poll.closed;
+  let h = '<section class="poll">';
+  poll.options.forEach(o => {
+    const pct  = total ? Math.round(100 * o.votes / total) : 0;
+    const mine = poll.my_vote === o.text;
+    h += `<div class="poll-option${mine ? ' poll-mine' : ''}">
+      <div class="poll-bar" style="width:${pct}%"></div>
+      <span class="poll-text">${esc(o.text)}</span>
+      <span class="poll-count">${o.votes} (${pct}%)</span>
+      ${canVote && !mine
+        ? `<button class="btn btn-sm" onclick="castVote('${esc(catSlug)}','${esc(threadSlug)}',decodeURIComponent('${encodeURIComponent(o.text)}'))">Vote</button>`
+        : ''}
+    </div>`;
+  });
+  const status = poll.closed
+    ? `closed ${relTime(poll.closes_at)}`
+    : poll.closes_at ? `closes ${new Date(poll.closes_at).toLocaleString()}` : 'open';
+  h += `<details class="poll-voters">
+    <summary>${poll.voters.length} voter${poll.voters.length === 1 ? '' : 's'} · ${status}</summary>
+    <ul>`;
+  poll.voters.forEach(v => {
+    h += `<li><strong>@${esc(v.voter)}</strong> → ${esc(v.option)}
+      <code title="${esc(v.signature)}">${esc(v.pubkey)} · ${esc(v.filename)}</code></li>`;
+  });
+  poll.rejected.forEach(v => {
+    h += `<li class="poll-rejected">${esc(v.voter ? '@' + v.voter : v.filename)}: ${esc(v.reason)}</li>`;
+  });
+  h += '</ul></details></section>';
+  return h;
+}
+
 function relTime(iso) {
   if (!iso) return '';
   const ms = Date.now() - new Date(iso).getTime();
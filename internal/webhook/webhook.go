@@ -0,0 +1,242 @@
+// Package webhook delivers JSON notifications about new posts to external
+// HTTP endpoints, such as a team chat or an automation service.
+//
+// Every payload is signed with HMAC-SHA256 using the hook's secret and sent
+// in the X-Gitorum-Signature header as "sha256=<hex>", so receivers can
+// check that it came from this server. Failed deliveries are retried with
+// increasing delays, and the outcome of every delivery is kept in an
+// in-memory log.
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// EventNewPost is the only event type sent today.
+const EventNewPost = "post.new"
+
+// Sources of a new post.
+const (
+	SourcePull  = "pull"  // the post arrived with a sync from the remote
+	SourceLocal = "local" // the post was committed by this server
+)
+
+// maxLog is the number of deliveries kept in the log.
+const maxLog = 200
+
+// DefaultBackoff is the delay before each retry of a failed delivery.
+var DefaultBackoff = []time.Duration{2 * time.Second, 10 * time.Second, time.Minute, 5 * time.Minute}
+
+// Hook is one configured receiver.
+type Hook struct {
+	URL    string `toml:"url"`
+	Secret string `toml:"secret"` // HMAC key; payloads are unsigned when empty
+}
+
+// LoadHooks reads the hooks configured in the TOML file at path, written as
+// one [[webhook]] table per receiver. A missing file means no hooks.
+func LoadHooks(path string) ([]Hook, error) {
+	var cfg struct {
+		Webhooks []Hook `toml:"webhook"`
+	}
+	if _, err := toml.DecodeFile(path, &cfg); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read %s: %w", filepath.Base(path), err)
+	}
+	for _, h := range cfg.Webhooks {
+		if !strings.HasPrefix(h.URL, "http://") && !strings.HasPrefix(h.URL, "https://") {
+			return nil, fmt.Errorf("webhook url must start with http:// or https://, got %q", h.URL)
+		}
+	}
+	return cfg.Webhooks, nil
+}
+
+// Event is the JSON payload describing a new post.
+type Event struct {
+	Event     string `json:"event"`
+	Source    string `json:"source"`
+	Forum     string `json:"forum"`
+	Category  string `json:"category"`
+	Thread    string `json:"thread"`
+	Filename  string `json:"filename"`
+	Author    string `json:"author"`
+	SigStatus string `json:"sig_status"`
+	Timestamp string `json:"timestamp"`
+	Excerpt   string `json:"excerpt"`
+}
+
+// Delivery records the fate of one event sent to one hook.
+type Delivery struct {
+	ID         string    `json:"id"`
+	URL        string    `json:"url"`
+	Event      Event     `json:"event"`
+	Attempts   int       `json:"attempts"`
+	StatusCode int       `json:"status_code,omitempty"` // of the last attempt
+	Error      string    `json:"error,omitempty"`       // of the last attempt
+	Delivered  bool      `json:"delivered"`
+	Pending    bool      `json:"pending"` // more attempts are scheduled
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Dispatcher sends events asynchronously and keeps the delivery log.
+type Dispatcher struct {
+	Client  *http.Client
+	Backoff []time.Duration // delays before each retry; len(Backoff)+1 attempts in total
+
+	mu  sync.Mutex
+	log []*Delivery // oldest first
+	wg  sync.WaitGroup
+}
+
+// NewDispatcher returns a Dispatcher with a 10-second request timeout and
+// DefaultBackoff.
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher{
+		Client:  &http.Client{Timeout: 10 * time.Second},
+		Backoff: DefaultBackoff,
+	}
+}
+
+// Send queues every event for every hook and returns immediately.
+func (d *Dispatcher) Send(hooks []Hook, events []Event) {
+	for _, h := range hooks {
+		for _, ev := range events {
+			del := d.record(h.URL, ev)
+			d.wg.Add(1)
+			go func() {
+				defer d.wg.Done()
+				d.deliver(h, del)
+			}()
+		}
+	}
+}
+
+// Wait blocks until every queued delivery has succeeded or given up.
+func (d *Dispatcher) Wait() { d.wg.Wait() }
+
+// Log returns a snapshot of the delivery log, newest first.
+func (d *Dispatcher) Log() []Delivery {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	out := make([]Delivery, 0, len(d.log))
+	for i := len(d.log) - 1; i >= 0; i-- {
+		out = append(out, *d.log[i])
+	}
+	return out
+}
+
+func (d *Dispatcher) record(url string, ev Event) *Delivery {
+	now := time.Now()
+	del := &Delivery{ID: newID(), URL: url, Event: ev, Pending: true, CreatedAt: now, UpdatedAt: now}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.log = append(d.log, del)
+	if len(d.log) > maxLog {
+		d.log = d.log[len(d.log)-maxLog:]
+	}
+	return del
+}
+
+// deliver posts the event, retrying after each Backoff delay until the
+// receiver answers with a 2xx status.
+func (d *Dispatcher) deliver(h Hook, del *Delivery) {
+	body, err := json.Marshal(del.Event)
+	if err != nil {
+		d.update(del, 0, err, false)
+		return
+	}
+	for attempt := 0; ; attempt++ {
+		status, err := d.post(h, del.ID, body)
+		more := err != nil && attempt < len(d.Backoff)
+		d.update(del, status, err, more)
+		if !more {
+			return
+		}
+		time.Sleep(d.Backoff[attempt])
+	}
+}
+
+func (d *Dispatcher) post(h Hook, id string, body []byte) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, h.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "gitorum-webhook")
+	req.Header.Set("X-Gitorum-Event", EventNewPost)
+	req.Header.Set("X-Gitorum-Delivery", id)
+	if h.Secret != "" {
+		req.Header.Set("X-Gitorum-Signature", Sign(h.Secret, body))
+	}
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver answered %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+func (d *Dispatcher) update(del *Delivery, status int, err error, pending bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	del.Attempts++
+	del.StatusCode = status
+	del.Error = ""
+	if err != nil {
+		del.Error = err.Error()
+	}
+	del.Delivered = err == nil
+	del.Pending = pending
+	del.UpdatedAt = time.Now()
+}
+
+// Sign returns the X-Gitorum-Signature header value for body.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is a valid X-Gitorum-Signature for body.
+func Verify(secret string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
+
+// Excerpt returns the first n characters of a Markdown body, with all
+// whitespace collapsed to single spaces.
+func Excerpt(body string, n int) string {
+	s := strings.Join(strings.Fields(body), " ")
+	if r := []rune(s); len(r) > n {
+		return string(r[:n]) + "…"
+	}
+	return s
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,169 @@
+package webhook_test
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gosub/gitorum/internal/webhook"
+)
+
+// receiver records the requests it gets and fails the first failures of them.
+type receiver struct {
+	mu       sync.Mutex
+	failures int
+	bodies   [][]byte
+	headers  []http.Header
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.bodies = append(rc.bodies, body)
+	rc.headers = append(rc.headers, r.Header.Clone())
+	if rc.failures > 0 {
+		rc.failures--
+		http.Error(w, "try again", http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func newDispatcher(retries int) *webhook.Dispatcher {
+	d := webhook.NewDispatcher()
+	d.Backoff = make([]time.Duration, retries)
+	for i := range d.Backoff {
+		d.Backoff[i] = time.Millisecond
+	}
+	return d
+}
+
+var testEvent = webhook.Event{
+	Event:     webhook.EventNewPost,
+	Source:    webhook.SourceLocal,
+	Category:  "general",
+	Thread:    "hello",
+	Filename:  "1708123456789_a3f9c1b2.md",
+	Author:    "alice",
+	SigStatus: "valid",
+	Excerpt:   "hi",
+}
+
+func TestSend_SignedPayload(t *testing.T) {
+	rc := &receiver{}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+
+	d := newDispatcher(0)
+	d.Send([]webhook.Hook{{URL: srv.URL, Secret: "s3cret"}}, []webhook.Event{testEvent})
+	d.Wait()
+
+	if len(rc.bodies) != 1 {
+		t.Fatalf("requests: got %d, want 1", len(rc.bodies))
+	}
+	var got webhook.Event
+	if err := json.Unmarshal(rc.bodies[0], &got); err != nil {
+		t.Fatal(err)
+	}
+	if got != testEvent {
+		t.Errorf("payload: got %+v", got)
+	}
+	sig := rc.headers[0].Get("X-Gitorum-Signature")
+	if !webhook.Verify("s3cret", rc.bodies[0], sig) {
+		t.Errorf("signature %q does not verify", sig)
+	}
+	if webhook.Verify("wrong", rc.bodies[0], sig) {
+		t.Error("signature verifies with the wrong secret")
+	}
+	if rc.headers[0].Get("X-Gitorum-Event") != webhook.EventNewPost {
+		t.Errorf("X-Gitorum-Event: got %q", rc.headers[0].Get("X-Gitorum-Event"))
+	}
+
+	log := d.Log()
+	if len(log) != 1 || !log[0].Delivered || log[0].Pending || log[0].Attempts != 1 || log[0].StatusCode != http.StatusNoContent {
+		t.Errorf("log: %+v", log)
+	}
+	if log[0].ID != rc.headers[0].Get("X-Gitorum-Delivery") {
+		t.Errorf("delivery ID mismatch: %q vs %q", log[0].ID, rc.headers[0].Get("X-Gitorum-Delivery"))
+	}
+}
+
+func TestSend_RetriesUntilDelivered(t *testing.T) {
+	rc := &receiver{failures: 2}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+
+	d := newDispatcher(3)
+	d.Send([]webhook.Hook{{URL: srv.URL}}, []webhook.Event{testEvent})
+	d.Wait()
+
+	log := d.Log()
+	if len(log) != 1 || !log[0].Delivered || log[0].Attempts != 3 || log[0].Error != "" {
+		t.Errorf("log: %+v", log)
+	}
+	if sig := rc.headers[0].Get("X-Gitorum-Signature"); sig != "" {
+		t.Errorf("unsigned hook sent signature %q", sig)
+	}
+}
+
+func TestSend_GivesUp(t *testing.T) {
+	rc := &receiver{failures: 10}
+	srv := httptest.NewServer(rc)
+	defer srv.Close()
+
+	d := newDispatcher(2)
+	d.Send([]webhook.Hook{{URL: srv.URL}}, []webhook.Event{testEvent})
+	d.Wait()
+
+	log := d.Log()
+	if len(log) != 1 || log[0].Delivered || log[0].Pending || log[0].Attempts != 3 {
+		t.Errorf("log: %+v", log)
+	}
+	if log[0].StatusCode != http.StatusServiceUnavailable || log[0].Error == "" {
+		t.Errorf("last attempt: status %d, error %q", log[0].StatusCode, log[0].Error)
+	}
+}
+
+func TestLoadHooks(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "webhooks.toml")
+
+	if hooks, err := webhook.LoadHooks(path); err != nil || hooks != nil {
+		t.Errorf("missing file: got %v, %v", hooks, err)
+	}
+
+	cfg := "[[webhook]]\nurl = \"https://chat.example/hook\"\nsecret = \"abc\"\n\n[[webhook]]\nurl = \"http://localhost:9000\"\n"
+	if err := os.WriteFile(path, []byte(cfg), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	hooks, err := webhook.LoadHooks(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hooks) != 2 || hooks[0].Secret != "abc" || hooks[1].URL != "http://localhost:9000" {
+		t.Errorf("hooks: %+v", hooks)
+	}
+
+	if err := os.WriteFile(path, []byte("[[webhook]]\nurl = \"file:///etc/passwd\"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := webhook.LoadHooks(path); err == nil {
+		t.Error("expected error for non-HTTP URL")
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	if got := webhook.Excerpt("# Title\n\nsome   text", 100); got != "# Title some text" {
+		t.Errorf("got %q", got)
+	}
+	if got := webhook.Excerpt("héllo wörld", 5); got != "héllo…" {
+		t.Errorf("got %q", got)
+	}
+}